@@ -0,0 +1,155 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// name of the checkpoint of ArchiveUsersOlderThan
+const archiveUsersJob = "archive_users"
+
+// default number of users moved by a transaction
+const defaultArchiveBatchSize = 1000
+
+type userArchiver struct {
+	db        *sql.DB
+	batchSize int
+	clock     Clock
+	// afterBatch is called after each batch is committed, e.g. to stop the job midway in tests
+	afterBatch func()
+}
+
+// NewUserArchiver returns an archiver which moves batchSize users by a transaction (1000 if non-positive).
+func NewUserArchiver(db *sql.DB, batchSize int) *userArchiver {
+	if batchSize <= 0 {
+		batchSize = defaultArchiveBatchSize
+	}
+	return &userArchiver{
+		db:        db,
+		batchSize: batchSize,
+		clock:     systemClock{},
+	}
+}
+
+// ArchiveUsersOlderThan moves users registered before cutoff (by their created_at) to user_archive in batches,
+// including soft-deleted ones. It returns the number of users moved by this call.
+// The checkpoint is saved with each batch, so the job resumes from it if it was stopped midway (e.g. ctx is canceled).
+// Users who have orders are not archived.
+// NOTE: credentials of archived users are deleted by the foreign key
+func (a *userArchiver) ArchiveUsersOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	checkpoint, err := models.FindArchiveCheckpoint(ctx, a.db, archiveUsersJob)
+	if errors.Is(err, sql.ErrNoRows) {
+		checkpoint = &models.ArchiveCheckpoint{Job: archiveUsersJob}
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var archived int64
+	for {
+		n, err := a.archiveBatch(ctx, checkpoint, cutoff)
+		archived += int64(n)
+		if err != nil {
+			return archived, err
+		}
+		if n < a.batchSize {
+			break
+		}
+		if a.afterBatch != nil {
+			a.afterBatch()
+		}
+	}
+
+	// NOTE: the next job starts from the beginning, so that it also finds users whose ids are less than the checkpoint
+	if _, err := models.ArchiveCheckpoints(models.ArchiveCheckpointWhere.Job.EQ(archiveUsersJob)).DeleteAll(ctx, a.db); err != nil {
+		return archived, fmt.Errorf("failed to clear checkpoint: %w", err)
+	}
+
+	return archived, nil
+}
+
+// archiveBatch moves users after the checkpoint and saves the checkpoint in a transaction.
+func (a *userArchiver) archiveBatch(ctx context.Context, checkpoint *models.ArchiveCheckpoint, cutoff time.Time) (int, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	users, err := models.Users(
+		qm.WithDeleted(),
+		models.UserWhere.ID.GT(checkpoint.LastID),
+		models.UserWhere.CreatedAt.LT(cutoff.UTC()),
+		// NOTE: users who have orders are kept, as their orders must reference them
+		qm.Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM `%s` WHERE %s = %s)", models.TableNames.Order,
+			quotedColumn(models.TableNames.Order, models.OrderColumns.UserID), quotedColumn(models.TableNames.User, models.UserColumns.ID))),
+		qm.OrderBy(quotedColumn(models.TableNames.User, models.UserColumns.ID)+" ASC"),
+		qm.Limit(a.batchSize),
+		// NOTE: lock the rows so that updates between copying and deleting them are not lost
+		qm.For("UPDATE"),
+	).All(ctx, tx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get users to archive: %w", err)
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	if err := insertArchives(ctx, tx, users, a.clock.Now()); err != nil {
+		return 0, err
+	}
+	ids := lo.Map(users, func(u *models.User, _ int) string { return u.ID })
+	if _, err := models.Users(qm.WithDeleted(), models.UserWhere.ID.IN(ids)).DeleteAll(ctx, tx, true); err != nil {
+		return 0, fmt.Errorf("failed to delete archived users: %w", err)
+	}
+
+	next := &models.ArchiveCheckpoint{
+		Job:      archiveUsersJob,
+		LastID:   users[len(users)-1].ID,
+		Archived: checkpoint.Archived + int64(len(users)),
+	}
+	if err := next.Upsert(ctx, tx, boil.Infer(), boil.Infer()); err != nil {
+		return 0, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	*checkpoint = *next
+
+	return len(users), nil
+}
+
+// insertArchives copies users into user_archive.
+// NOTE: archives are overwritten so that a batch can be retried even if its copies were left,
+// e.g. by go-mysql-server, which does not roll back transactions
+func insertArchives(ctx context.Context, exec boil.ContextExecutor, users models.UserSlice, archivedAt time.Time) error {
+	columns := userArchiveColumnNames
+
+	rows := make([]string, 0, len(users))
+	args := make([]interface{}, 0, len(users)*len(columns))
+	for _, u := range users {
+		rows = append(rows, "("+strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",")+")")
+		args = append(args, u.ID, u.Name, u.Age, u.DeletedAt, u.Email, archivedAt, u.Version, u.CreatedAt, u.UpdatedAt)
+	}
+	updates := lo.Map(columns[1:], func(c string, _ int) string {
+		return fmt.Sprintf("`%s` = VALUES(`%s`)", c, c)
+	})
+
+	query := fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES %s ON DUPLICATE KEY UPDATE %s",
+		models.TableNames.UserArchive, strings.Join(columns, "`,`"), strings.Join(rows, ","), strings.Join(updates, ","))
+	if _, err := exec.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert archives: %w", wrapStorageError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/oklog/ulid/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+var archiveCutoff = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// archiveFixture returns users registered before and after archiveCutoff in the order of ids.
+// The first old user is soft-deleted by seedArchiveFixture.
+func archiveFixture(t *testing.T) (old []*User, recent []*User) {
+	r := testRand(t)
+	newUser := func(at time.Time, name string) *User {
+		return &User{ID: ulid.MustNew(ulid.Timestamp(at), r).String(), Name: name, Age: lo.ToPtr(20), CreatedAt: at, UpdatedAt: at}
+	}
+
+	for i, name := range []string{"Mike", "Bob", "Mary", "Alice", "John"} {
+		old = append(old, newUser(archiveCutoff.Add(time.Duration(i-5)*time.Hour), name))
+	}
+	old[1].Email = "bob@example.com"
+	old[2].UpdatedAt = archiveCutoff.Add(time.Hour)
+	// NOTE: the id of Emma is older than the cutoff (e.g. given by the caller), but Emma was registered after it
+	emma := newUser(archiveCutoff.Add(-10*time.Hour), "Emma")
+	emma.CreatedAt, emma.UpdatedAt = archiveCutoff, archiveCutoff
+	recent = []*User{
+		emma,
+		newUser(archiveCutoff.Add(time.Hour), "James"),
+	}
+	return old, recent
+}
+
+func seedArchiveFixture(ctx context.Context, t *testing.T, db *sql.DB, old, recent []*User) {
+	r := NewUserRepository(db)
+	for _, u := range append(append([]*User{}, old...), recent...) {
+		require.NoError(t, r.Register(ctx, u))
+	}
+	require.NoError(t, r.Delete(ctx, old[0]))
+	// NOTE: the version is set directly, as Update would bump updated_at to the current time
+	_, err := models.Users(models.UserWhere.ID.EQ(old[2].ID)).UpdateAll(ctx, db, models.M{models.UserColumns.Version: 2})
+	require.NoError(t, err)
+}
+
+// assertArchived checks each old user is moved to the archive exactly once and recent users are kept.
+func assertArchived(ctx context.Context, t *testing.T, db *sql.DB, old, recent []*User) {
+	t.Helper()
+
+	users, err := models.Users(qm.WithDeleted(), qm.OrderBy("id")).All(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, recent, lo.Map(users, func(u *models.User, _ int) *User { return fromUserModel(u) }))
+
+	archives, err := models.UserArchives(qm.WithDeleted(), qm.OrderBy("id")).All(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, old, lo.Map(archives, func(a *models.UserArchive, _ int) *User {
+		return &User{ID: a.ID, Name: a.Name, Age: a.Age.Ptr(), Email: a.Email.String, CreatedAt: a.CreatedAt, UpdatedAt: a.UpdatedAt}
+	}))
+	require.Equal(t, []int64{1, 1, 2, 1, 1}, lo.Map(archives, func(a *models.UserArchive, _ int) int64 { return a.Version }))
+	require.True(t, archives[0].DeletedAt.Valid, "soft-deleted user must be archived as deleted")
+	require.False(t, archives[1].DeletedAt.Valid)
+
+	// the checkpoint is cleared after the job finishes
+	n, err := models.ArchiveCheckpoints().Count(ctx, db)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+// prepareMigratedSimulator starts go-mysql-server with the tables created by migrations.
+func prepareMigratedSimulator(ctx context.Context, t *testing.T) int {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	prepareEmptySimulator(t, port)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, Migrate(ctx, db))
+	return port
+}
+
+// test using go-mysql-server
+func TestArchiveUsersOlderThanWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	old, recent := archiveFixture(t)
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	seedArchiveFixture(ctx, t, db, old, recent)
+
+	// run
+	n, err := NewUserArchiver(db, 2).ArchiveUsersOlderThan(ctx, archiveCutoff)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, int64(len(old)), n)
+	assertArchived(ctx, t, db, old, recent)
+
+	// nothing is left to archive
+	n, err = NewUserArchiver(db, 2).ArchiveUsersOlderThan(ctx, archiveCutoff)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+// test using go-mysql-server
+func TestArchiveUsersKeepsUsersWhoHaveOrdersWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	old, recent := archiveFixture(t)
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	seedArchiveFixture(ctx, t, db, old, recent)
+	require.NoError(t, NewOrderRepository(db).Place(ctx, &Order{ID: "01GNNA1J000000000000000001", UserID: old[2].ID, Item: "pen", Amount: 100, CreatedAt: archiveCutoff}))
+
+	// run
+	n, err := NewUserArchiver(db, 2).ArchiveUsersOlderThan(ctx, archiveCutoff)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, int64(len(old)-1), n)
+	_, err = NewUserRepository(db).Get(ctx, old[2].ID)
+	require.NoError(t, err)
+	exists, err := models.UserArchiveExists(ctx, db, old[2].ID)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+// assertArchiveResumesAfterCancel stops the job after the first batch by canceling ctx and runs it again.
+func assertArchiveResumesAfterCancel(ctx context.Context, t *testing.T, db *sql.DB, old, recent []*User) {
+	// run
+	cancelCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	archiver := NewUserArchiver(db, 2)
+	archiver.afterBatch = cancel
+	n, err := archiver.ArchiveUsersOlderThan(cancelCtx, archiveCutoff)
+
+	// assert
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int64(2), n)
+	checkpoint, err := models.FindArchiveCheckpoint(ctx, db, archiveUsersJob)
+	require.NoError(t, err)
+	require.Equal(t, old[1].ID, checkpoint.LastID)
+	require.Equal(t, int64(2), checkpoint.Archived)
+
+	// resume
+	n, err = NewUserArchiver(db, 2).ArchiveUsersOlderThan(ctx, archiveCutoff)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(old)-2), n)
+	assertArchived(ctx, t, db, old, recent)
+}
+
+func TestArchiveUsersResumesAfterCancelWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	old, recent := archiveFixture(t)
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	seedArchiveFixture(ctx, t, db, old, recent)
+
+	assertArchiveResumesAfterCancel(ctx, t, db, old, recent)
+}
+
+func TestArchiveUsersResumesAfterFailureWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	old, recent := archiveFixture(t)
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	injector := &faultInjector{}
+	// NOTE: foreign key checks are disabled as newMigrationClient does
+	cfg, err := mysql.ParseDSN(dsn(port, defaultDatabase))
+	require.NoError(t, err)
+	cfg.Params = map[string]string{"foreign_key_checks": "0"}
+	connector, err := mysql.NewConnector(cfg)
+	require.NoError(t, err)
+	db := sql.OpenDB(&faultConnector{Connector: connector, injector: injector})
+	defer db.Close()
+	seedArchiveFixture(ctx, t, db, old, recent)
+
+	// run
+	// NOTE: the job is killed between copying and deleting users of the second batch.
+	// go-mysql-server does not roll back the transaction, so the copies are left in the archive.
+	archiver := NewUserArchiver(db, 2)
+	archiver.afterBatch = func() { injector.failNextMatching(1, "DELETE FROM `user`", errors.New("killed")) }
+	n, err := archiver.ArchiveUsersOlderThan(ctx, archiveCutoff)
+
+	// assert
+	require.EqualError(t, err, "failed to delete archived users: models: unable to delete all from user: killed")
+	require.Equal(t, int64(2), n)
+
+	// resume
+	n, err = NewUserArchiver(db, 2).ArchiveUsersOlderThan(ctx, archiveCutoff)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(old)-2), n)
+	assertArchived(ctx, t, db, old, recent)
+}
+
+// test using testcontainers
+func TestArchiveUsersWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	t.Run("archive users", func(t *testing.T) {
+		require.NoError(t, truncateTables(ctx, db, "practice"))
+		old, recent := archiveFixture(t)
+		seedArchiveFixture(ctx, t, db, old, recent)
+
+		// run
+		n, err := NewUserArchiver(db, 2).ArchiveUsersOlderThan(ctx, archiveCutoff)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, int64(len(old)), n)
+		assertArchived(ctx, t, db, old, recent)
+	})
+
+	t.Run("resume after cancel", func(t *testing.T) {
+		require.NoError(t, truncateTables(ctx, db, "practice"))
+		old, recent := archiveFixture(t)
+		seedArchiveFixture(ctx, t, db, old, recent)
+
+		assertArchiveResumesAfterCancel(ctx, t, db, old, recent)
+	})
+}
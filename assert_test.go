@@ -0,0 +1,82 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records failures instead of stopping the test.
+type recordingT struct {
+	errors []string
+	failed bool
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) FailNow() {
+	r.failed = true
+	// NOTE: stop like testing.T does
+	panic(r)
+}
+
+func (r *recordingT) run(f func()) {
+	defer func() {
+		if p := recover(); p != nil && p != r {
+			panic(p)
+		}
+	}()
+	f()
+}
+
+// test using go-mysql-server
+func TestAssertPersistedWithGoMySQLServer(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), CreatedAt: seededAt, UpdatedAt: seededAt}
+
+	tests := []struct {
+		title          string
+		stored         []*User
+		expectedFailed bool
+	}{
+		{
+			"user is persisted",
+			[]*User{mike},
+			false,
+		},
+		{
+			"user is not persisted",
+			nil,
+			true,
+		},
+		{
+			"user is persisted with different values",
+			[]*User{{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(21)}},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			table := prepareSimulator(t, 23306)
+			for _, u := range tt.stored {
+				_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow(u.ID, u.Name, int32(*u.Age), nil, nil, int64(1), seededAt, seededAt))
+			}
+			db, err := NewStrictClient(23306)
+			require.NoError(t, err)
+
+			// run
+			rt := &recordingT{}
+			rt.run(func() { AssertPersisted(rt, context.TODO(), db, mike) })
+
+			// assert
+			require.Equal(t, tt.expectedFailed, rt.failed)
+		})
+	}
+}
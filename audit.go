@@ -0,0 +1,313 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/volatiletech/null/v8"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// actions recorded in audit_log, which are derived from the states of the user before and after each write
+const (
+	AuditActionRegister   = "register"
+	AuditActionUpdate     = "update"
+	AuditActionDelete     = "delete"
+	AuditActionRestore    = "restore"
+	AuditActionHardDelete = "hard_delete"
+)
+
+// actor recorded if ctx does not have one
+const unknownActor = "unknown"
+
+type actorKey struct{}
+
+// WithActor returns ctx whose writes are recorded in audit_log as done by actor, e.g. the id of an operator.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return unknownActor
+}
+
+// AuditedUser is a state of a user recorded in audit_log as JSON.
+type AuditedUser struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Age       *int       `json:"age"`
+	Email     string     `json:"email,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// AuditEntry is a write of a user recorded in audit_log.
+type AuditEntry struct {
+	ID        int64
+	UserID    string
+	Action    string
+	Actor     string
+	CreatedAt time.Time
+	// Before is nil if the user was registered, and After is nil if the user was hard-deleted.
+	Before *AuditedUser
+	After  *AuditedUser
+}
+
+func toAuditedUser(m *models.User) *AuditedUser {
+	if m == nil {
+		return nil
+	}
+	u := &AuditedUser{
+		ID:    m.ID,
+		Name:  m.Name,
+		Age:   m.Age.Ptr(),
+		Email: m.Email.String,
+	}
+	if m.DeletedAt.Valid {
+		deletedAt := m.DeletedAt.Time
+		u.DeletedAt = &deletedAt
+	}
+	return u
+}
+
+// auditAction returns the action which changed the user from before to after, or false if nothing is changed.
+func auditAction(before, after *models.User) (string, bool) {
+	switch {
+	case before == nil && after == nil:
+		return "", false
+	case before == nil:
+		return AuditActionRegister, true
+	case after == nil:
+		return AuditActionHardDelete, true
+	case !before.DeletedAt.Valid && after.DeletedAt.Valid:
+		return AuditActionDelete, true
+	case before.DeletedAt.Valid && !after.DeletedAt.Valid:
+		return AuditActionRestore, true
+	default:
+		return AuditActionUpdate, true
+	}
+}
+
+// auditor records writes of userRepository enabled by WithAuditLog.
+type auditor struct {
+	clock Clock
+}
+
+// WithAuditLog records every write of a user (Register, RegisterAll, RegisterIdempotent, Upsert, Delete, HardDelete and Restore)
+// in audit_log in the same transaction as the write, with the actor of ctx (see WithActor) and the states before and after it.
+// Read the history by NewAuditRepository.
+func WithAuditLog() UserRepositoryOption {
+	return func(r *userRepository) {
+		r.audit = &auditor{clock: systemClock{}}
+	}
+}
+
+// audited runs write and records its change of the user in the same transaction if WithAuditLog is set.
+// The user is found by id, or by conflict if it is not nil (e.g. the user of the same name for Upsert).
+// write also runs in a transaction if the repository is scoped to a tenant, which writes the tenant of the user with it.
+func (r *userRepository) audited(
+	ctx context.Context,
+	id string,
+	conflict func(context.Context, boil.ContextExecutor) (*models.User, error),
+	write func(context.Context, boil.ContextExecutor) error,
+) error {
+	if r.audit == nil && r.tenant == "" {
+		return write(ctx, r.db)
+	}
+
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if r.audit == nil {
+		err = write(ctx, tx)
+	} else {
+		err = r.audit.record(ctx, tx, id, conflict, write)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// record runs write by exec, which must be a transaction, between reading the user before and after it.
+func (a *auditor) record(
+	ctx context.Context,
+	exec boil.ContextExecutor,
+	id string,
+	conflict func(context.Context, boil.ContextExecutor) (*models.User, error),
+	write func(context.Context, boil.ContextExecutor) error,
+) error {
+	if conflict == nil {
+		conflict = func(ctx context.Context, exec boil.ContextExecutor) (*models.User, error) {
+			return findUserForUpdate(ctx, exec, models.UserWhere.ID.EQ(id))
+		}
+	}
+	before, err := conflict(ctx, exec)
+	if err != nil {
+		return err
+	}
+	// NOTE: the user of the same name or email keeps its id on Upsert
+	if before != nil {
+		id = before.ID
+	}
+
+	if err := write(ctx, exec); err != nil {
+		return err
+	}
+
+	after, err := findUserForUpdate(ctx, exec, models.UserWhere.ID.EQ(id))
+	if err != nil {
+		return err
+	}
+	return a.insert(ctx, exec, id, before, after)
+}
+
+// insert records the change of the user from before to after. Nothing is recorded if both are nil.
+func (a *auditor) insert(ctx context.Context, exec boil.ContextExecutor, id string, before, after *models.User) error {
+	action, ok := auditAction(before, after)
+	if !ok {
+		return nil
+	}
+
+	beforeJSON, err := marshalAuditedUser(toAuditedUser(before))
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalAuditedUser(toAuditedUser(after))
+	if err != nil {
+		return err
+	}
+
+	l := &models.AuditLog{
+		UserID: id,
+		Action: action,
+		Actor:  actorFrom(ctx),
+		// NOTE: MySQL rounds fractional seconds of DATETIME, which could record the entry in the next second
+		CreatedAt:  a.clock.Now().UTC().Truncate(time.Second),
+		BeforeJSON: beforeJSON,
+		AfterJSON:  afterJSON,
+	}
+	if err := l.Insert(ctx, exec, boil.Infer()); err != nil {
+		return fmt.Errorf("failed to insert audit log (user_id: %s, action: %s): %w", id, action, wrapStorageError(err))
+	}
+	return nil
+}
+
+// findUserForUpdate returns the user including a soft-deleted one, or nil if it does not exist.
+func findUserForUpdate(ctx context.Context, exec boil.ContextExecutor, mods ...qm.QueryMod) (*models.User, error) {
+	mods = append(mods, qm.WithDeleted(), qm.For("UPDATE"))
+	user, err := models.Users(mods...).One(ctx, exec)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user for audit log: %w", err)
+	}
+	return user, nil
+}
+
+func marshalAuditedUser(u *AuditedUser) (null.JSON, error) {
+	if u == nil {
+		return null.JSON{}, nil
+	}
+	b, err := json.Marshal(u)
+	if err != nil {
+		return null.JSON{}, fmt.Errorf("failed to marshal user (id: %s): %w", u.ID, err)
+	}
+	return null.JSONFrom(b), nil
+}
+
+func unmarshalAuditedUser(j null.JSON) (*AuditedUser, error) {
+	if !j.Valid {
+		return nil, nil
+	}
+	var u AuditedUser
+	if err := json.Unmarshal(j.JSON, &u); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	return &u, nil
+}
+
+// AuditQuery filters entries of audit_log. Zero values match all entries.
+type AuditQuery struct {
+	UserID string
+	Actor  string
+	// Since is the earliest time of entries (inclusive).
+	Since time.Time
+}
+
+type auditRepository struct {
+	db boil.ContextExecutor
+}
+
+// NewAuditRepository returns a reader of entries recorded by WithAuditLog.
+func NewAuditRepository(db *sql.DB) *auditRepository {
+	return newAuditRepository(db)
+}
+
+func newAuditRepository(db boil.ContextExecutor) *auditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// History returns entries matching query in the order of writes.
+func (r *auditRepository) History(ctx context.Context, query *AuditQuery) ([]*AuditEntry, error) {
+	if query == nil {
+		query = &AuditQuery{}
+	}
+
+	mods := []qm.QueryMod{qm.OrderBy(models.AuditLogColumns.ID)}
+	if query.UserID != "" {
+		mods = append(mods, models.AuditLogWhere.UserID.EQ(query.UserID))
+	}
+	if query.Actor != "" {
+		mods = append(mods, models.AuditLogWhere.Actor.EQ(query.Actor))
+	}
+	if !query.Since.IsZero() {
+		mods = append(mods, models.AuditLogWhere.CreatedAt.GTE(query.Since.UTC()))
+	}
+
+	logs, err := models.AuditLogs(mods...).All(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit logs: %w", err)
+	}
+
+	entries := make([]*AuditEntry, len(logs))
+	for i, l := range logs {
+		before, err := unmarshalAuditedUser(l.BeforeJSON)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit log (id: %d): %w", l.ID, err)
+		}
+		after, err := unmarshalAuditedUser(l.AfterJSON)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit log (id: %d): %w", l.ID, err)
+		}
+		entries[i] = &AuditEntry{
+			ID:        l.ID,
+			UserID:    l.UserID,
+			Action:    l.Action,
+			Actor:     l.Actor,
+			CreatedAt: l.CreatedAt,
+			Before:    before,
+			After:     after,
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,271 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/volatiletech/null/v8"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+func TestAuditAction(t *testing.T) {
+	active := &models.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"}
+	deleted := &models.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", DeletedAt: null.TimeFrom(time.Now())}
+
+	tests := []struct {
+		title    string
+		before   *models.User
+		after    *models.User
+		expected string
+		changed  bool
+	}{
+		{"register", nil, active, AuditActionRegister, true},
+		{"update", active, active, AuditActionUpdate, true},
+		{"delete", active, deleted, AuditActionDelete, true},
+		{"restore", deleted, active, AuditActionRestore, true},
+		{"hard delete", deleted, nil, AuditActionHardDelete, true},
+		{"nothing is written", nil, nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			actual, changed := auditAction(tt.before, tt.after)
+			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.changed, changed)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestRegisterWithAuditLogWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	selectUser := regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) LIMIT 1 FOR UPDATE;")
+	insertUser := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")
+	insertLog := regexp.QuoteMeta("INSERT INTO `audit_log` (`user_id`,`action`,`actor`,`created_at`,`before_json`,`after_json`) VALUES (?,?,?,?,?,?)")
+
+	tests := []struct {
+		title       string
+		mock        func(mock sqlmock.Sqlmock)
+		expectedErr string
+	}{
+		{
+			"user and log are written in a transaction",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectUser).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames))
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(selectUser).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow(mike.ID, mike.Name, *mike.Age, nil, nil, 1, time.Time{}, time.Time{}))
+				mock.ExpectExec(insertLog).
+					WithArgs(mike.ID, AuditActionRegister, "admin", TimeArg(time.Now(), time.Minute), nil,
+						[]byte(`{"id":"0123456789ABCDEFGHJKMNPQRS","name":"Mike","age":20}`)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			"",
+		},
+		{
+			"log is not written if the user is not",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectUser).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames))
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnError(errors.New("connection refused"))
+				mock.ExpectRollback()
+			},
+			"failed to insert user: models: unable to insert into user: connection refused",
+		},
+		{
+			"user is not written if the log is not",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectUser).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames))
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(selectUser).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow(mike.ID, mike.Name, *mike.Age, nil, nil, 1, time.Time{}, time.Time{}))
+				mock.ExpectExec(insertLog).
+					WillReturnError(errors.New("connection refused"))
+				mock.ExpectRollback()
+			},
+			"failed to insert audit log (user_id: 0123456789ABCDEFGHJKMNPQRS, action: register): models: unable to insert into audit_log: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			r := NewUserRepository(db, WithAuditLog())
+			err := r.Register(WithActor(context.TODO(), "admin"), mike)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// assertAuditLog writes users in every way with WithAuditLog and checks the history.
+func assertAuditLog(ctx context.Context, t *testing.T, db *sql.DB) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	mary := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)}
+	r := NewUserRepository(db, WithAuditLog())
+	admin := WithActor(ctx, "admin")
+	start := time.Now().Add(-time.Second)
+
+	// run
+	require.NoError(t, r.Register(admin, mike))
+	// NOTE: the user of the same name keeps its id
+	require.NoError(t, r.Upsert(admin, &User{ID: "3123456789ABCDEFGHJKMNPQRS", Name: mike.Name, Age: lo.ToPtr(21)}))
+	require.NoError(t, r.Delete(ctx, mike))
+	require.NoError(t, r.Restore(admin, mike.ID))
+	require.NoError(t, r.HardDelete(admin, mike))
+	require.NoError(t, r.RegisterAll(admin, []*User{bob}))
+	_, err := r.RegisterIdempotent(admin, "key-1", mary)
+	require.NoError(t, err)
+	// failed writes are not recorded
+	require.Error(t, r.Register(admin, &User{ID: "4123456789ABCDEFGHJKMNPQRS", Name: bob.Name, Age: lo.ToPtr(22)}))
+	require.Error(t, r.Restore(admin, bob.ID))
+
+	// assert
+	audit := NewAuditRepository(db)
+	history, err := audit.History(ctx, &AuditQuery{UserID: mike.ID})
+	require.NoError(t, err)
+	require.Len(t, history, 5)
+	for i, expected := range []struct {
+		action string
+		actor  string
+	}{
+		{AuditActionRegister, "admin"},
+		{AuditActionUpdate, "admin"},
+		{AuditActionDelete, unknownActor},
+		{AuditActionRestore, "admin"},
+		{AuditActionHardDelete, "admin"},
+	} {
+		require.Equal(t, mike.ID, history[i].UserID, "entry %d", i)
+		require.Equal(t, expected.action, history[i].Action, "entry %d", i)
+		require.Equal(t, expected.actor, history[i].Actor, "entry %d", i)
+		require.WithinDuration(t, time.Now(), history[i].CreatedAt, time.Minute, "entry %d", i)
+	}
+
+	require.Nil(t, history[0].Before)
+	require.Equal(t, &AuditedUser{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(20), Email: mike.Email}, history[0].After)
+	require.Equal(t, history[0].After, history[1].Before)
+	require.Equal(t, &AuditedUser{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(21), Email: mike.Email}, history[1].After)
+	require.NotNil(t, history[2].After.DeletedAt)
+	require.Nil(t, history[3].After.DeletedAt)
+	require.Equal(t, history[3].After, history[4].Before)
+	require.Nil(t, history[4].After)
+
+	all, err := audit.History(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, all, 7)
+	require.Equal(t, &AuditedUser{ID: bob.ID, Name: bob.Name, Age: bob.Age}, all[5].After)
+	require.Equal(t, &AuditedUser{ID: mary.ID, Name: mary.Name, Age: mary.Age}, all[6].After)
+
+	byUnknown, err := audit.History(ctx, &AuditQuery{Actor: unknownActor})
+	require.NoError(t, err)
+	require.Equal(t, []*AuditEntry{history[2]}, byUnknown)
+
+	since, err := audit.History(ctx, &AuditQuery{Since: start})
+	require.NoError(t, err)
+	require.Len(t, since, 7)
+	future, err := audit.History(ctx, &AuditQuery{Since: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.Empty(t, future)
+}
+
+// assertAuditLogOfUpsertWithoutEmail upserts a user without email and checks that it is not audited as another user without email.
+func assertAuditLogOfUpsertWithoutEmail(ctx context.Context, t *testing.T, db *sql.DB) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	r := NewUserRepository(db, WithAuditLog())
+	admin := WithActor(ctx, "admin")
+
+	// run
+	require.NoError(t, r.Register(admin, bob))
+	require.NoError(t, r.Upsert(admin, mike))
+
+	// assert
+	audit := NewAuditRepository(db)
+	history, err := audit.History(ctx, &AuditQuery{UserID: mike.ID})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, AuditActionRegister, history[0].Action)
+	require.Nil(t, history[0].Before)
+	require.Equal(t, &AuditedUser{ID: mike.ID, Name: mike.Name, Age: mike.Age}, history[0].After)
+
+	history, err = audit.History(ctx, &AuditQuery{UserID: bob.ID})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, AuditActionRegister, history[0].Action)
+}
+
+// test using go-mysql-server
+func TestAuditLogWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	assertAuditLog(ctx, t, db)
+}
+
+// test using testcontainers
+func TestAuditLogWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	assertAuditLog(ctx, t, db)
+}
+
+// test using go-mysql-server
+func TestAuditLogOfUpsertWithoutEmailWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	assertAuditLogOfUpsertWithoutEmail(ctx, t, db)
+}
+
+// test using testcontainers
+func TestAuditLogOfUpsertWithoutEmailWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	assertAuditLogOfUpsertWithoutEmail(ctx, t, db)
+}
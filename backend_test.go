@@ -0,0 +1,466 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dolthub/go-mysql-server/memory"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/pipeline"
+	"github.com/syuparn/gosqltests/seed"
+)
+
+// NOTE: backends are chosen by the profile of GOSQLTESTS_PROFILE (see the pipeline package).
+// Set GOSQLTESTS_BACKENDS (comma separated) to override them, e.g. GOSQLTESTS_BACKENDS=gomysqlserver,testcontainers.
+// "all" selects every backend. docker starts the stack of docker-compose.yml unless it is running.
+const backendsEnv = "GOSQLTESTS_BACKENDS"
+
+var backendFactories = map[string]func() DBTestBackend{
+	"sqlmock":        func() DBTestBackend { return &sqlmockBackend{} },
+	"gomysqlserver":  func() DBTestBackend { return &simulatorBackend{} },
+	"testcontainers": func() DBTestBackend { return &testcontainersBackend{} },
+	"docker":         func() DBTestBackend { return &dockerBackend{} },
+}
+
+// testProfile returns the profile selected by GOSQLTESTS_PROFILE.
+func testProfile(t testing.TB) *pipeline.Profile {
+	t.Helper()
+
+	profile, err := pipeline.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+// selectedBackend is a backend which the matrix of tests runs on.
+type selectedBackend struct {
+	name       string
+	newBackend func() DBTestBackend
+}
+
+func selectedBackends(t *testing.T) []*selectedBackend {
+	if dbBackend != nil {
+		return []*selectedBackend{dbBackend}
+	}
+	profile := testProfile(t)
+
+	var names []string
+	switch env := os.Getenv(backendsEnv); env {
+	case "":
+		names = profile.Backends
+	case "all":
+		names = []string{"sqlmock", "gomysqlserver", "testcontainers", "docker"}
+	default:
+		names = strings.Split(env, ",")
+	}
+
+	selected := []*selectedBackend{}
+	for _, name := range names {
+		factory, ok := backendFactories[name]
+		if !ok {
+			t.Fatalf("unknown backend %q (%s: %s, %s: %s)", name, pipeline.ProfileEnv, profile.Name, backendsEnv, os.Getenv(backendsEnv))
+		}
+
+		// testcontainers runs on each MySQL version of the profile
+		if name == "testcontainers" && len(profile.MySQLImages) > 0 {
+			for _, image := range profile.MySQLImages {
+				image := image
+				selected = append(selected, &selectedBackend{
+					name:       fmt.Sprintf("%s(%s)", name, image),
+					newBackend: func() DBTestBackend { return &testcontainersBackend{image: image} },
+				})
+			}
+			continue
+		}
+		selected = append(selected, &selectedBackend{name: name, newBackend: factory})
+	}
+	return selected
+}
+
+// NOTE: run `go test . -db-backend=simulator` to run the tests on one backend chosen by the job with the same code,
+// e.g. fast backends in CI and containers in nightly jobs. It overrides GOSQLTESTS_BACKENDS and the profile.
+var dbBackendFlag = flag.String("db-backend", "", "backend which tests run on (sqlmock, simulator, container or compose)")
+
+// dbBackendNames maps values of -db-backend to names of backendFactories.
+var dbBackendNames = map[string]string{
+	"sqlmock":   "sqlmock",
+	"simulator": "gomysqlserver",
+	"container": "testcontainers",
+	"compose":   "docker",
+}
+
+// dbBackend is the backend chosen by -db-backend, which is set by TestMain (nil if the flag is not set).
+var dbBackend *selectedBackend
+
+// parseDBBackend returns the backend of the value of -db-backend (nil if it is empty).
+func parseDBBackend(value string) (*selectedBackend, error) {
+	if value == "" {
+		return nil, nil
+	}
+	name, ok := dbBackendNames[value]
+	if !ok {
+		return nil, fmt.Errorf("unknown -db-backend %q (sqlmock, simulator, container or compose)", value)
+	}
+	return &selectedBackend{name: name, newBackend: backendFactories[name]}, nil
+}
+
+// sharedMockDB is the sqlmock created once by TestMain for tests of -db-backend=sqlmock (nil db otherwise).
+// NOTE: expectations of sqlmock cannot be reset, so expectations left by a failed test fail the following tests, too
+var sharedMockDB struct {
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+// initDBBackend sets dbBackend by -db-backend and sets up the backend once before tests run.
+// NOTE: sqlmock is shared by tests, and go-mysql-server serves a database of each test (see createSimulatorDatabase).
+// The container (or the stack of docker-compose.yml) needs *testing.T to start,
+// so it is started by the first test using it instead and shared by the others until TestMain finishes.
+func initDBBackend() error {
+	b, err := parseDBBackend(*dbBackendFlag)
+	if err != nil {
+		return err
+	}
+	dbBackend = b
+	if dbBackend == nil {
+		return nil
+	}
+
+	switch dbBackend.name {
+	case "sqlmock":
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			return fmt.Errorf("failed to create shared sqlmock: %w", err)
+		}
+		sharedMockDB.db, sharedMockDB.mock = db, mock
+	case "gomysqlserver":
+		if _, _, err := startSharedSimulator(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeDBBackend closes the sqlmock or removes the container shared by tests of -db-backend.
+// NOTE: the shared simulator is closed by closeSharedSimulator
+func closeDBBackend(ctx context.Context) error {
+	if sharedMockDB.db != nil {
+		// NOTE: the error is ignored because sqlmock reports closing the database without ExpectClose
+		_ = sharedMockDB.db.Close()
+	}
+	if !sharesDBBackendContainer() {
+		return nil
+	}
+	return removeSharedContainer(ctx, mysqlImage())
+}
+
+func sharesDBBackendContainer() bool {
+	return dbBackend != nil && dbBackend.name == "testcontainers"
+}
+
+// testDBBackend returns a new backend chosen by -db-backend (go-mysql-server if the flag is not set).
+func testDBBackend() DBTestBackend {
+	if dbBackend == nil {
+		return &simulatorBackend{}
+	}
+	return dbBackend.newBackend()
+}
+
+// setupDBBackend sets up the backend chosen by -db-backend for the test, which is torn down when the test finishes.
+func setupDBBackend(ctx context.Context, t *testing.T) (*sql.DB, DBTestBackend) {
+	backend := testDBBackend()
+	db := backend.Setup(ctx, t)
+	t.Cleanup(func() {
+		backend.Teardown(ctx, t)
+	})
+	return db, backend
+}
+
+// setupStoringDBBackend is setupDBBackend for tests which read rows they seed, which are skipped on -db-backend=sqlmock.
+func setupStoringDBBackend(ctx context.Context, t *testing.T) *sql.DB {
+	if dbBackend != nil && isMockBackend(dbBackend.newBackend()) {
+		t.Skip("skipped because sqlmock cannot store rows")
+	}
+	db, _ := setupDBBackend(ctx, t)
+	return db
+}
+
+// setupMockDBBackend returns the sqlmock for tests which set expectations,
+// which is shared by tests of -db-backend=sqlmock and is a new one otherwise.
+// Expectations must be met when the test finishes.
+func setupMockDBBackend(ctx context.Context, t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	backend := &sqlmockBackend{}
+	db := backend.Setup(ctx, t)
+	t.Cleanup(func() {
+		backend.Teardown(ctx, t)
+	})
+	return db, backend.Mock()
+}
+
+type sqlmockBackend struct {
+	db   *sql.DB
+	mock sqlmock.Sqlmock
+}
+
+func (b *sqlmockBackend) Name() string {
+	return "sqlmock"
+}
+
+func (b *sqlmockBackend) Setup(ctx context.Context, t *testing.T) *sql.DB {
+	if sharedMockDB.db != nil {
+		b.db, b.mock = sharedMockDB.db, sharedMockDB.mock
+		return b.db
+	}
+	b.db, b.mock = prepareMockDB(t)
+	return b.db
+}
+
+// Seed does nothing because sqlmock cannot store rows. Set expectations by Mock instead.
+func (b *sqlmockBackend) Seed(ctx context.Context, t *testing.T, users ...*User) {}
+
+func (b *sqlmockBackend) Teardown(ctx context.Context, t *testing.T) {
+	require.NoError(t, b.mock.ExpectationsWereMet())
+}
+
+func (b *sqlmockBackend) Mock() sqlmock.Sqlmock {
+	return b.mock
+}
+
+type simulatorBackend struct {
+	db       *sql.DB
+	table    *memory.Table
+	fixtures *fixtureUsage
+}
+
+func (b *simulatorBackend) Name() string {
+	return "gomysqlserver"
+}
+
+// Setup creates a database of the test on the shared simulator, so that the server is started only once.
+func (b *simulatorBackend) Setup(ctx context.Context, t *testing.T) *sql.DB {
+	port, database, table := createSimulatorDatabase(t)
+	b.table = table
+
+	// NOTE: seeded users which the test never reads are reported by -fixture-report
+	b.fixtures = trackFixtures(t)
+	var err error
+	b.db, err = newFixtureTrackedClient(port, database, b.fixtures)
+	require.NoError(t, err)
+	return b.db
+}
+
+func (b *simulatorBackend) Seed(ctx context.Context, t *testing.T, users ...*User) {
+	simCtx := simsql.NewEmptyContext()
+	for _, u := range users {
+		// NOTE: users without email are stored as NULL as the repository does
+		var age, email interface{}
+		if u.Age != nil {
+			age = int32(*u.Age)
+		}
+		if u.Email != "" {
+			email = u.Email
+		}
+		createdAt, updatedAt := seededAt, seededAt
+		if !u.CreatedAt.IsZero() {
+			createdAt, updatedAt = u.CreatedAt, u.UpdatedAt
+		}
+		require.NoError(t, b.table.Insert(simCtx, simsql.NewRow(u.ID, u.Name, age, nil, email, int64(1), createdAt, updatedAt)))
+		b.fixtures.register(models.TableNames.User, u.ID)
+	}
+}
+
+func (b *simulatorBackend) Teardown(ctx context.Context, t *testing.T) {
+	closeTestClient(t, b.db)
+}
+
+type testcontainersBackend struct {
+	// image is the image of MySQL (the default one if empty)
+	image string
+	db    *sql.DB
+}
+
+func (b *testcontainersBackend) Name() string {
+	return "testcontainers"
+}
+
+func (b *testcontainersBackend) Setup(ctx context.Context, t *testing.T) *sql.DB {
+	var opts []containerOption
+	if b.image != "" {
+		opts = append(opts, withImage(b.image))
+	}
+	b.db = prepareContainer(ctx, t, opts...)
+	return b.db
+}
+
+func (b *testcontainersBackend) Seed(ctx context.Context, t *testing.T, users ...*User) {
+	seedUsers(ctx, t, b.db, users)
+}
+
+// Teardown does nothing because the container is released when the test finishes.
+func (b *testcontainersBackend) Teardown(ctx context.Context, t *testing.T) {}
+
+// NOTE: the MySQL of docker compose may be shared by CI jobs,
+// so tests using it hold the lock until they clean up their rows
+const (
+	sharedDatabaseLockName    = "gosqltests:practice"
+	sharedDatabaseLockTimeout = 5 * time.Minute
+)
+
+func lockSharedDatabase(ctx context.Context, t *testing.T, db *sql.DB) func() {
+	lock, err := AcquireLock(ctx, db, sharedDatabaseLockName, sharedDatabaseLockTimeout)
+	require.NoError(t, err)
+	return func() {
+		require.NoError(t, lock.Release(ctx))
+	}
+}
+
+// NOTE: set GOSQLTESTS_NAMESPACE to a job-unique token (e.g. the CI job id) so that
+// tests of the job use their own database of the shared MySQL instead of waiting for the lock
+const namespaceEnv = "GOSQLTESTS_NAMESPACE"
+
+// openSharedDatabase connects to the migrated database of the MySQL started by docker-compose.yml.
+// It returns the database name and the function to release the database after the test cleans up its rows.
+func openSharedDatabase(ctx context.Context, t *testing.T) (*sql.DB, string, func()) {
+	port := dockerComposePort(ctx, t)
+
+	token := os.Getenv(namespaceEnv)
+	if token == "" {
+		db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, StrictScan: true})
+		require.NoError(t, err)
+		require.NoError(t, Migrate(ctx, db))
+		return db, defaultDatabase, lockSharedDatabase(ctx, t, db)
+	}
+
+	database, err := NamespacedDatabase(defaultDatabase, token)
+	require.NoError(t, err)
+	root, err := NewClientWithWait(ctx, &ClientConfig{Port: port})
+	require.NoError(t, err)
+	defer root.Close()
+	require.NoError(t, CreateDatabase(ctx, root, database))
+
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, Database: database, StrictScan: true})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(ctx, db))
+	return db, database, func() {
+		require.NoError(t, DropDatabase(ctx, db, database))
+	}
+}
+
+// dockerBackend uses the MySQL started by docker-compose.yml.
+type dockerBackend struct {
+	db       *sql.DB
+	database string
+	release  func()
+}
+
+func (b *dockerBackend) Name() string {
+	return "docker"
+}
+
+func (b *dockerBackend) Setup(ctx context.Context, t *testing.T) *sql.DB {
+	b.db, b.database, b.release = openSharedDatabase(ctx, t)
+	// NOTE: the database is shared with other runs, so clean up rows left by them
+	require.NoError(t, truncateTables(ctx, b.db, b.database))
+	return b.db
+}
+
+func (b *dockerBackend) Seed(ctx context.Context, t *testing.T, users ...*User) {
+	seedUsers(ctx, t, b.db, users)
+}
+
+func (b *dockerBackend) Teardown(ctx context.Context, t *testing.T) {
+	defer closeTestClient(t, b.db)
+	defer b.release()
+	require.NoError(t, truncateTables(ctx, b.db, b.database))
+}
+
+// generateUsers returns n realistic users, which are reproducible by the seed of the test (see testSeed).
+func generateUsers(t testing.TB, n int) []*User {
+	return toUsers(seed.NewGeneratorFromRand(testRand(t)).Users(n))
+}
+
+func toUsers(users []*seed.User) []*User {
+	return lo.Map(users, func(u *seed.User, _ int) *User {
+		return fromSeedUser(u)
+	})
+}
+
+// fromSeedUser converts the generated user, which has no timestamps until it is registered.
+func fromSeedUser(u *seed.User) *User {
+	return &User{ID: u.ID, Name: u.Name, Age: u.Age, Email: u.Email}
+}
+
+func seedUsers(ctx context.Context, t *testing.T, db *sql.DB, users []*User) {
+	r := NewUserRepository(db)
+	for _, u := range users {
+		require.NoError(t, r.Register(ctx, u))
+	}
+}
+
+func TestParseDBBackend(t *testing.T) {
+	tests := []struct {
+		title        string
+		value        string
+		expectedName string
+		expectedErr  string
+	}{
+		{"not set", "", "", ""},
+		{"sqlmock", "sqlmock", "sqlmock", ""},
+		{"simulator", "simulator", "gomysqlserver", ""},
+		{"container", "container", "testcontainers", ""},
+		{"compose", "compose", "docker", ""},
+		{"unknown", "gomysqlserver", "", `unknown -db-backend "gomysqlserver" (sqlmock, simulator, container or compose)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual, err := parseDBBackend(tt.value)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.expectedName == "" {
+				require.Nil(t, actual)
+				return
+			}
+			require.Equal(t, tt.expectedName, actual.name)
+			require.Equal(t, tt.expectedName, actual.newBackend().Name())
+		})
+	}
+}
+
+// test using the backend chosen by -db-backend
+func TestSetupDBBackend(t *testing.T) {
+	ctx := context.Background()
+
+	// run
+	db, backend := setupDBBackend(ctx, t)
+
+	// assert
+	expected := "gomysqlserver"
+	if dbBackend != nil {
+		expected = dbBackend.name
+	}
+	require.Equal(t, expected, backend.Name())
+	require.NoError(t, db.PingContext(ctx))
+}
+
+// test using the backend chosen by -db-backend
+func TestStandardSuiteOnDBBackend(t *testing.T) {
+	RunStandardSuite(t, testDBBackend)
+}
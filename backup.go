@@ -0,0 +1,239 @@
+package gosqltests
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// number of rows written by an INSERT statement of backups
+const backupInsertChunkSize = 1000
+
+// terminator of statements in backups
+// NOTE: string literals escape newlines, so statements are split by it safely
+const backupStatementTerminator = ";\n"
+
+// BackupDatabase writes SQL statements which re-create all tables of the database of db (including the migration history)
+// with their rows to w. Rows are read in a transaction, so the backup is a consistent state of the database.
+// NOTE: it does not use mysqldump, so that it works with go-mysql-server as well as MySQL
+func BackupDatabase(ctx context.Context, db *sql.DB, w io.Writer) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tables, err := listBaseTables(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, table := range tables {
+		if err := backupTable(ctx, tx, bw, table); err != nil {
+			return fmt.Errorf("failed to back up table %s: %w", table, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreDatabase executes statements written by BackupDatabase on the database of db.
+// Tables in the backup are dropped and re-created, while other tables are left as they are.
+func RestoreDatabase(ctx context.Context, db *sql.DB, r io.Reader) error {
+	backup, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	// NOTE: foreign key checks are disabled per session, so all statements must run on the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return fmt.Errorf("failed to disable foreign key checks: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 1")
+
+	statements := strings.SplitAfter(string(backup), backupStatementTerminator)
+	for i, stmt := range statements {
+		stmt = strings.TrimSpace(trimCommentLines(strings.TrimSuffix(stmt, backupStatementTerminator)))
+		if stmt == "" {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to restore statement %d: %w", i+1, wrapStorageError(err))
+		}
+	}
+
+	return nil
+}
+
+// trimCommentLines removes comment lines before the statement.
+func trimCommentLines(stmt string) string {
+	for strings.HasPrefix(stmt, "--") {
+		i := strings.Index(stmt, "\n")
+		if i < 0 {
+			return ""
+		}
+		stmt = stmt[i+1:]
+	}
+	return stmt
+}
+
+// listBaseTables returns names of tables (not views) of the current database in alphabetical order.
+func listBaseTables(ctx context.Context, tx *sql.Tx) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT `table_name` FROM `information_schema`.`tables` WHERE `table_schema` = DATABASE() AND `table_type` = 'BASE TABLE' ORDER BY `table_name`",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+func backupTable(ctx context.Context, tx *sql.Tx, w io.Writer, table string) error {
+	var name, create string
+	if err := tx.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoteIdentifier(table)).Scan(&name, &create); err != nil {
+		return fmt.Errorf("failed to show create table: %w", err)
+	}
+	fmt.Fprintf(w, "-- table %s\n", table)
+	fmt.Fprintf(w, "DROP TABLE IF EXISTS %s%s", quoteIdentifier(table), backupStatementTerminator)
+	fmt.Fprintf(w, "%s%s", create, backupStatementTerminator)
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT 0", quoteIdentifier(table)))
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+	columns, err := rows.ColumnTypes()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+	names := lo.Map(columns, func(c *sql.ColumnType, _ int) string { return quoteIdentifier(c.Name()) })
+
+	// NOTE: rows are ordered by all columns so that backups of the same state are identical
+	rows, err = tx.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s ORDER BY %s",
+		strings.Join(names, ", "), quoteIdentifier(table), strings.Join(names, ", ")))
+	if err != nil {
+		return fmt.Errorf("failed to select rows: %w", err)
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var chunk []string
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES %s%s",
+			quoteIdentifier(table), strings.Join(names, ","), strings.Join(chunk, ","), backupStatementTerminator)
+		chunk = chunk[:0]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		literals := make([]string, len(values))
+		for i, v := range values {
+			literals[i] = sqlLiteral(v, columns[i].DatabaseTypeName())
+		}
+		chunk = append(chunk, "("+strings.Join(literals, ",")+")")
+		if len(chunk) == backupInsertChunkSize {
+			flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to select rows: %w", err)
+	}
+	flush()
+
+	return nil
+}
+
+// sqlLiteral returns the literal of the value scanned from a column of the type.
+func sqlLiteral(v interface{}, typeName string) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999") + "'"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []byte:
+		switch {
+		case isNumericType(typeName):
+			return string(v)
+		case isBinaryType(typeName):
+			return fmt.Sprintf("X'%X'", v)
+		default:
+			return quoteString(string(v))
+		}
+	default:
+		return quoteString(fmt.Sprint(v))
+	}
+}
+
+// NOTE: the driver reports unsigned types with the prefix, e.g. "UNSIGNED BIGINT"
+func isNumericType(typeName string) bool {
+	switch strings.TrimPrefix(typeName, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		return true
+	default:
+		return false
+	}
+}
+
+func isBinaryType(typeName string) bool {
+	switch typeName {
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		return true
+	default:
+		return false
+	}
+}
+
+var stringLiteralEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+func quoteString(s string) string {
+	return "'" + stringLiteralEscaper.Replace(s) + "'"
+}
@@ -0,0 +1,207 @@
+package gosqltests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testsupport"
+)
+
+func TestSQLLiteral(t *testing.T) {
+	tests := []struct {
+		title    string
+		value    interface{}
+		typeName string
+		expected string
+	}{
+		{"null", nil, "VARCHAR", "NULL"},
+		{"string", []byte("Mike"), "VARCHAR", "'Mike'"},
+		{"escaped string", []byte("O'Brien\\\n\r\x00\x1a"), "TEXT", `'O\'Brien\\\n\r\0\Z'`},
+		{"integer", []byte("20"), "INT", "20"},
+		{"unsigned integer", []byte("18446744073709551615"), "UNSIGNED BIGINT", "18446744073709551615"},
+		{"decimal", []byte("1.50"), "DECIMAL", "1.50"},
+		{"binary", []byte{0x00, 0xff}, "VARBINARY", "X'00FF'"},
+		{"time", time.Date(2023, 1, 2, 3, 4, 5, 600000000, time.UTC), "DATETIME", "'2023-01-02 03:04:05.6'"},
+		{"int64", int64(-1), "BIGINT", "-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual := sqlLiteral(tt.value, tt.typeName)
+
+			// assert
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestRestoreDatabaseWithSQLMock(t *testing.T) {
+	backup := "-- table user\n" +
+		"DROP TABLE IF EXISTS `user`;\n" +
+		"CREATE TABLE `user` (`id` varchar(26) NOT NULL);\n" +
+		"INSERT INTO `user` (`id`) VALUES ('0123456789ABCDEFGHJKMNPQRS');\n"
+
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta("SET FOREIGN_KEY_CHECKS = 0")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE IF EXISTS `user`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE `user` (`id` varchar(26) NOT NULL)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`) VALUES ('0123456789ABCDEFGHJKMNPQRS')")).
+		WillReturnError(errors.New("connection refused"))
+	// foreign key checks are enabled again even if the restore fails
+	mock.ExpectExec(regexp.QuoteMeta("SET FOREIGN_KEY_CHECKS = 1")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// run
+	err := RestoreDatabase(context.TODO(), db, bytes.NewBufferString(backup))
+
+	// assert
+	require.EqualError(t, err, "failed to restore statement 3: connection refused")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// assertBackupRestoresState backs up db, changes it and checks RestoreDatabase brings the state back
+// to db and to another database created by newDB.
+func assertBackupRestoresState(ctx context.Context, t *testing.T, db *sql.DB, newDB func() *sql.DB) {
+	users := NewUserRepository(db)
+	seeded := generateUsers(t, 3)
+	seeded[0].Name = "O'Brien\\"
+	seeded[1].Email = "bob@example.com"
+	seedUsers(ctx, t, db, seeded)
+	require.NoError(t, users.Delete(ctx, seeded[2]))
+	require.NoError(t, NewCredentialRepository(db).SetPassword(ctx, seeded[0].ID, "password"))
+
+	version, err := MigrationVersion(ctx, db)
+	require.NoError(t, err)
+
+	// run
+	var backup bytes.Buffer
+	require.NoError(t, BackupDatabase(ctx, db, &backup))
+
+	// change the state after the backup
+	require.NoError(t, users.Register(ctx, generateUsers(t, 4)[3]))
+	require.NoError(t, users.HardDelete(ctx, seeded[1]))
+
+	require.NoError(t, RestoreDatabase(ctx, db, bytes.NewReader(backup.Bytes())))
+	another := newDB()
+	require.NoError(t, RestoreDatabase(ctx, another, bytes.NewReader(backup.Bytes())))
+
+	// assert
+	for _, restored := range []*sql.DB{db, another} {
+		var actual bytes.Buffer
+		require.NoError(t, BackupDatabase(ctx, restored, &actual))
+		require.Equal(t, backup.String(), actual.String())
+
+		found, total, err := NewUserRepository(restored).List(ctx, nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), total)
+		require.ElementsMatch(t, seeded[:2], found)
+		require.NoError(t, NewCredentialRepository(restored).VerifyPassword(ctx, seeded[0].ID, "password"))
+		restoredVersion, err := MigrationVersion(ctx, restored)
+		require.NoError(t, err)
+		require.Equal(t, version, restoredVersion)
+	}
+}
+
+// test using go-mysql-server
+func TestBackupDatabaseWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+
+	assertBackupRestoresState(ctx, t, db, func() *sql.DB {
+		require.NoError(t, CreateDatabase(ctx, db, "practice_restored"))
+		another, err := NewClientWithWait(ctx, &ClientConfig{Port: port, Database: "practice_restored"})
+		require.NoError(t, err)
+		t.Cleanup(func() { another.Close() })
+		return another
+	})
+}
+
+// test using testcontainers
+func TestBackupDatabaseWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	port := startContainer(ctx, t)
+	db, _ := newIsolatedDatabase(ctx, t, port)
+
+	assertBackupRestoresState(ctx, t, db, func() *sql.DB {
+		// NOTE: the backup re-creates the migrated tables of the database
+		another, _ := newIsolatedDatabase(ctx, t, port)
+		return another
+	})
+}
+
+// test using testcontainers
+func TestDumpAndLoadDatabaseWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	// NOTE: options make containers dedicated to the test, as the dump replaces tables of the whole database
+	source, sourcePort := startMySQLContainer(ctx, t, withFastMySQL())
+	target, targetPort := startMySQLContainer(ctx, t, withFastMySQL())
+	sourceDB, err := NewClientWithWait(ctx, &ClientConfig{Port: sourcePort, StrictScan: true})
+	require.NoError(t, err)
+	defer closeTestClient(t, sourceDB)
+	targetDB, err := NewClientWithWait(ctx, &ClientConfig{Port: targetPort, StrictScan: true})
+	require.NoError(t, err)
+	defer closeTestClient(t, targetDB)
+
+	// seed once
+	require.NoError(t, Migrate(ctx, sourceDB))
+	seeded := generateUsers(t, 3)
+	seeded[0].Name = "O'Brien\\"
+	seedUsers(ctx, t, sourceDB, seeded)
+	require.NoError(t, NewCredentialRepository(sourceDB).SetPassword(ctx, seeded[0].ID, "password"))
+	var expected bytes.Buffer
+	require.NoError(t, BackupDatabase(ctx, sourceDB, &expected))
+
+	// run
+	var dump bytes.Buffer
+	require.NoError(t, testsupport.DumpDatabase(ctx, source, &dump))
+
+	t.Run("dump is loaded into another container", func(t *testing.T) {
+		// run
+		err := testsupport.LoadDatabase(ctx, target, bytes.NewReader(dump.Bytes()))
+
+		// assert
+		require.NoError(t, err)
+		var actual bytes.Buffer
+		require.NoError(t, BackupDatabase(ctx, targetDB, &actual))
+		require.Equal(t, expected.String(), actual.String())
+		require.NoError(t, NewCredentialRepository(targetDB).VerifyPassword(ctx, seeded[0].ID, "password"))
+	})
+
+	t.Run("dump restores the state before changes", func(t *testing.T) {
+		require.NoError(t, NewUserRepository(sourceDB).HardDelete(ctx, seeded[1]))
+		require.NoError(t, NewUserRepository(sourceDB).Register(ctx, generateUsers(t, 4)[3]))
+
+		// run
+		err := testsupport.LoadDatabase(ctx, source, bytes.NewReader(dump.Bytes()))
+
+		// assert
+		require.NoError(t, err)
+		var actual bytes.Buffer
+		require.NoError(t, BackupDatabase(ctx, sourceDB, &actual))
+		require.Equal(t, expected.String(), actual.String())
+	})
+
+	t.Run("invalid dump", func(t *testing.T) {
+		// run
+		err := testsupport.LoadDatabase(ctx, target, bytes.NewBufferString("SELEC 1;"))
+
+		// assert
+		require.ErrorContains(t, err, "failed to load database: ")
+		require.ErrorContains(t, err, "ERROR 1064")
+	})
+}
@@ -0,0 +1,120 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/seed"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// e.g. go test -run '^$' -bench . -bench-users 10000
+var benchUsers = flag.Int("bench-users", 1000, "number of users inserted before benchmarks")
+
+// NOTE: benchmarks use the fixed seed instead of testRand so that all backends read the same users
+const benchSeed = 1
+
+// benchmarkGet reads the seeded users in turn.
+func benchmarkGet(b *testing.B, db *sql.DB) {
+	ctx := context.Background()
+	users := seed.NewGenerator(benchSeed).Users(*benchUsers)
+	require.NoError(b, seed.Insert(ctx, db, users))
+	r := NewUserRepository(db)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Get(ctx, users[i%len(users)].ID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// number of expectations set to a mock at once
+// NOTE: sqlmock keeps fulfilled expectations and scans them on every query, so the mock is renewed periodically
+const benchMockBatch = 1000
+
+func BenchmarkGet_SQLMock(b *testing.B) {
+	ctx := context.Background()
+	users := seed.NewGenerator(benchSeed).Users(*benchUsers)
+	query := regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1")
+
+	// NOTE: mocks are closed as soon as they are renewed, instead of being kept until the benchmark finishes
+	var r UserRepository
+	var db *sql.DB
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%benchMockBatch == 0 {
+			b.StopTimer()
+			if db != nil {
+				db.Close()
+			}
+			var mock sqlmock.Sqlmock
+			var err error
+			db, mock, err = sqlmock.New()
+			if err != nil {
+				b.Fatal(err)
+			}
+			for j := i; j < i+benchMockBatch; j++ {
+				u := users[j%len(users)]
+				mock.ExpectQuery(query).
+					WithArgs(u.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow(u.ID, u.Name, *u.Age, nil, nil, 1, time.Time{}, time.Time{}))
+			}
+			r = NewUserRepository(db)
+			b.StartTimer()
+		}
+
+		if _, err := r.Get(ctx, users[i%len(users)].ID); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	db.Close()
+}
+
+func BenchmarkGet_GoMySQLServer(b *testing.B) {
+	port, err := testport.Reserve()
+	require.NoError(b, err)
+
+	// simulator
+	prepareSimulator(b, port)
+
+	db, err := NewClientWithWait(context.Background(), &ClientConfig{Port: port})
+	require.NoError(b, err)
+	defer db.Close()
+
+	benchmarkGet(b, db)
+}
+
+func BenchmarkGet_Testcontainers(b *testing.B) {
+	db := prepareContainer(context.Background(), b)
+	defer db.Close()
+
+	benchmarkGet(b, db)
+}
+
+// sqliteSchema is the user table of the migrations translated for SQLite.
+const sqliteSchema = "CREATE TABLE `user` (`id` VARCHAR(26) PRIMARY KEY, `name` VARCHAR(40) NOT NULL UNIQUE, `age` INT, `deleted_at` DATETIME NULL)"
+
+// NOTE: SQLite is not MySQL compatible, but queries of the repository happen to work as they are
+func BenchmarkGet_SQLite(b *testing.B) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(b, err)
+	defer db.Close()
+	// NOTE: each connection would open another in-memory database
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(sqliteSchema)
+	require.NoError(b, err)
+
+	benchmarkGet(b, db)
+}
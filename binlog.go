@@ -0,0 +1,166 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/samber/lo"
+	"github.com/siddontang/go-log/log"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrBinlogDisabled is returned by NewBinlogReader if the server does not write the binary log (e.g. started with --skip-log-bin).
+var ErrBinlogDisabled = errors.New("binary log is disabled")
+
+// userIDColumnIndex is the index of id in rows of the user table (see migrations/000001_create_user.up.sql).
+// NOTE: rows of the binlog have no column names unless binlog_row_metadata is FULL
+const userIDColumnIndex = 0
+
+// UserChangeHandler is called with ids of users changed by a row event of the binlog.
+type UserChangeHandler func(ctx context.Context, ids []string) error
+
+// BinlogReader reads changes of users from the binary log as a replica does (change data capture),
+// so that it observes writes of every client, including raw SQL and other processes.
+// NOTE: the server must write the binlog in the ROW format, which is the default of MySQL 8
+type BinlogReader struct {
+	syncer   *replication.BinlogSyncer
+	streamer *replication.BinlogStreamer
+	database string
+}
+
+// NewBinlogReader starts reading the binlog of the server of cfg from the current position,
+// so that changes committed after it returns are read by Run.
+// serverID must be unique among the replicas of the server (including other readers) and must not be 0.
+// NOTE: cfg.TLS is not supported
+func NewBinlogReader(ctx context.Context, cfg *ClientConfig, serverID uint32) (*BinlogReader, error) {
+	if serverID == 0 {
+		return nil, errors.New("server id of binlog reader must not be 0")
+	}
+
+	db, err := openClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	pos, err := binlogPosition(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID: serverID,
+		Host:     valueOr(cfg.Host, defaultHost),
+		Port:     uint16(cfg.Port),
+		User:     valueOr(cfg.User, defaultUser),
+		Password: cfg.Password,
+		// NOTE: the syncer logs every event to stdout by default
+		Logger: log.NewDefault(&log.NullHandler{}),
+	})
+	streamer, err := syncer.StartSync(pos)
+	if err != nil {
+		syncer.Close()
+		return nil, fmt.Errorf("failed to start reading binlog (position: %s): %w", pos, err)
+	}
+
+	return &BinlogReader{
+		syncer:   syncer,
+		streamer: streamer,
+		database: valueOr(cfg.Database, defaultDatabase),
+	}, nil
+}
+
+// binlogPosition returns the position of the binlog which the server writes next.
+func binlogPosition(ctx context.Context, db *sql.DB) (mysql.Position, error) {
+	// NOTE: SHOW MASTER STATUS is renamed to SHOW BINARY LOG STATUS in MySQL 8.2 and removed in 8.4
+	pos, err := queryBinlogPosition(ctx, db, "SHOW BINARY LOG STATUS")
+	if err != nil && !errors.Is(err, ErrBinlogDisabled) {
+		pos, err = queryBinlogPosition(ctx, db, "SHOW MASTER STATUS")
+	}
+	if err != nil {
+		return mysql.Position{}, fmt.Errorf("failed to get binlog position: %w", err)
+	}
+	return pos, nil
+}
+
+func queryBinlogPosition(ctx context.Context, db *sql.DB, query string) (mysql.Position, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	defer rows.Close()
+
+	// NOTE: columns other than File and Position (e.g. Executed_Gtid_Set) differ among versions
+	columns, err := rows.Columns()
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return mysql.Position{}, err
+		}
+		return mysql.Position{}, ErrBinlogDisabled
+	}
+	values := make([]sql.RawBytes, len(columns))
+	if err := rows.Scan(lo.Map(values, func(_ sql.RawBytes, i int) interface{} { return &values[i] })...); err != nil {
+		return mysql.Position{}, err
+	}
+	pos, err := strconv.ParseUint(string(values[1]), 10, 32)
+	if err != nil {
+		return mysql.Position{}, fmt.Errorf("invalid binlog position %q: %w", values[1], err)
+	}
+	return mysql.Position{Name: string(values[0]), Pos: uint32(pos)}, nil
+}
+
+// Run calls handle with ids of users changed in the database of the reader until ctx is done or handle fails.
+// It returns ctx.Err() after ctx is done.
+func (r *BinlogReader) Run(ctx context.Context, handle UserChangeHandler) error {
+	for {
+		ev, err := r.streamer.GetEvent(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to read binlog: %w", err)
+		}
+
+		rows, ok := ev.Event.(*replication.RowsEvent)
+		if !ok {
+			continue
+		}
+		ids := changedUserIDs(rows, r.database)
+		if len(ids) == 0 {
+			continue
+		}
+		if err := handle(ctx, ids); err != nil {
+			return fmt.Errorf("failed to handle changed users (ids: %v): %w", ids, err)
+		}
+	}
+}
+
+// Close stops reading the binlog.
+func (r *BinlogReader) Close() {
+	r.syncer.Close()
+}
+
+// changedUserIDs returns ids of users of the row event if it changes the user table of database.
+// NOTE: an update event has both the rows before and after the update, whose ids are the same unless the id is updated
+func changedUserIDs(ev *replication.RowsEvent, database string) []string {
+	if string(ev.Table.Schema) != database || string(ev.Table.Table) != models.TableNames.User {
+		return nil
+	}
+
+	ids := lo.FilterMap(ev.Rows, func(row []interface{}, _ int) (string, bool) {
+		if len(row) <= userIDColumnIndex {
+			return "", false
+		}
+		id, ok := row[userIDColumnIndex].(string)
+		return id, ok
+	})
+	return lo.Uniq(ids)
+}
@@ -0,0 +1,207 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedUserIDs(t *testing.T) {
+	userTable := &replication.TableMapEvent{Schema: []byte("practice"), Table: []byte("user")}
+
+	tests := []struct {
+		title    string
+		event    *replication.RowsEvent
+		expected []string
+	}{
+		{
+			"inserted users",
+			&replication.RowsEvent{Table: userTable, Rows: [][]interface{}{
+				{"0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20)},
+				{"1123456789ABCDEFGHJKMNPQRS", "Bob", nil},
+			}},
+			[]string{"0123456789ABCDEFGHJKMNPQRS", "1123456789ABCDEFGHJKMNPQRS"},
+		},
+		{
+			"updated user has rows before and after the update",
+			&replication.RowsEvent{Table: userTable, Rows: [][]interface{}{
+				{"0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20)},
+				{"0123456789ABCDEFGHJKMNPQRS", "Mike", int32(21)},
+			}},
+			[]string{"0123456789ABCDEFGHJKMNPQRS"},
+		},
+		{
+			"another table",
+			&replication.RowsEvent{Table: &replication.TableMapEvent{Schema: []byte("practice"), Table: []byte("credential")}, Rows: [][]interface{}{
+				{"0123456789ABCDEFGHJKMNPQRS", "$2a$10$hash"},
+			}},
+			nil,
+		},
+		{
+			"user table of another database",
+			&replication.RowsEvent{Table: &replication.TableMapEvent{Schema: []byte("practice_job2"), Table: []byte("user")}, Rows: [][]interface{}{
+				{"0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20)},
+			}},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, changedUserIDs(tt.event, "practice"))
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestBinlogPositionWithSQLMock(t *testing.T) {
+	columns := []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}
+
+	tests := []struct {
+		title       string
+		mock        func(sqlmock.Sqlmock)
+		expected    mysql.Position
+		expectedErr error
+	}{
+		{
+			"binary log status",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SHOW BINARY LOG STATUS")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("binlog.000002", "157", "", "", ""))
+			},
+			mysql.Position{Name: "binlog.000002", Pos: 157},
+			nil,
+		},
+		{
+			"master status of servers before MySQL 8.2",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SHOW BINARY LOG STATUS")).
+					WillReturnError(errors.New("You have an error in your SQL syntax"))
+				mock.ExpectQuery(regexp.QuoteMeta("SHOW MASTER STATUS")).
+					WillReturnRows(sqlmock.NewRows(columns[:4]).AddRow("mysql-bin.000003", "1024", "", ""))
+			},
+			mysql.Position{Name: "mysql-bin.000003", Pos: 1024},
+			nil,
+		},
+		{
+			"binlog is disabled",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SHOW BINARY LOG STATUS")).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			mysql.Position{},
+			ErrBinlogDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			pos, err := binlogPosition(context.TODO(), db)
+
+			// assert
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.expectedErr)
+			}
+			require.Equal(t, tt.expected, pos)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestNewBinlogReaderRejectsServerIDZero(t *testing.T) {
+	_, err := NewBinlogReader(context.TODO(), &ClientConfig{Port: 3306}, 0)
+
+	require.EqualError(t, err, "server id of binlog reader must not be 0")
+}
+
+// test using testcontainers
+func TestBinlogCacheInvalidationWithTestContainers(t *testing.T) {
+	// NOTE: MariaDB has a different flavor of the replication protocol
+	if !strings.HasPrefix(mysqlImage(), "mysql:") {
+		t.Skipf("%s does not support binlog reader of the harness", mysqlImage())
+	}
+
+	ctx := context.Background()
+	port := startContainer(ctx, t, withReplication(1))
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	require.NoError(t, Migrate(ctx, db))
+
+	cache := NewLRUUserCache(10, time.Hour)
+	r := NewBinlogCachedUserRepository(NewUserRepository(db), cache)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, r.Register(ctx, mike))
+
+	reader, err := NewBinlogReader(ctx, &ClientConfig{Port: port}, 100)
+	require.NoError(t, err)
+	defer reader.Close()
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- reader.Run(runCtx, InvalidateUserCache(cache)) }()
+	defer func() {
+		cancel()
+		require.ErrorIs(t, <-done, context.Canceled)
+	}()
+
+	// NOTE: cases run in order, and each writes out of band (not through the repository)
+	tests := []struct {
+		title    string
+		write    string
+		expected func(*testing.T, *User, error)
+	}{
+		{
+			"update is seen eventually",
+			"UPDATE `user` SET `age` = 21 WHERE `id` = ?",
+			func(t *testing.T, found *User, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 21, *found.Age)
+			},
+		},
+		{
+			"delete is seen eventually",
+			"DELETE FROM `user` WHERE `id` = ?",
+			func(t *testing.T, found *User, err error) {
+				require.ErrorIs(t, err, sql.ErrNoRows)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			_, err := r.Get(ctx, mike.ID)
+			require.NoError(t, err)
+			_, cached, _ := cache.Get(ctx, mike.ID)
+			require.True(t, cached, "user must be cached before the write")
+
+			// run
+			_, err = db.ExecContext(ctx, tt.write, mike.ID)
+			require.NoError(t, err)
+
+			// assert
+			require.Eventually(t, func() bool {
+				_, cached, _ := cache.Get(ctx, mike.ID)
+				return !cached
+			}, 10*time.Second, 50*time.Millisecond, "user is not invalidated after %s", tt.write)
+			found, err := r.Get(ctx, mike.ID)
+			tt.expected(t, found, err)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package gosqltests
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// NOTE: set GOSQLTESTS_BUDGET (e.g. GOSQLTESTS_BUDGET=5m) to limit the wall time of tests using real MySQL.
+// Tests which start containers (or use docker compose) after the budget is exceeded are skipped,
+// while tests using sqlmock or go-mysql-server always run because they finish quickly.
+const budgetEnv = "GOSQLTESTS_BUDGET"
+
+// testsStartedAt is when the test binary started.
+var testsStartedAt = time.Now()
+
+// overBudget reports whether elapsed exceeds budget. Empty budget means unlimited.
+func overBudget(budget string, elapsed time.Duration) (bool, error) {
+	if budget == "" {
+		return false, nil
+	}
+	d, err := time.ParseDuration(budget)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", budgetEnv, err)
+	}
+	return elapsed > d, nil
+}
+
+// skipIfOverBudget must be called before starting real MySQL.
+func skipIfOverBudget(t testing.TB) {
+	t.Helper()
+
+	budget := os.Getenv(budgetEnv)
+	elapsed := time.Since(testsStartedAt)
+	over, err := overBudget(budget, elapsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if over {
+		t.Skipf("skipped because the test budget is exceeded (%s=%s, elapsed: %s)", budgetEnv, budget, elapsed.Round(time.Second))
+	}
+}
+
+func TestOverBudget(t *testing.T) {
+	tests := []struct {
+		title       string
+		budget      string
+		elapsed     time.Duration
+		expected    bool
+		expectedErr string
+	}{
+		{"unlimited", "", time.Hour, false, ""},
+		{"within budget", "5m", 4 * time.Minute, false, ""},
+		{"over budget", "5m", 6 * time.Minute, true, ""},
+		{"invalid budget", "5", time.Minute, false, `invalid GOSQLTESTS_BUDGET: time: missing unit in duration "5"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual, err := overBudget(tt.budget, tt.elapsed)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// default age of users built by UserBuilder
+const defaultBuiltUserAge = 20
+
+// UserBuilder builds users for tests, so that each test only sets the fields it cares about.
+// By default, a user has a new ULID, a name unique by the id, the age of 20 and no email.
+//
+//	mike := NewUserBuilder().WithName("Mike").WithAge(20).Build()
+type UserBuilder struct {
+	user    User
+	deleted bool
+}
+
+func NewUserBuilder() *UserBuilder {
+	id := NewUserID().String()
+	age := defaultBuiltUserAge
+	return &UserBuilder{
+		user: User{
+			ID: id,
+			// NOTE: the random part of the id keeps names of users built in the same millisecond unique
+			Name: "user-" + strings.ToLower(id[len(id)-10:]),
+			Age:  &age,
+		},
+	}
+}
+
+func (b *UserBuilder) WithID(id string) *UserBuilder {
+	b.user.ID = id
+	return b
+}
+
+func (b *UserBuilder) WithName(name string) *UserBuilder {
+	b.user.Name = name
+	return b
+}
+
+func (b *UserBuilder) WithAge(age int) *UserBuilder {
+	b.user.Age = &age
+	return b
+}
+
+// WithoutAge builds a user whose age is unknown (NULL).
+func (b *UserBuilder) WithoutAge() *UserBuilder {
+	b.user.Age = nil
+	return b
+}
+
+func (b *UserBuilder) WithEmail(email string) *UserBuilder {
+	b.user.Email = email
+	return b
+}
+
+// Deleted makes Persist soft-delete the user after registering it. Build ignores it.
+func (b *UserBuilder) Deleted() *UserBuilder {
+	b.deleted = true
+	return b
+}
+
+// Build returns a new user, which does not share the age with users built before.
+func (b *UserBuilder) Build() *User {
+	return copyUser(&b.user)
+}
+
+// Persist registers the built user by NewUserRepository and returns it.
+func (b *UserBuilder) Persist(ctx context.Context, db *sql.DB) (*User, error) {
+	user := b.Build()
+	r := NewUserRepository(db)
+	if err := r.Register(ctx, user); err != nil {
+		return nil, fmt.Errorf("failed to persist built user: %w", err)
+	}
+	if b.deleted {
+		if err := r.Delete(ctx, user); err != nil {
+			return nil, fmt.Errorf("failed to persist built user: %w", err)
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,96 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+func TestUserBuilder(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		user := NewUserBuilder().Build()
+
+		_, err := ParseUserID(user.ID)
+		require.NoError(t, err)
+		require.Regexp(t, `^user-[0-9a-z]{10}$`, user.Name)
+		require.Equal(t, lo.ToPtr(20), user.Age)
+		require.Empty(t, user.Email)
+	})
+
+	t.Run("all fields", func(t *testing.T) {
+		user := NewUserBuilder().
+			WithID("0123456789ABCDEFGHJKMNPQRS").
+			WithName("Mike").
+			WithAge(25).
+			WithEmail("mike@example.com").
+			Build()
+
+		require.Equal(t, &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(25), Email: "mike@example.com"}, user)
+	})
+
+	t.Run("without age", func(t *testing.T) {
+		user := NewUserBuilder().WithoutAge().Build()
+
+		require.Nil(t, user.Age)
+	})
+
+	t.Run("users of different builders are unique", func(t *testing.T) {
+		a := NewUserBuilder().Build()
+		b := NewUserBuilder().Build()
+
+		require.NotEqual(t, a.ID, b.ID)
+		require.NotEqual(t, a.Name, b.Name)
+	})
+
+	t.Run("built users are independent", func(t *testing.T) {
+		builder := NewUserBuilder()
+		a := builder.Build()
+		*a.Age = 30
+		b := builder.WithName("Mike").Build()
+
+		require.Equal(t, lo.ToPtr(20), b.Age)
+		require.NotEqual(t, a.Name, b.Name)
+	})
+}
+
+// test using go-mysql-server
+func TestUserBuilderPersistWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	r := NewUserRepository(db)
+
+	t.Run("persist a user", func(t *testing.T) {
+		user, err := NewUserBuilder().WithName("Mike").WithEmail("mike@example.com").Persist(ctx, db)
+
+		require.NoError(t, err)
+		actual, err := r.Get(ctx, user.ID)
+		require.NoError(t, err)
+		require.Equal(t, user, actual)
+	})
+
+	t.Run("persist a deleted user", func(t *testing.T) {
+		user, err := NewUserBuilder().Deleted().Persist(ctx, db)
+
+		require.NoError(t, err)
+		_, err = r.Get(ctx, user.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("user which cannot be registered", func(t *testing.T) {
+		_, err := NewUserBuilder().WithID("invalid").Persist(ctx, db)
+
+		require.ErrorIs(t, err, ErrInvalidUserID)
+		require.ErrorContains(t, err, "failed to persist built user")
+	})
+}
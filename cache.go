@@ -0,0 +1,253 @@
+package gosqltests
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// UserCache stores users by id for NewCachedUserRepository.
+// It is an interface so that a cache shared among processes (e.g. Redis) can replace the in-memory one.
+type UserCache interface {
+	// Get returns false if the user is not cached.
+	Get(ctx context.Context, id string) (*User, bool, error)
+	Set(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id string) error
+}
+
+// lruUserCache keeps up to size users for ttl each, evicting the least recently used one.
+type lruUserCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	clock Clock
+	// entries are *lruEntry, from the most recently used
+	entries *list.List
+	index   map[string]*list.Element
+}
+
+type lruEntry struct {
+	user      *User
+	expiresAt time.Time
+}
+
+// NewLRUUserCache returns an in-memory UserCache of up to size users, each of which expires after ttl.
+func NewLRUUserCache(size int, ttl time.Duration) *lruUserCache {
+	return newLRUUserCache(size, ttl, systemClock{})
+}
+
+func newLRUUserCache(size int, ttl time.Duration, clock Clock) *lruUserCache {
+	return &lruUserCache{
+		size:    size,
+		ttl:     ttl,
+		clock:   clock,
+		entries: list.New(),
+		index:   map[string]*list.Element{},
+	}
+}
+
+var _ UserCache = (*lruUserCache)(nil)
+
+func (c *lruUserCache) Get(ctx context.Context, id string) (*User, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.index[id]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := e.Value.(*lruEntry)
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.remove(e)
+		return nil, false, nil
+	}
+	c.entries.MoveToFront(e)
+	// NOTE: a copy is returned so that callers cannot modify the cached user
+	return copyUser(entry.user), true, nil
+}
+
+func (c *lruUserCache) Set(ctx context.Context, user *User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{user: copyUser(user), expiresAt: c.clock.Now().Add(c.ttl)}
+	if e, ok := c.index[user.ID]; ok {
+		e.Value = entry
+		c.entries.MoveToFront(e)
+		return nil
+	}
+	c.index[user.ID] = c.entries.PushFront(entry)
+	for c.entries.Len() > c.size {
+		c.remove(c.entries.Back())
+	}
+	return nil
+}
+
+func (c *lruUserCache) Delete(ctx context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.index[id]; ok {
+		c.remove(e)
+	}
+	return nil
+}
+
+func (c *lruUserCache) remove(e *list.Element) {
+	c.entries.Remove(e)
+	delete(c.index, e.Value.(*lruEntry).user.ID)
+}
+
+// Len returns the number of cached users including expired ones.
+func (c *lruUserCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+// cacheableUserRepository is UserRepository with the other writes of userRepository, which invalidate the cache.
+type cacheableUserRepository interface {
+	UserRepository
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Upsert(ctx context.Context, user *User, updateColumns ...string) error
+	Update(ctx context.Context, user *VersionedUser) error
+	HardDelete(ctx context.Context, user *User) error
+	Restore(ctx context.Context, id string) error
+}
+
+// cachedUserRepository reads users of Get from the cache, which writes invalidate.
+// NOTE: only Get is cached because the other reads find users by conditions, which cannot be invalidated by id
+type cachedUserRepository struct {
+	cacheableUserRepository
+	cache UserCache
+	// byBinlog leaves invalidation to InvalidateUserCache run by a BinlogReader instead of writes through the repository
+	byBinlog bool
+}
+
+// NewCachedUserRepository caches users of Get in cache.
+// Writes through it invalidate the users they change, but writes by other clients are seen only after the cache expires.
+func NewCachedUserRepository(repo cacheableUserRepository, cache UserCache) *cachedUserRepository {
+	return &cachedUserRepository{
+		cacheableUserRepository: repo,
+		cache:                   cache,
+	}
+}
+
+// NewBinlogCachedUserRepository caches users of Get in cache as NewCachedUserRepository, but its writes do not invalidate the cache.
+// Instead, run a BinlogReader with InvalidateUserCache(cache), which removes users changed by any client
+// (including raw SQL and other processes) after they are written to the binlog.
+// NOTE: a user changed while Get reads it may be cached as it was before the change until it expires
+func NewBinlogCachedUserRepository(repo cacheableUserRepository, cache UserCache) *cachedUserRepository {
+	return &cachedUserRepository{
+		cacheableUserRepository: repo,
+		cache:                   cache,
+		byBinlog:                true,
+	}
+}
+
+// InvalidateUserCache is a handler of BinlogReader.Run which removes changed users from cache.
+func InvalidateUserCache(cache UserCache) UserChangeHandler {
+	return func(ctx context.Context, ids []string) error {
+		for _, id := range ids {
+			if err := cache.Delete(ctx, id); err != nil {
+				return fmt.Errorf("failed to invalidate cached user (id: %s): %w", id, err)
+			}
+		}
+		return nil
+	}
+}
+
+var _ UserRepository = (*cachedUserRepository)(nil)
+
+func (r *cachedUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	// NOTE: errors of the cache are ignored because the database has every user
+	if user, ok, err := r.cache.Get(ctx, id); err == nil && ok {
+		return user, nil
+	}
+
+	user, err := r.cacheableUserRepository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	_ = r.cache.Set(ctx, user)
+	return user, nil
+}
+
+func (r *cachedUserRepository) Register(ctx context.Context, user *User) error {
+	// NOTE: the id is read after Register because it may be generated
+	err := r.cacheableUserRepository.Register(ctx, user)
+	return r.invalidate(ctx, err, user.ID)
+}
+
+// Upsert invalidates the user of the same id, name or email, which may have been updated.
+func (r *cachedUserRepository) Upsert(ctx context.Context, user *User, updateColumns ...string) error {
+	err := r.cacheableUserRepository.Upsert(ctx, user, updateColumns...)
+	if r.byBinlog {
+		return err
+	}
+
+	ids, findErr := r.conflictingIDs(ctx, user)
+	if err == nil && findErr != nil {
+		err = fmt.Errorf("failed to find upserted user to invalidate cache: %w", findErr)
+	}
+	return r.invalidate(ctx, err, append(ids, user.ID)...)
+}
+
+// conflictingIDs returns ids of users of the same name or email as user.
+// NOTE: they are found after Upsert, because the name and email are still the same as user's even if updated
+func (r *cachedUserRepository) conflictingIDs(ctx context.Context, user *User) ([]string, error) {
+	finds := []func() (*User, error){
+		func() (*User, error) { return r.cacheableUserRepository.GetByName(ctx, user.Name) },
+	}
+	if user.Email != "" {
+		finds = append(finds, func() (*User, error) { return r.cacheableUserRepository.GetByEmail(ctx, user.Email) })
+	}
+
+	var ids []string
+	for _, find := range finds {
+		found, err := find()
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, found.ID)
+	}
+	return ids, nil
+}
+
+func (r *cachedUserRepository) Delete(ctx context.Context, user *User) error {
+	return r.invalidate(ctx, r.cacheableUserRepository.Delete(ctx, user), user.ID)
+}
+
+func (r *cachedUserRepository) Update(ctx context.Context, user *VersionedUser) error {
+	return r.invalidate(ctx, r.cacheableUserRepository.Update(ctx, user), user.ID)
+}
+
+func (r *cachedUserRepository) HardDelete(ctx context.Context, user *User) error {
+	return r.invalidate(ctx, r.cacheableUserRepository.HardDelete(ctx, user), user.ID)
+}
+
+func (r *cachedUserRepository) Restore(ctx context.Context, id string) error {
+	return r.invalidate(ctx, r.cacheableUserRepository.Restore(ctx, id), id)
+}
+
+// invalidate removes users of ids from the cache and returns err of the write, or the error of the cache if the write succeeded.
+// It leaves the cache as it is if the binlog invalidates the cache.
+// NOTE: users are removed even if the write failed, because it may have been executed (e.g. the connection was lost)
+func (r *cachedUserRepository) invalidate(ctx context.Context, err error, ids ...string) error {
+	if r.byBinlog {
+		return err
+	}
+	for _, id := range ids {
+		if cacheErr := r.cache.Delete(ctx, id); cacheErr != nil && err == nil {
+			err = fmt.Errorf("failed to invalidate cached user (id: %s): %w", id, cacheErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,334 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUUserCache(t *testing.T) {
+	ctx := context.TODO()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	mary := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)}
+
+	t.Run("least recently used user is evicted", func(t *testing.T) {
+		c := newLRUUserCache(2, time.Minute, newFakeClock())
+		require.NoError(t, c.Set(ctx, mike))
+		require.NoError(t, c.Set(ctx, bob))
+		_, ok, _ := c.Get(ctx, mike.ID)
+		require.True(t, ok)
+
+		// run
+		require.NoError(t, c.Set(ctx, mary))
+
+		// assert
+		require.Equal(t, 2, c.Len())
+		_, ok, _ = c.Get(ctx, bob.ID)
+		require.False(t, ok)
+		for _, u := range []*User{mike, mary} {
+			cached, ok, err := c.Get(ctx, u.ID)
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, u, cached)
+		}
+	})
+
+	t.Run("user expires after ttl", func(t *testing.T) {
+		clock := newFakeClock()
+		c := newLRUUserCache(2, time.Minute, clock)
+		require.NoError(t, c.Set(ctx, mike))
+
+		// run
+		<-clock.After(59 * time.Second)
+		_, beforeTTL, _ := c.Get(ctx, mike.ID)
+		<-clock.After(time.Second)
+		_, afterTTL, _ := c.Get(ctx, mike.ID)
+
+		// assert
+		require.True(t, beforeTTL)
+		require.False(t, afterTTL)
+		require.Equal(t, 0, c.Len())
+	})
+
+	t.Run("set refreshes the user and ttl", func(t *testing.T) {
+		clock := newFakeClock()
+		c := newLRUUserCache(2, time.Minute, clock)
+		require.NoError(t, c.Set(ctx, mike))
+		<-clock.After(30 * time.Second)
+
+		// run
+		require.NoError(t, c.Set(ctx, &User{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(21)}))
+		<-clock.After(45 * time.Second)
+
+		// assert
+		cached, ok, _ := c.Get(ctx, mike.ID)
+		require.True(t, ok)
+		require.Equal(t, 21, *cached.Age)
+		require.Equal(t, 1, c.Len())
+	})
+
+	t.Run("cached user is a copy", func(t *testing.T) {
+		c := newLRUUserCache(2, time.Minute, newFakeClock())
+		u := copyUser(mike)
+		require.NoError(t, c.Set(ctx, u))
+
+		// run
+		*u.Age = 99
+		cached, _, _ := c.Get(ctx, mike.ID)
+		*cached.Age = 98
+
+		// assert
+		again, _, _ := c.Get(ctx, mike.ID)
+		require.Equal(t, mike, again)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c := newLRUUserCache(2, time.Minute, newFakeClock())
+		require.NoError(t, c.Set(ctx, mike))
+
+		// run
+		require.NoError(t, c.Delete(ctx, mike.ID))
+		require.NoError(t, c.Delete(ctx, bob.ID))
+
+		// assert
+		_, ok, _ := c.Get(ctx, mike.ID)
+		require.False(t, ok)
+		require.Equal(t, 0, c.Len())
+	})
+}
+
+// test using go-mysql-server
+func TestCachedUserRepositoryWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	updated := &User{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(21)}
+
+	// simulator
+	// NOTE: tables are created by migrations to have the unique key of name
+	port := prepareMigratedSimulator(ctx, t)
+	log := &queryLog{}
+	db, err := newQueryLoggedClient(port, log)
+	require.NoError(t, err)
+	// NOTE: writes by direct bypass the cache, as if they were done by another process
+	direct := NewUserRepository(db)
+	require.NoError(t, direct.Register(ctx, mike))
+	clock := newFakeClock()
+	r := NewCachedUserRepository(NewUserRepository(db), newLRUUserCache(10, time.Minute, clock))
+
+	// NOTE: cases run in order on the same repository
+	tests := []struct {
+		title string
+		write func() error
+		// expected is nil if the user is not found
+		expected *User
+		// expectedSelects is the number of SELECT by two Get
+		expectedSelects int
+	}{
+		{
+			"first Get reads the database",
+			nil,
+			mike,
+			1,
+		},
+		{
+			"cached user is read",
+			nil,
+			mike,
+			0,
+		},
+		{
+			"upsert of the same name invalidates the user",
+			func() error {
+				return r.Upsert(ctx, &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: mike.Name, Age: lo.ToPtr(21)})
+			},
+			updated,
+			1,
+		},
+		{
+			"deleted user is not cached",
+			func() error { return r.Delete(ctx, mike) },
+			nil,
+			2,
+		},
+		{
+			"restore invalidates the user",
+			func() error { return r.Restore(ctx, mike.ID) },
+			updated,
+			1,
+		},
+		{
+			"write bypassing the cache is not seen until the user expires",
+			func() error { return direct.Upsert(ctx, &User{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(30)}) },
+			updated,
+			0,
+		},
+		{
+			"expired user is read again",
+			func() error {
+				<-clock.After(time.Minute)
+				return nil
+			},
+			&User{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(30)},
+			1,
+		},
+		{
+			"update invalidates the user",
+			func() error {
+				user, err := direct.GetVersioned(ctx, mike.ID)
+				if err != nil {
+					return err
+				}
+				user.Age = lo.ToPtr(31)
+				return r.Update(ctx, user)
+			},
+			&User{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(31)},
+			1,
+		},
+		{
+			"hard delete invalidates the user",
+			func() error { return r.HardDelete(ctx, mike) },
+			nil,
+			2,
+		},
+		{
+			"register invalidates the user",
+			func() error { return r.Register(ctx, mike) },
+			mike,
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			if tt.write != nil {
+				require.NoError(t, tt.write())
+			}
+			log.reset()
+
+			// run
+			for i := 0; i < 2; i++ {
+				found, err := r.Get(ctx, mike.ID)
+
+				// assert
+				if tt.expected == nil {
+					require.ErrorIs(t, err, sql.ErrNoRows)
+				} else {
+					require.NoError(t, err)
+					// NOTE: writes of the cases set timestamps of users
+					require.Equal(t, withoutTimestamps(tt.expected), withoutTimestamps(found))
+				}
+			}
+			require.Equal(t, tt.expectedSelects, log.count("SELECT"))
+		})
+	}
+}
+
+// failingUserCache fails every operation.
+type failingUserCache struct{}
+
+func (failingUserCache) Get(ctx context.Context, id string) (*User, bool, error) {
+	return nil, false, errors.New("cache is down")
+}
+
+func (failingUserCache) Set(ctx context.Context, user *User) error {
+	return errors.New("cache is down")
+}
+
+func (failingUserCache) Delete(ctx context.Context, id string) error {
+	return errors.New("cache is down")
+}
+
+// test using go-sqlmock
+func TestCachedUserRepositoryCacheErrorsWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	t.Run("get reads the database", func(t *testing.T) {
+		// mock
+		db, mock := prepareMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1")).
+			WithArgs(mike.ID).
+			WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow(mike.ID, mike.Name, *mike.Age, nil, nil, 1, time.Time{}, time.Time{}))
+
+		// run
+		found, err := NewCachedUserRepository(NewUserRepository(db), failingUserCache{}).Get(context.TODO(), mike.ID)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, mike, found)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("write fails if the user cannot be invalidated", func(t *testing.T) {
+		// mock
+		db, mock := prepareMockDB(t)
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user` WHERE `id`=?")).
+			WithArgs(mike.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		// run
+		err := NewCachedUserRepository(NewUserRepository(db), failingUserCache{}).HardDelete(context.TODO(), mike)
+
+		// assert
+		require.EqualError(t, err, "failed to invalidate cached user (id: 0123456789ABCDEFGHJKMNPQRS): cache is down")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// test using go-mysql-server
+func TestBinlogCachedUserRepositoryWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	updated := &User{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(21)}
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	log := &queryLog{}
+	db, err := newQueryLoggedClient(port, log)
+	require.NoError(t, err)
+	cache := newLRUUserCache(10, time.Minute, newFakeClock())
+	r := NewBinlogCachedUserRepository(NewUserRepository(db), cache)
+	require.NoError(t, r.Register(ctx, mike))
+	_, err = r.Get(ctx, mike.ID)
+	require.NoError(t, err)
+
+	t.Run("writes do not invalidate the cache", func(t *testing.T) {
+		require.NoError(t, r.Upsert(ctx, updated))
+		log.reset()
+
+		// run
+		found, err := r.Get(ctx, mike.ID)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, mike, found)
+		require.Equal(t, 0, log.count("SELECT"))
+	})
+
+	t.Run("changes of the binlog invalidate the cache", func(t *testing.T) {
+		// NOTE: the binlog reader would call the handler after the upsert is committed
+		require.NoError(t, InvalidateUserCache(cache)(ctx, []string{mike.ID, "1123456789ABCDEFGHJKMNPQRS"}))
+		log.reset()
+
+		// run
+		found, err := r.Get(ctx, mike.ID)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, updated, found)
+		require.Equal(t, 1, log.count("SELECT"))
+	})
+}
+
+func TestInvalidateUserCacheFails(t *testing.T) {
+	err := InvalidateUserCache(failingUserCache{})(context.TODO(), []string{"0123456789ABCDEFGHJKMNPQRS"})
+
+	require.EqualError(t, err, "failed to invalidate cached user (id: 0123456789ABCDEFGHJKMNPQRS): cache is down")
+}
@@ -0,0 +1,151 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/chaosproxy"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// prepareChaosProxy starts a proxy of the server on the port, which injects network faults into connections through it.
+func prepareChaosProxy(t testing.TB, port int) *chaosproxy.Proxy {
+	p, err := chaosproxy.New(fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatalf("failed to start proxy: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := p.Close(); err != nil {
+			t.Errorf("failed to close proxy: %s", err)
+		}
+	})
+	return p
+}
+
+// assertResilience checks timeouts and retries of the repository while the proxy injects faults
+// into connections to the migrated database of db.
+func assertResilience(ctx context.Context, t *testing.T, db *sql.DB, proxy *chaosproxy.Proxy) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	users := generateUsers(t, 100)
+	require.NoError(t, NewUserRepository(db).RegisterAll(ctx, append(users, mike)))
+
+	client, err := NewStrictClient(proxy.Port())
+	require.NoError(t, err)
+	defer closeTestClient(t, client)
+
+	t.Run("latency exceeds the timeout", func(t *testing.T) {
+		defer proxy.Reset()
+		proxy.SetLatency(500 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		// run
+		_, err := NewUserRepository(client).Get(ctx, mike.ID)
+
+		// assert
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("connection dropped during a read is retried", func(t *testing.T) {
+		defer proxy.Reset()
+		r := NewUserRepository(client, WithReadRetry(3, 10*time.Millisecond))
+		// NOTE: the latency keeps the query in flight while its connection is dropped
+		proxy.SetLatency(200 * time.Millisecond)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			proxy.DropConnections()
+			proxy.SetLatency(0)
+		}()
+
+		// run
+		found, err := r.Get(ctx, mike.ID)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, mike, found)
+	})
+
+	t.Run("connection dropped during a read fails without retries", func(t *testing.T) {
+		defer proxy.Reset()
+		proxy.SetLatency(200 * time.Millisecond)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			proxy.DropConnections()
+		}()
+
+		// run
+		_, err := NewUserRepository(client).Get(ctx, mike.ID)
+
+		// assert
+		require.Error(t, err)
+		require.True(t, IsTransient(err), "transient error: %v", err)
+	})
+
+	t.Run("bandwidth slows down reads", func(t *testing.T) {
+		defer proxy.Reset()
+		proxy.SetBandwidth(20000)
+
+		// run
+		start := time.Now()
+		actual, _, err := NewUserRepository(client).List(ctx, nil)
+
+		// assert
+		require.NoError(t, err)
+		require.Len(t, actual, len(users)+1)
+		// NOTE: each row has more than 40 bytes
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("database is down and recovers", func(t *testing.T) {
+		defer proxy.Reset()
+		checker := NewHealthChecker(client, nil)
+		proxy.Disable()
+
+		// run
+		err := checker.Health(ctx)
+
+		// assert
+		require.Error(t, err)
+		proxy.Enable()
+		require.NoError(t, checker.WaitHealthy(ctx, 5*time.Second))
+		found, err := NewUserRepository(client).Get(ctx, mike.ID)
+		require.NoError(t, err)
+		require.Equal(t, mike, found)
+	})
+}
+
+// test using go-mysql-server
+func TestResilienceWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	proxy := prepareChaosProxy(t, port)
+
+	assertResilience(context.Background(), t, db, proxy)
+}
+
+// test using testcontainers
+func TestResilienceWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	port := startContainer(ctx, t, withFastMySQL())
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, StrictScan: true})
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	require.NoError(t, Migrate(ctx, db))
+
+	proxy := prepareChaosProxy(t, port)
+
+	assertResilience(ctx, t, db, proxy)
+}
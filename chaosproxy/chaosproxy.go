@@ -0,0 +1,250 @@
+// Package chaosproxy forwards TCP connections to a server (e.g. MySQL started by tests) and injects network faults
+// such as latency, bandwidth limits and dropped connections, which can be changed while the connections are in use.
+package chaosproxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// size of the buffer of each direction of a connection
+const bufferSize = 32 * 1024
+
+// number of chunks per second into which data are split under a bandwidth limit, so that transfers slow down smoothly
+const chunksPerSecond = 10
+
+// Proxy forwards connections accepted on localhost to the upstream.
+type Proxy struct {
+	listener net.Listener
+	upstream string
+
+	mu        sync.Mutex
+	latency   time.Duration
+	bandwidth int
+	disabled  bool
+	closed    bool
+	links     map[*link]struct{}
+
+	wg sync.WaitGroup
+}
+
+// link is a pair of a connection from a client and the one to the upstream.
+type link struct {
+	client, server net.Conn
+}
+
+func (l *link) close() {
+	l.client.Close()
+	l.server.Close()
+}
+
+// New starts a proxy of the upstream (host:port) on a free port of localhost.
+func New(upstream string) (*Proxy, error) {
+	l, err := net.Listen("tcp4", "localhost:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	p := &Proxy{
+		listener: l,
+		upstream: upstream,
+		links:    map[*link]struct{}{},
+	}
+	p.wg.Add(1)
+	go p.serve()
+	return p, nil
+}
+
+// Port returns the port which clients connect to instead of the upstream.
+func (p *Proxy) Port() int {
+	return p.listener.Addr().(*net.TCPAddr).Port
+}
+
+// SetLatency delays each chunk of data read from either side by d (no delay if 0).
+// NOTE: the delay is added to every read, so a response split into several reads is delayed several times
+func (p *Proxy) SetLatency(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency = d
+}
+
+// SetBandwidth limits each direction of each connection to bytesPerSecond (unlimited if 0).
+func (p *Proxy) SetBandwidth(bytesPerSecond int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bandwidth = bytesPerSecond
+}
+
+// DropConnections closes all open connections on both sides and returns how many were closed.
+// New connections are accepted as usual.
+func (p *Proxy) DropConnections() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dropLocked()
+}
+
+// Disable drops all open connections and closes new connections as soon as they are accepted, as if the upstream were down.
+func (p *Proxy) Disable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.disabled = true
+	p.dropLocked()
+}
+
+// Enable forwards new connections again after Disable.
+func (p *Proxy) Enable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.disabled = false
+}
+
+// Reset removes all faults. Open connections are kept.
+func (p *Proxy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency = 0
+	p.bandwidth = 0
+	p.disabled = false
+}
+
+// Close stops accepting connections, drops open ones and waits for them to finish.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.dropLocked()
+	p.mu.Unlock()
+
+	err := p.listener.Close()
+	p.wg.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to close proxy: %w", err)
+	}
+	return nil
+}
+
+func (p *Proxy) dropLocked() int {
+	n := len(p.links)
+	for l := range p.links {
+		l.close()
+		delete(p.links, l)
+	}
+	return n
+}
+
+func (p *Proxy) serve() {
+	defer p.wg.Done()
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			// NOTE: the listener is closed by Close
+			return
+		}
+		p.wg.Add(1)
+		go p.handle(client)
+	}
+}
+
+func (p *Proxy) handle(client net.Conn) {
+	defer p.wg.Done()
+
+	if p.isDisabled() {
+		client.Close()
+		return
+	}
+	server, err := net.Dial("tcp", p.upstream)
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	l := &link{client: client, server: server}
+	if !p.add(l) {
+		l.close()
+		return
+	}
+	defer p.remove(l)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		p.pipe(server, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		p.pipe(client, server)
+		done <- struct{}{}
+	}()
+
+	// NOTE: a connection closed by one side is closed on the other side as well
+	<-done
+	l.close()
+	<-done
+}
+
+func (p *Proxy) isDisabled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.disabled || p.closed
+}
+
+// add registers the link unless the proxy has been disabled or closed since it was accepted.
+func (p *Proxy) add(l *link) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.disabled || p.closed {
+		return false
+	}
+	p.links[l] = struct{}{}
+	return true
+}
+
+func (p *Proxy) remove(l *link) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.links, l)
+}
+
+func (p *Proxy) faults() (time.Duration, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latency, p.bandwidth
+}
+
+// pipe copies data from src to dst with the faults at the time each chunk is read.
+func (p *Proxy) pipe(dst, src net.Conn) {
+	buf := make([]byte, bufferSize)
+	for {
+		_, bandwidth := p.faults()
+		n, err := src.Read(buf[:chunkSize(bandwidth)])
+		if n > 0 {
+			latency, bandwidth := p.faults()
+			time.Sleep(latency + transferTime(n, bandwidth))
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// chunkSize returns the maximum size of each read under the bandwidth.
+func chunkSize(bandwidth int) int {
+	if bandwidth <= 0 || bandwidth/chunksPerSecond >= bufferSize {
+		return bufferSize
+	}
+	if bandwidth < chunksPerSecond {
+		return 1
+	}
+	return bandwidth / chunksPerSecond
+}
+
+// transferTime returns how long n bytes take under the bandwidth.
+func transferTime(n, bandwidth int) time.Duration {
+	if bandwidth <= 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second / time.Duration(bandwidth)
+}
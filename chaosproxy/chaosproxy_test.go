@@ -0,0 +1,171 @@
+package chaosproxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startEchoServer starts a server which writes back everything it reads.
+func startEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp4", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func prepareProxy(t *testing.T) *Proxy {
+	p, err := New(startEchoServer(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, p.Close()) })
+	return p
+}
+
+func dialProxy(t *testing.T, p *Proxy) net.Conn {
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", p.Port()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// echo sends data and reads them back.
+func echo(conn net.Conn, data []byte) ([]byte, error) {
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+	res := make([]byte, len(data))
+	_, err := io.ReadFull(conn, res)
+	return res, err
+}
+
+func TestProxyForwards(t *testing.T) {
+	p := prepareProxy(t)
+	conn := dialProxy(t, p)
+
+	// run
+	res, err := echo(conn, []byte("hello"))
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(res))
+}
+
+func TestProxyLatency(t *testing.T) {
+	p := prepareProxy(t)
+	conn := dialProxy(t, p)
+	p.SetLatency(50 * time.Millisecond)
+
+	// run
+	start := time.Now()
+	_, err := echo(conn, []byte("hello"))
+
+	// assert
+	require.NoError(t, err)
+	// NOTE: the request and the response are delayed respectively
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	p.Reset()
+	start = time.Now()
+	_, err = echo(conn, []byte("hello"))
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestProxyBandwidth(t *testing.T) {
+	p := prepareProxy(t)
+	conn := dialProxy(t, p)
+	p.SetBandwidth(10000)
+	data := bytes.Repeat([]byte("a"), 2000)
+
+	// run
+	start := time.Now()
+	res, err := echo(conn, data)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, data, res)
+	// NOTE: 2000 bytes at 10000 bytes/s take 200ms in each direction, which overlap chunk by chunk
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestProxyDropConnections(t *testing.T) {
+	p := prepareProxy(t)
+	conn := dialProxy(t, p)
+	_, err := echo(conn, []byte("hello"))
+	require.NoError(t, err)
+
+	// run
+	dropped := p.DropConnections()
+
+	// assert
+	require.Equal(t, 1, dropped)
+	_, err = echo(conn, []byte("hello"))
+	require.Error(t, err)
+
+	// new connections are forwarded as usual
+	res, err := echo(dialProxy(t, p), []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(res))
+}
+
+func TestProxyDisable(t *testing.T) {
+	p := prepareProxy(t)
+	conn := dialProxy(t, p)
+	_, err := echo(conn, []byte("hello"))
+	require.NoError(t, err)
+
+	// run
+	p.Disable()
+
+	// assert
+	_, err = echo(conn, []byte("hello"))
+	require.Error(t, err)
+	_, err = echo(dialProxy(t, p), []byte("hello"))
+	require.Error(t, err)
+
+	p.Enable()
+	res, err := echo(dialProxy(t, p), []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(res))
+}
+
+func TestChunkSize(t *testing.T) {
+	tests := []struct {
+		title     string
+		bandwidth int
+		expected  int
+	}{
+		{"unlimited", 0, bufferSize},
+		{"larger than the buffer", 10 * bufferSize * chunksPerSecond, bufferSize},
+		{"split into chunks", 10000, 1000},
+		{"lower than chunks per second", 5, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual := chunkSize(tt.bandwidth)
+
+			// assert
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -1,18 +1,259 @@
 package gosqltests
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	// NOTE: used for mysql client plugin
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ER_CON_COUNT_ERROR
+const mysqlErrTooManyConnections = 1040
+
+// ErrLeakedConnections is returned by CloseClient if connections are still in use after the deadline.
+var ErrLeakedConnections = errors.New("connections are leaked")
+
+// LeakedConnectionsError reports connections which were not returned to the pool,
+// e.g. by rows which are not closed or transactions which are neither committed nor rolled back.
+type LeakedConnectionsError struct {
+	InUse int
+}
+
+func (e *LeakedConnectionsError) Error() string {
+	return fmt.Sprintf("%s: %d connections are still in use", ErrLeakedConnections, e.InUse)
+}
+
+func (e *LeakedConnectionsError) Is(target error) bool {
+	return target == ErrLeakedConnections
+}
+
+// IsTooManyConnections reports whether the server refused the connection because max_connections is reached.
+func IsTooManyConnections(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrTooManyConnections
+}
+
+// defaults of the server created by docker-compose.yml
+const (
+	defaultHost     = "localhost"
+	defaultUser     = "root"
+	defaultDatabase = "practice"
+)
+
+func dsn(port int, database string) string {
+	return (&ClientConfig{Port: port, Database: database}).mysqlConfig().FormatDSN()
+}
+
 func NewClient(port int) (*sql.DB, error) {
-	// TODO: make this configurable
-	db, err := sql.Open("mysql", fmt.Sprintf("root:@(localhost:%d)/practice", port))
+	return newClient(port, defaultDatabase)
+}
+
+// connectorWrapper adds a feature (e.g. tracing) to connections.
+type connectorWrapper func(driver.Connector) driver.Connector
+
+func newClient(port int, database string, wrappers ...connectorWrapper) (*sql.DB, error) {
+	return openClient(&ClientConfig{Port: port, Database: database}, wrappers...)
+}
+
+func openClient(cfg *ClientConfig, wrappers ...connectorWrapper) (*sql.DB, error) {
+	var connector driver.Connector
+	connector, err := mysql.NewConnector(cfg.mysqlConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MySQL client: %w", err)
 	}
+
+	for _, wrap := range wrappers {
+		connector = wrap(connector)
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// default values of ClientConfig
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 2 * time.Second
+	defaultWaitTimeout    = 30 * time.Second
+)
+
+type ClientConfig struct {
+	// Host, User and Password are "localhost", "root" and "" if empty, as docker-compose.yml does.
+	// See the config package to read them from environment variables or a file.
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// Database is the database to connect to ("practice" if empty).
+	// Use NamespacedDatabase to share one server among CI jobs.
+	Database string
+	// InitialBackoff is the first interval of pings, which is doubled up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// WaitTimeout is how long to wait for the database in total.
+	WaitTimeout time.Duration
+
+	// MaxOpenConns limits connections of the pool. Callers wait for a free connection if it is reached.
+	// Keep it below max_connections of the server, or connections fail with "Too many connections".
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime and ConnMaxIdleTime close connections which are older or idle longer than them.
+	// Keep ConnMaxLifetime below wait_timeout of the server, or pooled connections may be closed by the server.
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// TracerProvider enables tracing of queries if set.
+	TracerProvider trace.TracerProvider
+
+	// TLS is the name of the TLS config of connections: "true", "skip-verify", "preferred"
+	// or a name registered by RegisterTLSConfig (no TLS if empty). Managed MySQL (e.g. PlanetScale, Cloud SQL) requires TLS.
+	TLS string
+	// InterpolateParams interpolates arguments into statements in the client instead of preparing them on the server.
+	// It saves round trips, and is required by proxies which do not support prepared statements well (e.g. Vitess, ProxySQL).
+	InterpolateParams bool
+	// ConnectionAttributes are sent to the server on connect, which shows them in performance_schema.session_connect_attrs
+	// (e.g. the name of the service). Keys and values must not contain "," or ":".
+	ConnectionAttributes map[string]string
+
+	// StrictScan makes reading a row fail with ErrLossyScan if a value would be truncated or rounded in the repository,
+	// such as BIGINT into int on 32-bit platforms or DECIMAL into float64.
+	StrictScan bool
+}
+
+func (c *ClientConfig) mysqlConfig() *mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(valueOr(c.Host, defaultHost), strconv.Itoa(c.Port))
+	cfg.User = valueOr(c.User, defaultUser)
+	cfg.Passwd = c.Password
+	cfg.DBName = valueOr(c.Database, defaultDatabase)
+	cfg.ParseTime = true
+	cfg.TLSConfig = c.TLS
+	cfg.InterpolateParams = c.InterpolateParams
+	// NOTE: keys are sorted so that the DSN is stable
+	keys := lo.Keys(c.ConnectionAttributes)
+	sort.Strings(keys)
+	cfg.ConnectionAttributes = strings.Join(lo.Map(keys, func(k string, _ int) string {
+		return k + ":" + c.ConnectionAttributes[k]
+	}), ",")
+	return cfg
+}
+
+// RegisterTLSConfig registers the TLS config by the name, which ClientConfig.TLS selects,
+// e.g. to verify the certificate of the server by its CA. Names "true", "false", "skip-verify" and "preferred" are reserved.
+func RegisterTLSConfig(name string, cfg *tls.Config) error {
+	if err := mysql.RegisterTLSConfig(name, cfg); err != nil {
+		return fmt.Errorf("failed to register TLS config (name: %s): %w", name, err)
+	}
+	return nil
+}
+
+func valueOr(v, defaultValue string) string {
+	if v == "" {
+		return defaultValue
+	}
+	return v
+}
+
+// NewClientFromConfig returns a client of cfg without waiting for the database (see NewClientWithWait).
+func NewClientFromConfig(cfg *ClientConfig) (*sql.DB, error) {
+	var wrappers []connectorWrapper
+	if cfg.StrictScan {
+		wrappers = append(wrappers, withStrictScan(strconv.IntSize))
+	}
+	// NOTE: tracing is the outermost so that spans include errors of the other wrappers
+	if cfg.TracerProvider != nil {
+		wrappers = append(wrappers, withTracing(cfg.TracerProvider))
+	}
+
+	db, err := openClient(cfg, wrappers...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return db, nil
+}
+
+// NewClientWithWait returns a client after the database accepts connections.
+// NOTE: sql.Open does not connect to the database, so NewClient succeeds even if the database is not ready yet.
+func NewClientWithWait(ctx context.Context, cfg *ClientConfig) (*sql.DB, error) {
+	backoff := cfg.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	maxBackoff := cfg.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	timeout := cfg.WaitTimeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+
+	db, err := NewClientFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: the pool is not checked because nothing uses it yet
+	if err := NewHealthChecker(db, nil).waitHealthy(ctx, backoff, maxBackoff, timeout); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to wait for MySQL (port: %d): %w", cfg.Port, err)
+	}
 	return db, nil
 }
+
+// default values of CloseClient
+const (
+	defaultCloseTimeout = 10 * time.Second
+	closePollInterval   = 10 * time.Millisecond
+)
+
+// CloseClient closes the pool of db and waits until in-flight queries return their connections.
+// New queries fail as soon as it is called, while transactions which have begun can still be finished.
+// It waits until the deadline of ctx (10 seconds if ctx has none), and returns LeakedConnectionsError if connections are still in use.
+func CloseClient(ctx context.Context, db *sql.DB) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultCloseTimeout)
+		defer cancel()
+	}
+
+	// NOTE: connections in use are closed when they are returned to the closed pool
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close client: %w", err)
+	}
+
+	for {
+		inUse := db.Stats().InUse
+		if inUse == 0 {
+			return nil
+		}
+
+		select {
+		case <-time.After(closePollInterval):
+		case <-ctx.Done():
+			return &LeakedConnectionsError{InUse: inUse}
+		}
+	}
+}
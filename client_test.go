@@ -0,0 +1,271 @@
+package gosqltests
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// testCloseTimeout is how long tests wait for queries in flight when they close clients.
+const testCloseTimeout = 5 * time.Second
+
+// closeTestClient closes db at the end of a test, which fails if connections are leaked.
+func closeTestClient(t testing.TB, db *sql.DB) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testCloseTimeout)
+	defer cancel()
+	require.NoError(t, CloseClient(ctx, db))
+}
+
+// test using go-mysql-server
+func TestNewClientWithWaitWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	// NOTE: the server starts after the client begins to wait
+	// NOTE: the test waits for the server to start so that it is stopped by the cleanup of the test
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		time.Sleep(300 * time.Millisecond)
+		prepareSimulator(t, port)
+	}()
+	defer func() { <-started }()
+
+	// run
+	db, err := NewClientWithWait(context.TODO(), &ClientConfig{
+		Port:           port,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		WaitTimeout:    5 * time.Second,
+	})
+
+	// assert
+	require.NoError(t, err)
+	_, _, err = NewUserRepository(db).List(context.TODO(), nil)
+	require.NoError(t, err)
+}
+
+func TestNewClientWithWaitTimeout(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// run
+	start := time.Now()
+	_, err = NewClientWithWait(context.TODO(), &ClientConfig{
+		Port:           port,
+		InitialBackoff: 10 * time.Millisecond,
+		WaitTimeout:    200 * time.Millisecond,
+	})
+
+	// assert
+	require.ErrorContains(t, err, "failed to wait for MySQL")
+	require.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestClientConfigDSN(t *testing.T) {
+	tests := []struct {
+		title    string
+		cfg      *ClientConfig
+		expected string
+	}{
+		{
+			"defaults of docker-compose.yml",
+			&ClientConfig{Port: 3306},
+			"root@tcp(localhost:3306)/practice?parseTime=true",
+		},
+		{
+			"all fields",
+			&ClientConfig{Host: "db.example.com", Port: 13306, User: "app", Password: "p@ss:word", Database: "app"},
+			"app:p@ss:word@tcp(db.example.com:13306)/app?parseTime=true",
+		},
+		{
+			"ipv6 host",
+			&ClientConfig{Host: "::1", Port: 3306},
+			"root@tcp([::1]:3306)/practice?parseTime=true",
+		},
+		{
+			"managed MySQL",
+			&ClientConfig{Port: 3306, TLS: "skip-verify", InterpolateParams: true},
+			"root@tcp(localhost:3306)/practice?interpolateParams=true&parseTime=true&tls=skip-verify",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.cfg.mysqlConfig().FormatDSN())
+		})
+	}
+}
+
+func TestClientConfigConnectionAttributes(t *testing.T) {
+	cfg := &ClientConfig{Port: 3306, ConnectionAttributes: map[string]string{"service": "api", "env": "ci"}}
+
+	// run
+	actual := cfg.mysqlConfig()
+
+	// assert
+	// NOTE: FormatDSN of go-sql-driver/mysql v1.8 omits connectionAttributes, so the parsed DSN is compared instead
+	require.Equal(t, "env:ci,service:api", actual.ConnectionAttributes)
+	parsed, err := mysql.ParseDSN("root@tcp(localhost:3306)/practice?parseTime=true&connectionAttributes=env%3Aci%2Cservice%3Aapi")
+	require.NoError(t, err)
+	require.Equal(t, parsed.ConnectionAttributes, actual.ConnectionAttributes)
+}
+
+func TestRegisterTLSConfig(t *testing.T) {
+	// run
+	err := RegisterTLSConfig("gosqltests-test", &tls.Config{MinVersion: tls.VersionTLS12})
+
+	// assert
+	require.NoError(t, err)
+	_, err = NewClientFromConfig(&ClientConfig{Port: 3306, TLS: "gosqltests-test"})
+	require.NoError(t, err)
+
+	_, err = NewClientFromConfig(&ClientConfig{Port: 3306, TLS: "unknown"})
+	require.EqualError(t, err, "failed to create MySQL client: invalid value / unknown config name: unknown")
+	err = RegisterTLSConfig("skip-verify", &tls.Config{})
+	require.EqualError(t, err, "failed to register TLS config (name: skip-verify): key 'skip-verify' is reserved")
+}
+
+// test using go-mysql-server
+func TestNewClientFromConfigWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+
+	// run
+	db, err := NewClientFromConfig(&ClientConfig{Host: "127.0.0.1", Port: port, User: "root", MaxOpenConns: 2})
+
+	// assert
+	require.NoError(t, err)
+	defer db.Close()
+	_, _, err = NewUserRepository(db).List(context.TODO(), nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, db.Stats().MaxOpenConnections)
+}
+
+// test using go-mysql-server
+func TestCloseClientWithGoMySQLServer(t *testing.T) {
+	tests := []struct {
+		title string
+		// inFlight starts a query which uses a connection, and returns a function finishing it
+		inFlight    func(t *testing.T, db *sql.DB) func()
+		timeout     time.Duration
+		expectedErr string
+	}{
+		{
+			"no queries in flight",
+			func(t *testing.T, db *sql.DB) func() { return func() {} },
+			time.Second,
+			"",
+		},
+		{
+			"rows are closed before the deadline",
+			func(t *testing.T, db *sql.DB) func() {
+				rows, err := db.QueryContext(context.TODO(), "SELECT `id` FROM `user`")
+				require.NoError(t, err)
+				return func() { require.NoError(t, rows.Close()) }
+			},
+			5 * time.Second,
+			"",
+		},
+		{
+			"transaction is committed before the deadline",
+			func(t *testing.T, db *sql.DB) func() {
+				tx, err := db.BeginTx(context.TODO(), nil)
+				require.NoError(t, err)
+				return func() {
+					// NOTE: a transaction which has begun can run queries after the pool is closed
+					_, err := tx.ExecContext(context.TODO(), "INSERT INTO `credential` (`user_id`, `password_hash`) VALUES ('0123456789ABCDEFGHJKMNPQRS', 'hash')")
+					require.NoError(t, err)
+					require.NoError(t, tx.Commit())
+				}
+			},
+			5 * time.Second,
+			"",
+		},
+		{
+			"rows are leaked",
+			func(t *testing.T, db *sql.DB) func() {
+				_, err := db.QueryContext(context.TODO(), "SELECT `id` FROM `user`")
+				require.NoError(t, err)
+				return nil
+			},
+			100 * time.Millisecond,
+			"connections are leaked: 1 connections are still in use",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			port, err := testport.Reserve()
+			require.NoError(t, err)
+
+			// simulator
+			prepareSimulator(t, port)
+			db, err := NewClient(port)
+			require.NoError(t, err)
+			finish := tt.inFlight(t, db)
+
+			// run
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			errCh := make(chan error, 1)
+			go func() { errCh <- CloseClient(ctx, db) }()
+			if finish != nil {
+				time.Sleep(50 * time.Millisecond)
+				finish()
+			}
+			err = <-errCh
+
+			// assert
+			if tt.expectedErr != "" {
+				require.ErrorIs(t, err, ErrLeakedConnections)
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Zero(t, db.Stats().OpenConnections)
+			_, err = db.ExecContext(context.TODO(), "SELECT 1")
+			require.EqualError(t, err, "sql: database is closed")
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestCloseClientLeaksNoGoroutinesWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	// NOTE: goroutines of the simulator are not leaks of the client
+	ignore := goleak.IgnoreCurrent()
+
+	db, err := NewClientFromConfig(&ClientConfig{Port: port, MaxIdleConns: 2, ConnMaxIdleTime: time.Minute})
+	require.NoError(t, err)
+	r := NewUserRepository(db)
+	require.NoError(t, r.Register(context.TODO(), &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}))
+	_, _, err = r.List(context.TODO(), nil)
+	require.NoError(t, err)
+
+	// run
+	err = CloseClient(context.Background(), db)
+
+	// assert
+	require.NoError(t, err)
+	goleak.VerifyNone(t, ignore)
+}
@@ -0,0 +1,210 @@
+// Command gosqltests runs tools of the repository.
+//
+//	gosqltests serve [--host localhost] [--port 3306] [--fixtures dir]
+//	gosqltests import [--config file] [--format csv] [file]
+//	gosqltests export [--config file] [--format csv] [file]
+//	gosqltests seed [--config file] [--count 100] [--seed 1]
+//	gosqltests list-users [--config file] [--name-prefix prefix] [--limit 100]
+//	gosqltests get-user [--config file] id
+//
+// serve (or serve-simulator) runs the go-mysql-server simulator of Go tests as a standalone server, so that non-Go clients
+// (e.g. CLIs and other services in integration tests) connect to the same database with the same schema and fixtures.
+//
+// import registers users of a CSV or JSON file (stdin by default), and export writes all users to a file (stdout by default).
+// seed inserts users generated by the seed package, and list-users and get-user print users, e.g. to poke the simulator by hand.
+// They connect to the database of the config package (environment variables and the optional config file).
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/syuparn/gosqltests"
+	"github.com/syuparn/gosqltests/simulator"
+)
+
+const usage = `usage: gosqltests <command> [flags]
+
+commands:
+  serve        run the go-mysql-server simulator with migrations and fixtures applied (alias: serve-simulator)
+  import       register users of a CSV or JSON file
+  export       write users to a CSV or JSON file
+  seed         insert generated users
+  list-users   print users
+  get-user     print the user of the id as JSON
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errors.New("command is required")
+	}
+
+	switch args[0] {
+	case "serve", "serve-simulator":
+		opts, err := parseServeFlags(args[1:], stderr)
+		if err != nil {
+			return err
+		}
+		return serve(ctx, opts, func(address string) {
+			log.Printf("serving database %s on %s", simulator.Database, address)
+		})
+	case "import":
+		opts, err := parsePortFlags(args[0], args[1:], stderr)
+		if err != nil {
+			return err
+		}
+		n, err := importUsers(ctx, opts)
+		if err != nil {
+			return err
+		}
+		log.Printf("imported %d users", n)
+		return nil
+	case "export":
+		opts, err := parsePortFlags(args[0], args[1:], stderr)
+		if err != nil {
+			return err
+		}
+		n, err := exportUsers(ctx, opts)
+		if err != nil {
+			return err
+		}
+		log.Printf("exported %d users", n)
+		return nil
+	case "seed":
+		opts, err := parseUserFlags(args[0], args[1:], stderr)
+		if err != nil {
+			return err
+		}
+		n, err := seedUsers(ctx, opts)
+		if err != nil {
+			return err
+		}
+		log.Printf("seeded %d users", n)
+		return nil
+	case "list-users":
+		opts, err := parseUserFlags(args[0], args[1:], stderr)
+		if err != nil {
+			return err
+		}
+		_, err = listUsers(ctx, opts, os.Stdout)
+		return err
+	case "get-user":
+		opts, err := parseUserFlags(args[0], args[1:], stderr)
+		if err != nil {
+			return err
+		}
+		return getUser(ctx, opts, os.Stdout)
+	default:
+		fmt.Fprint(stderr, usage)
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// waitTimeout is how long serve waits for the simulator to accept connections.
+const waitTimeout = 10 * time.Second
+
+type serveOptions struct {
+	host     string
+	port     int
+	fixtures string
+}
+
+func parseServeFlags(args []string, stderr io.Writer) (*serveOptions, error) {
+	opts := &serveOptions{}
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.host, "host", "localhost", "address to listen on (0.0.0.0 to accept clients in other hosts, e.g. containers)")
+	fs.IntVar(&opts.port, "port", 3306, "port to listen on")
+	fs.StringVar(&opts.fixtures, "fixtures", "", "directory of fixture files loaded after migrations (see gosqltests.LoadFixtures)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return opts, nil
+}
+
+// serve runs the simulator until ctx is done. ready is called after migrations and fixtures are applied.
+func serve(ctx context.Context, opts *serveOptions, ready func(address string)) error {
+	address := net.JoinHostPort(opts.host, strconv.Itoa(opts.port))
+	s, err := simulator.New(address, simulator.NewDatabase())
+	if err != nil {
+		return fmt.Errorf("failed to start simulator: %w", err)
+	}
+	defer s.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start()
+	}()
+
+	if err := setUp(ctx, opts); err != nil {
+		return err
+	}
+	ready(address)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("simulator stopped: %w", err)
+	}
+}
+
+// setUp applies migrations and fixtures to the simulator.
+func setUp(ctx context.Context, opts *serveOptions) error {
+	host := opts.host
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, strconv.Itoa(opts.port))
+	cfg.User = "root"
+	cfg.DBName = simulator.Database
+	cfg.ParseTime = true
+	// NOTE: foreign key checks are disabled while migrations and fixtures are applied, as Go tests do
+	cfg.Params = map[string]string{"foreign_key_checks": "0"}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to simulator: %w", err)
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	if err := gosqltests.NewHealthChecker(db, nil).WaitHealthy(ctx, waitTimeout); err != nil {
+		return fmt.Errorf("failed to connect to simulator: %w", err)
+	}
+	if err := gosqltests.Migrate(ctx, db); err != nil {
+		return err
+	}
+	if opts.fixtures != "" {
+		if err := gosqltests.LoadFixtures(ctx, db, os.DirFS(opts.fixtures)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
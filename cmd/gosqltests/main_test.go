@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests"
+	"github.com/syuparn/gosqltests/seed"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-mysql-server
+func TestServe(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// run
+	ready := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, &serveOptions{host: "localhost", port: port, fixtures: "../../testdata/fixtures"}, func(address string) {
+			ready <- address
+		})
+	}()
+	select {
+	case address := <-ready:
+		require.Equal(t, fmt.Sprintf("localhost:%d", port), address)
+	case err := <-done:
+		t.Fatalf("serve stopped before ready: %s", err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("serve was not ready")
+	}
+
+	// assert
+	// NOTE: a client of the server does not need to know it is a simulator
+	db, err := gosqltests.NewClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+	users, total, err := gosqltests.NewUserRepository(db).List(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Equal(t, "Mike", users[0].Name)
+	version, err := gosqltests.MigrationVersion(ctx, db)
+	require.NoError(t, err)
+	require.NotZero(t, version)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServeWithInvalidFixtures(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// run
+	err = serve(context.Background(), &serveOptions{host: "localhost", port: port, fixtures: "testdata/missing"}, func(string) {
+		t.Fatal("serve must not be ready")
+	})
+
+	// assert
+	require.ErrorContains(t, err, "failed to read fixtures")
+}
+
+func TestParseServeFlags(t *testing.T) {
+	tests := []struct {
+		title       string
+		args        []string
+		expected    *serveOptions
+		expectedErr string
+	}{
+		{
+			"defaults",
+			nil,
+			&serveOptions{host: "localhost", port: 3306},
+			"",
+		},
+		{
+			"all flags",
+			[]string{"--host", "0.0.0.0", "--port", "13306", "--fixtures", "testdata/fixtures"},
+			&serveOptions{host: "0.0.0.0", port: 13306, fixtures: "testdata/fixtures"},
+			"",
+		},
+		{
+			"unknown flag",
+			[]string{"--database", "app"},
+			nil,
+			"flag provided but not defined: -database",
+		},
+		{
+			"extra arguments",
+			[]string{"--port", "13306", "now"},
+			nil,
+			"unexpected arguments: [now]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			opts, err := parseServeFlags(tt.args, &bytes.Buffer{})
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var stderr bytes.Buffer
+	err := run(context.Background(), []string{"start"}, &stderr)
+	require.EqualError(t, err, "unknown command: start")
+	require.Contains(t, stderr.String(), "usage: gosqltests")
+}
+
+// startServe runs serve in the background until the test ends.
+func startServe(t *testing.T, opts *serveOptions) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, opts, func(address string) {
+			ready <- address
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("serve stopped before ready: %s", err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("serve was not ready")
+	}
+}
+
+// test using go-mysql-server
+func TestImportAndExport(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	startServe(t, &serveOptions{host: "localhost", port: port})
+	t.Setenv("DB_PORT", strconv.Itoa(port))
+	t.Setenv("DB_USER", "root")
+	t.Setenv("DB_NAME", "practice")
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "users.csv")
+	jsonFile := filepath.Join(dir, "users.json")
+	require.NoError(t, os.WriteFile(csvFile, []byte("id,name,age,email\n"+
+		"0123456789ABCDEFGHJKMNPQRS,Mike,20,mike@example.com\n"+
+		"1123456789ABCDEFGHJKMNPQRS,Bob,,\n"), 0o600))
+
+	// run
+	require.NoError(t, run(context.Background(), []string{"import", csvFile}, &bytes.Buffer{}))
+	require.NoError(t, run(context.Background(), []string{"export", "--format", "json", jsonFile}, &bytes.Buffer{}))
+
+	// assert
+	actual, err := os.ReadFile(jsonFile)
+	require.NoError(t, err)
+	require.Equal(t, `[
+{"id":"0123456789ABCDEFGHJKMNPQRS","name":"Mike","age":20,"email":"mike@example.com"},
+{"id":"1123456789ABCDEFGHJKMNPQRS","name":"Bob"}
+]
+`, string(actual))
+}
+
+func TestParsePortFlags(t *testing.T) {
+	tests := []struct {
+		title       string
+		args        []string
+		expected    *portOptions
+		expectedErr string
+	}{
+		{
+			"defaults",
+			nil,
+			&portOptions{format: gosqltests.FormatCSV, file: "-"},
+			"",
+		},
+		{
+			"all flags",
+			[]string{"--config", "db.toml", "--format", "json", "users.json"},
+			&portOptions{config: "db.toml", format: gosqltests.FormatJSON, file: "users.json"},
+			"",
+		},
+		{
+			"unknown format",
+			[]string{"--format", "xml"},
+			nil,
+			"unknown format: xml (must be csv or json)",
+		},
+		{
+			"extra arguments",
+			[]string{"users.csv", "now"},
+			nil,
+			"unexpected arguments: [now]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			opts, err := parsePortFlags("import", tt.args, &bytes.Buffer{})
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestSeedListAndGetUsers(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	startServe(t, &serveOptions{host: "localhost", port: port})
+	t.Setenv("DB_PORT", strconv.Itoa(port))
+	t.Setenv("DB_USER", "root")
+	t.Setenv("DB_NAME", "practice")
+
+	// run
+	require.NoError(t, run(ctx, []string{"seed", "--count", "5", "--seed", "2"}, &bytes.Buffer{}))
+
+	// assert
+	expected := seed.NewGenerator(2).Users(5)
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+
+	t.Run("list-users", func(t *testing.T) {
+		var out bytes.Buffer
+		n, err := listUsers(ctx, &userOptions{limit: 3}, &out)
+
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		require.Len(t, lines, 4)
+		require.Regexp(t, `^ID +NAME +AGE +EMAIL +CREATED_AT$`, lines[0])
+		for i, u := range expected[:3] {
+			require.Regexp(t, fmt.Sprintf(`^%s +%s +%d +\d{4}-`, u.ID, regexp.QuoteMeta(u.Name), *u.Age), lines[i+1])
+		}
+	})
+
+	t.Run("list-users by name prefix", func(t *testing.T) {
+		var out bytes.Buffer
+		n, err := listUsers(ctx, &userOptions{namePrefix: expected[4].Name, limit: 100}, &out)
+
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Contains(t, out.String(), expected[4].ID)
+	})
+
+	t.Run("get-user", func(t *testing.T) {
+		var out bytes.Buffer
+		err := getUser(ctx, &userOptions{id: expected[0].ID}, &out)
+
+		require.NoError(t, err)
+		var actual gosqltests.User
+		require.NoError(t, json.Unmarshal(out.Bytes(), &actual))
+		require.Equal(t, expected[0].ID, actual.ID)
+		require.Equal(t, expected[0].Name, actual.Name)
+		require.Equal(t, expected[0].Age, actual.Age)
+		require.False(t, actual.CreatedAt.IsZero())
+	})
+
+	t.Run("get-user of an unknown id", func(t *testing.T) {
+		err := getUser(ctx, &userOptions{id: "0123456789ABCDEFGHJKMNPQRS"}, &bytes.Buffer{})
+
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestParseUserFlags(t *testing.T) {
+	tests := []struct {
+		title       string
+		command     string
+		args        []string
+		expected    *userOptions
+		expectedErr string
+	}{
+		{
+			"defaults of seed",
+			"seed",
+			nil,
+			&userOptions{count: 100, seed: 1},
+			"",
+		},
+		{
+			"all flags of seed",
+			"seed",
+			[]string{"--config", "db.toml", "--count", "10", "--seed", "42"},
+			&userOptions{config: "db.toml", count: 10, seed: 42},
+			"",
+		},
+		{
+			"count is not positive",
+			"seed",
+			[]string{"--count", "0"},
+			nil,
+			"count must be positive (count: 0)",
+		},
+		{
+			"all flags of list-users",
+			"list-users",
+			[]string{"--name-prefix", "Mi", "--limit", "0"},
+			&userOptions{namePrefix: "Mi"},
+			"",
+		},
+		{
+			"flag of another command",
+			"list-users",
+			[]string{"--count", "10"},
+			nil,
+			"flag provided but not defined: -count",
+		},
+		{
+			"id of get-user",
+			"get-user",
+			[]string{"--config", "db.toml", "0123456789ABCDEFGHJKMNPQRS"},
+			&userOptions{config: "db.toml", id: "0123456789ABCDEFGHJKMNPQRS"},
+			"",
+		},
+		{
+			"get-user without id",
+			"get-user",
+			nil,
+			nil,
+			"id of the user is required: []",
+		},
+		{
+			"extra arguments",
+			"seed",
+			[]string{"now"},
+			nil,
+			"unexpected arguments: [now]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			opts, err := parseUserFlags(tt.command, tt.args, &bytes.Buffer{})
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, opts)
+		})
+	}
+}
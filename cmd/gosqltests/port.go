@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/syuparn/gosqltests"
+	"github.com/syuparn/gosqltests/config"
+)
+
+// stdio is the file argument of import and export meaning stdin or stdout.
+const stdio = "-"
+
+type portOptions struct {
+	config string
+	format gosqltests.UserFormat
+	// file is read by import and written by export
+	file string
+}
+
+func parsePortFlags(command string, args []string, stderr io.Writer) (*portOptions, error) {
+	opts := &portOptions{file: stdio}
+	var format string
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.config, "config", "", "config file of the database (see the config package); environment variables are used if empty")
+	fs.StringVar(&format, "format", string(gosqltests.FormatCSV), "format of the file (csv or json)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 1 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args()[1:])
+	}
+	if fs.NArg() == 1 {
+		opts.file = fs.Arg(0)
+	}
+
+	f, err := gosqltests.ParseUserFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	opts.format = f
+	return opts, nil
+}
+
+// openDatabase connects to the database of the config.
+func openDatabase(path string) (*sql.DB, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return gosqltests.NewClientFromConfig(cfg)
+}
+
+// importUsers registers users of the file (stdin if "-") and returns the number of users imported.
+func importUsers(ctx context.Context, opts *portOptions) (int, error) {
+	in := io.Reader(os.Stdin)
+	if opts.file != stdio {
+		f, err := os.Open(opts.file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	db, err := openDatabase(opts.config)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	return gosqltests.NewUserRepository(db).ImportUsers(ctx, in, opts.format)
+}
+
+// exportUsers writes users to the file (stdout if "-") and returns the number of users exported.
+func exportUsers(ctx context.Context, opts *portOptions) (int, error) {
+	db, err := openDatabase(opts.config)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if opts.file == stdio {
+		return gosqltests.NewUserRepository(db).ExportUsers(ctx, os.Stdout, opts.format)
+	}
+
+	f, err := os.Create(opts.file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	n, err := gosqltests.NewUserRepository(db).ExportUsers(ctx, f, opts.format)
+	if err != nil {
+		f.Close()
+		return n, err
+	}
+	if err := f.Close(); err != nil {
+		return n, fmt.Errorf("failed to write file: %w", err)
+	}
+	return n, nil
+}
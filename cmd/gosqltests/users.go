@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/syuparn/gosqltests"
+	"github.com/syuparn/gosqltests/seed"
+)
+
+// userOptions are flags of seed, list-users and get-user.
+type userOptions struct {
+	config string
+	// count and seed are the number of users generated by seed and the seed value of them
+	count int
+	seed  int64
+	// namePrefix and limit filter users listed by list-users
+	namePrefix string
+	limit      int
+	// id is the user of get-user
+	id string
+}
+
+func parseUserFlags(command string, args []string, stderr io.Writer) (*userOptions, error) {
+	opts := &userOptions{}
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.config, "config", "", "config file of the database (see the config package); environment variables are used if empty")
+	switch command {
+	case "seed":
+		fs.IntVar(&opts.count, "count", 100, "number of users to generate")
+		fs.Int64Var(&opts.seed, "seed", 1, "seed value of users (the same value generates the same users)")
+	case "list-users":
+		fs.StringVar(&opts.namePrefix, "name-prefix", "", "list only users whose names start with it")
+		fs.IntVar(&opts.limit, "limit", 100, "maximum number of users to list (0 for all)")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if command == "get-user" {
+		if fs.NArg() != 1 {
+			return nil, fmt.Errorf("id of the user is required: %v", fs.Args())
+		}
+		opts.id = fs.Arg(0)
+		return opts, nil
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if command == "seed" && opts.count <= 0 {
+		return nil, fmt.Errorf("count must be positive (count: %d)", opts.count)
+	}
+	return opts, nil
+}
+
+// seedUsers inserts users generated by the seed package and returns the number of users inserted.
+func seedUsers(ctx context.Context, opts *userOptions) (int, error) {
+	db, err := openDatabase(opts.config)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	users := seed.NewGenerator(opts.seed).Users(opts.count)
+	if err := seed.Insert(ctx, db, users); err != nil {
+		return 0, err
+	}
+	return len(users), nil
+}
+
+// listUsers writes users in the order of ids to w as a table and returns the number of users listed.
+func listUsers(ctx context.Context, opts *userOptions, w io.Writer) (int, error) {
+	db, err := openDatabase(opts.config)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	users, _, err := gosqltests.NewUserRepository(db).List(ctx, &gosqltests.ListQuery{NamePrefix: opts.namePrefix, Limit: opts.limit})
+	if err != nil {
+		return 0, err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tAGE\tEMAIL\tCREATED_AT")
+	for _, u := range users {
+		age := ""
+		if u.Age != nil {
+			age = strconv.Itoa(*u.Age)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", u.ID, u.Name, age, u.Email, u.CreatedAt.Format(time.RFC3339))
+	}
+	if err := tw.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write users: %w", err)
+	}
+	return len(users), nil
+}
+
+// getUser writes the user of the id to w as JSON.
+func getUser(ctx context.Context, opts *userOptions, w io.Writer) error {
+	db, err := openDatabase(opts.config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := gosqltests.NewUserRepository(db).Get(ctx, opts.id)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(user); err != nil {
+		return fmt.Errorf("failed to write user: %w", err)
+	}
+	return nil
+}
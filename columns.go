@@ -0,0 +1,49 @@
+package gosqltests
+
+import (
+	"fmt"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// NOTE: names are taken from the sqlboiler models so that renaming a column breaks the build instead of queries.
+// Slices are in the order of the table definitions.
+var (
+	userColumnNames = []string{
+		models.UserColumns.ID, models.UserColumns.Name, models.UserColumns.Age, models.UserColumns.DeletedAt,
+		models.UserColumns.Email, models.UserColumns.Version, models.UserColumns.CreatedAt, models.UserColumns.UpdatedAt,
+	}
+	credentialColumnNames  = []string{models.CredentialColumns.UserID, models.CredentialColumns.PasswordHash}
+	userArchiveColumnNames = []string{
+		models.UserArchiveColumns.ID, models.UserArchiveColumns.Name, models.UserArchiveColumns.Age,
+		models.UserArchiveColumns.DeletedAt, models.UserArchiveColumns.Email, models.UserArchiveColumns.ArchivedAt,
+		models.UserArchiveColumns.Version, models.UserArchiveColumns.CreatedAt, models.UserArchiveColumns.UpdatedAt,
+	}
+	archiveCheckpointColumnNames = []string{models.ArchiveCheckpointColumns.Job, models.ArchiveCheckpointColumns.LastID, models.ArchiveCheckpointColumns.Archived}
+	idempotencyKeyColumnNames    = []string{models.IdempotencyKeyColumns.ID, models.IdempotencyKeyColumns.UserID, models.IdempotencyKeyColumns.CreatedAt}
+	auditLogColumnNames          = []string{
+		models.AuditLogColumns.ID, models.AuditLogColumns.UserID, models.AuditLogColumns.Action, models.AuditLogColumns.Actor,
+		models.AuditLogColumns.CreatedAt, models.AuditLogColumns.BeforeJSON, models.AuditLogColumns.AfterJSON,
+	}
+	orderColumnNames = []string{
+		models.OrderColumns.ID, models.OrderColumns.UserID, models.OrderColumns.Item, models.OrderColumns.Amount, models.OrderColumns.CreatedAt,
+	}
+	userTenantColumnNames = []string{models.UserTenantColumns.UserID, models.UserTenantColumns.TenantID}
+)
+
+// tableColumnNames maps each table managed by migrations to its columns.
+var tableColumnNames = map[string][]string{
+	models.TableNames.User:              userColumnNames,
+	models.TableNames.Credential:        credentialColumnNames,
+	models.TableNames.UserArchive:       userArchiveColumnNames,
+	models.TableNames.ArchiveCheckpoint: archiveCheckpointColumnNames,
+	models.TableNames.IdempotencyKey:    idempotencyKeyColumnNames,
+	models.TableNames.AuditLog:          auditLogColumnNames,
+	models.TableNames.Order:             orderColumnNames,
+	models.TableNames.UserTenant:        userTenantColumnNames,
+}
+
+// quotedColumn returns the column qualified by the table, e.g. `user`.`name`.
+func quotedColumn(table, column string) string {
+	return fmt.Sprintf("`%s`.`%s`", table, column)
+}
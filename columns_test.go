@@ -0,0 +1,60 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// assertColumnNames checks the column name constants match the migrated schema.
+func assertColumnNames(ctx context.Context, t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	for table, expected := range tableColumnNames {
+		rows, err := db.QueryContext(ctx,
+			"SELECT `column_name` FROM `information_schema`.`columns` WHERE `table_schema` = DATABASE() AND `table_name` = ? ORDER BY `ordinal_position`",
+			table,
+		)
+		require.NoError(t, err)
+
+		actual := []string{}
+		for rows.Next() {
+			var column string
+			require.NoError(t, rows.Scan(&column))
+			actual = append(actual, column)
+		}
+		require.NoError(t, rows.Err())
+		require.NoError(t, rows.Close())
+
+		require.Equal(t, expected, actual, "columns of table %s", table)
+	}
+}
+
+// test using go-mysql-server
+func TestColumnNamesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(ctx, db))
+
+	// assert
+	assertColumnNames(ctx, t, db)
+}
+
+// test using testcontainers
+func TestColumnNamesWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	// assert
+	assertColumnNames(ctx, t, db)
+}
@@ -0,0 +1,81 @@
+package gosqltests
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/pipeline"
+)
+
+// randomListQuery returns a valid query which matches some of users in most cases.
+func randomListQuery(r *rand.Rand, users []*User) *ListQuery {
+	q := &ListQuery{
+		Order: ListOrder(r.Intn(int(OrderByAgeDesc) + 1)),
+		Limit: r.Intn(20),
+	}
+	if r.Intn(2) == 0 {
+		q.Offset = r.Intn(10)
+	}
+	if r.Intn(2) == 0 {
+		name := users[r.Intn(len(users))].Name
+		q.NamePrefix = name[:1+r.Intn(len(name))]
+	}
+	if r.Intn(2) == 0 {
+		q.MinAge = 1 + r.Intn(90)
+	}
+	if r.Intn(2) == 0 {
+		q.MaxAge = q.MinAge + r.Intn(40)
+	}
+	if (q.Order == OrderByIDAsc || q.Order == OrderByIDDesc) && r.Intn(2) == 0 {
+		q.After = users[r.Intn(len(users))].ID
+	}
+	return q
+}
+
+// test comparing List of every selected backend with the in-memory fake by random queries (nightly profile)
+func TestListCompatibilityFuzz(t *testing.T) {
+	runs := testProfile(t).CompatibilityFuzzRuns
+	if runs == 0 {
+		t.Skipf("skipped because compatibility fuzzing is disabled in the profile (%s=nightly enables it)", pipeline.ProfileEnv)
+	}
+
+	users := generateUsers(t, 200)
+	rng := testRand(t)
+	queries := make([]*ListQuery, runs)
+	for i := range queries {
+		queries[i] = randomListQuery(rng, users)
+	}
+	reference := NewInMemoryUserRepository(users...)
+
+	for _, b := range selectedBackends(t) {
+		backend := b.newBackend()
+		if isMockBackend(backend) {
+			continue
+		}
+
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := backend.Setup(ctx, t)
+			defer backend.Teardown(ctx, t)
+			backend.Seed(ctx, t, users...)
+			r := NewUserRepository(db)
+
+			for _, q := range queries {
+				expected, expectedTotal, err := reference.List(ctx, q)
+				require.NoError(t, err)
+
+				// run
+				actual, total, err := r.List(ctx, q)
+
+				// assert
+				require.NoError(t, err, "query: %+v", q)
+				require.Equal(t, expectedTotal, total, "query: %+v", q)
+				// NOTE: backends seed users with their own timestamps, which queries do not depend on
+				require.Equal(t, withoutTimestamps(expected...), withoutTimestamps(actual...), "query: %+v", q)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package gosqltests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// composeService is the service of MySQL in docker-compose.yml.
+const composeService = "db"
+
+// composeHarness starts the stack of docker-compose.yml when a test needs it first, and stops it after all tests (see TestMain).
+// A stack which is already running (e.g. by `docker compose up -d`) is used as it is and left running.
+type composeHarness struct {
+	once sync.Once
+	err  error
+	// started is true if the harness started the stack
+	started bool
+	// run executes `docker compose` with args and extra environment variables
+	run func(ctx context.Context, env []string, args ...string) ([]byte, error)
+}
+
+var compose = newComposeHarness()
+
+func newComposeHarness() *composeHarness {
+	return &composeHarness{run: runDockerCompose}
+}
+
+func runDockerCompose(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "docker", append([]string{"compose"}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker compose %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// up starts the stack once unless it is running, and waits until MySQL is healthy.
+func (h *composeHarness) up(ctx context.Context) error {
+	h.once.Do(func() {
+		h.err = h.start(ctx)
+	})
+	return h.err
+}
+
+func (h *composeHarness) start(ctx context.Context) error {
+	out, err := h.run(ctx, nil, "ps", "--status", "running", "--quiet", composeService)
+	if err != nil {
+		return fmt.Errorf("failed to check docker compose: %w", err)
+	}
+	if len(bytes.TrimSpace(out)) > 0 {
+		return nil
+	}
+
+	// NOTE: MySQL is published on a free port so that it does not conflict with a local MySQL on 3306
+	port, err := testport.Reserve()
+	if err != nil {
+		return err
+	}
+	// NOTE: --wait (docker compose v2.1 or later) returns after the healthcheck of the service passes
+	env := []string{fmt.Sprintf("%s=%d", mysqlPortEnv, port)}
+	if _, err := h.run(ctx, env, "up", "--detach", "--wait", composeService); err != nil {
+		return fmt.Errorf("failed to start docker compose: %w", err)
+	}
+	h.started = true
+	return nil
+}
+
+// port returns the host port of MySQL.
+func (h *composeHarness) port(ctx context.Context) (int, error) {
+	out, err := h.run(ctx, nil, "port", composeService, "3306")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get port of docker compose: %w", err)
+	}
+	return parseComposePort(string(out))
+}
+
+// parseComposePort parses output of `docker compose port` like "0.0.0.0:3306".
+func parseComposePort(out string) (int, error) {
+	out = strings.TrimSpace(out)
+	i := strings.LastIndex(out, ":")
+	if i < 0 {
+		return 0, fmt.Errorf("failed to parse port of docker compose: %q", out)
+	}
+	port, err := strconv.Atoi(out[i+1:])
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse port of docker compose: %w", err)
+	}
+	return port, nil
+}
+
+// down stops the stack if the harness started it, removing its volumes.
+func (h *composeHarness) down(ctx context.Context) error {
+	if !h.started {
+		return nil
+	}
+	if _, err := h.run(ctx, nil, "down", "--volumes"); err != nil {
+		return fmt.Errorf("failed to stop docker compose: %w", err)
+	}
+	h.started = false
+	return nil
+}
+
+func TestComposeHarness(t *testing.T) {
+	tests := []struct {
+		title string
+		// outputs of docker compose by the first argument
+		outputs     map[string]string
+		errs        map[string]error
+		expectedCmd []string
+		expectedErr string
+	}{
+		{
+			"start and stop the stack",
+			map[string]string{"ps": "", "port": "0.0.0.0:13306\n"},
+			nil,
+			[]string{"ps --status running --quiet db", "up --detach --wait db", "port db 3306", "down --volumes"},
+			"",
+		},
+		{
+			"use the running stack",
+			map[string]string{"ps": "0123456789ab\n", "port": "[::]:3306\n"},
+			nil,
+			// NOTE: the stack is left running
+			[]string{"ps --status running --quiet db", "port db 3306"},
+			"",
+		},
+		{
+			"docker is not available",
+			nil,
+			map[string]error{"ps": errors.New("exec: \"docker\": executable file not found in $PATH")},
+			[]string{"ps --status running --quiet db"},
+			"failed to check docker compose: exec: \"docker\": executable file not found in $PATH",
+		},
+		{
+			"stack is unhealthy",
+			map[string]string{"ps": ""},
+			map[string]error{"up": errors.New("container gosqltests-db-1 is unhealthy")},
+			[]string{"ps --status running --quiet db", "up --detach --wait db"},
+			"failed to start docker compose: container gosqltests-db-1 is unhealthy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			var cmds []string
+			var upEnv []string
+			h := newComposeHarness()
+			h.run = func(ctx context.Context, env []string, args ...string) ([]byte, error) {
+				cmds = append(cmds, strings.Join(args, " "))
+				if args[0] == "up" {
+					upEnv = env
+				}
+				if err := tt.errs[args[0]]; err != nil {
+					return nil, err
+				}
+				return []byte(tt.outputs[args[0]]), nil
+			}
+
+			// run
+			ctx := context.TODO()
+			err := h.up(ctx)
+			if err == nil {
+				var port int
+				port, err = h.port(ctx)
+				require.NoError(t, err)
+				require.NotZero(t, port)
+				require.NoError(t, h.down(ctx))
+			}
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				// the error is kept for the following tests
+				require.Equal(t, err, h.up(ctx))
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expectedCmd, cmds)
+			if upEnv != nil {
+				require.Len(t, upEnv, 1)
+				require.True(t, strings.HasPrefix(upEnv[0], mysqlPortEnv+"="), upEnv[0])
+			}
+		})
+	}
+}
+
+func TestParseComposePort(t *testing.T) {
+	tests := []struct {
+		title       string
+		out         string
+		expected    int
+		expectedErr string
+	}{
+		{"ipv4", "0.0.0.0:3306\n", 3306, ""},
+		{"ipv6", "[::]:13306\n", 13306, ""},
+		{"no port", "\n", 0, `failed to parse port of docker compose: ""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			port, err := parseComposePort(tt.out)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, port)
+		})
+	}
+}
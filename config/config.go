@@ -0,0 +1,170 @@
+// Package config builds gosqltests.ClientConfig of an application from environment variables and an optional file.
+//
+// Each field is taken from the first one which sets it:
+//
+//   - environment variables (DB_HOST, DB_PORT, DB_USER, DB_PASSWORD and DB_NAME)
+//   - the file (TOML, or YAML by the extension .yaml or .yml)
+//   - defaults (localhost:3306)
+//
+// User and database name have no defaults, so that an application never connects to a wrong database by accident.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/syuparn/gosqltests"
+)
+
+// environment variables of each field
+const (
+	EnvHost     = "DB_HOST"
+	EnvPort     = "DB_PORT"
+	EnvUser     = "DB_USER"
+	EnvPassword = "DB_PASSWORD"
+	EnvName     = "DB_NAME"
+)
+
+// defaults of optional fields
+const (
+	DefaultHost = "localhost"
+	DefaultPort = 3306
+)
+
+// File is the content of a config file.
+//
+//	host = "db.example.com"
+//	port = 3306
+//	user = "app"
+//	password = "secret"
+//	name = "app"
+type File struct {
+	Host     string `toml:"host" yaml:"host"`
+	Port     int    `toml:"port" yaml:"port"`
+	User     string `toml:"user" yaml:"user"`
+	Password string `toml:"password" yaml:"password"`
+	Name     string `toml:"name" yaml:"name"`
+}
+
+// ErrInvalidConfig is the error if the config lacks required fields or has invalid values.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ValidationError lists every problem of the config, so that all of them can be fixed at once.
+type ValidationError struct {
+	// Missing are the environment variables of required fields which are not set anywhere.
+	Missing []string
+	// Invalid are descriptions of values which cannot be used.
+	Invalid []string
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(e.Missing, ", "))
+	}
+	problems = append(problems, e.Invalid...)
+	return fmt.Sprintf("%s: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// Load builds the config from environment variables, the file at path (skipped if empty) and defaults.
+// Other fields of ClientConfig (e.g. pool settings) are left zero for the caller to set.
+func Load(path string) (*gosqltests.ClientConfig, error) {
+	return load(path, os.Getenv)
+}
+
+func load(path string, getenv func(string) string) (*gosqltests.ClientConfig, error) {
+	f := &File{}
+	if path != "" {
+		var err error
+		f, err = readFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	verr := &ValidationError{}
+	cfg := &gosqltests.ClientConfig{
+		Host:     first(getenv(EnvHost), f.Host, DefaultHost),
+		User:     first(getenv(EnvUser), f.User),
+		Password: first(getenv(EnvPassword), f.Password),
+		Database: first(getenv(EnvName), f.Name),
+		Port:     f.Port,
+	}
+	if s := getenv(EnvPort); s != "" {
+		port, err := strconv.Atoi(s)
+		if err != nil {
+			verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s must be a number: %q", EnvPort, s))
+		} else {
+			cfg.Port = port
+		}
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+
+	if cfg.User == "" {
+		verr.Missing = append(verr.Missing, EnvUser)
+	}
+	if cfg.Database == "" {
+		verr.Missing = append(verr.Missing, EnvName)
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		verr.Invalid = append(verr.Invalid, fmt.Sprintf("%s must be between 1 and 65535: %d", EnvPort, cfg.Port))
+	}
+	if len(verr.Missing) > 0 || len(verr.Invalid) > 0 {
+		return nil, verr
+	}
+	return cfg, nil
+}
+
+func readFile(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	f := &File{}
+	switch ext := filepath.Ext(path); ext {
+	case ".toml":
+		md, err := toml.Decode(string(b), f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		// NOTE: unknown keys are rejected because they are likely typos of fields
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("failed to parse config file %s: unknown keys %v", path, undecoded)
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		// NOTE: an empty file is io.EOF, which is no settings rather than an error
+		if err := dec.Decode(f); err != nil && len(bytes.TrimSpace(b)) > 0 {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to parse config file %s: unsupported extension %q (.toml, .yaml or .yml)", path, ext)
+	}
+	return f, nil
+}
+
+// first returns the first non-empty value.
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
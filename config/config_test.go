@@ -0,0 +1,135 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		title       string
+		path        string
+		env         map[string]string
+		expected    *gosqltests.ClientConfig
+		expectedErr string
+	}{
+		{
+			"environment variables",
+			"",
+			map[string]string{EnvHost: "db", EnvPort: "13306", EnvUser: "app", EnvPassword: "secret", EnvName: "app"},
+			&gosqltests.ClientConfig{Host: "db", Port: 13306, User: "app", Password: "secret", Database: "app"},
+			"",
+		},
+		{
+			"defaults",
+			"",
+			map[string]string{EnvUser: "app", EnvName: "app"},
+			&gosqltests.ClientConfig{Host: DefaultHost, Port: DefaultPort, User: "app", Database: "app"},
+			"",
+		},
+		{
+			"toml file",
+			"testdata/config.toml",
+			nil,
+			&gosqltests.ClientConfig{Host: "db.example.com", Port: 13306, User: "app", Password: "secret", Database: "app"},
+			"",
+		},
+		{
+			"yaml file",
+			"testdata/config.yaml",
+			nil,
+			&gosqltests.ClientConfig{Host: "db.example.com", Port: 13306, User: "app", Password: "secret", Database: "app"},
+			"",
+		},
+		{
+			"environment variables override the file",
+			"testdata/config.toml",
+			map[string]string{EnvHost: "localhost", EnvPassword: "changed"},
+			&gosqltests.ClientConfig{Host: "localhost", Port: 13306, User: "app", Password: "changed", Database: "app"},
+			"",
+		},
+		{
+			"file and environment variables are merged",
+			"testdata/partial.yml",
+			map[string]string{EnvName: "app"},
+			&gosqltests.ClientConfig{Host: DefaultHost, Port: DefaultPort, User: "app", Database: "app"},
+			"",
+		},
+		{
+			"all missing fields are listed",
+			"",
+			nil,
+			nil,
+			"invalid config: missing DB_USER, DB_NAME",
+		},
+		{
+			"missing and invalid fields are listed",
+			"",
+			map[string]string{EnvPort: "mysql"},
+			nil,
+			`invalid config: missing DB_USER, DB_NAME; DB_PORT must be a number: "mysql"`,
+		},
+		{
+			"port out of range",
+			"",
+			map[string]string{EnvPort: "70000", EnvUser: "app", EnvName: "app"},
+			nil,
+			"invalid config: DB_PORT must be between 1 and 65535: 70000",
+		},
+		{
+			"file does not exist",
+			"testdata/missing.toml",
+			nil,
+			nil,
+			"failed to read config file: open testdata/missing.toml: no such file or directory",
+		},
+		{
+			"unknown key in the file",
+			"testdata/typo.toml",
+			nil,
+			nil,
+			"failed to parse config file testdata/typo.toml: unknown keys [database]",
+		},
+		{
+			"invalid value in the file",
+			"testdata/invalid.yaml",
+			nil,
+			nil,
+			"failed to parse config file testdata/invalid.yaml: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!str `abc` into int",
+		},
+		{
+			"unsupported extension",
+			"testdata/config.json",
+			nil,
+			nil,
+			`failed to parse config file testdata/config.json: unsupported extension ".json" (.toml, .yaml or .yml)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			cfg, err := load(tt.path, func(key string) string { return tt.env[key] })
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, cfg)
+		})
+	}
+}
+
+func TestValidationErrorIs(t *testing.T) {
+	_, err := load("", func(string) string { return "" })
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	require.Equal(t, []string{EnvUser, EnvName}, verr.Missing)
+}
@@ -0,0 +1,105 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	testcontainers "github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/syuparn/gosqltests/testsupport"
+)
+
+// withNetwork attaches the container to the docker network and names it hostname in the network,
+// so that other containers in the network (e.g. an app under test) connect to hostname:3306 without mapped ports.
+// The container is ready only after MySQL accepts connections by hostname as well.
+func withNetwork(network, hostname string) containerOption {
+	return func(req *testcontainers.ContainerRequest) {
+		req.Networks = append(req.Networks, network)
+		if req.NetworkAliases == nil {
+			req.NetworkAliases = map[string][]string{}
+		}
+		req.NetworkAliases[network] = append(req.NetworkAliases[network], hostname)
+		req.Hostname = hostname
+		// NOTE: the mapped port may be ready before the alias is resolved in the network
+		req.WaitingFor = wait.ForAll(
+			req.WaitingFor,
+			wait.ForExec([]string{"mysqladmin", "ping", "--host", hostname, "--user", "root", "--silent"}),
+		)
+	}
+}
+
+// prepareNetwork creates a uniquely named docker network, which is removed when the test finishes.
+func prepareNetwork(ctx context.Context, t testing.TB) string {
+	skipIfOverBudget(t)
+
+	name := "gosqltests-" + strings.ToLower(ulid.Make().String())
+	network, err := testcontainers.GenericNetwork(ctx, harnessRuntime.networkRequest(testcontainers.NetworkRequest{
+		Name:           name,
+		CheckDuplicate: true,
+	}))
+	if err != nil {
+		t.Fatalf("failed to create network: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := network.Remove(ctx); err != nil {
+			t.Errorf("failed to remove network: %s", err)
+		}
+	})
+	return name
+}
+
+// runInNetwork runs the command in a container of the MySQL image attached to the network and returns its output.
+func runInNetwork(ctx context.Context, t *testing.T, network string, cmd ...string) string {
+	container := testsupport.StartContainer(ctx, t, harnessRuntime.request(testcontainers.ContainerRequest{
+		Image:      mysqlImage(),
+		Entrypoint: cmd,
+		Networks:   []string{network},
+		WaitingFor: wait.ForExit(),
+	}))
+
+	logs, err := container.Logs(ctx)
+	require.NoError(t, err)
+	defer logs.Close()
+	out, err := io.ReadAll(logs)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestWithNetwork(t *testing.T) {
+	// run
+	req := mysqlContainerRequest()
+	withNetwork("test-network", "mysql")(&req)
+
+	// assert
+	require.Equal(t, []string{"test-network"}, req.Networks)
+	require.Equal(t, map[string][]string{"test-network": {"mysql"}}, req.NetworkAliases)
+	require.Equal(t, "mysql", req.Hostname)
+	require.IsType(t, &wait.MultiStrategy{}, req.WaitingFor)
+	require.Len(t, req.WaitingFor.(*wait.MultiStrategy).Strategies, 2)
+}
+
+// test using testcontainers
+func TestNetworkWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	network := prepareNetwork(ctx, t)
+	db := prepareContainer(ctx, t, withNetwork(network, "mysql"))
+
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"}
+	require.NoError(t, NewUserRepository(db).Register(ctx, mike))
+
+	// run
+	// NOTE: the client container reaches the server by its hostname, not by the port mapped to the host
+	out := runInNetwork(ctx, t, network,
+		"mysql", "--host", "mysql", "--user", "root", "--skip-column-names",
+		"--execute", fmt.Sprintf("SELECT name FROM practice.user WHERE id = '%s'", mike.ID))
+
+	// assert
+	require.Contains(t, out, "Mike")
+}
@@ -0,0 +1,269 @@
+package gosqltests
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+	testcontainers "github.com/testcontainers/testcontainers-go"
+
+	"github.com/syuparn/gosqltests/chaosproxy"
+	"github.com/syuparn/gosqltests/testsupport"
+)
+
+// NOTE: set GOSQLTESTS_CONTAINER_RUNTIME=podman to start containers by Podman through the socket of DOCKER_HOST
+// (detected automatically if the socket path contains "podman").
+// DOCKER_HOST of a remote daemon (tcp:// or ssh://) is supported as well (see containerRuntime).
+const containerRuntimeEnv = "GOSQLTESTS_CONTAINER_RUNTIME"
+
+// containerRuntime is the runtime which the harness starts containers on.
+// Clients of tests connect to localhost, so mapped ports of remote daemons are forwarded to localhost.
+type containerRuntime struct {
+	podman bool
+	remote bool
+	// privilegedReaper is true if Ryuk runs as a privileged container, which Podman requires
+	privilegedReaper bool
+}
+
+// runtimeCapability is a behavior of the harness which differs by container runtimes.
+type runtimeCapability string
+
+const (
+	// mapped ports of containers listen on localhost
+	capabilityLocalPorts runtimeCapability = "mapped ports on localhost"
+	// tests can choose host ports of containers, which are free on localhost
+	capabilityFixedHostPorts runtimeCapability = "fixed host ports"
+	// Ryuk removes containers left by killed test processes
+	capabilityReaper runtimeCapability = "reaper"
+)
+
+// harnessRuntime is the runtime of the environment of the test process.
+var harnessRuntime = detectContainerRuntime(os.Getenv)
+
+func detectContainerRuntime(getenv func(string) string) containerRuntime {
+	dockerHost := getenv("DOCKER_HOST")
+	r := containerRuntime{
+		podman:           getenv(containerRuntimeEnv) == "podman" || strings.Contains(dockerHost, "podman"),
+		privilegedReaper: getenv("TESTCONTAINERS_RYUK_CONTAINER_PRIVILEGED") == "true",
+	}
+
+	u, err := url.Parse(dockerHost)
+	if err != nil {
+		return r
+	}
+	switch u.Scheme {
+	case "tcp", "ssh", "http", "https":
+		r.remote = !isLoopback(u.Hostname())
+	}
+	return r
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (r containerRuntime) String() string {
+	name := "Docker"
+	if r.podman {
+		name = "Podman"
+	}
+	if r.remote {
+		return "remote " + name
+	}
+	return name
+}
+
+func (r containerRuntime) supports(c runtimeCapability) bool {
+	switch c {
+	case capabilityLocalPorts, capabilityFixedHostPorts:
+		return !r.remote
+	case capabilityReaper:
+		return !r.podman || r.privilegedReaper
+	default:
+		return false
+	}
+}
+
+// skipUnless skips the test if the runtime does not support the capability.
+func (r containerRuntime) skipUnless(t testing.TB, c runtimeCapability) {
+	t.Helper()
+	if !r.supports(c) {
+		t.Skipf("skipped because %s does not support %s", r, c)
+	}
+}
+
+// request returns the request to start the container on the runtime.
+func (r containerRuntime) request(req testcontainers.ContainerRequest) testcontainers.GenericContainerRequest {
+	if !r.supports(capabilityReaper) {
+		// NOTE: containers are still removed by t.Cleanup unless the test process is killed
+		req.SkipReaper = true
+	}
+	gr := testcontainers.GenericContainerRequest{ContainerRequest: req, Started: true}
+	if r.podman {
+		gr.ProviderType = testcontainers.ProviderPodman
+	}
+	return gr
+}
+
+// networkRequest returns the request to create the network on the runtime.
+func (r containerRuntime) networkRequest(req testcontainers.NetworkRequest) testcontainers.GenericNetworkRequest {
+	if !r.supports(capabilityReaper) {
+		req.SkipReaper = true
+	}
+	gr := testcontainers.GenericNetworkRequest{NetworkRequest: req}
+	if r.podman {
+		gr.ProviderType = testcontainers.ProviderPodman
+	}
+	return gr
+}
+
+// localPort returns the port of localhost which clients of tests connect to the port of the container on.
+// Ports of remote daemons are forwarded by a proxy until the test finishes.
+func (r containerRuntime) localPort(ctx context.Context, t testing.TB, container testcontainers.Container, port nat.Port) int {
+	mapped := testsupport.MappedPort(ctx, t, container, port)
+	if r.supports(capabilityLocalPorts) {
+		return mapped
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("failed to get host of container: %s", err)
+	}
+	// NOTE: the proxy forwards connections without faults unless they are injected
+	p, err := chaosproxy.New(net.JoinHostPort(host, strconv.Itoa(mapped)))
+	if err != nil {
+		t.Fatalf("failed to forward port: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := p.Close(); err != nil {
+			t.Errorf("failed to close forwarded port: %s", err)
+		}
+	})
+	return p.Port()
+}
+
+func TestDetectContainerRuntime(t *testing.T) {
+	tests := []struct {
+		title    string
+		env      map[string]string
+		expected containerRuntime
+		name     string
+	}{
+		{
+			"local docker",
+			map[string]string{},
+			containerRuntime{},
+			"Docker",
+		},
+		{
+			"docker socket",
+			map[string]string{"DOCKER_HOST": "unix:///var/run/docker.sock"},
+			containerRuntime{},
+			"Docker",
+		},
+		{
+			"remote docker by tcp",
+			map[string]string{"DOCKER_HOST": "tcp://10.0.0.5:2376"},
+			containerRuntime{remote: true},
+			"remote Docker",
+		},
+		{
+			"remote docker by ssh",
+			map[string]string{"DOCKER_HOST": "ssh://user@docker.example.com"},
+			containerRuntime{remote: true},
+			"remote Docker",
+		},
+		{
+			"docker daemon on localhost by tcp",
+			map[string]string{"DOCKER_HOST": "tcp://127.0.0.1:2375"},
+			containerRuntime{},
+			"Docker",
+		},
+		{
+			"podman socket",
+			map[string]string{"DOCKER_HOST": "unix:///run/user/1000/podman/podman.sock"},
+			containerRuntime{podman: true},
+			"Podman",
+		},
+		{
+			"podman by the variable",
+			map[string]string{containerRuntimeEnv: "podman", "TESTCONTAINERS_RYUK_CONTAINER_PRIVILEGED": "true"},
+			containerRuntime{podman: true, privilegedReaper: true},
+			"Podman",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual := detectContainerRuntime(func(key string) string { return tt.env[key] })
+
+			// assert
+			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.name, actual.String())
+		})
+	}
+}
+
+func TestContainerRuntimeSupports(t *testing.T) {
+	tests := []struct {
+		title    string
+		runtime  containerRuntime
+		expected []runtimeCapability
+	}{
+		{"docker", containerRuntime{}, []runtimeCapability{capabilityLocalPorts, capabilityFixedHostPorts, capabilityReaper}},
+		{"remote docker", containerRuntime{remote: true}, []runtimeCapability{capabilityReaper}},
+		{"podman", containerRuntime{podman: true}, []runtimeCapability{capabilityLocalPorts, capabilityFixedHostPorts}},
+		{"podman with privileged reaper", containerRuntime{podman: true, privilegedReaper: true}, []runtimeCapability{capabilityLocalPorts, capabilityFixedHostPorts, capabilityReaper}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			var actual []runtimeCapability
+			for _, c := range []runtimeCapability{capabilityLocalPorts, capabilityFixedHostPorts, capabilityReaper} {
+				if tt.runtime.supports(c) {
+					actual = append(actual, c)
+				}
+			}
+
+			// assert
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestContainerRuntimeRequest(t *testing.T) {
+	tests := []struct {
+		title              string
+		runtime            containerRuntime
+		expectedProvider   testcontainers.ProviderType
+		expectedSkipReaper bool
+	}{
+		{"docker", containerRuntime{}, testcontainers.ProviderDocker, false},
+		{"podman", containerRuntime{podman: true}, testcontainers.ProviderPodman, true},
+		{"podman with privileged reaper", containerRuntime{podman: true, privilegedReaper: true}, testcontainers.ProviderPodman, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual := tt.runtime.request(mysqlContainerRequest())
+
+			// assert
+			require.True(t, actual.Started)
+			require.Equal(t, tt.expectedProvider, actual.ProviderType)
+			require.Equal(t, tt.expectedSkipReaper, actual.SkipReaper)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrInvalidCredentials does not tell whether the user exists or the password is wrong
+// so that callers cannot enumerate users.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// passwordHash hides itself from fmt so that it never shows up in logs.
+type passwordHash string
+
+func (passwordHash) String() string {
+	return "[REDACTED]"
+}
+
+func (passwordHash) GoString() string {
+	return "[REDACTED]"
+}
+
+type credentialRepository struct {
+	db   boil.ContextExecutor
+	cost int
+
+	// dummyHash is compared when the user does not exist to take as long as a real comparison
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+}
+
+func NewCredentialRepository(db *sql.DB) *credentialRepository {
+	return newCredentialRepository(db)
+}
+
+func newCredentialRepository(db boil.ContextExecutor) *credentialRepository {
+	return &credentialRepository{
+		db:   db,
+		cost: bcrypt.DefaultCost,
+	}
+}
+
+func (r *credentialRepository) SetPassword(ctx context.Context, userID string, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), r.cost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password (user_id: %s): %w", userID, err)
+	}
+
+	c := &models.Credential{
+		UserID:       userID,
+		PasswordHash: string(hash),
+	}
+
+	// NOTE: sqlboiler debug output prints bound values, which include the hash
+	ctx = boil.WithDebug(ctx, false)
+	if err := c.Upsert(ctx, r.db, boil.Whitelist(models.CredentialColumns.PasswordHash), boil.Infer()); err != nil {
+		return fmt.Errorf("failed to save credential (user_id: %s): %w", userID, wrapStorageError(err))
+	}
+
+	return nil
+}
+
+func (r *credentialRepository) VerifyPassword(ctx context.Context, userID string, password string) error {
+	hash, err := r.passwordHash(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			r.dummyHashOnce.Do(func() {
+				r.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), r.cost)
+			})
+			_ = bcrypt.CompareHashAndPassword(r.dummyHash, []byte(password))
+			return fmt.Errorf("failed to verify password (user_id: %s): %w", userID, ErrInvalidCredentials)
+		}
+
+		return fmt.Errorf("failed to get credential (user_id: %s): %w", userID, err)
+	}
+
+	// NOTE: CompareHashAndPassword compares in constant time
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return fmt.Errorf("failed to verify password (user_id: %s): %w", userID, ErrInvalidCredentials)
+	}
+
+	return nil
+}
+
+// passwordHash returns sql.ErrNoRows also if the user is soft-deleted, so that deleted users cannot log in.
+func (r *credentialRepository) passwordHash(ctx context.Context, userID string) (passwordHash, error) {
+	c, err := models.Credentials(
+		qm.Select(quotedColumn(models.TableNames.Credential, models.CredentialColumns.PasswordHash)),
+		qm.InnerJoin(fmt.Sprintf("`%s` ON %s = %s", models.TableNames.User,
+			quotedColumn(models.TableNames.User, models.UserColumns.ID), quotedColumn(models.TableNames.Credential, models.CredentialColumns.UserID))),
+		models.CredentialWhere.UserID.EQ(userID),
+		models.UserWhere.DeletedAt.IsNull(),
+	).One(boil.WithDebug(ctx, false), r.db)
+	if err != nil {
+		return "", err
+	}
+
+	return passwordHash(c.PasswordHash), nil
+}
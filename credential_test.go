@@ -0,0 +1,204 @@
+package gosqltests
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// test using go-sqlmock
+func TestVerifyPasswordWithSQLMock(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("p@ssw0rd"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		title       string
+		userID      string
+		password    string
+		mockRow     []driver.Value
+		mockErr     error
+		expectedErr string
+	}{
+		{
+			"correct password",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"p@ssw0rd",
+			[]driver.Value{string(hash)},
+			nil,
+			"",
+		},
+		{
+			"wrong password",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"password",
+			[]driver.Value{string(hash)},
+			nil,
+			"failed to verify password (user_id: 0123456789ABCDEFGHJKMNPQRS): invalid credentials",
+		},
+		{
+			"unknown user",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"p@ssw0rd",
+			nil,
+			nil,
+			"failed to verify password (user_id: 0123456789ABCDEFGHJKMNPQRS): invalid credentials",
+		},
+		{
+			"unexpected error",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"p@ssw0rd",
+			nil,
+			fmt.Errorf("crashed unexpectedly!!!"),
+			"failed to get credential (user_id: 0123456789ABCDEFGHJKMNPQRS): models: failed to execute a one query for credential: bind failed to execute query: crashed unexpectedly!!!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			expected := mock.ExpectQuery(regexp.QuoteMeta("SELECT `credential`.`password_hash` FROM `credential` INNER JOIN `user` ON `user`.`id` = `credential`.`user_id` " +
+				"WHERE (`credential`.`user_id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1;")).
+				WithArgs(tt.userID)
+			rows := sqlmock.NewRows([]string{models.CredentialColumns.PasswordHash})
+			if tt.mockRow != nil {
+				rows.AddRow(tt.mockRow...)
+			}
+			if tt.mockErr != nil {
+				expected.WillReturnError(tt.mockErr)
+			} else {
+				expected.WillReturnRows(rows)
+			}
+
+			// run
+			r := NewCredentialRepository(db)
+			r.cost = bcrypt.MinCost
+			err := r.VerifyPassword(context.TODO(), tt.userID, tt.password)
+
+			// assert
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectedErr)
+			}
+			require.NotContains(t, fmt.Sprint(err), string(hash))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSetPasswordWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `credential` (`user_id`,`password_hash`) VALUES (?,?) ON DUPLICATE KEY UPDATE `password_hash` = VALUES(`password_hash`)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", bcryptHashOf("p@ssw0rd")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// run
+	r := NewCredentialRepository(db)
+	r.cost = bcrypt.MinCost
+	err := r.SetPassword(context.TODO(), "0123456789ABCDEFGHJKMNPQRS", "p@ssw0rd")
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// bcryptHashOf matches a bcrypt hash of the password, which cannot be compared directly since it is salted
+type bcryptHashOf string
+
+func (m bcryptHashOf) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	if !ok {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(m)) == nil
+}
+
+// test using go-mysql-server
+func TestVerifyPasswordWithGoMySQLServer(t *testing.T) {
+	tests := []struct {
+		title       string
+		userID      string
+		password    string
+		expectedErr error
+	}{
+		{
+			"correct password",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"p@ssw0rd",
+			nil,
+		},
+		{
+			"wrong password",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"password",
+			ErrInvalidCredentials,
+		},
+		{
+			"unknown user",
+			"1123456789ABCDEFGHJKMNPQRS",
+			"p@ssw0rd",
+			ErrInvalidCredentials,
+		},
+		{
+			"deleted user",
+			"2123456789ABCDEFGHJKMNPQRS",
+			"p@ssw0rd",
+			ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			table := prepareSimulator(t, 23306)
+			simCtx := simsql.NewEmptyContext()
+			_ = table.Insert(simCtx, simsql.NewRow("0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20), nil, nil, int64(1), seededAt, seededAt))
+			// NOTE: the credential of a soft-deleted user is kept until the user is hard-deleted
+			_ = table.Insert(simCtx, simsql.NewRow("2123456789ABCDEFGHJKMNPQRS", "Bob", int32(25), seededAt, nil, int64(1), seededAt, seededAt))
+
+			db, err := NewStrictClient(23306)
+			require.NoError(t, err)
+			r := NewCredentialRepository(db)
+			r.cost = bcrypt.MinCost
+			// NOTE: set twice to check the password can be changed
+			require.NoError(t, r.SetPassword(context.TODO(), "0123456789ABCDEFGHJKMNPQRS", "old password"))
+			require.NoError(t, r.SetPassword(context.TODO(), "0123456789ABCDEFGHJKMNPQRS", "p@ssw0rd"))
+			require.NoError(t, r.SetPassword(context.TODO(), "2123456789ABCDEFGHJKMNPQRS", "p@ssw0rd"))
+
+			// run
+			var debugOutput bytes.Buffer
+			ctx := boil.WithDebugWriter(boil.WithDebug(context.TODO(), true), &debugOutput)
+			err = r.VerifyPassword(ctx, tt.userID, tt.password)
+
+			// assert
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.expectedErr)
+			}
+			require.Empty(t, debugOutput.String())
+		})
+	}
+}
+
+func TestPasswordHashIsRedacted(t *testing.T) {
+	hash := passwordHash("$2a$04$abcdefghijklmnopqrstuuFbbZ3QoW3nSRfFmVXi6hh3tzM5dG.FS")
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v", "%q"} {
+		require.NotContains(t, fmt.Sprintf(format, hash), string(hash), format)
+		require.NotContains(t, fmt.Sprintf(format, struct{ Hash passwordHash }{hash}), string(hash), format)
+	}
+}
@@ -0,0 +1,487 @@
+package gosqltests
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// interval of sampling lock waits while a call is in flight
+const defaultLockWaitSampleInterval = 50 * time.Millisecond
+
+// lockConflict is a pair of statements conflicting on a lock.
+type lockConflict struct {
+	waiting  string
+	blocking string
+}
+
+// deadlockTransaction is one of the transactions of a deadlock reported by the InnoDB monitor.
+type deadlockTransaction struct {
+	label     string
+	threadID  int64
+	statement string
+}
+
+// lockConflictReporter wraps calls of concurrent tests, and reports the conflicting statements of calls failed by
+// deadlocks or lock wait timeouts, so that flaky concurrent tests are debuggable.
+// NOTE: it reads performance_schema and the InnoDB monitor, so monitor must be a client of MySQL 8 with the PROCESS privilege
+// (e.g. root of the container). Other servers are reported without statements.
+type lockConflictReporter struct {
+	t        testing.TB
+	monitor  *sql.DB
+	interval time.Duration
+
+	mu      sync.Mutex
+	reports []string
+}
+
+// reportLockConflicts returns the reporter of lock conflicts of calls in the test.
+func reportLockConflicts(t testing.TB, monitor *sql.DB) *lockConflictReporter {
+	return &lockConflictReporter{t: t, monitor: monitor, interval: defaultLockWaitSampleInterval}
+}
+
+// do runs f and returns its error. If f failed by a deadlock or a lock wait timeout, the conflicting statements are logged.
+func (r *lockConflictReporter) do(ctx context.Context, f func(ctx context.Context) error) error {
+	// NOTE: waits of a lock wait timeout disappear before the error is returned, so they are sampled while f runs
+	stop := make(chan struct{})
+	sampled := make(chan []lockConflict, 1)
+	go func() {
+		sampled <- r.sampleLockWaits(ctx, stop)
+	}()
+
+	err := f(ctx)
+	close(stop)
+	waits := <-sampled
+
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+	switch mysqlErr.Number {
+	case mysqlErrDeadlock:
+		r.report(fmt.Sprintf("deadlock (%s)\n%s", mysqlErr.Message, r.describeDeadlock(ctx)))
+	case mysqlErrLockWaitTimeout:
+		r.report(fmt.Sprintf("lock wait timeout (%s)\n%s", mysqlErr.Message, describeLockWaits(waits)))
+	}
+	return err
+}
+
+func (r *lockConflictReporter) report(report string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, report)
+	r.t.Logf("lock conflict: %s", report)
+}
+
+// sampleLockWaits collects lock waits until stop is closed.
+func (r *lockConflictReporter) sampleLockWaits(ctx context.Context, stop <-chan struct{}) []lockConflict {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	var conflicts []lockConflict
+	seen := map[lockConflict]struct{}{}
+	for {
+		select {
+		case <-stop:
+			return conflicts
+		case <-ticker.C:
+			waits, err := currentLockWaits(ctx, r.monitor)
+			if err != nil {
+				// NOTE: servers other than MySQL 8 do not have data_lock_waits
+				continue
+			}
+			for _, w := range waits {
+				if _, ok := seen[w]; !ok {
+					seen[w] = struct{}{}
+					conflicts = append(conflicts, w)
+				}
+			}
+		}
+	}
+}
+
+// currentLockWaits returns the statements waiting for locks and the statements which acquired the locks.
+// NOTE: the blocking statement is looked up by the event which acquired the lock, because the blocking transaction
+// may have run other statements since then
+func currentLockWaits(ctx context.Context, db *sql.DB) ([]lockConflict, error) {
+	rows, err := db.QueryContext(ctx, "SELECT COALESCE(rs.SQL_TEXT, ''), COALESCE(bh.SQL_TEXT, bs.SQL_TEXT, '') "+
+		"FROM performance_schema.data_lock_waits w "+
+		"LEFT JOIN performance_schema.events_statements_current rs ON rs.THREAD_ID = w.REQUESTING_THREAD_ID "+
+		"LEFT JOIN performance_schema.events_statements_history bh ON bh.THREAD_ID = w.BLOCKING_THREAD_ID AND bh.EVENT_ID = w.BLOCKING_EVENT_ID "+
+		"LEFT JOIN performance_schema.events_statements_current bs ON bs.THREAD_ID = w.BLOCKING_THREAD_ID")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock waits: %w", err)
+	}
+	defer rows.Close()
+
+	var conflicts []lockConflict
+	for rows.Next() {
+		var c lockConflict
+		if err := rows.Scan(&c.waiting, &c.blocking); err != nil {
+			return nil, fmt.Errorf("failed to scan lock wait: %w", err)
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+func describeLockWaits(waits []lockConflict) string {
+	if len(waits) == 0 {
+		return "  no lock waits were sampled"
+	}
+	var b strings.Builder
+	for _, w := range waits {
+		fmt.Fprintf(&b, "  waiting:  %s\n  blocking: %s\n", w.waiting, w.blocking)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// describeDeadlock describes the latest deadlock detected by InnoDB with the statements of its transactions.
+func (r *lockConflictReporter) describeDeadlock(ctx context.Context) string {
+	var typ, name, status string
+	if err := r.monitor.QueryRowContext(ctx, "SHOW ENGINE INNODB STATUS").Scan(&typ, &name, &status); err != nil {
+		return fmt.Sprintf("  failed to get InnoDB status: %s", err)
+	}
+	txs, victim := parseLatestDeadlock(status)
+	if len(txs) == 0 {
+		return "  no deadlock was found in InnoDB status"
+	}
+
+	var b strings.Builder
+	for _, tx := range txs {
+		fmt.Fprintf(&b, "  transaction %s (thread %d): %s\n", tx.label, tx.threadID, tx.statement)
+		// NOTE: the statements which acquired the locks of the transaction precede the conflicting one
+		history, err := threadStatementHistory(ctx, r.monitor, tx.threadID)
+		if err != nil {
+			fmt.Fprintf(&b, "    %s\n", err)
+			continue
+		}
+		for _, s := range history {
+			fmt.Fprintf(&b, "    %s\n", s)
+		}
+	}
+	fmt.Fprintf(&b, "  rolled back: transaction %s", victim)
+	return b.String()
+}
+
+// threadStatementHistory returns the recent statements of the connection, oldest first.
+func threadStatementHistory(ctx context.Context, db *sql.DB, threadID int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT h.SQL_TEXT FROM performance_schema.events_statements_history h "+
+		"JOIN performance_schema.threads th ON th.THREAD_ID = h.THREAD_ID "+
+		"WHERE th.PROCESSLIST_ID = ? AND h.SQL_TEXT IS NOT NULL ORDER BY h.EVENT_ID", threadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement history (thread: %d): %w", threadID, err)
+	}
+	defer rows.Close()
+
+	var history []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("failed to scan statement history (thread: %d): %w", threadID, err)
+		}
+		history = append(history, s)
+	}
+	return history, rows.Err()
+}
+
+var (
+	deadlockTransactionPattern = regexp.MustCompile(`^\*\*\* \((\d+)\) TRANSACTION:$`)
+	deadlockThreadPattern      = regexp.MustCompile(`^MySQL thread id (\d+),`)
+	deadlockVictimPattern      = regexp.MustCompile(`^\*\*\* WE ROLL BACK TRANSACTION \((\d+)\)$`)
+)
+
+// parseLatestDeadlock returns the transactions of the LATEST DETECTED DEADLOCK section of the InnoDB status
+// and the label of the one rolled back.
+func parseLatestDeadlock(status string) ([]*deadlockTransaction, string) {
+	var txs []*deadlockTransaction
+	var victim string
+	var current *deadlockTransaction
+	inSection, inStatement := false, false
+
+	scanner := bufio.NewScanner(strings.NewReader(status))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " ")
+		if line == "LATEST DETECTED DEADLOCK" {
+			inSection = true
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if line == "TRANSACTIONS" {
+			break
+		}
+
+		if m := deadlockTransactionPattern.FindStringSubmatch(line); m != nil {
+			current = &deadlockTransaction{label: "(" + m[1] + ")"}
+			txs = append(txs, current)
+			inStatement = false
+			continue
+		}
+		if m := deadlockVictimPattern.FindStringSubmatch(line); m != nil {
+			victim = "(" + m[1] + ")"
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if m := deadlockThreadPattern.FindStringSubmatch(line); m != nil {
+			current.threadID, _ = strconv.ParseInt(m[1], 10, 64)
+			inStatement = true
+			continue
+		}
+		// NOTE: the statement follows the thread line and may span lines until the lock sections
+		if inStatement {
+			if line == "" || strings.HasPrefix(line, "***") {
+				inStatement = false
+				continue
+			}
+			current.statement = strings.TrimSpace(current.statement + " " + line)
+		}
+	}
+	return txs, victim
+}
+
+// InnoDB status with a deadlock, abridged from MySQL 8
+const innodbStatusWithDeadlock = `
+=====================================
+2024-01-01 00:00:00 0x7f INNODB MONITOR OUTPUT
+=====================================
+------------------------
+LATEST DETECTED DEADLOCK
+------------------------
+2024-01-01 00:00:00 0x7f
+*** (1) TRANSACTION:
+TRANSACTION 1850, ACTIVE 1 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 1128, 2 row lock(s), undo log entries 1
+MySQL thread id 9, OS thread handle 140000000000001, query id 40 172.17.0.1 root updating
+UPDATE ` + "`user`" + ` SET ` + "`age`" + ` = 31
+WHERE ` + "`id`" + ` = '1123456789ABCDEFGHJKMNPQRS'
+
+*** (1) HOLDS THE LOCK(S):
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`practice`.`user`" + ` trx id 1850 lock_mode X locks rec but not gap
+
+*** (1) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`practice`.`user`" + ` trx id 1850 lock_mode X locks rec but not gap waiting
+
+*** (2) TRANSACTION:
+TRANSACTION 1851, ACTIVE 1 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 1128, 2 row lock(s), undo log entries 1
+MySQL thread id 10, OS thread handle 140000000000002, query id 41 172.17.0.1 root updating
+UPDATE ` + "`user`" + ` SET ` + "`age`" + ` = 21 WHERE ` + "`id`" + ` = '0123456789ABCDEFGHJKMNPQRS'
+
+*** (2) HOLDS THE LOCK(S):
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`practice`.`user`" + ` trx id 1851 lock_mode X locks rec but not gap
+
+*** WE ROLL BACK TRANSACTION (2)
+------------
+TRANSACTIONS
+------------
+Trx id counter 1852
+MySQL thread id 11, OS thread handle 140000000000003, query id 42 172.17.0.1 root starting
+SHOW ENGINE INNODB STATUS
+`
+
+func TestParseLatestDeadlock(t *testing.T) {
+	tests := []struct {
+		title          string
+		status         string
+		expected       []*deadlockTransaction
+		expectedVictim string
+	}{
+		{
+			"deadlock of two transactions",
+			innodbStatusWithDeadlock,
+			[]*deadlockTransaction{
+				{label: "(1)", threadID: 9, statement: "UPDATE `user` SET `age` = 31 WHERE `id` = '1123456789ABCDEFGHJKMNPQRS'"},
+				{label: "(2)", threadID: 10, statement: "UPDATE `user` SET `age` = 21 WHERE `id` = '0123456789ABCDEFGHJKMNPQRS'"},
+			},
+			"(2)",
+		},
+		{
+			"no deadlock has been detected",
+			"------------\nTRANSACTIONS\n------------\nTrx id counter 1852\n",
+			nil,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual, victim := parseLatestDeadlock(tt.status)
+
+			// assert
+			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.expectedVictim, victim)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestLockConflictReporterWithSQLMock(t *testing.T) {
+	history := regexp.QuoteMeta("SELECT h.SQL_TEXT FROM performance_schema.events_statements_history h")
+
+	tests := []struct {
+		title    string
+		err      error
+		mock     func(sqlmock.Sqlmock)
+		expected []string
+	}{
+		{
+			"deadlock is reported with statements of both transactions",
+			&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SHOW ENGINE INNODB STATUS").
+					WillReturnRows(sqlmock.NewRows([]string{"Type", "Name", "Status"}).AddRow("InnoDB", "", innodbStatusWithDeadlock))
+				mock.ExpectQuery(history).WithArgs(9).
+					WillReturnRows(sqlmock.NewRows([]string{"SQL_TEXT"}).AddRow("BEGIN").AddRow("UPDATE `user` SET `age` = 21 WHERE `id` = '0123456789ABCDEFGHJKMNPQRS'"))
+				mock.ExpectQuery(history).WithArgs(10).
+					WillReturnRows(sqlmock.NewRows([]string{"SQL_TEXT"}).AddRow("BEGIN").AddRow("UPDATE `user` SET `age` = 31 WHERE `id` = '1123456789ABCDEFGHJKMNPQRS'"))
+			},
+			[]string{"deadlock (Deadlock found when trying to get lock; try restarting transaction)\n" +
+				"  transaction (1) (thread 9): UPDATE `user` SET `age` = 31 WHERE `id` = '1123456789ABCDEFGHJKMNPQRS'\n" +
+				"    BEGIN\n" +
+				"    UPDATE `user` SET `age` = 21 WHERE `id` = '0123456789ABCDEFGHJKMNPQRS'\n" +
+				"  transaction (2) (thread 10): UPDATE `user` SET `age` = 21 WHERE `id` = '0123456789ABCDEFGHJKMNPQRS'\n" +
+				"    BEGIN\n" +
+				"    UPDATE `user` SET `age` = 31 WHERE `id` = '1123456789ABCDEFGHJKMNPQRS'\n" +
+				"  rolled back: transaction (2)"},
+		},
+		{
+			"lock wait timeout without sampled waits",
+			fmt.Errorf("failed to update user: %w", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}),
+			func(mock sqlmock.Sqlmock) {},
+			[]string{"lock wait timeout (Lock wait timeout exceeded; try restarting transaction)\n  no lock waits were sampled"},
+		},
+		{
+			"other errors are not reported",
+			&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+		},
+		{
+			"success is not reported",
+			nil,
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+			r := reportLockConflicts(t, db)
+			// NOTE: lock waits are not sampled while f runs
+			r.interval = time.Hour
+
+			// run
+			err := r.do(context.TODO(), func(ctx context.Context) error { return tt.err })
+
+			// assert
+			require.Equal(t, tt.err, err)
+			require.Equal(t, tt.expected, r.reports)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using testcontainers
+func TestLockConflictReporterWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(30)}
+	require.NoError(t, NewUserRepository(db).RegisterAll(ctx, []*User{mike, bob}))
+	updateAge := "UPDATE `user` SET `age` = ? WHERE `id` = ?"
+
+	t.Run("deadlock", func(t *testing.T) {
+		tx1, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx1.Rollback()
+		tx2, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx2.Rollback()
+		_, err = tx1.ExecContext(ctx, updateAge, 21, mike.ID)
+		require.NoError(t, err)
+		_, err = tx2.ExecContext(ctx, updateAge, 31, bob.ID)
+		require.NoError(t, err)
+		r := reportLockConflicts(t, db)
+
+		// run
+		errs := make(chan error, 2)
+		go func() {
+			errs <- r.do(ctx, func(ctx context.Context) error {
+				_, err := tx1.ExecContext(ctx, updateAge, 22, bob.ID)
+				return err
+			})
+		}()
+		// NOTE: the second update closes the cycle after the first one waits for the lock
+		time.Sleep(500 * time.Millisecond)
+		errs <- r.do(ctx, func(ctx context.Context) error {
+			_, err := tx2.ExecContext(ctx, updateAge, 32, mike.ID)
+			return err
+		})
+
+		// assert
+		failed := lo.Filter([]error{<-errs, <-errs}, func(err error, _ int) bool { return err != nil })
+		require.Len(t, failed, 1)
+		var mysqlErr *mysql.MySQLError
+		require.ErrorAs(t, failed[0], &mysqlErr)
+		require.Equal(t, uint16(mysqlErrDeadlock), mysqlErr.Number)
+		require.Len(t, r.reports, 1)
+		require.Contains(t, r.reports[0], "UPDATE `user` SET `age` = 22")
+		require.Contains(t, r.reports[0], "UPDATE `user` SET `age` = 32")
+		require.Contains(t, r.reports[0], "rolled back: transaction")
+	})
+
+	t.Run("lock wait timeout", func(t *testing.T) {
+		blocking, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer blocking.Rollback()
+		_, err = NewUserRepository(db).GetForUpdate(ctx, blocking, mike.ID)
+		require.NoError(t, err)
+
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.ExecContext(ctx, "SET SESSION innodb_lock_wait_timeout = 1")
+		require.NoError(t, err)
+		waiting, err := conn.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer waiting.Rollback()
+		r := reportLockConflicts(t, db)
+
+		// run
+		err = r.do(ctx, func(ctx context.Context) error {
+			_, err := waiting.ExecContext(ctx, updateAge, 23, mike.ID)
+			return err
+		})
+
+		// assert
+		var mysqlErr *mysql.MySQLError
+		require.ErrorAs(t, err, &mysqlErr)
+		require.Equal(t, uint16(mysqlErrLockWaitTimeout), mysqlErr.Number)
+		require.Len(t, r.reports, 1)
+		require.Contains(t, r.reports[0], "waiting:  UPDATE `user` SET `age` = 23")
+		require.Contains(t, r.reports[0], "FOR UPDATE")
+	})
+}
@@ -0,0 +1,425 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// number of rows compared at once by DiffDatabases
+const diffChunkSize = 1000
+
+// DiffRow is a row whose values are normalized to strings, so that rows read from different servers
+// (e.g. go-mysql-server and MySQL) can be compared. NULL is not Valid.
+type DiffRow []sql.NullString
+
+// ChangedRow is a row of the same key with different values in a and b.
+type ChangedRow struct {
+	A DiffRow
+	B DiffRow
+}
+
+// TableDiff is the difference of rows of a table.
+type TableDiff struct {
+	Table   string
+	Columns []string
+	// Key are the columns which identify rows: the primary key, or all columns if the table does not have one.
+	Key []string
+	// OnlyInA and OnlyInB are rows whose key is found in only one of the databases.
+	OnlyInA []DiffRow
+	OnlyInB []DiffRow
+	Changed []*ChangedRow
+}
+
+// Empty reports whether the table has the same rows in both databases.
+func (d *TableDiff) Empty() bool {
+	return len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Changed) == 0
+}
+
+func (d *TableDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d only in a, %d only in b, %d changed\n", d.Table, len(d.OnlyInA), len(d.OnlyInB), len(d.Changed))
+	for _, r := range d.OnlyInA {
+		fmt.Fprintf(&b, "  - (%s)\n", d.format(r, d.Columns))
+	}
+	for _, r := range d.OnlyInB {
+		fmt.Fprintf(&b, "  + (%s)\n", d.format(r, d.Columns))
+	}
+	for _, c := range d.Changed {
+		var changes []string
+		for i, column := range d.Columns {
+			if c.A[i] != c.B[i] {
+				changes = append(changes, fmt.Sprintf("%s: %s -> %s", column, formatDiffValue(c.A[i]), formatDiffValue(c.B[i])))
+			}
+		}
+		fmt.Fprintf(&b, "  ~ (%s) %s\n", d.format(c.A, d.Key), strings.Join(changes, ", "))
+	}
+	return b.String()
+}
+
+// format returns values of the columns in the row, e.g. "id: 0123456789ABCDEFGHJKMNPQRS, name: Mike".
+func (d *TableDiff) format(row DiffRow, columns []string) string {
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = fmt.Sprintf("%s: %s", c, formatDiffValue(row[lo.IndexOf(d.Columns, c)]))
+	}
+	return strings.Join(values, ", ")
+}
+
+func formatDiffValue(v sql.NullString) string {
+	if !v.Valid {
+		return "NULL"
+	}
+	return v.String
+}
+
+// DatabaseDiff is the row-level difference between databases a and b. Tables has only tables with differences.
+type DatabaseDiff struct {
+	Tables []*TableDiff
+}
+
+// Empty reports whether all compared tables have the same rows in both databases.
+func (d *DatabaseDiff) Empty() bool {
+	return len(d.Tables) == 0
+}
+
+func (d *DatabaseDiff) String() string {
+	if d.Empty() {
+		return "no differences"
+	}
+	return strings.TrimSuffix(strings.Join(lo.Map(d.Tables, func(t *TableDiff, _ int) string { return t.String() }), ""), "\n")
+}
+
+// DiffDatabases compares rows of tables (all base tables if none is given) between a and b,
+// e.g. go-mysql-server and MySQL after the same operations, or the primary and the secondary of NewDualWriteUserRepository.
+// Tables must have the same columns in both databases.
+// Rows are read in chunks in a transaction of each database, and rows of the diff are sorted by their keys.
+func DiffDatabases(ctx context.Context, a, b *sql.DB, tables ...string) (*DatabaseDiff, error) {
+	return diffDatabases(ctx, a, b, diffChunkSize, tables...)
+}
+
+func diffDatabases(ctx context.Context, a, b *sql.DB, chunkSize int, tables ...string) (*DatabaseDiff, error) {
+	txA, err := a.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer txA.Rollback()
+	txB, err := b.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer txB.Rollback()
+
+	if len(tables) == 0 {
+		tablesA, err := listBaseTables(ctx, txA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		tablesB, err := listBaseTables(ctx, txB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		onlyInA, onlyInB := lo.Difference(tablesA, tablesB)
+		if len(onlyInA) > 0 || len(onlyInB) > 0 {
+			return nil, fmt.Errorf("failed to diff databases: tables only in a %v, only in b %v", onlyInA, onlyInB)
+		}
+		tables = tablesA
+	}
+
+	diff := &DatabaseDiff{}
+	for _, table := range tables {
+		d, err := diffTable(ctx, txA, txB, table, chunkSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff table %s: %w", table, err)
+		}
+		if !d.Empty() {
+			diff.Tables = append(diff.Tables, d)
+		}
+	}
+	return diff, nil
+}
+
+func diffTable(ctx context.Context, a, b *sql.Tx, table string, chunkSize int) (*TableDiff, error) {
+	columns, jsonColumns, err := tableColumns(ctx, a, table)
+	if err != nil {
+		return nil, err
+	}
+	columnsB, _, err := tableColumns(ctx, b, table)
+	if err != nil {
+		return nil, err
+	}
+	if !lo.Every(columns, columnsB) || len(columns) != len(columnsB) {
+		return nil, fmt.Errorf("columns are different (a: %v, b: %v)", columns, columnsB)
+	}
+	key, err := primaryKey(ctx, a, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		key = columns
+	}
+
+	t := &diffTarget{table: table, columns: columns, jsonColumns: jsonColumns, key: key}
+	d := &TableDiff{Table: table, Columns: columns, Key: key}
+
+	// NOTE: rows of a are looked up in b and vice versa, so that the result does not depend on the order of rows in each server
+	// (e.g. collations)
+	err = t.eachChunk(ctx, a, chunkSize, func(rows []DiffRow) error {
+		found, err := t.lookup(ctx, b, rows)
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			other, ok := found[t.keyOf(r)]
+			switch {
+			case !ok:
+				d.OnlyInA = append(d.OnlyInA, r)
+			case !rowsEqual(r, other):
+				d.Changed = append(d.Changed, &ChangedRow{A: r, B: other})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = t.eachChunk(ctx, b, chunkSize, func(rows []DiffRow) error {
+		found, err := t.lookup(ctx, a, rows)
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if _, ok := found[t.keyOf(r)]; !ok {
+				d.OnlyInB = append(d.OnlyInB, r)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(d.OnlyInA, func(i, j int) bool { return t.keyOf(d.OnlyInA[i]) < t.keyOf(d.OnlyInA[j]) })
+	sort.Slice(d.OnlyInB, func(i, j int) bool { return t.keyOf(d.OnlyInB[i]) < t.keyOf(d.OnlyInB[j]) })
+	sort.Slice(d.Changed, func(i, j int) bool { return t.keyOf(d.Changed[i].A) < t.keyOf(d.Changed[j].A) })
+	return d, nil
+}
+
+// tableColumns returns names of columns of the table, and whether each of them is JSON.
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, []bool, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT 0", quoteIdentifier(table)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	return lo.Map(types, func(c *sql.ColumnType, _ int) string { return c.Name() }),
+		lo.Map(types, func(c *sql.ColumnType, _ int) bool { return c.DatabaseTypeName() == "JSON" }),
+		nil
+}
+
+var (
+	primaryKeyPattern = regexp.MustCompile(`(?m)^\s*PRIMARY KEY \((.+)\)`)
+	identifierPattern = regexp.MustCompile("`((?:[^`]|``)+)`")
+)
+
+// primaryKey returns columns of the primary key of the table, or nil if it does not have one.
+// NOTE: SHOW CREATE TABLE is parsed because go-mysql-server does not show primary keys in information_schema
+func primaryKey(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	var name, create string
+	if err := tx.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoteIdentifier(table)).Scan(&name, &create); err != nil {
+		return nil, fmt.Errorf("failed to show create table: %w", err)
+	}
+	m := primaryKeyPattern.FindStringSubmatch(create)
+	if m == nil {
+		return nil, nil
+	}
+	return lo.Map(identifierPattern.FindAllStringSubmatch(m[1], -1), func(m []string, _ int) string {
+		return strings.ReplaceAll(m[1], "``", "`")
+	}), nil
+}
+
+// diffTarget reads rows of a table to compare.
+type diffTarget struct {
+	table       string
+	columns     []string
+	jsonColumns []bool
+	key         []string
+}
+
+// eachChunk calls f with rows of the table in the order of the key, chunkSize rows at a time.
+func (t *diffTarget) eachChunk(ctx context.Context, tx *sql.Tx, chunkSize int, f func([]DiffRow) error) error {
+	keys := lo.Map(t.key, func(c string, _ int) string { return quoteIdentifier(c) })
+	selectRows := fmt.Sprintf("SELECT %s FROM %s", strings.Join(lo.Map(t.columns, func(c string, _ int) string { return quoteIdentifier(c) }), ", "), quoteIdentifier(t.table))
+
+	var last DiffRow
+	for offset := 0; ; offset += chunkSize {
+		query := selectRows
+		var args []interface{}
+		switch {
+		// NOTE: a key of all columns may have NULL, which cannot be compared by >, so such tables are read by offset
+		case len(t.key) == len(t.columns):
+			query += fmt.Sprintf(" ORDER BY %s LIMIT %d OFFSET %d", strings.Join(keys, ", "), chunkSize, offset)
+		case last != nil:
+			// (k1, k2) > (v1, v2) is written as k1 > v1 OR (k1 = v1 AND k2 > v2)
+			var conditions []string
+			for i := range keys {
+				var terms []string
+				for j := 0; j < i; j++ {
+					terms = append(terms, keys[j]+" = ?")
+					args = append(args, last[lo.IndexOf(t.columns, t.key[j])].String)
+				}
+				terms = append(terms, keys[i]+" > ?")
+				args = append(args, last[lo.IndexOf(t.columns, t.key[i])].String)
+				conditions = append(conditions, "("+strings.Join(terms, " AND ")+")")
+			}
+			query += fmt.Sprintf(" WHERE %s ORDER BY %s LIMIT %d", strings.Join(conditions, " OR "), strings.Join(keys, ", "), chunkSize)
+		default:
+			query += fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(keys, ", "), chunkSize)
+		}
+
+		rows, err := t.query(ctx, tx, query, args...)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := f(rows); err != nil {
+			return err
+		}
+		if len(rows) < chunkSize {
+			return nil
+		}
+		last = rows[len(rows)-1]
+	}
+}
+
+// lookup returns rows of the table which have the same keys as rows, by their keys.
+func (t *diffTarget) lookup(ctx context.Context, tx *sql.Tx, rows []DiffRow) (map[string]DiffRow, error) {
+	var conditions []string
+	var args []interface{}
+	for _, r := range rows {
+		terms := make([]string, len(t.key))
+		for i, c := range t.key {
+			// NOTE: <=> matches NULL as well
+			terms[i] = quoteIdentifier(c) + " <=> ?"
+			v := r[lo.IndexOf(t.columns, c)]
+			if v.Valid {
+				args = append(args, v.String)
+			} else {
+				args = append(args, nil)
+			}
+		}
+		conditions = append(conditions, "("+strings.Join(terms, " AND ")+")")
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		strings.Join(lo.Map(t.columns, func(c string, _ int) string { return quoteIdentifier(c) }), ", "),
+		quoteIdentifier(t.table), strings.Join(conditions, " OR "))
+
+	found, err := t.query(ctx, tx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return lo.KeyBy(found, t.keyOf), nil
+}
+
+func (t *diffTarget) query(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]DiffRow, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select rows: %w", err)
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(t.columns))
+	dest := make([]interface{}, len(t.columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var result []DiffRow
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		row := make(DiffRow, len(values))
+		for i, v := range values {
+			if row[i], err = normalizeDiffValue(v, t.jsonColumns[i]); err != nil {
+				return nil, fmt.Errorf("failed to read column %s: %w", t.columns[i], err)
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select rows: %w", err)
+	}
+	return result, nil
+}
+
+// keyOf returns a string which identifies the row by the key.
+func (t *diffTarget) keyOf(row DiffRow) string {
+	values := make([]string, len(t.key))
+	for i, c := range t.key {
+		v := row[lo.IndexOf(t.columns, c)]
+		// NOTE: NULL is distinguished from any string by the prefix
+		if v.Valid {
+			values[i] = "v" + v.String
+		} else {
+			values[i] = "n"
+		}
+	}
+	return strings.Join(values, "\x00")
+}
+
+func rowsEqual(a, b DiffRow) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeDiffValue converts a scanned value to the string, which is the same whichever server or protocol returns it.
+func normalizeDiffValue(v interface{}, isJSON bool) (sql.NullString, error) {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return sql.NullString{}, nil
+	case []byte:
+		s = string(v)
+		// NOTE: servers format JSON differently (e.g. spaces), so it is compared as the value
+		if isJSON {
+			var j interface{}
+			if err := json.Unmarshal(v, &j); err != nil {
+				return sql.NullString{}, err
+			}
+			b, err := json.Marshal(j)
+			if err != nil {
+				return sql.NullString{}, err
+			}
+			s = string(b)
+		}
+	case time.Time:
+		s = v.UTC().Format("2006-01-02 15:04:05.999999")
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		s = fmt.Sprint(v)
+	}
+	return sql.NullString{String: s, Valid: true}, nil
+}
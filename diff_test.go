@@ -0,0 +1,257 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-mysql-server
+func TestDiffDatabasesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	portA := prepareMigratedSimulator(ctx, t)
+	portB := prepareMigratedSimulator(ctx, t)
+	a, err := newMigrationClient(portA)
+	require.NoError(t, err)
+	b, err := newMigrationClient(portB)
+	require.NoError(t, err)
+
+	users := generateUsers(t, 7)
+	for _, db := range []*sql.DB{a, b} {
+		seedUsers(ctx, t, db, users)
+	}
+	// NOTE: the same JSON is formatted differently
+	_, err = a.ExecContext(ctx, "INSERT INTO `audit_log` (`id`, `user_id`, `action`, `actor`, `created_at`, `after_json`) "+
+		"VALUES (1, ?, 'register', 'admin', '2022-11-01 00:00:00', '{\"name\": \"Mike\", \"age\": 20}')", users[0].ID)
+	require.NoError(t, err)
+	_, err = b.ExecContext(ctx, "INSERT INTO `audit_log` (`id`, `user_id`, `action`, `actor`, `created_at`, `after_json`) "+
+		"VALUES (1, ?, 'register', 'admin', '2022-11-01 00:00:00', '{\"age\":20,\"name\":\"Mike\"}')", users[0].ID)
+	require.NoError(t, err)
+
+	t.Run("same rows", func(t *testing.T) {
+		// run
+		diff, err := diffDatabases(ctx, a, b, 2)
+
+		// assert
+		require.NoError(t, err)
+		require.True(t, diff.Empty(), diff)
+		require.Equal(t, "no differences", diff.String())
+	})
+
+	t.Run("different rows", func(t *testing.T) {
+		onlyInA := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+		onlyInB := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25), Email: "bob@example.com"}
+		require.NoError(t, NewUserRepository(a, WithClock(newFakeClock())).Register(ctx, onlyInA))
+		require.NoError(t, NewUserRepository(b, WithClock(newFakeClock())).Register(ctx, onlyInB))
+		// NOTE: changed rows are in different chunks
+		_, err := b.ExecContext(ctx, "UPDATE `user` SET `age` = `age` + 1 WHERE `id` IN (?, ?)", users[1].ID, users[6].ID)
+		require.NoError(t, err)
+		_, err = b.ExecContext(ctx, "UPDATE `user` SET `email` = 'changed@example.com' WHERE `id` = ?", users[3].ID)
+		require.NoError(t, err)
+
+		// run
+		diff, err := diffDatabases(ctx, a, b, 2, models.TableNames.User, models.TableNames.AuditLog)
+
+		// assert
+		require.NoError(t, err)
+		require.Len(t, diff.Tables, 1)
+		d := diff.Tables[0]
+		require.Equal(t, models.TableNames.User, d.Table)
+		require.Equal(t, []string{models.UserColumns.ID}, d.Key)
+		require.Equal(t, []DiffRow{diffRow(onlyInA.ID, "Mike", "20", nil, nil, "1", "2022-11-01 00:00:00", "2022-11-01 00:00:00")}, d.OnlyInA)
+		require.Equal(t, []DiffRow{diffRow(onlyInB.ID, "Bob", "25", nil, "bob@example.com", "1", "2022-11-01 00:00:00", "2022-11-01 00:00:00")}, d.OnlyInB)
+		require.Len(t, d.Changed, 3)
+
+		changed := []*User{users[1], users[3], users[6]}
+		sort.Slice(changed, func(i, j int) bool { return changed[i].ID < changed[j].ID })
+		lines := strings.Split(diff.String(), "\n")
+		require.Equal(t, []string{
+			"user: 1 only in a, 1 only in b, 3 changed",
+			"  - (id: 0123456789ABCDEFGHJKMNPQRS, name: Mike, age: 20, deleted_at: NULL, email: NULL, version: 1, created_at: 2022-11-01 00:00:00, updated_at: 2022-11-01 00:00:00)",
+			"  + (id: 1123456789ABCDEFGHJKMNPQRS, name: Bob, age: 25, deleted_at: NULL, email: bob@example.com, version: 1, created_at: 2022-11-01 00:00:00, updated_at: 2022-11-01 00:00:00)",
+		}, lines[:3])
+		for i, u := range changed {
+			require.True(t, strings.HasPrefix(lines[3+i], "  ~ (id: "+u.ID+") "), lines[3+i])
+		}
+		require.Contains(t, diff.String(), "email: NULL -> changed@example.com")
+	})
+}
+
+func diffRow(values ...interface{}) DiffRow {
+	row := make(DiffRow, len(values))
+	for i, v := range values {
+		if v != nil {
+			row[i] = sql.NullString{String: v.(string), Valid: true}
+		}
+	}
+	return row
+}
+
+// test using go-mysql-server
+func TestDiffDatabasesWithoutPrimaryKeyWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	portA, err := testport.Reserve()
+	require.NoError(t, err)
+	portB, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, portA)
+	prepareEmptySimulator(t, portB)
+	a, err := newMigrationClient(portA)
+	require.NoError(t, err)
+	b, err := newMigrationClient(portB)
+	require.NoError(t, err)
+	for _, db := range []*sql.DB{a, b} {
+		_, err := db.ExecContext(ctx, "CREATE TABLE `tag` (`name` VARCHAR(40), `note` VARCHAR(40))")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "INSERT INTO `tag` VALUES ('go', NULL), ('sql', 'database'), ('test', NULL)")
+		require.NoError(t, err)
+	}
+	_, err = b.ExecContext(ctx, "UPDATE `tag` SET `note` = 'language' WHERE `name` = 'go'")
+	require.NoError(t, err)
+
+	// run
+	diff, err := diffDatabases(ctx, a, b, 2)
+
+	// assert
+	require.NoError(t, err)
+	require.Len(t, diff.Tables, 1)
+	d := diff.Tables[0]
+	// NOTE: rows are identified by all columns, so a changed row is found in only one database
+	require.Equal(t, []string{"name", "note"}, d.Key)
+	require.Equal(t, []DiffRow{diffRow("go", nil)}, d.OnlyInA)
+	require.Equal(t, []DiffRow{diffRow("go", "language")}, d.OnlyInB)
+	require.Empty(t, d.Changed)
+}
+
+// test using go-mysql-server
+func TestDiffDatabasesErrorsWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		title       string
+		prepareB    func(db *sql.DB) error
+		tables      []string
+		expectedErr string
+	}{
+		{
+			"table is only in one database",
+			func(db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "CREATE TABLE `tag` (`name` VARCHAR(40) PRIMARY KEY)")
+				return err
+			},
+			nil,
+			"failed to diff databases: tables only in a [], only in b [tag]",
+		},
+		{
+			"columns are different",
+			func(db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "ALTER TABLE `user` ADD COLUMN `nickname` VARCHAR(40)")
+				return err
+			},
+			[]string{models.TableNames.User},
+			"failed to diff table user: columns are different (a: [id name age deleted_at email version created_at updated_at], b: [id name age deleted_at email version created_at updated_at nickname])",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			portA := prepareMigratedSimulator(ctx, t)
+			portB := prepareMigratedSimulator(ctx, t)
+			a, err := newMigrationClient(portA)
+			require.NoError(t, err)
+			b, err := newMigrationClient(portB)
+			require.NoError(t, err)
+			require.NoError(t, tt.prepareB(b))
+
+			// run
+			_, err = DiffDatabases(ctx, a, b, tt.tables...)
+
+			// assert
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestDiffDatabasesOfDualWriteWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	portA, err := testport.Reserve()
+	require.NoError(t, err)
+	portB, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, portA)
+	prepareSimulator(t, portB)
+	primary, err := NewClient(portA)
+	require.NoError(t, err)
+	secondary, err := NewClient(portB)
+	require.NoError(t, err)
+
+	// run
+	recorder := NewLogDivergenceRecorder(log.New(&strings.Builder{}, "", 0))
+	r := NewDualWriteUserRepository(NewUserRepository(primary), NewUserRepository(secondary), recorder)
+	users := generateUsers(t, 5)
+	// NOTE: Delete is not mirrored exactly, because each repository sets deleted_at by its own clock
+	for _, u := range users {
+		require.NoError(t, r.Register(ctx, u))
+	}
+
+	// assert
+	diff, err := DiffDatabases(ctx, primary, secondary)
+	require.NoError(t, err)
+	require.True(t, diff.Empty(), diff)
+
+	// a write bypassing the decorator is found
+	require.NoError(t, NewUserRepository(primary).Delete(ctx, users[3]))
+	diff, err = DiffDatabases(ctx, primary, secondary)
+	require.NoError(t, err)
+	require.Len(t, diff.Tables, 1)
+	require.Len(t, diff.Tables[0].Changed, 1)
+	require.Contains(t, diff.String(), "(id: "+users[3].ID+") deleted_at: ")
+}
+
+// test using testcontainers
+func TestDiffSimulatorAndContainerWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	container := prepareContainer(ctx, t)
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	simulator, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	// run
+	users := generateUsers(t, 20)
+	for _, db := range []*sql.DB{simulator, container} {
+		// NOTE: the fake clock writes the same created_at and updated_at to both
+		r := NewUserRepository(db, WithClock(newFakeClock()))
+		require.NoError(t, r.RegisterAll(ctx, users))
+		require.NoError(t, r.Delete(ctx, users[0]))
+		require.NoError(t, r.Upsert(ctx, &User{ID: users[1].ID, Name: users[1].Name, Age: lo.ToPtr(99)}))
+	}
+
+	// assert
+	// NOTE: deleted_at is set by the clock of each server, so deleted users are compared by another query
+	_, err = simulator.ExecContext(ctx, "UPDATE `user` SET `deleted_at` = '2022-11-01 00:00:00' WHERE `deleted_at` IS NOT NULL")
+	require.NoError(t, err)
+	_, err = container.ExecContext(ctx, "UPDATE `user` SET `deleted_at` = '2022-11-01 00:00:00' WHERE `deleted_at` IS NOT NULL")
+	require.NoError(t, err)
+	diff, err := DiffDatabases(ctx, simulator, container, models.TableNames.User, "schema_migrations")
+	require.NoError(t, err)
+	require.True(t, diff.Empty(), diff)
+}
@@ -0,0 +1,152 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// Divergence is a difference between the primary and the secondary repository.
+type Divergence struct {
+	Operation string
+	Primary   interface{}
+	Secondary interface{}
+	// Err is an error which occurred only in the secondary
+	Err error
+	// PrimaryErr is an error which occurred only in the primary (other than sql.ErrNoRows)
+	PrimaryErr error
+}
+
+func (d *Divergence) String() string {
+	if d.Err != nil {
+		return fmt.Sprintf("%s: secondary failed: %s", d.Operation, d.Err)
+	}
+	if d.PrimaryErr != nil {
+		return fmt.Sprintf("%s: primary failed: %s, secondary %+v", d.Operation, d.PrimaryErr, d.Secondary)
+	}
+	return fmt.Sprintf("%s: primary %+v, secondary %+v", d.Operation, d.Primary, d.Secondary)
+}
+
+type DivergenceRecorder interface {
+	Record(ctx context.Context, d *Divergence)
+}
+
+type logDivergenceRecorder struct {
+	logger *log.Logger
+}
+
+func NewLogDivergenceRecorder(logger *log.Logger) *logDivergenceRecorder {
+	return &logDivergenceRecorder{
+		logger: logger,
+	}
+}
+
+func (r *logDivergenceRecorder) Record(ctx context.Context, d *Divergence) {
+	r.logger.Printf("divergence detected: %s", d)
+}
+
+// dualWriteUserRepository mirrors writes to the secondary repository and compares reads of both,
+// which is used to migrate data online. The primary is always the source of truth.
+type dualWriteUserRepository struct {
+	primary   UserRepository
+	secondary UserRepository
+	recorder  DivergenceRecorder
+}
+
+func NewDualWriteUserRepository(primary, secondary UserRepository, recorder DivergenceRecorder) *dualWriteUserRepository {
+	return &dualWriteUserRepository{
+		primary:   primary,
+		secondary: secondary,
+		recorder:  recorder,
+	}
+}
+
+var _ UserRepository = (*dualWriteUserRepository)(nil)
+
+func (r *dualWriteUserRepository) Register(ctx context.Context, user *User) error {
+	if err := r.primary.Register(ctx, user); err != nil {
+		return err
+	}
+
+	if err := r.secondary.Register(ctx, user); err != nil {
+		r.recorder.Record(ctx, &Divergence{Operation: fmt.Sprintf("Register(%s)", user.ID), Err: err})
+	}
+
+	return nil
+}
+
+func (r *dualWriteUserRepository) Delete(ctx context.Context, user *User) error {
+	if err := r.primary.Delete(ctx, user); err != nil {
+		return err
+	}
+
+	if err := r.secondary.Delete(ctx, user); err != nil {
+		r.recorder.Record(ctx, &Divergence{Operation: fmt.Sprintf("Delete(%s)", user.ID), Err: err})
+	}
+
+	return nil
+}
+
+func (r *dualWriteUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	user, err := r.primary.Get(ctx, id)
+	secondaryUser, secondaryErr := r.secondary.Get(ctx, id)
+	compareResults(ctx, r.recorder, fmt.Sprintf("Get(%s)", id), user, err, secondaryUser, secondaryErr)
+
+	return user, err
+}
+
+func (r *dualWriteUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	user, err := r.primary.GetByName(ctx, name)
+	secondaryUser, secondaryErr := r.secondary.GetByName(ctx, name)
+	compareResults(ctx, r.recorder, fmt.Sprintf("GetByName(%s)", name), user, err, secondaryUser, secondaryErr)
+
+	return user, err
+}
+
+type listResult struct {
+	Users []*User
+	Total int64
+}
+
+func (r *dualWriteUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	users, total, err := r.primary.List(ctx, query)
+	secondaryUsers, secondaryTotal, secondaryErr := r.secondary.List(ctx, query)
+	compareResults(ctx, r.recorder, "List",
+		&listResult{Users: users, Total: total}, err,
+		&listResult{Users: secondaryUsers, Total: secondaryTotal}, secondaryErr,
+	)
+
+	return users, total, err
+}
+
+// compareResults records a divergence if results of the primary and the secondary are different.
+func compareResults(
+	ctx context.Context,
+	recorder DivergenceRecorder,
+	operation string,
+	primary interface{},
+	primaryErr error,
+	secondary interface{},
+	secondaryErr error,
+) {
+	switch {
+	case primaryErr == nil && secondaryErr == nil:
+		if !reflect.DeepEqual(primary, secondary) {
+			recorder.Record(ctx, &Divergence{Operation: operation, Primary: primary, Secondary: secondary})
+		}
+	case primaryErr != nil && secondaryErr != nil:
+		// NOTE: both are not found or both are broken
+		return
+	case errors.Is(secondaryErr, sql.ErrNoRows):
+		recorder.Record(ctx, &Divergence{Operation: operation, Primary: primary, Secondary: nil})
+	case errors.Is(primaryErr, sql.ErrNoRows):
+		recorder.Record(ctx, &Divergence{Operation: operation, Primary: nil, Secondary: secondary})
+	case secondaryErr != nil:
+		recorder.Record(ctx, &Divergence{Operation: operation, Err: secondaryErr})
+	case primaryErr != nil:
+		recorder.Record(ctx, &Divergence{Operation: operation, Secondary: secondary, PrimaryErr: primaryErr})
+	}
+}
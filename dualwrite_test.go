@@ -0,0 +1,238 @@
+package gosqltests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/memory"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+type recordingDivergenceRecorder struct {
+	mu          sync.Mutex
+	divergences []*Divergence
+}
+
+func (r *recordingDivergenceRecorder) Record(ctx context.Context, d *Divergence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.divergences = append(r.divergences, d)
+}
+
+func (r *recordingDivergenceRecorder) Divergences() []*Divergence {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Divergence{}, r.divergences...)
+}
+
+// test using two go-mysql-server instances
+func TestDualWriteWithGoMySQLServer(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	type expectedDivergence struct {
+		operation       string
+		primary         interface{}
+		secondary       interface{}
+		secondaryFailed bool
+	}
+
+	tests := []struct {
+		title               string
+		prepareSecondary    func(*simsql.Context, *memory.Table)
+		run                 func(context.Context, UserRepository) error
+		expectedDivergences []expectedDivergence
+	}{
+		{
+			"writes are mirrored",
+			func(ctx *simsql.Context, table *memory.Table) {},
+			func(ctx context.Context, r UserRepository) error {
+				if err := r.Register(ctx, mike); err != nil {
+					return err
+				}
+				if _, err := r.Get(ctx, mike.ID); err != nil {
+					return err
+				}
+				_, _, err := r.List(ctx, nil)
+				return err
+			},
+			nil,
+		},
+		{
+			"deletes are mirrored",
+			func(ctx *simsql.Context, table *memory.Table) {},
+			func(ctx context.Context, r UserRepository) error {
+				if err := r.Register(ctx, mike); err != nil {
+					return err
+				}
+				if err := r.Delete(ctx, mike); err != nil {
+					return err
+				}
+				_, _, err := r.List(ctx, nil)
+				return err
+			},
+			nil,
+		},
+		{
+			"secondary write fails",
+			func(ctx *simsql.Context, table *memory.Table) {
+				_ = table.Insert(ctx, simsql.NewRow(
+					"0123456789ABCDEFGHJKMNPQRS",
+					"Michael",
+					int32(25),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
+				))
+			},
+			func(ctx context.Context, r UserRepository) error {
+				return r.Register(ctx, mike)
+			},
+			[]expectedDivergence{
+				{operation: "Register(0123456789ABCDEFGHJKMNPQRS)", secondaryFailed: true},
+			},
+		},
+		{
+			"row is missing in secondary",
+			func(ctx *simsql.Context, table *memory.Table) {},
+			func(ctx context.Context, r UserRepository) error {
+				dual := r.(*dualWriteUserRepository)
+				if err := dual.primary.Register(ctx, mike); err != nil {
+					return err
+				}
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			[]expectedDivergence{
+				{operation: "Get(0123456789ABCDEFGHJKMNPQRS)", primary: mike},
+			},
+		},
+		{
+			"rows are different",
+			func(ctx *simsql.Context, table *memory.Table) {
+				_ = table.Insert(ctx, simsql.NewRow(
+					"0123456789ABCDEFGHJKMNPQRS",
+					"Mike",
+					int32(21),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
+				))
+			},
+			func(ctx context.Context, r UserRepository) error {
+				dual := r.(*dualWriteUserRepository)
+				if err := dual.primary.Register(ctx, mike); err != nil {
+					return err
+				}
+				_, err := r.GetByName(ctx, mike.Name)
+				return err
+			},
+			[]expectedDivergence{
+				{
+					operation: "GetByName(Mike)",
+					primary:   mike,
+					secondary: &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(21), CreatedAt: seededAt, UpdatedAt: seededAt},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			primaryPort, err := testport.Reserve()
+			require.NoError(t, err)
+			prepareSimulator(t, primaryPort)
+			secondaryPort, err := testport.Reserve()
+			require.NoError(t, err)
+			secondaryTable := prepareSimulator(t, secondaryPort)
+			tt.prepareSecondary(simsql.NewEmptyContext(), secondaryTable)
+
+			primaryDB, err := NewStrictClient(primaryPort)
+			require.NoError(t, err)
+			secondaryDB, err := NewStrictClient(secondaryPort)
+			require.NoError(t, err)
+
+			// run
+			recorder := &recordingDivergenceRecorder{}
+			r := NewDualWriteUserRepository(NewUserRepository(primaryDB), NewUserRepository(secondaryDB), recorder)
+			err = tt.run(context.TODO(), r)
+
+			// assert
+			require.NoError(t, err)
+			divergences := recorder.Divergences()
+			require.Len(t, divergences, len(tt.expectedDivergences))
+			for i, expected := range tt.expectedDivergences {
+				require.Equal(t, expected.operation, divergences[i].Operation)
+				require.Equal(t, expected.primary, divergences[i].Primary)
+				require.Equal(t, expected.secondary, divergences[i].Secondary)
+				require.Equal(t, expected.secondaryFailed, divergences[i].Err != nil)
+			}
+		})
+	}
+}
+
+// test using mocked repositories
+func TestDualWritePrimaryReadFails(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	primaryErr := errors.New("crashed unexpectedly!!!")
+
+	// mock
+	primary := &mockUserRepository{err: primaryErr}
+	secondary := &mockUserRepository{users: map[string]*User{"Mike": mike}}
+
+	// run
+	recorder := &recordingDivergenceRecorder{}
+	r := NewDualWriteUserRepository(primary, secondary, recorder)
+	_, err := r.GetByName(context.TODO(), mike.Name)
+
+	// assert
+	// NOTE: the error of the primary is returned, and the divergence is recorded even though the secondary succeeded
+	require.ErrorIs(t, err, primaryErr)
+	require.Equal(t, []*Divergence{
+		{Operation: "GetByName(Mike)", Secondary: mike, PrimaryErr: primaryErr},
+	}, recorder.Divergences())
+}
+
+func TestLogDivergenceRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewLogDivergenceRecorder(log.New(&buf, "", 0))
+
+	recorder.Record(context.TODO(), &Divergence{
+		Operation: "Get(0123456789ABCDEFGHJKMNPQRS)",
+		Primary:   &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		Secondary: &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(21)},
+	})
+
+	require.Equal(t,
+		"divergence detected: Get(0123456789ABCDEFGHJKMNPQRS): primary &{ID:0123456789ABCDEFGHJKMNPQRS Name:Mike Age:20 Email:}, secondary &{ID:0123456789ABCDEFGHJKMNPQRS Name:Mike Age:21 Email:}\n",
+		buf.String(),
+	)
+}
+
+func TestLogDivergenceRecorderPrimaryFailed(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewLogDivergenceRecorder(log.New(&buf, "", 0))
+
+	recorder.Record(context.TODO(), &Divergence{
+		Operation:  "GetByName(Mike)",
+		Secondary:  &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		PrimaryErr: errors.New("crashed unexpectedly!!!"),
+	})
+
+	require.Equal(t,
+		"divergence detected: GetByName(Mike): primary failed: crashed unexpectedly!!!, secondary &{ID:0123456789ABCDEFGHJKMNPQRS Name:Mike Age:20 Email:}\n",
+		buf.String(),
+	)
+}
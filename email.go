@@ -0,0 +1,83 @@
+package gosqltests
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrEmailTaken is returned when the email is already used by another user.
+var ErrEmailTaken = errors.New("email is already taken")
+
+// MySQL error number of unique key violations
+const mysqlErrDupEntry = 1062 // ER_DUP_ENTRY
+
+// messages of duplicate-key errors
+// NOTE: go-mysql-server reports only the duplicated value, not the key
+var (
+	mysqlDupEntryPattern     = regexp.MustCompile(`^Duplicate entry '(.*)' for key '(.*)'$`)
+	simulatorDupEntryPattern = regexp.MustCompile(`^duplicate unique key given: \[(.*)\]$`)
+)
+
+// EmailTakenError is a write failure caused by the unique key of email.
+type EmailTakenError struct {
+	Email string
+	Err   error
+}
+
+func (e *EmailTakenError) Error() string {
+	return fmt.Sprintf("%s (email: %s): %s", ErrEmailTaken, e.Email, e.Err)
+}
+
+func (e *EmailTakenError) Unwrap() error {
+	return e.Err
+}
+
+func (e *EmailTakenError) Is(target error) bool {
+	return target == ErrEmailTaken
+}
+
+// duplicateEntry returns the value and the key (empty if unknown) of a duplicate-key error.
+func duplicateEntry(err error) (value, key string, ok bool) {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDupEntry {
+		return "", "", false
+	}
+
+	if m := mysqlDupEntryPattern.FindStringSubmatch(mysqlErr.Message); m != nil {
+		return m[1], m[2], true
+	}
+	if m := simulatorDupEntryPattern.FindStringSubmatch(mysqlErr.Message); m != nil {
+		return m[1], "", true
+	}
+	return "", "", true
+}
+
+// wrapEmailTakenError converts the duplicate-key error of the email of user into EmailTakenError
+// and returns other errors as they are.
+func wrapEmailTakenError(err error, user *User) error {
+	if user.Email == "" {
+		return err
+	}
+
+	value, key, ok := duplicateEntry(err)
+	if !ok {
+		return err
+	}
+
+	switch key {
+	// NOTE: MySQL 8.0.19 or later qualifies the key by the table name
+	case models.UserColumns.Email, models.UserTableColumns.Email:
+		return &EmailTakenError{Email: user.Email, Err: err}
+	case "":
+		// the key is unknown, so compare the duplicated value instead
+		if value == user.Email {
+			return &EmailTakenError{Email: user.Email, Err: err}
+		}
+	}
+	return err
+}
@@ -0,0 +1,299 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+func TestWrapEmailTakenError(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+
+	tests := []struct {
+		title    string
+		user     *User
+		err      error
+		expected bool
+	}{
+		{
+			"key qualified by the table (MySQL 8.0.19 or later)",
+			mike,
+			&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'mike@example.com' for key 'user.email'"},
+			true,
+		},
+		{
+			"key not qualified by the table",
+			mike,
+			&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'mike@example.com' for key 'email'"},
+			true,
+		},
+		{
+			"value without key (go-mysql-server)",
+			mike,
+			&mysql.MySQLError{Number: 1062, Message: "duplicate unique key given: [mike@example.com]"},
+			true,
+		},
+		{
+			"other key",
+			mike,
+			&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'Mike' for key 'user.name'"},
+			false,
+		},
+		{
+			"other value without key",
+			mike,
+			&mysql.MySQLError{Number: 1062, Message: "duplicate unique key given: [Mike]"},
+			false,
+		},
+		{
+			"user without email",
+			&User{ID: mike.ID, Name: mike.Name, Age: mike.Age},
+			&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '' for key 'user.email'"},
+			false,
+		},
+		{
+			"other error",
+			mike,
+			&mysql.MySQLError{Number: 1114, Message: "The table 'user' is full"},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			err := wrapEmailTakenError(tt.err, tt.user)
+
+			// assert
+			require.Equal(t, tt.expected, errors.Is(err, ErrEmailTaken))
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestRegisterEmailTakenWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, "mike@example.com", 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'mike@example.com' for key 'user.email'"})
+
+	// run
+	r := NewUserRepository(db)
+	err := r.Register(context.TODO(), &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"})
+
+	// assert
+	require.ErrorIs(t, err, ErrEmailTaken)
+	require.EqualError(t, err, "failed to insert user: email is already taken (email: mike@example.com): models: unable to insert into user: Error 1062: Duplicate entry 'mike@example.com' for key 'user.email'")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByEmailWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`email` = ?) AND (`user`.`deleted_at` is null) LIMIT 1")).
+		WithArgs("mike@example.com").
+		WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, "mike@example.com", 1, time.Time{}, time.Time{}))
+
+	// run
+	r := NewUserRepository(db)
+	actual, err := r.GetByEmail(context.TODO(), "mike@example.com")
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}, actual)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type emailUserRepository interface {
+	UserRepository
+	GetByEmail(ctx context.Context, email string) (*User, error)
+}
+
+type emailCase struct {
+	title       string
+	seed        []*User
+	run         func(context.Context, emailUserRepository) (interface{}, error)
+	expected    interface{}
+	expectedErr error
+}
+
+func emailCases() []*emailCase {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	mary := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)}
+
+	return []*emailCase{
+		{
+			"get a user by email",
+			[]*User{mike, bob},
+			func(ctx context.Context, r emailUserRepository) (interface{}, error) {
+				return r.GetByEmail(ctx, mike.Email)
+			},
+			mike,
+			nil,
+		},
+		{
+			"user of the email is not found",
+			[]*User{mike},
+			func(ctx context.Context, r emailUserRepository) (interface{}, error) {
+				return r.GetByEmail(ctx, "bob@example.com")
+			},
+			nil,
+			sql.ErrNoRows,
+		},
+		{
+			"users without email are not found by empty email",
+			[]*User{bob},
+			func(ctx context.Context, r emailUserRepository) (interface{}, error) {
+				return r.GetByEmail(ctx, "")
+			},
+			nil,
+			sql.ErrNoRows,
+		},
+		{
+			"soft-deleted user is not found by email",
+			[]*User{mike},
+			func(ctx context.Context, r emailUserRepository) (interface{}, error) {
+				if err := r.Delete(ctx, mike); err != nil {
+					return nil, err
+				}
+				return r.GetByEmail(ctx, mike.Email)
+			},
+			nil,
+			sql.ErrNoRows,
+		},
+		{
+			"email is taken",
+			[]*User{mike},
+			func(ctx context.Context, r emailUserRepository) (interface{}, error) {
+				return nil, r.Register(ctx, &User{ID: bob.ID, Name: bob.Name, Age: bob.Age, Email: mike.Email})
+			},
+			nil,
+			ErrEmailTaken,
+		},
+		{
+			"email of a soft-deleted user is taken",
+			[]*User{mike},
+			func(ctx context.Context, r emailUserRepository) (interface{}, error) {
+				if err := r.Delete(ctx, mike); err != nil {
+					return nil, err
+				}
+				return nil, r.Register(ctx, &User{ID: bob.ID, Name: bob.Name, Age: bob.Age, Email: mike.Email})
+			},
+			nil,
+			ErrEmailTaken,
+		},
+		{
+			"users without email do not conflict",
+			[]*User{bob},
+			func(ctx context.Context, r emailUserRepository) (interface{}, error) {
+				if err := r.Register(ctx, mary); err != nil {
+					return nil, err
+				}
+				return r.Get(ctx, mary.ID)
+			},
+			mary,
+			nil,
+		},
+	}
+}
+
+// assertEmail runs the case and checks the result.
+func assertEmail(t *testing.T, r emailUserRepository, tt *emailCase) {
+	ctx := context.Background()
+	for _, u := range tt.seed {
+		require.NoError(t, r.Register(ctx, u))
+	}
+
+	// run
+	actual, err := tt.run(ctx, r)
+
+	// assert
+	if tt.expectedErr != nil {
+		require.ErrorIs(t, err, tt.expectedErr)
+		return
+	}
+	require.NoError(t, err)
+	require.Equal(t, tt.expected, actual)
+}
+
+// test using go-mysql-server
+func TestEmailWithGoMySQLServer(t *testing.T) {
+	for _, tt := range emailCases() {
+		t.Run(tt.title, func(t *testing.T) {
+			ctx := context.Background()
+			port, err := testport.Reserve()
+			require.NoError(t, err)
+
+			// simulator
+			// NOTE: tables are created by migrations to have the unique key of email
+			prepareEmptySimulator(t, port)
+			db, err := newMigrationClient(port)
+			require.NoError(t, err)
+			require.NoError(t, Migrate(ctx, db))
+
+			assertEmail(t, NewUserRepository(db), tt)
+		})
+	}
+}
+
+func TestRegisterAllEmailTakenWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(ctx, db))
+
+	// run
+	r := NewUserRepository(db)
+	err = r.RegisterAll(ctx, []*User{
+		{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"},
+		{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25), Email: "mike@example.com"},
+	})
+
+	// assert
+	var registerAllErr *RegisterAllError
+	require.True(t, errors.As(err, &registerAllErr), "unexpected error: %v", err)
+	require.Len(t, registerAllErr.Failures, 1)
+	require.Equal(t, 1, registerAllErr.Failures[0].Index)
+	require.ErrorIs(t, registerAllErr.Failures[0].Err, ErrEmailTaken)
+}
+
+// test using testcontainers
+func TestEmailWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	for _, tt := range emailCases() {
+		t.Run(tt.title, func(t *testing.T) {
+			require.NoError(t, truncateTables(ctx, db, "practice"))
+
+			assertEmail(t, NewUserRepository(db), tt)
+		})
+	}
+}
+
+// test using the in-memory fake
+func TestEmailWithInMemory(t *testing.T) {
+	for _, tt := range emailCases() {
+		t.Run(tt.title, func(t *testing.T) {
+			assertEmail(t, NewInMemoryUserRepository(), tt)
+		})
+	}
+}
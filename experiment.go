@@ -0,0 +1,168 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ListStrategy is an implementation of List. filters and pagination are query mods built from a validated ListQuery.
+// Register alternative strategies by RegisterListStrategy to try other queries, and select one by WithListStrategy.
+type ListStrategy func(ctx context.Context, exec boil.ContextExecutor, filters, pagination []qm.QueryMod) ([]*User, int64, error)
+
+// names of built-in list strategies
+const (
+	// ListStrategyCountThenSelect counts users and selects the page by separate queries (default).
+	ListStrategyCountThenSelect = "count-then-select"
+	// ListStrategyWindowCount selects the page with the total count by one query using COUNT(*) OVER ().
+	ListStrategyWindowCount = "window-count"
+)
+
+var listStrategies = struct {
+	mu         sync.RWMutex
+	strategies map[string]ListStrategy
+}{
+	strategies: map[string]ListStrategy{
+		ListStrategyCountThenSelect: listByCountThenSelect,
+		ListStrategyWindowCount:     listByWindowCount,
+	},
+}
+
+// RegisterListStrategy registers an alternative implementation of List. A strategy of the same name is replaced.
+func RegisterListStrategy(name string, strategy ListStrategy) {
+	listStrategies.mu.Lock()
+	defer listStrategies.mu.Unlock()
+	listStrategies.strategies[name] = strategy
+}
+
+func lookupListStrategy(name string) (ListStrategy, error) {
+	if name == "" {
+		name = ListStrategyCountThenSelect
+	}
+
+	listStrategies.mu.RLock()
+	defer listStrategies.mu.RUnlock()
+	strategy, ok := listStrategies.strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown list strategy: %s", name)
+	}
+	return strategy, nil
+}
+
+func listByCountThenSelect(ctx context.Context, exec boil.ContextExecutor, filters, pagination []qm.QueryMod) ([]*User, int64, error) {
+	total, err := models.Users(filters...).Count(ctx, exec)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	users, err := models.Users(append(filters, pagination...)...).All(ctx, exec)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return lo.Map(users, func(c *models.User, _ int) *User {
+		return fromUserModel(c)
+	}), total, nil
+}
+
+type userWithTotalCount struct {
+	models.User `boil:",bind"`
+	TotalCount  int64 `boil:"total_count"`
+}
+
+func listByWindowCount(ctx context.Context, exec boil.ContextExecutor, filters, pagination []qm.QueryMod) ([]*User, int64, error) {
+	mods := append([]qm.QueryMod{qm.Select("`user`.*", "COUNT(*) OVER () AS `total_count`")}, filters...)
+	var rows []*userWithTotalCount
+	if err := models.Users(append(mods, pagination...)...).Bind(ctx, exec, &rows); err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	// NOTE: the total count cannot be read from an empty page (e.g. offset is beyond the last user)
+	if len(rows) == 0 {
+		total, err := models.Users(filters...).Count(ctx, exec)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to count users: %w", err)
+		}
+		return []*User{}, total, nil
+	}
+
+	return lo.Map(rows, func(r *userWithTotalCount, _ int) *User {
+		return fromUserModel(&r.User)
+	}), rows[0].TotalCount, nil
+}
+
+// ListExperimentResult is the result of a list strategy in an experiment.
+type ListExperimentResult struct {
+	Strategy string
+	Users    []*User
+	Total    int64
+	Err      error
+	// Elapsed is the median time of the runs.
+	Elapsed time.Duration
+}
+
+// ListExperiment compares a candidate strategy with the control.
+type ListExperiment struct {
+	Control   *ListExperimentResult
+	Candidate *ListExperimentResult
+}
+
+// Matched reports whether the candidate returns the same result as the control.
+func (e *ListExperiment) Matched() bool {
+	return reflect.DeepEqual(
+		&listResult{Users: e.Control.Users, Total: e.Control.Total},
+		&listResult{Users: e.Candidate.Users, Total: e.Candidate.Total},
+	) && (e.Control.Err == nil) == (e.Candidate.Err == nil)
+}
+
+func (e *ListExperiment) String() string {
+	return fmt.Sprintf("%s: %s, %s: %s, matched: %t",
+		e.Control.Strategy, e.Control.Elapsed, e.Candidate.Strategy, e.Candidate.Elapsed, e.Matched())
+}
+
+// RunListExperiment runs List of the control and the candidate strategy against the same database runs times each,
+// and returns the result of the last runs with the median timings.
+// NOTE: strategies are run alternately so that caches of the database do not favor either of them
+func RunListExperiment(ctx context.Context, exec boil.ContextExecutor, query *ListQuery, control, candidate string, runs int) (*ListExperiment, error) {
+	if runs <= 0 {
+		return nil, fmt.Errorf("number of runs must be positive (runs: %d)", runs)
+	}
+	filters, err := query.filters()
+	if err != nil {
+		return nil, fmt.Errorf("invalid list query: %w", err)
+	}
+
+	names := []string{control, candidate}
+	strategies := make([]ListStrategy, len(names))
+	results := make([]*ListExperimentResult, len(names))
+	elapsed := make([][]time.Duration, len(names))
+	for i, name := range names {
+		if strategies[i], err = lookupListStrategy(name); err != nil {
+			return nil, err
+		}
+		results[i] = &ListExperimentResult{Strategy: name}
+	}
+
+	for n := 0; n < runs; n++ {
+		for i, result := range results {
+			start := time.Now()
+			result.Users, result.Total, result.Err = strategies[i](ctx, exec, filters, query.pagination())
+			elapsed[i] = append(elapsed[i], time.Since(start))
+		}
+	}
+
+	for i, result := range results {
+		sort.Slice(elapsed[i], func(a, b int) bool { return elapsed[i][a] < elapsed[i][b] })
+		result.Elapsed = elapsed[i][len(elapsed[i])/2]
+	}
+	return &ListExperiment{Control: results[0], Candidate: results[1]}, nil
+}
@@ -0,0 +1,117 @@
+package gosqltests
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/seed"
+)
+
+// test using go-sqlmock
+func TestListWithWindowCountWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `user`.*, COUNT(*) OVER () AS `total_count` FROM `user` WHERE (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 1;")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "age", "deleted_at", "email", "total_count"}).
+			AddRow("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 2))
+
+	// run
+	r := NewUserRepository(db, WithListStrategy(ListStrategyWindowCount))
+	users, total, err := r.List(context.TODO(), &ListQuery{Limit: 1})
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, []*User{{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}}, users)
+	require.Equal(t, int64(2), total)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListWithUnknownStrategyWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+
+	// run
+	r := NewUserRepository(db, WithListStrategy("unknown"))
+	_, _, err := r.List(context.TODO(), nil)
+
+	// assert
+	require.EqualError(t, err, "unknown list strategy: unknown")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestRunListExperimentWithGoMySQLServer(t *testing.T) {
+	tests := []struct {
+		title string
+		query *ListQuery
+	}{
+		{
+			"all users",
+			nil,
+		},
+		{
+			"page",
+			&ListQuery{Limit: 10, Offset: 20, Order: OrderByAgeDesc},
+		},
+		{
+			"filtered",
+			&ListQuery{MinAge: 30, MaxAge: 40, Order: OrderByNameAsc},
+		},
+		{
+			"offset beyond the last user",
+			&ListQuery{Limit: 10, Offset: 1000},
+		},
+	}
+
+	ctx := context.Background()
+
+	// simulator
+	prepareSimulator(t, 23306)
+	db, err := NewStrictClient(23306)
+	require.NoError(t, err)
+	require.NoError(t, seed.Insert(ctx, db, seed.NewGeneratorFromRand(testRand(t)).Users(300)))
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			experiment, err := RunListExperiment(ctx, db, tt.query, ListStrategyCountThenSelect, ListStrategyWindowCount, 3)
+
+			// assert
+			require.NoError(t, err)
+			require.NoError(t, experiment.Control.Err)
+			require.True(t, experiment.Matched(), experiment.String())
+			require.Positive(t, experiment.Candidate.Elapsed)
+		})
+	}
+}
+
+func TestRunListExperimentDetectsDivergenceWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	// NOTE: a broken strategy which ignores the total count
+	RegisterListStrategy("test-broken", func(ctx context.Context, exec boil.ContextExecutor, filters, pagination []qm.QueryMod) ([]*User, int64, error) {
+		users, _, err := listByCountThenSelect(ctx, exec, filters, pagination)
+		return users, int64(len(users)), err
+	})
+
+	// simulator
+	prepareSimulator(t, 23306)
+	db, err := NewStrictClient(23306)
+	require.NoError(t, err)
+	require.NoError(t, seed.Insert(ctx, db, seed.NewGeneratorFromRand(testRand(t)).Users(30)))
+
+	// run
+	experiment, err := RunListExperiment(ctx, db, &ListQuery{Limit: 10}, ListStrategyCountThenSelect, "test-broken", 1)
+
+	// assert
+	require.NoError(t, err)
+	require.False(t, experiment.Matched())
+	require.Equal(t, int64(30), experiment.Control.Total)
+	require.Equal(t, int64(10), experiment.Candidate.Total)
+}
@@ -0,0 +1,205 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// explainedAccess is how a row of EXPLAIN reads a table.
+type explainedAccess struct {
+	table string
+	// accessType is the type column of EXPLAIN, e.g. const, range or ALL (full table scan)
+	accessType string
+	// key is the index used to read the table (empty if no index is used)
+	key string
+}
+
+func (a *explainedAccess) String() string {
+	key := a.key
+	if key == "" {
+		key = "NULL"
+	}
+	return fmt.Sprintf("(table: %s, type: %s, key: %s)", a.table, a.accessType, key)
+}
+
+// explainAccesses returns how MySQL reads tables to run the statement.
+// NOTE: db should be a client of MySQL (e.g. of prepareContainer) because go-mysql-server does not plan queries as MySQL does
+func explainAccesses(ctx context.Context, db *sql.DB, q *loggedQuery) ([]*explainedAccess, error) {
+	args := lo.Map(q.args, func(arg driver.NamedValue, _ int) interface{} { return arg.Value })
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+q.query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain %s: %w", q.query, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	accesses := []*explainedAccess{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		if err := rows.Scan(lo.Map(values, func(_ sql.NullString, i int) interface{} { return &values[i] })...); err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		for i, c := range columns {
+			row[c] = values[i].String
+		}
+		accesses = append(accesses, &explainedAccess{table: row["table"], accessType: row["type"], key: row["key"]})
+	}
+	return accesses, rows.Err()
+}
+
+// assertIndexUsage fails unless every SELECT logged in l reads table by the index key,
+// so that a change of queries which makes MySQL scan the whole table is caught even if tests have only a few rows.
+func assertIndexUsage(t require.TestingT, db *sql.DB, l *queryLog, table string, key string) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	l.mu.Lock()
+	selects := lo.Filter(l.queries, func(q *loggedQuery, _ int) bool {
+		return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(q.query)), "SELECT")
+	})
+	l.mu.Unlock()
+	require.NotEmpty(t, selects, "no SELECT statements are logged")
+
+	for _, q := range selects {
+		accesses, err := explainAccesses(context.Background(), db, q)
+		require.NoError(t, err)
+		// NOTE: derived tables (e.g. of window functions) and other tables are not checked
+		reads := lo.Filter(accesses, func(a *explainedAccess, _ int) bool { return a.table == table })
+		require.NotEmpty(t, reads, "%s does not read %s: %v", q.query, table, accesses)
+		for _, a := range reads {
+			require.Equal(t, key, a.key, "%s does not use index %s of %s: %v", q.query, key, table, accesses)
+		}
+	}
+}
+
+// test using testcontainers
+func TestIndexUsageWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	port := startContainer(ctx, t, withFastMySQL())
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, StrictScan: true})
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	require.NoError(t, Migrate(ctx, db))
+
+	// NOTE: MySQL may prefer a full scan of a small table to an index, so the table has as many rows as in production
+	users := generateUsers(t, 1000)
+	require.NoError(t, NewUserRepository(db).RegisterAll(ctx, users))
+	mike := &User{ID: NewUserID().String(), Name: "Mike", Email: "mike@example.com"}
+	require.NoError(t, NewUserRepository(db).Register(ctx, mike))
+	_, err = db.ExecContext(ctx, "ANALYZE TABLE `user`")
+	require.NoError(t, err)
+	target := users[len(users)/2]
+
+	// NOTE: unique keys of name and email are named after their columns
+	tests := []struct {
+		title string
+		run   func(context.Context, *userRepository) error
+		key   string
+	}{
+		{
+			"Get uses the primary key",
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.Get(ctx, target.ID)
+				return err
+			},
+			"PRIMARY",
+		},
+		{
+			"GetMany uses the primary key",
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.GetMany(ctx, []string{users[1].ID, target.ID, users[len(users)-1].ID})
+				return err
+			},
+			"PRIMARY",
+		},
+		{
+			"ListAfter uses the primary key",
+			func(ctx context.Context, r *userRepository) error {
+				_, _, err := r.ListAfter(ctx, "", 10)
+				return err
+			},
+			"PRIMARY",
+		},
+		{
+			"GetByName uses the unique key of name",
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.GetByName(ctx, target.Name)
+				return err
+			},
+			models.UserColumns.Name,
+		},
+		{
+			"GetByEmail uses the unique key of email",
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.GetByEmail(ctx, mike.Email)
+				return err
+			},
+			models.UserColumns.Email,
+		},
+		{
+			"Search by name prefix uses the unique key of name",
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.Search(ctx, SearchQuery{NamePrefix: target.Name, Limit: 10})
+				return err
+			},
+			models.UserColumns.Name,
+		},
+		{
+			"List by name prefix uses the unique key of name",
+			func(ctx context.Context, r *userRepository) error {
+				_, _, err := r.List(ctx, &ListQuery{NamePrefix: target.Name})
+				return err
+			},
+			models.UserColumns.Name,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			l := &queryLog{}
+			client, err := newQueryLoggedClient(port, l)
+			require.NoError(t, err)
+			defer closeTestClient(t, client)
+
+			// run
+			require.NoError(t, tt.run(ctx, NewUserRepository(client)))
+
+			// assert
+			assertIndexUsage(t, db, l, models.TableNames.User, tt.key)
+		})
+	}
+
+	t.Run("full table scan is reported", func(t *testing.T) {
+		l := &queryLog{}
+		client, err := newQueryLoggedClient(port, l)
+		require.NoError(t, err)
+		defer closeTestClient(t, client)
+
+		// NOTE: age has no index
+		_, err = NewUserRepository(client).Count(ctx, &ListQuery{MinAge: 30, MaxAge: 30})
+		require.NoError(t, err)
+
+		// run
+		rt := &recordingT{}
+		rt.run(func() { assertIndexUsage(rt, db, l, models.TableNames.User, models.UserColumns.Name) })
+
+		// assert
+		require.True(t, rt.failed)
+		require.Contains(t, rt.errors[0], "does not use index name of user")
+		require.Contains(t, rt.errors[0], "key: NULL")
+	})
+}
@@ -0,0 +1,437 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// inMemoryUserRepository is a map-backed UserRepository for tests of layers above the repository.
+// It returns the same errors as userRepository so that callers can check them with errors.Is/As.
+type inMemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	// deleted holds ids of soft-deleted users, which are still in users
+	deleted map[string]bool
+	// idempotencyKeys maps keys of RegisterIdempotent to ids of users registered with them
+	idempotencyKeys map[string]string
+	// clock sets CreatedAt and UpdatedAt as WithClock of userRepository
+	clock Clock
+	// ids generates ids of users registered without them as WithIDGenerator of userRepository
+	ids IDGenerator
+}
+
+var _ UserRepository = (*inMemoryUserRepository)(nil)
+
+// NewInMemoryUserRepository returns a repository which contains copies of users.
+func NewInMemoryUserRepository(users ...*User) *inMemoryUserRepository {
+	r := &inMemoryUserRepository{users: map[string]*User{}, deleted: map[string]bool{}, idempotencyKeys: map[string]string{}, clock: systemClock{}, ids: ulidGenerator{}}
+	for _, u := range users {
+		r.users[u.ID] = copyUser(u)
+	}
+	return r
+}
+
+// NOTE: users are copied in and out so that callers cannot modify stored users, like rows in a database
+func copyUser(u *User) *User {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	if u.Age != nil {
+		c.Age = lo.ToPtr(*u.Age)
+	}
+	return &c
+}
+
+func (r *inMemoryUserRepository) Register(ctx context.Context, user *User) error {
+	r.assignID(user)
+	if _, err := ParseUserID(user.ID); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.insert(user)
+}
+
+// assignID sets a generated id to the user without id as userRepository does.
+func (r *inMemoryUserRepository) assignID(user *User) {
+	if user.ID == "" {
+		user.ID = r.ids.NewUserID().String()
+	}
+}
+
+// insert stores the user. r.mu must be locked.
+func (r *inMemoryUserRepository) insert(user *User) error {
+	// NOTE: same errors as the primary key and the unique keys of name and email (soft-deleted users are also checked)
+	if _, ok := r.users[user.ID]; ok {
+		return fmt.Errorf("failed to insert user: %w", &mysql.MySQLError{
+			Number:  1062,
+			Message: fmt.Sprintf("Duplicate entry '%s' for key 'user.PRIMARY'", user.ID),
+		})
+	}
+	for _, u := range r.users {
+		if u.Name == user.Name {
+			return fmt.Errorf("failed to insert user: %w", &mysql.MySQLError{
+				Number:  1062,
+				Message: fmt.Sprintf("Duplicate entry '%s' for key 'user.name'", user.Name),
+			})
+		}
+	}
+	if err := r.checkEmail(user); err != nil {
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+
+	setInsertTimestamps(user, timestampOf(r.clock))
+	r.users[user.ID] = copyUser(user)
+	return nil
+}
+
+// RegisterIdempotent registers the user once per key as userRepository.RegisterIdempotent does.
+func (r *inMemoryUserRepository) RegisterIdempotent(ctx context.Context, key string, user *User) (*User, error) {
+	if err := validateIdempotencyKey(key); err != nil {
+		return nil, err
+	}
+	r.assignID(user)
+	if _, err := ParseUserID(user.ID); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, ok := r.idempotencyKeys[key]; ok {
+		u, ok := r.users[id]
+		if !ok || r.deleted[id] {
+			return nil, &IdempotentUserDeletedError{Key: key, UserID: id}
+		}
+		return copyUser(u), nil
+	}
+
+	if err := r.insert(user); err != nil {
+		return nil, err
+	}
+	r.idempotencyKeys[key] = user.ID
+	return copyUser(user), nil
+}
+
+// checkEmail returns the same error as userRepository if another user has the email of user.
+func (r *inMemoryUserRepository) checkEmail(user *User) error {
+	if user.Email == "" {
+		return nil
+	}
+	for _, u := range r.users {
+		if u.ID != user.ID && u.Email == user.Email {
+			return wrapEmailTakenError(&mysql.MySQLError{
+				Number:  1062,
+				Message: fmt.Sprintf("Duplicate entry '%s' for key 'user.email'", user.Email),
+			}, user)
+		}
+	}
+	return nil
+}
+
+// Upsert registers the user, or updates the user of the same id, name or email as userRepository.Upsert does.
+func (r *inMemoryUserRepository) Upsert(ctx context.Context, user *User, updateColumns ...string) error {
+	if _, err := ParseUserID(user.ID); err != nil {
+		return err
+	}
+	if len(updateColumns) == 0 {
+		updateColumns = upsertColumns
+	}
+	for _, c := range updateColumns {
+		if !lo.Contains(upsertColumns, c) {
+			return fmt.Errorf("column cannot be updated by upsert: %s", c)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to upsert user: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := timestampOf(r.clock)
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+
+	// NOTE: MySQL updates the row conflicting with the primary key first, then the unique keys in order
+	existing, ok := r.users[user.ID]
+	if !ok {
+		existing, ok = lo.Find(lo.Values(r.users), func(u *User) bool { return u.Name == user.Name })
+	}
+	if !ok && user.Email != "" {
+		existing, ok = lo.Find(lo.Values(r.users), func(u *User) bool { return u.Email == user.Email })
+	}
+	if !ok {
+		r.users[user.ID] = copyUser(user)
+		return nil
+	}
+
+	updated := copyUser(existing)
+	updated.UpdatedAt = now
+	for _, c := range updateColumns {
+		switch c {
+		case models.UserColumns.Name:
+			updated.Name = user.Name
+		case models.UserColumns.Age:
+			updated.Age = user.Age
+		}
+	}
+	for _, u := range r.users {
+		if u.ID != updated.ID && u.Name == updated.Name {
+			return fmt.Errorf("failed to upsert user: %w", &mysql.MySQLError{
+				Number:  1062,
+				Message: fmt.Sprintf("Duplicate entry '%s' for key 'user.name'", updated.Name),
+			})
+		}
+	}
+	r.users[updated.ID] = updated
+	return nil
+}
+
+func (r *inMemoryUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	if _, err := query.filters(); err != nil {
+		return nil, 0, fmt.Errorf("invalid list query: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matched := []*User{}
+	for _, u := range r.users {
+		if !r.deleted[u.ID] && query.matches(u) {
+			matched = append(matched, copyUser(u))
+		}
+	}
+	total := int64(len(matched))
+
+	var q ListQuery
+	if query != nil {
+		q = *query
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return q.less(matched[i], matched[j])
+	})
+
+	page := []*User{}
+	for _, u := range matched {
+		if q.After != "" && !q.isAfter(u) {
+			continue
+		}
+		page = append(page, u)
+	}
+	if q.Offset >= len(page) {
+		return []*User{}, total, nil
+	}
+	page = page[q.Offset:]
+	if q.Limit != 0 && q.Limit < len(page) {
+		page = page[:q.Limit]
+	}
+
+	return page, total, nil
+}
+
+// ListStream calls fn with each user matching query as userRepository.ListStream does.
+func (r *inMemoryUserRepository) ListStream(ctx context.Context, query *ListQuery, fn func(*User) error) error {
+	users, _, err := r.List(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches reports whether the user satisfies the filters of q.
+// NOTE: unlike MySQL, names are compared case-sensitively
+func (q *ListQuery) matches(u *User) bool {
+	if q == nil {
+		return true
+	}
+	if !strings.HasPrefix(u.Name, q.NamePrefix) {
+		return false
+	}
+	// NOTE: users without age do not match any range of ages, as NULL is not compared in MySQL
+	if q.MinAge != 0 && (u.Age == nil || *u.Age < q.MinAge) {
+		return false
+	}
+	if q.MaxAge != 0 && (u.Age == nil || *u.Age > q.MaxAge) {
+		return false
+	}
+	return true
+}
+
+// less orders users in the same way as pagination.
+func (q *ListQuery) less(a, b *User) bool {
+	switch q.Order {
+	case OrderByIDDesc:
+		return a.ID > b.ID
+	case OrderByNameAsc:
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	case OrderByNameDesc:
+		if a.Name != b.Name {
+			return a.Name > b.Name
+		}
+		return a.ID > b.ID
+	case OrderByAgeAsc:
+		if c := compareAges(a.Age, b.Age); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	case OrderByAgeDesc:
+		if c := compareAges(a.Age, b.Age); c != 0 {
+			return c > 0
+		}
+		return a.ID > b.ID
+	default:
+		return a.ID < b.ID
+	}
+}
+
+// compareAges returns -1, 0 or 1 by the order of MySQL, which sorts NULL before any value.
+func compareAges(a, b *int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case *a < *b:
+		return -1
+	case *a > *b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// isAfter reports whether the user comes after the cursor of q.
+func (q *ListQuery) isAfter(u *User) bool {
+	if q.Order == OrderByIDDesc {
+		return u.ID < q.After
+	}
+	return u.ID > q.After
+}
+
+func (r *inMemoryUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	if _, err := ParseUserID(id); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user (id: %s): %w", id, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[id]
+	if !ok || r.deleted[id] {
+		return nil, fmt.Errorf("user was not found (id: %s): %w", id, sql.ErrNoRows)
+	}
+	return copyUser(u), nil
+}
+
+func (r *inMemoryUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user (name: %s): %w", name, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if u.Name == name && !r.deleted[u.ID] {
+			return copyUser(u), nil
+		}
+	}
+	return nil, fmt.Errorf("user was not found (name: %s): %w", name, sql.ErrNoRows)
+}
+
+func (r *inMemoryUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get user (email: %s): %w", email, err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if email != "" && u.Email == email && !r.deleted[u.ID] {
+			return copyUser(u), nil
+		}
+	}
+	return nil, fmt.Errorf("user was not found (email: %s): %w", email, sql.ErrNoRows)
+}
+
+// Delete soft-deletes the user. It does nothing if the user does not exist, as UPDATE affecting no rows is not an error.
+func (r *inMemoryUserRepository) Delete(ctx context.Context, user *User) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		r.deleted[user.ID] = true
+	}
+	return nil
+}
+
+func (r *inMemoryUserRepository) HardDelete(ctx context.Context, user *User) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to hard delete user: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.users, user.ID)
+	delete(r.deleted, user.ID)
+	return nil
+}
+
+func (r *inMemoryUserRepository) Restore(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to restore user (id: %s): %w", id, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.deleted[id] {
+		return fmt.Errorf("deleted user was not found (id: %s): %w", id, sql.ErrNoRows)
+	}
+	delete(r.deleted, id)
+	r.users[id].UpdatedAt = timestampOf(r.clock)
+	return nil
+}
@@ -0,0 +1,92 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// test using the in-memory fake
+func TestInMemoryUserRepository(t *testing.T) {
+	RunRepositorySuite(t, func(t *testing.T, users ...*User) UserRepository {
+		return NewInMemoryUserRepository(users...)
+	})
+}
+
+func TestInMemoryUserRepositoryRegisterDuplicated(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+
+	tests := []struct {
+		title           string
+		user            *User
+		expectedMessage string
+	}{
+		{
+			"duplicated id",
+			&User{ID: mike.ID, Name: "Bob", Age: lo.ToPtr(25)},
+			"Duplicate entry '0123456789ABCDEFGHJKMNPQRS' for key 'user.PRIMARY'",
+		},
+		{
+			"duplicated name",
+			&User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: mike.Name, Age: lo.ToPtr(25)},
+			"Duplicate entry 'Mike' for key 'user.name'",
+		},
+		{
+			"duplicated email",
+			&User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25), Email: mike.Email},
+			"Duplicate entry 'mike@example.com' for key 'user.email'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			r := NewInMemoryUserRepository(mike)
+
+			// run
+			err := r.Register(context.TODO(), tt.user)
+
+			// assert
+			var mysqlErr *mysql.MySQLError
+			require.True(t, errors.As(err, &mysqlErr))
+			require.Equal(t, uint16(1062), mysqlErr.Number)
+			require.Equal(t, tt.expectedMessage, mysqlErr.Message)
+		})
+	}
+}
+
+func TestInMemoryUserRepositoryErrors(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := NewInMemoryUserRepository(mike)
+
+	_, err := r.Get(ctx, mike.ID)
+	require.ErrorIs(t, err, context.Canceled)
+	_, _, err = r.List(ctx, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, r.Register(ctx, &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}), context.Canceled)
+
+	_, _, err = r.List(context.TODO(), &ListQuery{Limit: -1})
+	require.EqualError(t, err, "invalid list query: limit and offset must not be negative (limit: -1, offset: 0)")
+}
+
+func TestInMemoryUserRepositoryCopiesUsers(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	r := NewInMemoryUserRepository(mike)
+
+	// run
+	*mike.Age = 30
+	found, err := r.Get(context.TODO(), mike.ID)
+	require.NoError(t, err)
+	*found.Age = 40
+
+	// assert
+	actual, err := r.Get(context.TODO(), mike.ID)
+	require.NoError(t, err)
+	require.Equal(t, 20, *actual.Age)
+}
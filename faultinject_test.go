@@ -0,0 +1,119 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+// faultInjector makes statements fail instead of sending them to the server, e.g. to emulate lost connections.
+type faultInjector struct {
+	mu sync.Mutex
+	// failures is the number of statements containing match to fail next
+	failures int
+	match    string
+	err      error
+	// statements are all statements received, including failed ones
+	statements []string
+}
+
+// failNext makes the next n statements fail with err.
+func (f *faultInjector) failNext(n int, err error) {
+	f.failNextMatching(n, "", err)
+}
+
+// failNextMatching makes the next n statements containing match fail with err. Other statements are sent as usual.
+func (f *faultInjector) failNextMatching(n int, match string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = n
+	f.match = match
+	f.err = err
+}
+
+func (f *faultInjector) inject(query string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, query)
+	if f.failures > 0 && strings.Contains(query, f.match) {
+		f.failures--
+		return f.err
+	}
+	return nil
+}
+
+func (f *faultInjector) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = nil
+	f.failures = 0
+}
+
+// newFaultInjectedClient returns a client whose statements go through the injector.
+// NOTE: parameters are interpolated so that every statement is sent by a single call of the connection
+func newFaultInjectedClient(t testing.TB, port int, injector *faultInjector) *sql.DB {
+	cfg, err := mysql.ParseDSN(dsn(port, defaultDatabase))
+	require.NoError(t, err)
+	cfg.InterpolateParams = true
+	connector, err := mysql.NewConnector(cfg)
+	require.NoError(t, err)
+	return sql.OpenDB(&faultConnector{Connector: connector, injector: injector})
+}
+
+type faultConnector struct {
+	driver.Connector
+	injector *faultInjector
+}
+
+func (c *faultConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{Conn: conn, injector: c.injector}, nil
+}
+
+type faultConn struct {
+	driver.Conn
+	injector *faultInjector
+}
+
+func (c *faultConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.injector.inject(query); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *faultConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.injector.inject(query); err != nil {
+		return nil, err
+	}
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c *faultConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *faultConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *faultConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *faultConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+func (c *faultConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.Conn.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
@@ -0,0 +1,419 @@
+package gosqltests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// NOTE: run `go test . -fixture-report unused_fixtures.txt` to list fixtures which tests seed but never read
+var fixtureReportPath = flag.String("fixture-report", "", "write fixtures which were never read by tests to the file")
+
+// queryCaptureHook receives each row read by a client with the query which returned it.
+type queryCaptureHook func(query string, columns []string, row []driver.Value)
+
+// withQueryCapture wraps a connector so that hook receives every row read through it.
+func withQueryCapture(hook queryCaptureHook) connectorWrapper {
+	return func(c driver.Connector) driver.Connector {
+		return &captureConnector{Connector: c, hook: hook}
+	}
+}
+
+type captureConnector struct {
+	driver.Connector
+	hook queryCaptureHook
+}
+
+func (c *captureConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &captureConn{Conn: conn, hook: c.hook}, nil
+}
+
+// captureConn wraps a connection of the MySQL driver, which implements every optional interface used below.
+type captureConn struct {
+	driver.Conn
+	hook queryCaptureHook
+}
+
+func (c *captureConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *captureConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &captureRows{Rows: rows, query: query, hook: c.hook}, nil
+}
+
+func (c *captureConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &captureStmt{Stmt: stmt, query: query, hook: c.hook}, nil
+}
+
+func (c *captureConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *captureConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *captureConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *captureConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+func (c *captureConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.Conn.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+type captureStmt struct {
+	driver.Stmt
+	query string
+	hook  queryCaptureHook
+}
+
+func (s *captureStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+func (s *captureStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &captureRows{Rows: rows, query: s.query, hook: s.hook}, nil
+}
+
+func (s *captureStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.Stmt.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+// NOTE: column type interfaces are passed through because database/sql finds them by type assertions
+type captureRows struct {
+	driver.Rows
+	query string
+	hook  queryCaptureHook
+}
+
+func (r *captureRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	r.hook(r.query, r.Columns(), dest)
+	return nil
+}
+
+func (r *captureRows) HasNextResultSet() bool {
+	return r.Rows.(driver.RowsNextResultSet).HasNextResultSet()
+}
+
+func (r *captureRows) NextResultSet() error {
+	return r.Rows.(driver.RowsNextResultSet).NextResultSet()
+}
+
+func (r *captureRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.Rows.(driver.RowsColumnTypeDatabaseTypeName).ColumnTypeDatabaseTypeName(index)
+}
+
+func (r *captureRows) ColumnTypeScanType(index int) reflect.Type {
+	return r.Rows.(driver.RowsColumnTypeScanType).ColumnTypeScanType(index)
+}
+
+func (r *captureRows) ColumnTypeNullable(index int) (bool, bool) {
+	return r.Rows.(driver.RowsColumnTypeNullable).ColumnTypeNullable(index)
+}
+
+func (r *captureRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	return r.Rows.(driver.RowsColumnTypePrecisionScale).ColumnTypePrecisionScale(index)
+}
+
+// fixture is a row seeded by a test.
+type fixture struct {
+	table string
+	id    string
+}
+
+func (f fixture) String() string {
+	return fmt.Sprintf("%s(%s)", f.table, f.id)
+}
+
+// fixtureUsage tracks which fixtures are read by queries.
+// NOTE: a row is attributed to the first table after FROM, and identified by its id column,
+// so rows of joined tables and tables without id (e.g. credential) are not tracked
+type fixtureUsage struct {
+	mu sync.Mutex
+	// read reports whether each fixture has been read
+	read map[fixture]bool
+}
+
+func newFixtureUsage() *fixtureUsage {
+	return &fixtureUsage{read: map[fixture]bool{}}
+}
+
+// register adds rows of the table with the ids as fixtures.
+func (u *fixtureUsage) register(table string, ids ...string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, id := range ids {
+		f := fixture{table: table, id: id}
+		if _, ok := u.read[f]; !ok {
+			u.read[f] = false
+		}
+	}
+}
+
+var fromTablePattern = regexp.MustCompile("(?i)\\bFROM\\s+`?(\\w+)`?")
+
+// capture marks the fixture of the row as read. It is a queryCaptureHook.
+func (u *fixtureUsage) capture(query string, columns []string, row []driver.Value) {
+	m := fromTablePattern.FindStringSubmatch(query)
+	if m == nil {
+		return
+	}
+	for i, c := range columns {
+		if c != "id" {
+			continue
+		}
+		var id string
+		switch v := row[i].(type) {
+		case []byte:
+			id = string(v)
+		case string:
+			id = v
+		case int64:
+			id = strconv.FormatInt(v, 10)
+		default:
+			return
+		}
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		f := fixture{table: m[1], id: id}
+		if _, ok := u.read[f]; ok {
+			u.read[f] = true
+		}
+		return
+	}
+}
+
+// unused returns fixtures which have never been read, in the order of tables and ids.
+func (u *fixtureUsage) unused() []fixture {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var unused []fixture
+	for f, read := range u.read {
+		if !read {
+			unused = append(unused, f)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		if unused[i].table != unused[j].table {
+			return unused[i].table < unused[j].table
+		}
+		return unused[i].id < unused[j].id
+	})
+	return unused
+}
+
+// unusedTables returns tables none of whose fixtures have been read.
+func (u *fixtureUsage) unusedTables() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	read := map[string]bool{}
+	for f, r := range u.read {
+		read[f.table] = read[f.table] || r
+	}
+	var tables []string
+	for table, r := range read {
+		if !r {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// fixtureReport collects unused fixtures of the tests in the run.
+var fixtureReport = struct {
+	mu sync.Mutex
+	// unused fixtures and tables by test names
+	fixtures map[string][]fixture
+	tables   map[string][]string
+}{
+	fixtures: map[string][]fixture{},
+	tables:   map[string][]string{},
+}
+
+// trackFixtures returns a fixtureUsage whose unused fixtures are added to the report when the test finishes.
+func trackFixtures(t testing.TB) *fixtureUsage {
+	u := newFixtureUsage()
+	t.Cleanup(func() {
+		unused := u.unused()
+		if len(unused) == 0 {
+			return
+		}
+		fixtureReport.mu.Lock()
+		defer fixtureReport.mu.Unlock()
+		fixtureReport.fixtures[t.Name()] = unused
+		fixtureReport.tables[t.Name()] = u.unusedTables()
+	})
+	return u
+}
+
+// writeFixtureReport writes unused fixtures by tests in the order of their names.
+func writeFixtureReport(w io.Writer) error {
+	fixtureReport.mu.Lock()
+	defer fixtureReport.mu.Unlock()
+
+	names := make([]string, 0, len(fixtureReport.fixtures))
+	for name := range fixtureReport.fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s\n", name); err != nil {
+			return err
+		}
+		for _, table := range fixtureReport.tables[name] {
+			if _, err := fmt.Fprintf(w, "\ttable never read: %s\n", table); err != nil {
+				return err
+			}
+		}
+		for _, f := range fixtureReport.fixtures[name] {
+			if _, err := fmt.Fprintf(w, "\tfixture never read: %s\n", f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveFixtureReport writes the report to the file of -fixture-report if it is set.
+func saveFixtureReport() error {
+	if *fixtureReportPath == "" {
+		return nil
+	}
+	f, err := os.Create(*fixtureReportPath)
+	if err != nil {
+		return fmt.Errorf("failed to create fixture report: %w", err)
+	}
+	defer f.Close()
+	if err := writeFixtureReport(f); err != nil {
+		return fmt.Errorf("failed to write fixture report: %w", err)
+	}
+	return f.Close()
+}
+
+// newFixtureTrackedClient returns a strict client which marks fixtures of u as read.
+func newFixtureTrackedClient(port int, database string, u *fixtureUsage) (*sql.DB, error) {
+	return newClient(port, database, withStrictScan(strconv.IntSize), withQueryCapture(u.capture))
+}
+
+// test using go-mysql-server
+func TestFixtureUsageWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	u := newFixtureUsage()
+	db, err := newFixtureTrackedClient(port, defaultDatabase, u)
+	require.NoError(t, err)
+	defer db.Close()
+	users := []*User{
+		{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+		{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Alice", Age: lo.ToPtr(30)},
+	}
+	seedUsers(ctx, t, db, users)
+	u.register(models.TableNames.User, users[0].ID, users[1].ID, users[2].ID)
+	u.register(models.TableNames.IdempotencyKey, "key")
+
+	// run
+	r := NewUserRepository(db)
+	_, err = r.Get(ctx, users[0].ID)
+	require.NoError(t, err)
+	_, _, err = r.List(ctx, &ListQuery{NamePrefix: "B"})
+	require.NoError(t, err)
+
+	// assert
+	require.Equal(t, []fixture{
+		{table: models.TableNames.IdempotencyKey, id: "key"},
+		{table: models.TableNames.User, id: users[2].ID},
+	}, u.unused())
+	require.Equal(t, []string{models.TableNames.IdempotencyKey}, u.unusedTables())
+}
+
+func TestWriteFixtureReport(t *testing.T) {
+	// NOTE: the report is shared by the run
+	fixtureReport.mu.Lock()
+	saved := fixtureReport.fixtures
+	savedTables := fixtureReport.tables
+	fixtureReport.fixtures = map[string][]fixture{}
+	fixtureReport.tables = map[string][]string{}
+	fixtureReport.mu.Unlock()
+	defer func() {
+		fixtureReport.mu.Lock()
+		defer fixtureReport.mu.Unlock()
+		fixtureReport.fixtures = saved
+		fixtureReport.tables = savedTables
+	}()
+
+	t.Run("all read", func(t *testing.T) {
+		u := trackFixtures(t)
+		u.register("user", "0")
+		u.capture("SELECT `id` FROM `user`", []string{"id"}, []driver.Value{[]byte("0")})
+	})
+	t.Run("partly read", func(t *testing.T) {
+		u := trackFixtures(t)
+		u.register("user", "1", "0")
+		u.register("credential", "0")
+		u.capture("select * from user where id = ?", []string{"id", "name"}, []driver.Value{[]byte("0"), []byte("Mike")})
+		// other tables do not mark fixtures of the same id
+		u.capture("SELECT `id` FROM `idempotency_key`", []string{"id"}, []driver.Value{[]byte("1")})
+	})
+
+	// run
+	var actual bytes.Buffer
+	err := writeFixtureReport(&actual)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, "TestWriteFixtureReport/partly_read\n"+
+		"\ttable never read: credential\n"+
+		"\tfixture never read: credential(0)\n"+
+		"\tfixture never read: user(1)\n", actual.String())
+}
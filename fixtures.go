@@ -0,0 +1,121 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// fixtureFile is rows of a table read from a fixture file.
+type fixtureFile struct {
+	file  string
+	table string
+	rows  []map[string]interface{}
+}
+
+// LoadFixtures inserts rows of the fixture files in fsys, which Go tests and `gosqltests serve` share.
+// Each file is named after its table (e.g. user.yml) and has a list of rows, which map columns to values:
+//
+//   - id: 0123456789ABCDEFGHJKMNPQRS
+//     name: Mike
+//     age: 20
+//
+// Files are YAML (.yml or .yaml) or JSON (.json). Tables referenced by foreign keys are loaded first.
+func LoadFixtures(ctx context.Context, db *sql.DB, fsys fs.FS) error {
+	fixtures, err := readFixtures(fsys)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fixtures {
+		for i, row := range f.rows {
+			if err := insertFixtureRow(ctx, db, f.table, row); err != nil {
+				return fmt.Errorf("failed to load fixture %s (row: %d): %w", f.file, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readFixtures reads and validates all fixture files before any row is inserted.
+func readFixtures(fsys fs.FS) ([]*fixtureFile, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var fixtures []*fixtureFile
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || !lo.Contains([]string{".yml", ".yaml", ".json"}, ext) {
+			continue
+		}
+
+		f := &fixtureFile{file: e.Name(), table: strings.TrimSuffix(e.Name(), ext)}
+		columns, ok := tableColumnNames[f.table]
+		if !ok {
+			return nil, fmt.Errorf("failed to load fixture %s: unknown table %s", f.file, f.table)
+		}
+		b, err := fs.ReadFile(fsys, f.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load fixture %s: %w", f.file, err)
+		}
+		// NOTE: JSON is also parsed as YAML
+		if err := yaml.Unmarshal(b, &f.rows); err != nil {
+			return nil, fmt.Errorf("failed to load fixture %s: %w", f.file, err)
+		}
+		for i, row := range f.rows {
+			unknown := lo.Filter(lo.Keys(row), func(c string, _ int) bool { return !lo.Contains(columns, c) })
+			if len(unknown) > 0 {
+				sort.Strings(unknown)
+				return nil, fmt.Errorf("failed to load fixture %s (row: %d): unknown columns %s", f.file, i, strings.Join(unknown, ", "))
+			}
+		}
+		fixtures = append(fixtures, f)
+	}
+
+	sort.SliceStable(fixtures, func(i, j int) bool {
+		return isReferencedTable(fixtures[i].table) && !isReferencedTable(fixtures[j].table)
+	})
+	return fixtures, nil
+}
+
+func isReferencedTable(table string) bool {
+	return lo.ContainsBy(declaredForeignKeys, func(k *ForeignKey) bool { return k.ReferencedTable == table })
+}
+
+func insertFixtureRow(ctx context.Context, db *sql.DB, table string, row map[string]interface{}) error {
+	var columns, placeholders []string
+	var args []interface{}
+	// NOTE: columns are in the order of the table definition so that statements are the same in every run
+	for _, c := range tableColumnNames[table] {
+		v, ok := row[c]
+		if !ok {
+			continue
+		}
+		// values of JSON columns are written as objects or arrays in fixtures
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			v = b
+		}
+		columns = append(columns, quoteIdentifier(c))
+		placeholders = append(placeholders, "?")
+		args = append(args, v)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(table), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
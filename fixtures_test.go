@@ -0,0 +1,120 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureDir has fixtures shared with `gosqltests serve`.
+const fixtureDir = "testdata/fixtures"
+
+// test using go-mysql-server
+func TestLoadFixturesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	// run
+	err = LoadFixtures(ctx, db, os.DirFS(fixtureDir))
+
+	// assert
+	require.NoError(t, err)
+	r := NewUserRepository(db)
+	users, total, err := r.List(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+	require.Equal(t, []*User{
+		{
+			ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com",
+			CreatedAt: time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2022, 10, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25),
+			CreatedAt: time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC), UpdatedAt: time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}, users)
+	_, err = r.Get(ctx, "2123456789ABCDEFGHJKMNPQRS")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, NewCredentialRepository(db).VerifyPassword(ctx, "0123456789ABCDEFGHJKMNPQRS", "password"))
+}
+
+// test using go-sqlmock
+func TestLoadInvalidFixturesWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title       string
+		files       fstest.MapFS
+		expectedErr string
+	}{
+		{
+			"unknown table",
+			fstest.MapFS{"users.yml": {Data: []byte("- id: 0123456789ABCDEFGHJKMNPQRS\n")}},
+			"failed to load fixture users.yml: unknown table users",
+		},
+		{
+			"unknown columns",
+			fstest.MapFS{"user.json": {Data: []byte(`[{"id": "0123456789ABCDEFGHJKMNPQRS", "nickname": "Mike", "Age": 20}]`)}},
+			"failed to load fixture user.json (row: 0): unknown columns Age, nickname",
+		},
+		{
+			"not a list of rows",
+			fstest.MapFS{"user.yaml": {Data: []byte("id: 0123456789ABCDEFGHJKMNPQRS\n")}},
+			"failed to load fixture user.yaml: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!map into []map[string]interface {}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			// NOTE: nothing is inserted if any file is invalid
+			db, mock := prepareMockDB(t)
+			tt.files["credential.yml"] = &fstest.MapFile{Data: []byte("- user_id: 0123456789ABCDEFGHJKMNPQRS\n  password_hash: hash\n")}
+
+			// run
+			err := LoadFixtures(context.TODO(), db, tt.files)
+
+			// assert
+			require.EqualError(t, err, tt.expectedErr)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestLoadFixturesOrderWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	// NOTE: user is loaded first because credential references it, and the others are in the order of names
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`, `name`, `age`) VALUES (?, ?, ?)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `audit_log` (`user_id`, `action`, `after_json`) VALUES (?, ?, ?)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", AuditActionRegister, []byte(`{"id":"0123456789ABCDEFGHJKMNPQRS"}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `credential` (`user_id`, `password_hash`) VALUES (?, ?)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", "hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// run
+	err := LoadFixtures(context.TODO(), db, fstest.MapFS{
+		"audit_log.yml":  {Data: []byte("- user_id: 0123456789ABCDEFGHJKMNPQRS\n  action: register\n  after_json: {id: 0123456789ABCDEFGHJKMNPQRS}\n")},
+		"credential.yml": {Data: []byte("- user_id: 0123456789ABCDEFGHJKMNPQRS\n  password_hash: hash\n")},
+		"user.yml":       {Data: []byte("- {id: 0123456789ABCDEFGHJKMNPQRS, age: 20, name: Mike}\n")},
+		"README.md":      {Data: []byte("not a fixture")},
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
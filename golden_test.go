@@ -0,0 +1,92 @@
+package gosqltests
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// NOTE: run `go test . -run Golden -update` to rewrite golden files by actual results
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// assertGolden compares v serialized into JSON with testdata/<name>.golden.json.
+func assertGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+
+	actual, err := json.MarshalIndent(v, "", "  ")
+	require.NoError(t, err)
+	actual = append(actual, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file is missing; run tests with -update to create it")
+	require.JSONEq(t, string(expected), string(actual))
+}
+
+// test using every selected DBTestBackend which stores rows and the in-memory fake
+func TestListGoldenOnBackends(t *testing.T) {
+	users := make([]*User, 100)
+	for i := range users {
+		users[i] = &User{ID: sequentialID(i), Name: fmt.Sprintf("user%02d", i), Age: lo.ToPtr(20 + i%50)}
+		// NOTE: timestamps are fixed so that every backend stores the same ones
+		users[i].CreatedAt, users[i].UpdatedAt = seededAt, seededAt
+	}
+
+	tests := []struct {
+		title string
+		query *ListQuery
+	}{
+		{"all", nil},
+		{"name_prefix", &ListQuery{NamePrefix: "user1", Order: OrderByNameDesc}},
+		{"age_range", &ListQuery{MinAge: 30, MaxAge: 39, Order: OrderByAgeAsc}},
+		{"page", &ListQuery{Limit: 10, Offset: 45, Order: OrderByIDDesc}},
+	}
+
+	run := func(t *testing.T, r UserRepository) {
+		for _, tt := range tests {
+			t.Run(tt.title, func(t *testing.T) {
+				// run
+				actual, total, err := r.List(context.Background(), tt.query)
+
+				// assert
+				require.NoError(t, err)
+				// NOTE: golden files are shared by backends because results must not depend on them
+				assertGolden(t, strings.Join([]string{"list", tt.title}, "/"), &listResult{Users: actual, Total: total})
+			})
+		}
+	}
+
+	for _, b := range selectedBackends(t) {
+		backend := b.newBackend()
+		if isMockBackend(backend) {
+			continue
+		}
+
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := backend.Setup(ctx, t)
+			defer backend.Teardown(ctx, t)
+			backend.Seed(ctx, t, users...)
+			run(t, NewUserRepository(db))
+		})
+	}
+
+	// the fake must behave the same as databases
+	t.Run("inmemory", func(t *testing.T) {
+		run(t, NewInMemoryUserRepository(users...))
+	})
+}
@@ -0,0 +1,143 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrUnhealthy is returned when the database or the connection pool cannot serve queries.
+var ErrUnhealthy = errors.New("database is unhealthy")
+
+// names of the checks of Health
+const (
+	healthCheckPing  = "ping"
+	healthCheckQuery = "query"
+	healthCheckPool  = "pool"
+)
+
+// HealthError is a failure of a check of Health.
+type HealthError struct {
+	// Check is the name of the failed check: "ping", "query" or "pool".
+	Check string
+	Err   error
+}
+
+func (e *HealthError) Error() string {
+	return fmt.Sprintf("%s (check: %s): %s", ErrUnhealthy, e.Check, e.Err)
+}
+
+func (e *HealthError) Unwrap() error {
+	return e.Err
+}
+
+func (e *HealthError) Is(target error) bool {
+	return target == ErrUnhealthy
+}
+
+// HealthThresholds are limits of the connection pool checked by Health. Zero values disable each limit.
+type HealthThresholds struct {
+	// MaxInUseRatio is the ratio of connections in use to MaxOpenConns over which the pool is saturated.
+	// It is ignored if the pool is unlimited.
+	MaxInUseRatio float64
+	// MaxNewWaits is the number of callers which waited for a free connection since the previous check.
+	MaxNewWaits int64
+}
+
+type healthChecker struct {
+	db         *sql.DB
+	thresholds HealthThresholds
+
+	mu sync.Mutex
+	// waitCount is WaitCount of the pool at the previous check
+	waitCount int64
+}
+
+// NewHealthChecker returns a checker of db. thresholds may be nil to check only the database.
+func NewHealthChecker(db *sql.DB, thresholds *HealthThresholds) *healthChecker {
+	c := &healthChecker{
+		db:        db,
+		waitCount: db.Stats().WaitCount,
+	}
+	if thresholds != nil {
+		c.thresholds = *thresholds
+	}
+	return c
+}
+
+// Health reports whether the database accepts connections and answers a query,
+// and whether the pool is within the thresholds. It returns a HealthError otherwise.
+// NOTE: the query uses a connection of the pool, so the pool is checked before it
+func (c *healthChecker) Health(ctx context.Context) error {
+	if err := c.checkPool(c.db.Stats()); err != nil {
+		return &HealthError{Check: healthCheckPool, Err: err}
+	}
+
+	if err := c.db.PingContext(ctx); err != nil {
+		return &HealthError{Check: healthCheckPing, Err: err}
+	}
+
+	var one int
+	if err := c.db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return &HealthError{Check: healthCheckQuery, Err: err}
+	}
+	if one != 1 {
+		return &HealthError{Check: healthCheckQuery, Err: fmt.Errorf("SELECT 1 returned %d", one)}
+	}
+
+	return nil
+}
+
+func (c *healthChecker) checkPool(stats sql.DBStats) error {
+	c.mu.Lock()
+	newWaits := stats.WaitCount - c.waitCount
+	c.waitCount = stats.WaitCount
+	c.mu.Unlock()
+
+	if c.thresholds.MaxInUseRatio > 0 && stats.MaxOpenConnections > 0 {
+		ratio := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		if ratio > c.thresholds.MaxInUseRatio {
+			return fmt.Errorf("%d of %d connections are in use", stats.InUse, stats.MaxOpenConnections)
+		}
+	}
+	if c.thresholds.MaxNewWaits > 0 && newWaits > c.thresholds.MaxNewWaits {
+		return fmt.Errorf("%d callers waited for a connection since the previous check", newWaits)
+	}
+	return nil
+}
+
+// WaitHealthy checks the health repeatedly until it is healthy or timeout passes.
+// The error wraps the last HealthError on timeout.
+func (c *healthChecker) WaitHealthy(ctx context.Context, timeout time.Duration) error {
+	if err := c.waitHealthy(ctx, defaultInitialBackoff, defaultMaxBackoff, timeout); err != nil {
+		return fmt.Errorf("failed to wait for healthy database: %w", err)
+	}
+	return nil
+}
+
+// waitHealthy returns the last error of Health on timeout.
+func (c *healthChecker) waitHealthy(ctx context.Context, backoff, maxBackoff, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		err := c.Health(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-sqlmock
+func TestHealthWithSQLMock(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT 1")
+
+	tests := []struct {
+		title       string
+		mock        func(mock sqlmock.Sqlmock)
+		expectedErr string
+	}{
+		{
+			"healthy",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+			},
+			"",
+		},
+		{
+			"ping fails",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+			},
+			"database is unhealthy (check: ping): connection refused",
+		},
+		{
+			"query fails",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+				mock.ExpectQuery(query).WillReturnError(errors.New("server is shutting down"))
+			},
+			"database is unhealthy (check: query): server is shutting down",
+		},
+		{
+			"query returns another value",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(2))
+			},
+			"database is unhealthy (check: query): SELECT 1 returned 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			require.NoError(t, err)
+			defer db.Close()
+			tt.mock(mock)
+
+			// run
+			err = NewHealthChecker(db, nil).Health(context.TODO())
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				require.ErrorIs(t, err, ErrUnhealthy)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestHealthPoolThresholdsWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, MaxOpenConns: 2})
+	require.NoError(t, err)
+	defer db.Close()
+	c := NewHealthChecker(db, &HealthThresholds{MaxInUseRatio: 0.5, MaxNewWaits: 1})
+	require.NoError(t, c.Health(ctx))
+
+	t.Run("saturated pool", func(t *testing.T) {
+		conn1, err := db.Conn(ctx)
+		require.NoError(t, err)
+		defer conn1.Close()
+		conn2, err := db.Conn(ctx)
+		require.NoError(t, err)
+
+		// run
+		err = c.Health(ctx)
+
+		// assert
+		require.EqualError(t, err, "database is unhealthy (check: pool): 2 of 2 connections are in use")
+		var healthErr *HealthError
+		require.ErrorAs(t, err, &healthErr)
+		require.Equal(t, "pool", healthErr.Check)
+
+		// healthy again after the connection is released
+		require.NoError(t, conn2.Close())
+		require.NoError(t, c.Health(ctx))
+	})
+
+	t.Run("waits since the previous check", func(t *testing.T) {
+		conn1, err := db.Conn(ctx)
+		require.NoError(t, err)
+		conn2, err := db.Conn(ctx)
+		require.NoError(t, err)
+		// NOTE: each caller waits until the timeout because both connections are in use
+		for i := 0; i < 2; i++ {
+			waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			_, err := db.Conn(waitCtx)
+			cancel()
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		}
+		require.NoError(t, conn1.Close())
+		require.NoError(t, conn2.Close())
+
+		// run
+		err = c.Health(ctx)
+
+		// assert
+		require.EqualError(t, err, "database is unhealthy (check: pool): 2 callers waited for a connection since the previous check")
+		// the waits are counted only once
+		require.NoError(t, c.Health(ctx))
+	})
+}
+
+// test using go-mysql-server
+func TestWaitHealthyWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// simulator
+	// NOTE: the server starts after the client begins to wait
+	// NOTE: the test waits for the server to start so that it is stopped by the cleanup of the test
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		time.Sleep(300 * time.Millisecond)
+		prepareSimulator(t, port)
+	}()
+	defer func() { <-started }()
+
+	// run
+	err = NewHealthChecker(db, nil).WaitHealthy(context.TODO(), 10*time.Second)
+
+	// assert
+	require.NoError(t, err)
+}
+
+func TestWaitHealthyTimeout(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// run
+	start := time.Now()
+	err = NewHealthChecker(db, nil).WaitHealthy(context.TODO(), 200*time.Millisecond)
+
+	// assert
+	require.ErrorIs(t, err, ErrUnhealthy)
+	require.ErrorContains(t, err, "failed to wait for healthy database: database is unhealthy (check: ping)")
+	require.Less(t, time.Since(start), 2*time.Second)
+}
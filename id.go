@@ -0,0 +1,64 @@
+package gosqltests
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var ErrInvalidUserID = errors.New("invalid user id")
+
+// InvalidUserIDError is returned if a user id is not a ULID.
+type InvalidUserIDError struct {
+	ID  string
+	Err error
+}
+
+func (e *InvalidUserIDError) Error() string {
+	return fmt.Sprintf("%s (id: %s): %s", ErrInvalidUserID, e.ID, e.Err)
+}
+
+func (e *InvalidUserIDError) Unwrap() error {
+	return e.Err
+}
+
+func (e *InvalidUserIDError) Is(target error) bool {
+	return target == ErrInvalidUserID
+}
+
+// UserID is a ULID in the canonical form (26 characters of upper case Crockford's base32).
+type UserID string
+
+// NewUserID generates an id, which is ordered by the generated time.
+func NewUserID() UserID {
+	return UserID(ulid.Make().String())
+}
+
+// IDGenerator generates ids of users registered without them.
+type IDGenerator interface {
+	NewUserID() UserID
+}
+
+// ulidGenerator generates ids by NewUserID.
+type ulidGenerator struct{}
+
+func (ulidGenerator) NewUserID() UserID {
+	return NewUserID()
+}
+
+// ParseUserID validates id. Lower case ids are rejected because ids are compared as strings in the database.
+func ParseUserID(id string) (UserID, error) {
+	parsed, err := ulid.ParseStrict(id)
+	if err != nil {
+		return "", &InvalidUserIDError{ID: id, Err: err}
+	}
+	if parsed.String() != id {
+		return "", &InvalidUserIDError{ID: id, Err: errors.New("id must be upper case")}
+	}
+	return UserID(id), nil
+}
+
+func (id UserID) String() string {
+	return string(id)
+}
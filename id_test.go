@@ -0,0 +1,196 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// sequentialIDs generates predictable ids, which are sequentialID(1), sequentialID(2), ...
+type sequentialIDs struct {
+	mu sync.Mutex
+	n  int
+}
+
+func newSequentialIDs() *sequentialIDs {
+	return &sequentialIDs{}
+}
+
+func (g *sequentialIDs) NewUserID() UserID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return UserID(sequentialID(g.n))
+}
+
+// sequentialID returns the n-th id generated by sequentialIDs.
+// NOTE: ids of digits are valid ULIDs and ordered by n
+func sequentialID(n int) string {
+	return fmt.Sprintf("%026d", n)
+}
+
+func TestNewUserID(t *testing.T) {
+	// run
+	id := NewUserID()
+	next := NewUserID()
+
+	// assert
+	_, err := ParseUserID(id.String())
+	require.NoError(t, err)
+	require.NotEqual(t, id, next)
+}
+
+func TestParseUserID(t *testing.T) {
+	tests := []struct {
+		title       string
+		id          string
+		expectedErr string
+	}{
+		{
+			"valid",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"",
+		},
+		{
+			"too short",
+			"0123456789ABCDEFGHJKMNPQR",
+			"invalid user id (id: 0123456789ABCDEFGHJKMNPQR): ulid: bad data size when unmarshaling",
+		},
+		{
+			"invalid character",
+			"0123456789ABCDEFGHJKMNPQRU",
+			"invalid user id (id: 0123456789ABCDEFGHJKMNPQRU): ulid: bad data characters when unmarshaling",
+		},
+		{
+			"overflow",
+			"8123456789ABCDEFGHJKMNPQRS",
+			"invalid user id (id: 8123456789ABCDEFGHJKMNPQRS): ulid: overflow when unmarshaling",
+		},
+		{
+			"lower case",
+			"0123456789abcdefghjkmnpqrs",
+			"invalid user id (id: 0123456789abcdefghjkmnpqrs): id must be upper case",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			id, err := ParseUserID(tt.id)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				require.True(t, errors.Is(err, ErrInvalidUserID))
+				var invalidErr *InvalidUserIDError
+				require.True(t, errors.As(err, &invalidErr))
+				require.Equal(t, tt.id, invalidErr.ID)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, UserID(tt.id), id)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestRegisterGeneratedIDWithSQLMock(t *testing.T) {
+	insert := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")
+	now := newFakeClock().Now()
+
+	tests := []struct {
+		title      string
+		user       *User
+		expectedID string
+	}{
+		{
+			"user without id gets a generated id",
+			&User{Name: "Mike", Age: lo.ToPtr(20)},
+			sequentialID(1),
+		},
+		{
+			"id of the user is kept",
+			&User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+			"0123456789ABCDEFGHJKMNPQRS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			mock.ExpectExec(insert).
+				WithArgs(tt.expectedID, "Mike", 20, nil, nil, 1, now, now).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			// run
+			r := NewUserRepository(db, WithClock(newFakeClock()), WithIDGenerator(newSequentialIDs()))
+			err := r.Register(context.TODO(), tt.user)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedID, tt.user.ID)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using every selected DBTestBackend which stores rows and the in-memory fake
+func TestRegisterGoldenOnBackends(t *testing.T) {
+	// run registers users without ids or timestamps, which are generated by the fakes
+	run := func(t *testing.T, r UserRepository, clock *fakeClock) {
+		ctx := context.Background()
+		for i := 0; i < 5; i++ {
+			clock.Advance(time.Minute)
+			require.NoError(t, r.Register(ctx, &User{Name: fmt.Sprintf("user%02d", i), Age: lo.ToPtr(20 + i)}))
+		}
+		bulk := []*User{{Name: "bulk00"}, {Name: "bulk01", Email: "bulk01@example.com"}}
+		clock.Advance(time.Minute)
+		if bulkRegisterer, ok := r.(interface {
+			RegisterAll(ctx context.Context, users []*User) error
+		}); ok {
+			require.NoError(t, bulkRegisterer.RegisterAll(ctx, bulk))
+		} else {
+			for _, u := range bulk {
+				require.NoError(t, r.Register(ctx, u))
+			}
+		}
+
+		actual, total, err := r.List(ctx, nil)
+
+		require.NoError(t, err)
+		// NOTE: golden files are shared by backends because results must not depend on them
+		assertGolden(t, "register/generated", &listResult{Users: actual, Total: total})
+	}
+
+	for _, b := range selectedBackends(t) {
+		backend := b.newBackend()
+		if isMockBackend(backend) {
+			continue
+		}
+
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := backend.Setup(ctx, t)
+			defer backend.Teardown(ctx, t)
+			clock := newFakeClock()
+			run(t, NewUserRepository(db, WithClock(clock), WithIDGenerator(newSequentialIDs())), clock)
+		})
+	}
+
+	// the fake must behave the same as databases
+	t.Run("inmemory", func(t *testing.T) {
+		clock := newFakeClock()
+		r := NewInMemoryUserRepository()
+		r.clock, r.ids = clock, newSequentialIDs()
+		run(t, r, clock)
+	})
+}
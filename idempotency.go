@@ -0,0 +1,123 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/volatiletech/sqlboiler/v4/boil"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrInvalidIdempotencyKey is returned when the idempotency key is empty or too long.
+var ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+
+// ErrIdempotentUserDeleted is returned by RegisterIdempotent if the user registered with the key has been deleted.
+// The key is still used, so it does not register another user.
+var ErrIdempotentUserDeleted = errors.New("user registered with idempotency key has been deleted")
+
+// IdempotentUserDeletedError reports the id of the deleted user registered with the idempotency key.
+type IdempotentUserDeletedError struct {
+	Key    string
+	UserID string
+}
+
+func (e *IdempotentUserDeletedError) Error() string {
+	return fmt.Sprintf("%s (key: %q, id: %s)", ErrIdempotentUserDeleted, e.Key, e.UserID)
+}
+
+func (e *IdempotentUserDeletedError) Is(target error) bool {
+	return target == ErrIdempotentUserDeleted
+}
+
+// maximum length of idempotency keys (the length of idempotency_key.id)
+const maxIdempotencyKeyLength = 255
+
+func validateIdempotencyKey(key string) error {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: must be 1 to %d bytes (length: %d)", ErrInvalidIdempotencyKey, maxIdempotencyKeyLength, len(key))
+	}
+	return nil
+}
+
+// RegisterIdempotent registers the user once per key, so that clients can retry requests safely.
+// If the key has been used, it returns the user registered with the key instead of user,
+// or IdempotentUserDeletedError if the user has been deleted.
+// NOTE: the key is inserted in the same transaction as the user. Concurrent calls with the same key wait for
+// the first one on the primary key of the key, and return the user registered by it after it is committed.
+func (r *userRepository) RegisterIdempotent(ctx context.Context, key string, user *User) (*User, error) {
+	if err := validateIdempotencyKey(key); err != nil {
+		return nil, err
+	}
+	r.assignID(user)
+	if _, err := ParseUserID(user.ID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	registered, err := r.registerWithKey(ctx, key, user)
+	if err != nil {
+		return nil, err
+	}
+	if registered {
+		return user, nil
+	}
+
+	k, err := models.FindIdempotencyKey(ctx, r.db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key %q: %w", key, err)
+	}
+	found, err := models.FindUser(ctx, r.db, k.UserID)
+	// NOTE: FindUser skips soft-deleted users
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &IdempotentUserDeletedError{Key: key, UserID: k.UserID}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user registered with idempotency key %q (id: %s): %w", key, k.UserID, err)
+	}
+	return fromUserModel(found), nil
+}
+
+// registerWithKey inserts the key and the user in a transaction. It returns false if the key has been used.
+func (r *userRepository) registerWithKey(ctx context.Context, key string, user *User) (bool, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	k := &models.IdempotencyKey{ID: key, UserID: user.ID, CreatedAt: r.now()}
+	if err := k.Insert(ctx, tx, boil.Infer()); err != nil {
+		// NOTE: the primary key is the only unique key of the table
+		if _, _, ok := duplicateEntry(err); ok {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to insert idempotency key: %w", wrapStorageError(err))
+	}
+
+	insert := func(ctx context.Context, exec boil.ContextExecutor) error {
+		setInsertTimestamps(user, r.now())
+		c := toUserModel(user)
+		if err := c.Insert(ctx, exec, boil.Infer()); err != nil {
+			return fmt.Errorf("failed to insert user: %w", wrapEmailTakenError(wrapStorageError(err), user))
+		}
+		return nil
+	}
+	if r.audit != nil {
+		err = r.audit.record(ctx, tx, user.ID, nil, insert)
+	} else {
+		err = insert(ctx, tx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
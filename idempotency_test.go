@@ -0,0 +1,305 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// test using go-sqlmock
+func TestRegisterIdempotentWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	registered := &User{ID: mike.ID, Name: "Mike", Age: lo.ToPtr(20), CreatedAt: seededAt, UpdatedAt: seededAt}
+
+	insertKey := regexp.QuoteMeta("INSERT INTO `idempotency_key` (`id`,`user_id`,`created_at`) VALUES (?,?,?)")
+	insertUser := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")
+
+	tests := []struct {
+		title       string
+		key         string
+		user        *User
+		mock        func(mock sqlmock.Sqlmock)
+		expected    *User
+		expectedErr string
+	}{
+		{
+			"register with a new key",
+			"key-1",
+			mike,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertKey).
+					WithArgs("key-1", mike.ID, seededAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, "Mike", 20, nil, nil, 1, seededAt, seededAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			mike,
+			"",
+		},
+		{
+			"replayed key returns the registered user",
+			"key-1",
+			bob,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertKey).
+					WithArgs("key-1", bob.ID, seededAt).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'key-1' for key 'idempotency_key.PRIMARY'"})
+				mock.ExpectRollback()
+				mock.ExpectQuery(regexp.QuoteMeta("select * from `idempotency_key` where `id`=?")).
+					WithArgs("key-1").
+					WillReturnRows(sqlmock.NewRows(idempotencyKeyColumnNames).AddRow("key-1", mike.ID, archiveCutoff))
+				mock.ExpectQuery(regexp.QuoteMeta("select * from `user` where `id`=? and `deleted_at` is null")).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).AddRow(mike.ID, "Mike", 20, nil, nil, 1, seededAt, seededAt))
+			},
+			registered,
+			"",
+		},
+		{
+			"replayed key of a deleted user",
+			"key-1",
+			bob,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertKey).
+					WithArgs("key-1", bob.ID, seededAt).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'key-1' for key 'idempotency_key.PRIMARY'"})
+				mock.ExpectRollback()
+				mock.ExpectQuery(regexp.QuoteMeta("select * from `idempotency_key` where `id`=?")).
+					WithArgs("key-1").
+					WillReturnRows(sqlmock.NewRows(idempotencyKeyColumnNames).AddRow("key-1", mike.ID, archiveCutoff))
+				mock.ExpectQuery(regexp.QuoteMeta("select * from `user` where `id`=? and `deleted_at` is null")).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows(userColumnNames))
+			},
+			nil,
+			`user registered with idempotency key has been deleted (key: "key-1", id: 0123456789ABCDEFGHJKMNPQRS)`,
+		},
+		{
+			"key is not saved if the user cannot be registered",
+			"key-1",
+			mike,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertKey).
+					WithArgs("key-1", mike.ID, seededAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, "Mike", 20, nil, nil, 1, seededAt, seededAt).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'Mike' for key 'user.name'"})
+				mock.ExpectRollback()
+			},
+			nil,
+			"failed to insert user: models: unable to insert into user: Error 1062: Duplicate entry 'Mike' for key 'user.name'",
+		},
+		{
+			"empty key",
+			"",
+			mike,
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+			"invalid idempotency key: must be 1 to 255 bytes (length: 0)",
+		},
+		{
+			"too long key",
+			strings.Repeat("a", 256),
+			mike,
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+			"invalid idempotency key: must be 1 to 255 bytes (length: 256)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			// NOTE: the clock sets created_at of both the key and the user
+			r := NewUserRepository(db, WithClock(newFakeClock()))
+			actual, err := r.RegisterIdempotent(context.TODO(), tt.key, tt.user)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, actual)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// idempotentRegisterer is implemented by userRepository and inMemoryUserRepository.
+type idempotentRegisterer interface {
+	RegisterIdempotent(ctx context.Context, key string, user *User) (*User, error)
+	Get(ctx context.Context, id string) (*User, error)
+	Delete(ctx context.Context, user *User) error
+}
+
+// assertRegisterIdempotent checks replayed keys return the first user without registering the others.
+// Ids of users are generated by sequentialIDs of r.
+func assertRegisterIdempotent(ctx context.Context, t *testing.T, r idempotentRegisterer) {
+	mike := &User{Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{Name: "Bob", Age: lo.ToPtr(25)}
+
+	// run
+	first, err := r.RegisterIdempotent(ctx, "key-1", mike)
+	require.NoError(t, err)
+	replayed, err := r.RegisterIdempotent(ctx, "key-1", bob)
+	require.NoError(t, err)
+	another, err := r.RegisterIdempotent(ctx, "key-2", bob)
+	require.NoError(t, err)
+
+	// assert
+	require.Equal(t, sequentialID(1), first.ID)
+	require.Equal(t, mike, first)
+	require.Equal(t, mike, replayed)
+	require.Equal(t, bob, another)
+	found, err := r.Get(ctx, bob.ID)
+	require.NoError(t, err)
+	require.Equal(t, bob, found)
+}
+
+// assertRegisterIdempotentOfDeletedUser checks a replayed key of a deleted user neither registers another user nor reports not found.
+func assertRegisterIdempotentOfDeletedUser(ctx context.Context, t *testing.T, r idempotentRegisterer) {
+	mike := &User{Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{Name: "Bob", Age: lo.ToPtr(25)}
+	first, err := r.RegisterIdempotent(ctx, "key-1", mike)
+	require.NoError(t, err)
+	require.NoError(t, r.Delete(ctx, first))
+
+	// run
+	_, err = r.RegisterIdempotent(ctx, "key-1", bob)
+
+	// assert
+	require.ErrorIs(t, err, ErrIdempotentUserDeleted)
+	var deletedErr *IdempotentUserDeletedError
+	require.ErrorAs(t, err, &deletedErr)
+	require.Equal(t, first.ID, deletedErr.UserID)
+	_, err = r.Get(ctx, bob.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+// test using go-mysql-server
+func TestRegisterIdempotentWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	assertRegisterIdempotent(ctx, t, NewUserRepository(db, WithIDGenerator(newSequentialIDs())))
+}
+
+// test using go-mysql-server
+func TestRegisterIdempotentOfDeletedUserWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	assertRegisterIdempotentOfDeletedUser(ctx, t, NewUserRepository(db))
+}
+
+// test using in-memory fake
+func TestRegisterIdempotentInMemory(t *testing.T) {
+	r := NewInMemoryUserRepository()
+	r.ids = newSequentialIDs()
+	assertRegisterIdempotent(context.Background(), t, r)
+}
+
+// test using in-memory fake
+func TestRegisterIdempotentOfDeletedUserInMemory(t *testing.T) {
+	assertRegisterIdempotentOfDeletedUser(context.Background(), t, NewInMemoryUserRepository())
+}
+
+// assertRegisterIdempotentConcurrently calls RegisterIdempotent with the same key and different users at once,
+// and checks exactly one of them is registered and returned to every caller.
+func assertRegisterIdempotentConcurrently(ctx context.Context, t *testing.T, r idempotentRegisterer, countUsers func() int64) {
+	const n = 20
+	users := generateUsers(t, n)
+
+	// run
+	results := make([]*User, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = r.RegisterIdempotent(ctx, "same-key", users[i])
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	// assert
+	for i := range users {
+		require.NoError(t, errs[i], "caller %d", i)
+		require.Equal(t, results[0], results[i], "caller %d", i)
+	}
+	require.Contains(t, users, results[0])
+	require.Equal(t, int64(1), countUsers())
+}
+
+// test using testcontainers
+// NOTE: go-mysql-server is not used because it does not isolate transactions,
+// so a replayed call may read the key before the user registered with it
+func TestRegisterIdempotentConcurrentlyWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	for i := 0; i < 5; i++ {
+		t.Run(fmt.Sprintf("round %d", i), func(t *testing.T) {
+			require.NoError(t, truncateTables(ctx, db, "practice"))
+
+			assertRegisterIdempotentConcurrently(ctx, t, NewUserRepository(db), func() int64 {
+				n, err := models.Users().Count(ctx, db)
+				require.NoError(t, err)
+				return n
+			})
+			keys, err := models.IdempotencyKeys().Count(ctx, db)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), keys)
+		})
+	}
+	requireNoLeakedConnections(t, db)
+}
+
+// test using in-memory fake
+func TestRegisterIdempotentConcurrentlyInMemory(t *testing.T) {
+	ctx := context.Background()
+	r := NewInMemoryUserRepository()
+
+	assertRegisterIdempotentConcurrently(ctx, t, r, func() int64 {
+		users, total, err := r.List(ctx, nil)
+		require.NoError(t, err)
+		require.Len(t, users, int(total))
+		return total
+	})
+}
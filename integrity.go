@@ -0,0 +1,138 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ForeignKey is a reference from columns of a child table to columns of a parent table.
+type ForeignKey struct {
+	Table             string
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+}
+
+func (k *ForeignKey) String() string {
+	return fmt.Sprintf("%s(%s) -> %s(%s)", k.Table, strings.Join(k.Columns, ", "), k.ReferencedTable, strings.Join(k.ReferencedColumns, ", "))
+}
+
+// declaredForeignKeys are the foreign keys defined by migrations.
+// NOTE: idempotency_key.user_id and audit_log.user_id have no foreign keys because they outlive hard-deleted users
+var declaredForeignKeys = []*ForeignKey{
+	{
+		Table:             models.TableNames.Credential,
+		Columns:           []string{models.CredentialColumns.UserID},
+		ReferencedTable:   models.TableNames.User,
+		ReferencedColumns: []string{models.UserColumns.ID},
+	},
+	{
+		Table:             models.TableNames.Order,
+		Columns:           []string{models.OrderColumns.UserID},
+		ReferencedTable:   models.TableNames.User,
+		ReferencedColumns: []string{models.UserColumns.ID},
+	},
+	{
+		Table:             models.TableNames.UserTenant,
+		Columns:           []string{models.UserTenantColumns.UserID},
+		ReferencedTable:   models.TableNames.User,
+		ReferencedColumns: []string{models.UserColumns.ID},
+	},
+}
+
+// ForeignKeys returns the foreign keys of the current database defined in the server.
+func ForeignKeys(ctx context.Context, db *sql.DB) ([]*ForeignKey, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT `table_name`, `column_name`, `referenced_table_name`, `referenced_column_name`, `ordinal_position` "+
+			"FROM `information_schema`.`key_column_usage` "+
+			"WHERE `table_schema` = DATABASE() AND `referenced_table_name` IS NOT NULL "+
+			"ORDER BY `table_name`, `constraint_name`, `ordinal_position`",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*ForeignKey
+	for rows.Next() {
+		var table, column, referencedTable, referencedColumn string
+		var position int
+		if err := rows.Scan(&table, &column, &referencedTable, &referencedColumn, &position); err != nil {
+			return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+		}
+		// NOTE: a key of multiple columns has a row for each column, which starts from position 1
+		if position == 1 || len(keys) == 0 {
+			keys = append(keys, &ForeignKey{Table: table, ReferencedTable: referencedTable})
+		}
+		k := keys[len(keys)-1]
+		k.Columns = append(k.Columns, column)
+		k.ReferencedColumns = append(k.ReferencedColumns, referencedColumn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+	return keys, nil
+}
+
+// OrphanedRows returns the number of rows of the child table whose referenced row does not exist.
+// Rows with NULL in any column of the key are not orphaned, as MySQL does not check them.
+func OrphanedRows(ctx context.Context, db *sql.DB, key *ForeignKey) (int64, error) {
+	conditions := make([]string, len(key.Columns))
+	notNull := make([]string, len(key.Columns))
+	for i, c := range key.Columns {
+		conditions[i] = fmt.Sprintf("c.%s = p.%s", quoteIdentifier(c), quoteIdentifier(key.ReferencedColumns[i]))
+		notNull[i] = fmt.Sprintf("c.%s IS NOT NULL", quoteIdentifier(c))
+	}
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s c LEFT JOIN %s p ON %s WHERE %s AND p.%s IS NULL",
+		quoteIdentifier(key.Table), quoteIdentifier(key.ReferencedTable), strings.Join(conditions, " AND "),
+		strings.Join(notNull, " AND "), quoteIdentifier(key.ReferencedColumns[0]),
+	)
+
+	var n int64
+	if err := db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count orphaned rows of %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// AssertReferentialIntegrity fails the test if any row references a missing row by a foreign key defined in the server.
+// Call it after a scenario, e.g. to check writes which bypass or disable foreign key checks.
+// NOTE: use AssertDeclaredReferentialIntegrity for tables created without foreign keys (e.g. by prepareSimulator)
+func AssertReferentialIntegrity(t require.TestingT, db *sql.DB) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	ctx := context.Background()
+	keys, err := ForeignKeys(ctx, db)
+	require.NoError(t, err)
+	assertNoOrphanedRows(t, ctx, db, keys)
+}
+
+// AssertDeclaredReferentialIntegrity is AssertReferentialIntegrity checking the foreign keys defined by migrations
+// instead of reading them from the server, so that it also works on go-mysql-server tables created without them.
+func AssertDeclaredReferentialIntegrity(t require.TestingT, db *sql.DB) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	assertNoOrphanedRows(t, context.Background(), db, declaredForeignKeys)
+}
+
+func assertNoOrphanedRows(t require.TestingT, ctx context.Context, db *sql.DB, keys []*ForeignKey) {
+	var violations []string
+	for _, k := range keys {
+		n, err := OrphanedRows(ctx, db, k)
+		require.NoError(t, err)
+		if n > 0 {
+			violations = append(violations, fmt.Sprintf("%d rows of %s", n, k))
+		}
+	}
+	require.Empty(t, violations, "orphaned rows are found")
+}
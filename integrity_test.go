@@ -0,0 +1,140 @@
+package gosqltests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-mysql-server
+func TestForeignKeysWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	t.Run("declared foreign keys match migrations", func(t *testing.T) {
+		// run
+		keys, err := ForeignKeys(ctx, db)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, declaredForeignKeys, keys)
+	})
+
+	t.Run("key of multiple columns", func(t *testing.T) {
+		_, err := db.ExecContext(ctx, "CREATE TABLE `team` (`org` VARCHAR(26), `name` VARCHAR(40), PRIMARY KEY (`org`, `name`))")
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, "CREATE TABLE `member` (`user_id` VARCHAR(26) PRIMARY KEY, `org` VARCHAR(26), `team` VARCHAR(40), "+
+			"CONSTRAINT `member_team` FOREIGN KEY (`org`, `team`) REFERENCES `team` (`org`, `name`), "+
+			"CONSTRAINT `member_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`))")
+		require.NoError(t, err)
+
+		// run
+		keys, err := ForeignKeys(ctx, db)
+
+		// assert
+		require.NoError(t, err)
+		require.ElementsMatch(t, []*ForeignKey{
+			declaredForeignKeys[0],
+			declaredForeignKeys[1],
+			declaredForeignKeys[2],
+			{Table: "member", Columns: []string{"org", "team"}, ReferencedTable: "team", ReferencedColumns: []string{"org", "name"}},
+			{Table: "member", Columns: []string{"user_id"}, ReferencedTable: "user", ReferencedColumns: []string{"id"}},
+		}, keys)
+	})
+}
+
+// test using testcontainers
+func TestForeignKeysWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	// run
+	keys, err := ForeignKeys(ctx, db)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, declaredForeignKeys, keys)
+}
+
+// test using go-mysql-server
+func TestAssertReferentialIntegrityWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	tests := []struct {
+		title       string
+		credentials []string
+		expected    []string
+	}{
+		{
+			"no orphaned rows",
+			[]string{mike.ID},
+			nil,
+		},
+		{
+			"credential of a missing user",
+			[]string{mike.ID, "1123456789ABCDEFGHJKMNPQRS", "2123456789ABCDEFGHJKMNPQRS"},
+			[]string{"2 rows of credential(user_id) -> user(id)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			port := prepareMigratedSimulator(ctx, t)
+			// NOTE: foreign key checks are disabled in the client, so orphaned rows can be inserted
+			db, err := newMigrationClient(port)
+			require.NoError(t, err)
+			require.NoError(t, NewUserRepository(db).Register(ctx, mike))
+			for _, id := range tt.credentials {
+				_, err := db.ExecContext(ctx, "INSERT INTO `credential` (`user_id`, `password_hash`) VALUES (?, 'hash')", id)
+				require.NoError(t, err)
+			}
+
+			// run
+			rt := &recordingT{}
+			rt.run(func() { AssertReferentialIntegrity(rt, db) })
+
+			// assert
+			require.Equal(t, tt.expected != nil, rt.failed, rt.errors)
+			for _, e := range tt.expected {
+				require.Contains(t, rt.errors[0], e)
+			}
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestAssertDeclaredReferentialIntegrityWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	// NOTE: tables of prepareSimulator have no foreign keys
+	prepareSimulator(t, port)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO `credential` (`user_id`, `password_hash`) VALUES (?, 'hash')", "1123456789ABCDEFGHJKMNPQRS")
+	require.NoError(t, err)
+
+	keys, err := ForeignKeys(ctx, db)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// run
+	rt := &recordingT{}
+	rt.run(func() { AssertDeclaredReferentialIntegrity(rt, db) })
+
+	// assert
+	require.True(t, rt.failed)
+	require.Contains(t, rt.errors[0], "1 rows of credential(user_id) -> user(id)")
+}
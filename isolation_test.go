@@ -0,0 +1,120 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// newIsolatedDatabase creates a uniquely named database with the schema applied on the server and
+// returns the client scoped to it. The database is dropped on cleanup.
+// NOTE: tests using their own databases can call t.Parallel() while sharing one server,
+// because no test truncates or writes tables of the others
+func newIsolatedDatabase(ctx context.Context, t testing.TB, port int) (*sql.DB, string) {
+	t.Helper()
+
+	database, err := NamespacedDatabase(defaultDatabase, "test_"+strings.ToLower(ulid.Make().String()))
+	require.NoError(t, err)
+
+	root, err := NewClientWithWait(ctx, &ClientConfig{Port: port})
+	require.NoError(t, err)
+	defer root.Close()
+	require.NoError(t, CreateDatabase(ctx, root, database))
+
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, Database: database, StrictScan: true})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		defer db.Close()
+		// NOTE: ctx of the test may be canceled already
+		require.NoError(t, DropDatabase(context.Background(), db, database))
+	})
+
+	skipUnlessSupported(ctx, t, db, featureLargeIndexPrefix)
+	require.NoError(t, Migrate(ctx, db))
+	return db, database
+}
+
+func listDatabases(ctx context.Context, t testing.TB, port int) []string {
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port})
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SHOW DATABASES")
+	require.NoError(t, err)
+	defer rows.Close()
+	var databases []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		databases = append(databases, name)
+	}
+	require.NoError(t, rows.Err())
+	return databases
+}
+
+// assertIsolatedDatabases runs parallel subtests writing the same user to their own databases of the server.
+func assertIsolatedDatabases(ctx context.Context, t *testing.T, port int) {
+	user := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	var mu sync.Mutex
+	var databases []string
+	// NOTE: the group waits for its parallel subtests, including their cleanups
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
+				t.Parallel()
+				db, database := newIsolatedDatabase(ctx, t, port)
+				mu.Lock()
+				databases = append(databases, database)
+				mu.Unlock()
+				r := NewUserRepository(db)
+
+				// run
+				err := r.Register(ctx, user)
+
+				// assert
+				require.NoError(t, err)
+				found, total, err := r.List(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, int64(1), total)
+				require.Equal(t, []*User{user}, found)
+			})
+		}
+	})
+
+	// assert
+	require.Len(t, databases, 4)
+	remaining := listDatabases(ctx, t, port)
+	for _, database := range databases {
+		require.NotContains(t, remaining, database)
+	}
+}
+
+// test using go-mysql-server
+func TestIsolatedDatabaseWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+
+	assertIsolatedDatabases(ctx, t, port)
+}
+
+// test using testcontainers
+func TestIsolatedDatabaseWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	port := startContainer(ctx, t)
+
+	assertIsolatedDatabases(ctx, t, port)
+}
@@ -0,0 +1,72 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrLockTimeout = errors.New("lock was not acquired in time")
+
+// databaseLock is a named lock of MySQL (GET_LOCK), which coordinates processes sharing one database server.
+// NOTE: the lock belongs to the session, so it is held by a dedicated connection until Release.
+// It is also released by the server if the process dies and the connection is closed.
+type databaseLock struct {
+	conn *sql.Conn
+	name string
+}
+
+// AcquireLock waits for the named lock up to timeout (rounded up to seconds).
+func AcquireLock(ctx context.Context, db *sql.DB, name string, timeout time.Duration) (*databaseLock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	var acquired sql.NullInt64
+	seconds := int64(math.Ceil(timeout.Seconds()))
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, seconds).Scan(&acquired); err != nil {
+		// NOTE: the lock may have been acquired even if the result was lost (e.g. ctx was canceled)
+		discardConn(conn)
+		return nil, fmt.Errorf("failed to get lock (name: %s): %w", name, err)
+	}
+	// NOTE: GET_LOCK returns 0 on timeout and NULL on errors
+	if !acquired.Valid {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get lock (name: %s)", name)
+	}
+	if acquired.Int64 == 0 {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get lock (name: %s, timeout: %s): %w", name, timeout, ErrLockTimeout)
+	}
+
+	return &databaseLock{conn: conn, name: name}, nil
+}
+
+// Release releases the lock and returns the connection to the pool.
+// If RELEASE_LOCK fails (e.g. ctx is canceled), the connection is closed instead, which makes the server release the lock,
+// so that the next borrower of the connection does not hold the lock unknowingly.
+func (l *databaseLock) Release(ctx context.Context) error {
+	var released sql.NullInt64
+	if err := l.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", l.name).Scan(&released); err != nil {
+		discardConn(l.conn)
+		return fmt.Errorf("failed to release lock (name: %s): %w", l.name, err)
+	}
+	defer l.conn.Close()
+
+	// NOTE: RELEASE_LOCK returns 0 if another session holds the lock and NULL if nobody holds it
+	if released.Int64 != 1 {
+		return fmt.Errorf("lock was not held (name: %s)", l.name)
+	}
+	return nil
+}
+
+// discardConn closes the connection instead of returning it to the pool, which ends the session and the locks it holds.
+func discardConn(conn *sql.Conn) {
+	// NOTE: the pool closes a connection if Raw returns driver.ErrBadConn
+	_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+}
@@ -0,0 +1,134 @@
+package gosqltests
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-sqlmock
+func TestAcquireLockWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title       string
+		result      interface{}
+		expectedErr string
+	}{
+		{
+			"acquired",
+			int64(1),
+			"",
+		},
+		{
+			"timeout",
+			int64(0),
+			"failed to get lock (name: gosqltests:practice, timeout: 1s): lock was not acquired in time",
+		},
+		{
+			"error",
+			nil,
+			"failed to get lock (name: gosqltests:practice)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).
+				WithArgs("gosqltests:practice", 1).
+				WillReturnRows(sqlmock.NewRows([]string{"GET_LOCK"}).AddRow(tt.result))
+			if tt.expectedErr == "" {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT RELEASE_LOCK(?)")).
+					WithArgs("gosqltests:practice").
+					WillReturnRows(sqlmock.NewRows([]string{"RELEASE_LOCK"}).AddRow(1))
+			}
+
+			// run
+			lock, err := AcquireLock(context.TODO(), db, "gosqltests:practice", time.Second)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, lock.Release(context.TODO()))
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestReleaseLockFailureDiscardsConnectionWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT GET_LOCK(?, ?)")).
+		WithArgs("gosqltests:practice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"GET_LOCK"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT RELEASE_LOCK(?)")).
+		WithArgs("gosqltests:practice").
+		WillReturnError(context.Canceled)
+	// NOTE: closing the session makes the server release the lock
+	mock.ExpectClose()
+
+	lock, err := AcquireLock(context.TODO(), db, "gosqltests:practice", time.Second)
+	require.NoError(t, err)
+
+	// run
+	err = lock.Release(context.TODO())
+
+	// assert
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, db.Stats().OpenConnections, "connection holding the lock must not be returned to the pool")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestAcquireLockByCompetingProcessesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+
+	// NOTE: each client simulates a process, e.g. a CI job
+	processA, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer processA.Close()
+	processB, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer processB.Close()
+
+	// run
+	lockA, err := AcquireLock(ctx, processA, "gosqltests:practice", time.Second)
+	require.NoError(t, err)
+
+	// assert
+	_, err = AcquireLock(ctx, processB, "gosqltests:practice", 0)
+	require.ErrorIs(t, err, ErrLockTimeout)
+
+	// B gets the lock while waiting for it
+	acquired := make(chan error)
+	go func() {
+		lockB, err := AcquireLock(ctx, processB, "gosqltests:practice", 5*time.Second)
+		if err == nil {
+			err = lockB.Release(ctx)
+		}
+		acquired <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, lockA.Release(ctx))
+	require.NoError(t, <-acquired)
+
+	// other names are independent
+	lockOther, err := AcquireLock(ctx, processB, "gosqltests:other", 0)
+	require.NoError(t, err)
+	require.NoError(t, lockOther.Release(ctx))
+}
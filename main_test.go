@@ -0,0 +1,56 @@
+package gosqltests
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	registerMutationHooks()
+	// NOTE: flags are parsed before m.Run to choose the backend of -db-backend
+	flag.Parse()
+
+	var code int
+	images, err := mysqlMatrixImages()
+	if err == nil {
+		err = initDBBackend()
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		code = 1
+	case images != nil:
+		code = runMySQLMatrix(m, images)
+	default:
+		code = m.Run()
+	}
+	if err := saveFixtureReport(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	if err := closeDBBackend(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	if err := closeSharedSimulator(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	// NOTE: the stack of docker-compose.yml is started by the first test using it
+	if err := compose.down(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,157 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// outcomes of repository operations in metrics
+const (
+	outcomeOK       = "ok"
+	outcomeNotFound = "not_found"
+	outcomeError    = "error"
+)
+
+// metricsUserRepository counts operations of the repository and observes their latencies by method and outcome.
+type metricsUserRepository struct {
+	cacheableUserRepository
+	operations *prometheus.CounterVec
+	durations  *prometheus.HistogramVec
+	clock      Clock
+}
+
+// NewMetricsUserRepository registers the metrics below on reg and records operations of repo on them.
+//
+//   - gosqltests_repository_operations_total: counter of operations
+//   - gosqltests_repository_operation_duration_seconds: histogram of latencies
+//
+// Both have the labels method (e.g. Get) and outcome (ok, not_found or error).
+// not_found is the outcome of reads which return sql.ErrNoRows.
+func NewMetricsUserRepository(repo cacheableUserRepository, reg prometheus.Registerer) (*metricsUserRepository, error) {
+	return newMetricsUserRepository(repo, reg, systemClock{})
+}
+
+func newMetricsUserRepository(repo cacheableUserRepository, reg prometheus.Registerer, clock Clock) (*metricsUserRepository, error) {
+	r := &metricsUserRepository{
+		cacheableUserRepository: repo,
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gosqltests",
+			Subsystem: "repository",
+			Name:      "operations_total",
+			Help:      "Number of operations of the user repository.",
+		}, []string{"method", "outcome"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gosqltests",
+			Subsystem: "repository",
+			Name:      "operation_duration_seconds",
+			Help:      "Latencies of operations of the user repository.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "outcome"}),
+		clock: clock,
+	}
+
+	for _, c := range []prometheus.Collector{r.operations, r.durations} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+	return r, nil
+}
+
+var _ UserRepository = (*metricsUserRepository)(nil)
+
+func (r *metricsUserRepository) Register(ctx context.Context, user *User) error {
+	return r.observe(OperationRegister, func() error {
+		return r.cacheableUserRepository.Register(ctx, user)
+	})
+}
+
+func (r *metricsUserRepository) Upsert(ctx context.Context, user *User, updateColumns ...string) error {
+	return r.observe(OperationUpsert, func() error {
+		return r.cacheableUserRepository.Upsert(ctx, user, updateColumns...)
+	})
+}
+
+func (r *metricsUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	var users []*User
+	var total int64
+	err := r.observe(OperationList, func() error {
+		var err error
+		users, total, err = r.cacheableUserRepository.List(ctx, query)
+		return err
+	})
+	return users, total, err
+}
+
+func (r *metricsUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	return r.observeUser(OperationGet, func() (*User, error) {
+		return r.cacheableUserRepository.Get(ctx, id)
+	})
+}
+
+func (r *metricsUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	return r.observeUser(OperationGetByName, func() (*User, error) {
+		return r.cacheableUserRepository.GetByName(ctx, name)
+	})
+}
+
+func (r *metricsUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.observeUser(OperationGetByEmail, func() (*User, error) {
+		return r.cacheableUserRepository.GetByEmail(ctx, email)
+	})
+}
+
+func (r *metricsUserRepository) Delete(ctx context.Context, user *User) error {
+	return r.observe(OperationDelete, func() error {
+		return r.cacheableUserRepository.Delete(ctx, user)
+	})
+}
+
+func (r *metricsUserRepository) HardDelete(ctx context.Context, user *User) error {
+	return r.observe(OperationHardDelete, func() error {
+		return r.cacheableUserRepository.HardDelete(ctx, user)
+	})
+}
+
+func (r *metricsUserRepository) Restore(ctx context.Context, id string) error {
+	return r.observe(OperationRestore, func() error {
+		return r.cacheableUserRepository.Restore(ctx, id)
+	})
+}
+
+func (r *metricsUserRepository) observeUser(method string, f func() (*User, error)) (*User, error) {
+	var user *User
+	err := r.observe(method, func() error {
+		var err error
+		user, err = f()
+		return err
+	})
+	return user, err
+}
+
+// observe runs f and records it with the outcome of the error. The error is returned as it is.
+func (r *metricsUserRepository) observe(method string, f func() error) error {
+	start := r.clock.Now()
+	err := f()
+	elapsed := r.clock.Now().Sub(start)
+
+	outcome := operationOutcome(err)
+	r.operations.WithLabelValues(method, outcome).Inc()
+	r.durations.WithLabelValues(method, outcome).Observe(elapsed.Seconds())
+	return err
+}
+
+func operationOutcome(err error) string {
+	switch {
+	case err == nil:
+		return outcomeOK
+	case errors.Is(err, sql.ErrNoRows):
+		return outcomeNotFound
+	default:
+		return outcomeError
+	}
+}
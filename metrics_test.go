@@ -0,0 +1,118 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// steppingClock advances by step every time Now is called, so that each operation takes step.
+type steppingClock struct {
+	*fakeClock
+	step time.Duration
+}
+
+func (c *steppingClock) Now() time.Time {
+	c.Advance(c.step)
+	return c.fakeClock.Now()
+}
+
+// test using go-mysql-server
+func TestMetricsUserRepositoryWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// simulator
+	// NOTE: tables are created by migrations to have the unique key of name,
+	// and foreign key checks are disabled to soft-delete users (see newMigrationClient)
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	reg := prometheus.NewRegistry()
+	r, err := newMetricsUserRepository(NewUserRepository(db), reg, &steppingClock{fakeClock: newFakeClock(), step: 200 * time.Millisecond})
+	require.NoError(t, err)
+
+	// run
+	require.NoError(t, r.Register(ctx, mike))
+	require.Error(t, r.Register(ctx, mike))
+	_, err = r.Get(ctx, mike.ID)
+	require.NoError(t, err)
+	_, err = r.GetByName(ctx, "Bob")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, _, err = r.List(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Delete(ctx, mike))
+	_, err = r.Get(ctx, mike.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// assert
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP gosqltests_repository_operations_total Number of operations of the user repository.
+# TYPE gosqltests_repository_operations_total counter
+gosqltests_repository_operations_total{method="Delete",outcome="ok"} 1
+gosqltests_repository_operations_total{method="Get",outcome="not_found"} 1
+gosqltests_repository_operations_total{method="Get",outcome="ok"} 1
+gosqltests_repository_operations_total{method="GetByName",outcome="not_found"} 1
+gosqltests_repository_operations_total{method="List",outcome="ok"} 1
+gosqltests_repository_operations_total{method="Register",outcome="error"} 1
+gosqltests_repository_operations_total{method="Register",outcome="ok"} 1
+`), "gosqltests_repository_operations_total"))
+
+	histogram := gatherHistogram(t, reg, "gosqltests_repository_operation_duration_seconds", map[string]string{"method": "Get", "outcome": "ok"})
+	require.Equal(t, uint64(1), histogram.GetSampleCount())
+	require.InDelta(t, 0.2, histogram.GetSampleSum(), 1e-9)
+	for _, b := range histogram.GetBucket() {
+		// NOTE: buckets are cumulative
+		expected := uint64(0)
+		if b.GetUpperBound() >= 0.2 {
+			expected = 1
+		}
+		require.Equal(t, expected, b.GetCumulativeCount(), "bucket le=%v", b.GetUpperBound())
+	}
+}
+
+func TestMetricsUserRepositoryOfRegisteredMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	_, err := NewMetricsUserRepository(NewUserRepository(&sql.DB{}), reg)
+	require.NoError(t, err)
+
+	// run
+	_, err = NewMetricsUserRepository(NewUserRepository(&sql.DB{}), reg)
+
+	// assert
+	// NOTE: repositories sharing a registry must share the metrics instead
+	require.ErrorContains(t, err, "failed to register metrics")
+}
+
+// gatherHistogram returns the histogram of the name and labels in reg.
+func gatherHistogram(t *testing.T, reg prometheus.Gatherer, name string, labels map[string]string) *dto.Histogram {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			found := len(m.GetLabel()) == len(labels)
+			for _, l := range m.GetLabel() {
+				found = found && labels[l.GetName()] == l.GetValue()
+			}
+			if found {
+				return m.GetHistogram()
+			}
+		}
+	}
+	t.Fatalf("histogram %s%v was not found", name, labels)
+	return nil
+}
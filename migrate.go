@@ -0,0 +1,143 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrate applies all migrations which have not been applied yet.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	return runMigration(ctx, db, func(m *migrate.Migrate) error {
+		return m.Up()
+	})
+}
+
+// Rollback reverts the last n migrations.
+func Rollback(ctx context.Context, db *sql.DB, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("number of migrations to roll back must be positive (n: %d)", n)
+	}
+	return runMigration(ctx, db, func(m *migrate.Migrate) error {
+		return m.Steps(-n)
+	})
+}
+
+// MigrationVersion returns the version of the last applied migration (0 if nothing is applied).
+func MigrationVersion(ctx context.Context, db *sql.DB) (uint, error) {
+	var version uint
+	err := runMigration(ctx, db, func(m *migrate.Migrate) error {
+		v, dirty, err := m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d failed halfway and must be fixed manually", v)
+		}
+		version = v
+		return nil
+	})
+	return version, err
+}
+
+// ApplySchema creates the tables by executing the up migrations statement by statement in their order,
+// without recording the version as Migrate does. It is for databases which are created from scratch and never migrated,
+// e.g. in tests where the server cannot read the schema from a path of the host.
+// NOTE: the database must be empty, since every migration is executed
+func ApplySchema(ctx context.Context, db *sql.DB) error {
+	paths, err := fs.Glob(migrationFiles, "migrations/*.up.sql")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	// NOTE: names of migrations start with zero-padded versions, so that they are sorted in order
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		content, err := migrationFiles.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", path, err)
+		}
+		for _, stmt := range splitStatements(string(content)) {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// splitStatements splits SQL by semicolons at the end of lines.
+// NOTE: migrations must not have semicolons at the end of lines inside statements (e.g. in string literals)
+func splitStatements(content string) []string {
+	var stmts []string
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		lines = append(lines, line)
+		if !strings.HasSuffix(strings.TrimSpace(line), ";") {
+			continue
+		}
+		stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";"))
+		lines = nil
+	}
+	if rest := strings.TrimSpace(strings.Join(lines, "\n")); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
+
+func runMigration(ctx context.Context, db *sql.DB, f func(*migrate.Migrate) error) error {
+	src, err := iofs.New(migrationFiles, "migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	// NOTE: the driver created by mysql.WithInstance closes db when migration finishes, so pass a connection instead
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	driver, err := mysql.WithConnection(ctx, conn, &mysql.Config{})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "mysql", driver)
+	if err != nil {
+		driver.Close()
+		return fmt.Errorf("failed to create migration: %w", err)
+	}
+	defer m.Close()
+
+	// stop after the current migration if ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.GracefulStop <- true
+		case <-done:
+		}
+	}()
+
+	if err := f(m); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+
+	return nil
+}
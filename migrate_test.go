@@ -0,0 +1,151 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// NOTE: go-mysql-server does not treat the primary key as an index referenced by foreign keys,
+// so foreign key checks are disabled to create the credential table
+func newMigrationClient(port int) (*sql.DB, error) {
+	return sql.Open("mysql", fmt.Sprintf("root:@(localhost:%d)/practice?parseTime=true&foreign_key_checks=0", port))
+}
+
+// test using go-mysql-server
+func TestMigrateWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	// run
+	err = Migrate(ctx, db)
+
+	// assert
+	require.NoError(t, err)
+	version, err := MigrationVersion(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, uint(13), version)
+
+	r := NewUserRepository(db)
+	user := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, r.Register(ctx, user))
+	found, err := r.Get(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, user, found)
+
+	// migrating again does nothing
+	require.NoError(t, Migrate(ctx, db))
+}
+
+// test using go-mysql-server
+func TestApplySchemaWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	// run
+	err = ApplySchema(ctx, db)
+
+	// assert
+	require.NoError(t, err)
+	// NOTE: the version is not recorded
+	version, err := MigrationVersion(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, uint(0), version)
+
+	r := NewUserRepository(db)
+	user := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+	require.NoError(t, r.Register(ctx, user))
+	found, err := r.Get(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, user, found)
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		title    string
+		content  string
+		expected []string
+	}{
+		{
+			"statement over lines",
+			"CREATE TABLE user\n(\n    id VARCHAR(26) PRIMARY KEY\n);\n",
+			[]string{"CREATE TABLE user\n(\n    id VARCHAR(26) PRIMARY KEY\n)"},
+		},
+		{
+			"statements",
+			"ALTER TABLE user ADD COLUMN a INT;\nALTER TABLE user ADD COLUMN b INT;",
+			[]string{"ALTER TABLE user ADD COLUMN a INT", "ALTER TABLE user ADD COLUMN b INT"},
+		},
+		{
+			"statement without a semicolon",
+			"ALTER TABLE user ADD COLUMN a INT\n",
+			[]string{"ALTER TABLE user ADD COLUMN a INT"},
+		},
+		{
+			"empty",
+			"\n",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, splitStatements(tt.content))
+		})
+	}
+}
+
+func TestRollbackWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(ctx, db))
+
+	// run
+	err = Rollback(ctx, db, 12)
+
+	// assert
+	require.NoError(t, err)
+	version, err := MigrationVersion(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), version)
+
+	var tables []string
+	rows, err := db.QueryContext(ctx, "SHOW TABLES")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		require.NoError(t, rows.Scan(&table))
+		tables = append(tables, table)
+	}
+	require.ElementsMatch(t, []string{"schema_migrations", "user"}, tables)
+}
+
+func TestRollbackInvalidSteps(t *testing.T) {
+	err := Rollback(context.TODO(), nil, 0)
+	require.EqualError(t, err, "number of migrations to roll back must be positive (n: 0)")
+}
@@ -1,7 +1,3 @@
-USE practice;
-
-DROP TABLE IF EXISTS user;
-
 CREATE TABLE user
 (
     id          VARCHAR(26) PRIMARY KEY,
@@ -0,0 +1,6 @@
+CREATE TABLE credential
+(
+    user_id         VARCHAR(26) PRIMARY KEY,
+    password_hash   VARCHAR(60) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE
+);
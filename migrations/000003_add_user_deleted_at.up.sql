@@ -0,0 +1 @@
+ALTER TABLE user ADD COLUMN deleted_at DATETIME NULL;
@@ -0,0 +1 @@
+ALTER TABLE user ADD COLUMN email VARCHAR(254) NULL, ADD UNIQUE KEY email (email);
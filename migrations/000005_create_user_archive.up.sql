@@ -0,0 +1,9 @@
+CREATE TABLE user_archive
+(
+    id          VARCHAR(26) PRIMARY KEY,
+    name        VARCHAR(40) NOT NULL,
+    age         INT,
+    deleted_at  DATETIME NULL,
+    email       VARCHAR(254) NULL,
+    archived_at DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE archive_checkpoint
+(
+    job         VARCHAR(64) PRIMARY KEY,
+    last_id     VARCHAR(26) NOT NULL,
+    archived    BIGINT NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE idempotency_key
+(
+    id          VARCHAR(255) PRIMARY KEY,
+    user_id     VARCHAR(26) NOT NULL,
+    created_at  DATETIME NOT NULL
+);
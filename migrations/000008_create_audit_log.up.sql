@@ -0,0 +1,11 @@
+CREATE TABLE audit_log
+(
+    id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id     VARCHAR(26) NOT NULL,
+    action      VARCHAR(16) NOT NULL,
+    actor       VARCHAR(255) NOT NULL,
+    created_at  DATETIME NOT NULL,
+    before_json JSON NULL,
+    after_json  JSON NULL,
+    INDEX user_id (user_id)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE `order`
+(
+    id          VARCHAR(26) PRIMARY KEY,
+    user_id     VARCHAR(26) NOT NULL,
+    item        VARCHAR(255) NOT NULL,
+    amount      INT NOT NULL,
+    created_at  DATETIME NOT NULL,
+    CONSTRAINT order_user FOREIGN KEY (user_id) REFERENCES user (id)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE user_tenant
+(
+    user_id     VARCHAR(26) PRIMARY KEY,
+    tenant_id   VARCHAR(64) NOT NULL,
+    INDEX tenant_id (tenant_id),
+    CONSTRAINT user_tenant_user FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE user ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE user DROP COLUMN created_at, DROP COLUMN updated_at;
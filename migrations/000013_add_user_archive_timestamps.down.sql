@@ -0,0 +1 @@
+ALTER TABLE user_archive DROP COLUMN version, DROP COLUMN created_at, DROP COLUMN updated_at;
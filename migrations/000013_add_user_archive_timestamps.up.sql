@@ -0,0 +1 @@
+ALTER TABLE user_archive ADD COLUMN version BIGINT NOT NULL DEFAULT 1, ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, ADD COLUMN updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
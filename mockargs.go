@@ -0,0 +1,44 @@
+package gosqltests
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// argument matchers for sqlmock expectations, which check values not known before the test runs
+
+var ulidPattern = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+
+type ulidArg struct{}
+
+// ULIDArg matches a ULID string.
+func ULIDArg() sqlmock.Argument {
+	return ulidArg{}
+}
+
+func (ulidArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && ulidPattern.MatchString(s)
+}
+
+type timeArg struct {
+	expected time.Time
+	delta    time.Duration
+}
+
+// TimeArg matches a time within delta of expected, e.g. deleted_at set by the repository.
+func TimeArg(expected time.Time, delta time.Duration) sqlmock.Argument {
+	return &timeArg{expected: expected, delta: delta}
+}
+
+func (a *timeArg) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	d := t.Sub(a.expected)
+	return -a.delta <= d && d <= a.delta
+}
@@ -0,0 +1,50 @@
+package gosqltests
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestULIDArg(t *testing.T) {
+	tests := []struct {
+		title    string
+		value    driver.Value
+		expected bool
+	}{
+		{"ulid", "01ARZ3NDEKTSV4RRFFQ69G5FAV", true},
+		{"too short", "01ARZ3NDEKTSV4RRFFQ69G5FA", false},
+		{"invalid character", "01ARZ3NDEKTSV4RRFFQ69G5FAU", false},
+		{"overflow", "81ARZ3NDEKTSV4RRFFQ69G5FAV", false},
+		{"not string", int64(1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, ULIDArg().Match(tt.value))
+		})
+	}
+}
+
+func TestTimeArg(t *testing.T) {
+	now := time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		title    string
+		value    driver.Value
+		expected bool
+	}{
+		{"same time", now, true},
+		{"within delta", now.Add(-time.Second), true},
+		{"after delta", now.Add(2 * time.Second), false},
+		{"not time", "2022-11-01 12:00:00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, TimeArg(now, time.Second).Match(tt.value))
+		})
+	}
+}
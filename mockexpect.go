@@ -0,0 +1,117 @@
+package gosqltests
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/volatiletech/sqlboiler/v4/queries"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// expectations of sqlmock for queries of NewUserRepository with the default options
+// NOTE: SQL of reads is built by sqlboiler from the same query mods as the repository,
+// so that mock tests follow changes of the queries instead of copying SQL
+
+// UserRows returns rows of the user table, which the repository reads by `SELECT *`.
+func UserRows(users ...*User) *sqlmock.Rows {
+	rows := sqlmock.NewRows(userColumnNames)
+	for _, u := range users {
+		m := toUserModel(u)
+		rows.AddRow(m.ID, m.Name, driverValue(m.Age), driverValue(m.DeletedAt), driverValue(m.Email), m.Version, m.CreatedAt, m.UpdatedAt)
+	}
+	return rows
+}
+
+// ExpectGetUser expects Get of the user, which returns the user.
+func ExpectGetUser(mock sqlmock.Sqlmock, user *User) *sqlmock.ExpectedQuery {
+	return expectOneUser(mock, userByID(user.ID)).WillReturnRows(UserRows(user))
+}
+
+// ExpectGetUserNotFound expects Get of the id, which finds no user.
+func ExpectGetUserNotFound(mock sqlmock.Sqlmock, id string) *sqlmock.ExpectedQuery {
+	return expectOneUser(mock, userByID(id)).WillReturnRows(UserRows())
+}
+
+// ExpectGetUserByName expects GetByName of the user, which returns the user.
+func ExpectGetUserByName(mock sqlmock.Sqlmock, user *User) *sqlmock.ExpectedQuery {
+	return expectOneUser(mock, userByName(user.Name)).WillReturnRows(UserRows(user))
+}
+
+// ExpectGetUserByNameNotFound expects GetByName of the name, which finds no user.
+func ExpectGetUserByNameNotFound(mock sqlmock.Sqlmock, name string) *sqlmock.ExpectedQuery {
+	return expectOneUser(mock, userByName(name)).WillReturnRows(UserRows())
+}
+
+// ExpectListUsers expects List by the query (nil for all users), which returns users of the page and the total count.
+// It panics if the query is invalid, as List does not send queries then.
+func ExpectListUsers(mock sqlmock.Sqlmock, query *ListQuery, total int64, users ...*User) *sqlmock.ExpectedQuery {
+	filters, err := query.filters()
+	if err != nil {
+		panic(fmt.Sprintf("invalid list query: %s", err))
+	}
+
+	count := models.Users(filters...)
+	queries.SetSelect(count.Query, nil)
+	queries.SetCount(count.Query)
+	expectQuery(mock, count.Query).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(total))
+
+	// NOTE: filters are copied because append may write into their backing array
+	mods := append(append([]qm.QueryMod{}, filters...), query.pagination()...)
+	return expectQuery(mock, models.Users(mods...).Query).WillReturnRows(UserRows(users...))
+}
+
+// ExpectInsertUser expects Register of the user, which inserts one row.
+// Timestamps which the user does not have yet are expected to be set to about now.
+func ExpectInsertUser(mock sqlmock.Sqlmock, user *User) *sqlmock.ExpectedExec {
+	m := toUserModel(user)
+	query := fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES (%s)", models.TableNames.User,
+		strings.Join(userColumnNames, "`,`"), strings.TrimSuffix(strings.Repeat("?,", len(userColumnNames)), ","))
+	return mock.ExpectExec(regexp.QuoteMeta(query)).
+		WithArgs(m.ID, m.Name, m.Age, m.DeletedAt, m.Email, m.Version, timestampArg(m.CreatedAt), timestampArg(m.UpdatedAt)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func timestampArg(t time.Time) driver.Value {
+	if t.IsZero() {
+		return TimeArg(time.Now(), time.Minute)
+	}
+	return t
+}
+
+// ExpectSoftDeleteUser expects Delete of the user, which sets deleted_at to about now.
+func ExpectSoftDeleteUser(mock sqlmock.Sqlmock, user *User) *sqlmock.ExpectedExec {
+	query := fmt.Sprintf("UPDATE `%s` SET `%s`=? WHERE `%s`=?", models.TableNames.User, models.UserColumns.DeletedAt, models.UserColumns.ID)
+	return mock.ExpectExec(regexp.QuoteMeta(query)).
+		WithArgs(TimeArg(time.Now(), time.Minute), user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectOneUser(mock sqlmock.Sqlmock, mods ...qm.QueryMod) *sqlmock.ExpectedQuery {
+	q := models.Users(mods...)
+	queries.SetLimit(q.Query, 1)
+	return expectQuery(mock, q.Query)
+}
+
+func expectQuery(mock sqlmock.Sqlmock, q *queries.Query) *sqlmock.ExpectedQuery {
+	query, args := queries.BuildQuery(q)
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a
+	}
+	return mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(values...)
+}
+
+// driverValue returns the value of a nullable column as the driver returns it.
+func driverValue(v driver.Valuer) driver.Value {
+	value, err := v.Value()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// test using go-sqlmock
+func TestMockExpectations(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+	anonymous := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Anonymous"}
+
+	tests := []struct {
+		title       string
+		mock        func(sqlmock.Sqlmock)
+		run         func(context.Context, *userRepository) (interface{}, error)
+		expected    interface{}
+		expectedErr error
+	}{
+		{
+			"get a user",
+			func(mock sqlmock.Sqlmock) { ExpectGetUser(mock, mike) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return r.Get(ctx, mike.ID) },
+			mike,
+			nil,
+		},
+		{
+			"get a user without age and email",
+			func(mock sqlmock.Sqlmock) { ExpectGetUser(mock, anonymous) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return r.Get(ctx, anonymous.ID) },
+			anonymous,
+			nil,
+		},
+		{
+			"user is not found",
+			func(mock sqlmock.Sqlmock) { ExpectGetUserNotFound(mock, mike.ID) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return r.Get(ctx, mike.ID) },
+			nil,
+			sql.ErrNoRows,
+		},
+		{
+			"get a user by name",
+			func(mock sqlmock.Sqlmock) { ExpectGetUserByName(mock, mike) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return r.GetByName(ctx, mike.Name) },
+			mike,
+			nil,
+		},
+		{
+			"user of the name is not found",
+			func(mock sqlmock.Sqlmock) { ExpectGetUserByNameNotFound(mock, mike.Name) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return r.GetByName(ctx, mike.Name) },
+			nil,
+			sql.ErrNoRows,
+		},
+		{
+			"list users",
+			func(mock sqlmock.Sqlmock) { ExpectListUsers(mock, nil, 2, mike, anonymous) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) {
+				users, _, err := r.List(ctx, nil)
+				return users, err
+			},
+			[]*User{mike, anonymous},
+			nil,
+		},
+		{
+			"list users by all conditions",
+			func(mock sqlmock.Sqlmock) {
+				ExpectListUsers(mock, &ListQuery{Limit: 1, Offset: 1, NamePrefix: "M_", MinAge: 13, MaxAge: 30, Order: OrderByNameDesc}, 2, mike)
+			},
+			func(ctx context.Context, r *userRepository) (interface{}, error) {
+				users, total, err := r.List(ctx, &ListQuery{Limit: 1, Offset: 1, NamePrefix: "M_", MinAge: 13, MaxAge: 30, Order: OrderByNameDesc})
+				require.Equal(t, int64(2), total)
+				return users, err
+			},
+			[]*User{mike},
+			nil,
+		},
+		{
+			"register a user",
+			func(mock sqlmock.Sqlmock) { ExpectInsertUser(mock, mike) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return nil, r.Register(ctx, mike) },
+			nil,
+			nil,
+		},
+		{
+			"register a user without age and email",
+			func(mock sqlmock.Sqlmock) { ExpectInsertUser(mock, anonymous) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) {
+				return nil, r.Register(ctx, anonymous)
+			},
+			nil,
+			nil,
+		},
+		{
+			"delete a user",
+			func(mock sqlmock.Sqlmock) { ExpectSoftDeleteUser(mock, mike) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return nil, r.Delete(ctx, mike) },
+			nil,
+			nil,
+		},
+		{
+			"expectation can be overridden",
+			func(mock sqlmock.Sqlmock) { ExpectGetUser(mock, mike).WillReturnError(sql.ErrConnDone) },
+			func(ctx context.Context, r *userRepository) (interface{}, error) { return r.Get(ctx, mike.ID) },
+			nil,
+			sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			actual, err := tt.run(context.TODO(), NewUserRepository(db))
+
+			// assert
+			require.NoError(t, mock.ExpectationsWereMet())
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestExpectListUsersOfInvalidQuery(t *testing.T) {
+	_, mock := prepareMockDB(t)
+
+	require.PanicsWithValue(t, "invalid list query: min age must not exceed max age (min: 30, max: 13)", func() {
+		ExpectListUsers(mock, &ListQuery{MinAge: 30, MaxAge: 13}, 0)
+	})
+}
@@ -4,7 +4,21 @@
 package models
 
 var TableNames = struct {
-	User string
+	ArchiveCheckpoint string
+	AuditLog          string
+	Credential        string
+	IdempotencyKey    string
+	Order             string
+	User              string
+	UserArchive       string
+	UserTenant        string
 }{
-	User: "user",
+	ArchiveCheckpoint: "archive_checkpoint",
+	AuditLog:          "audit_log",
+	Credential:        "credential",
+	IdempotencyKey:    "idempotency_key",
+	Order:             "order",
+	User:              "user",
+	UserArchive:       "user_archive",
+	UserTenant:        "user_tenant",
 }
@@ -0,0 +1,243 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// mutationTelemetry counts rows inserted and deleted through sqlboiler models by a test.
+// NOTE: only writes of models call hooks, so raw SQL (e.g. RegisterAll) and upserts are not counted
+type mutationTelemetry struct {
+	mu sync.Mutex
+	// created and deleted are primary keys by tables
+	created map[string][]string
+	deleted map[string][]string
+}
+
+func newMutationTelemetry() *mutationTelemetry {
+	return &mutationTelemetry{
+		created: map[string][]string{},
+		deleted: map[string][]string{},
+	}
+}
+
+type mutationTelemetryKey struct{}
+
+// withMutationTelemetry returns ctx whose writes of models are recorded by m.
+func withMutationTelemetry(ctx context.Context, m *mutationTelemetry) context.Context {
+	return context.WithValue(ctx, mutationTelemetryKey{}, m)
+}
+
+func mutationTelemetryFrom(ctx context.Context) (*mutationTelemetry, bool) {
+	m, ok := ctx.Value(mutationTelemetryKey{}).(*mutationTelemetry)
+	return m, ok
+}
+
+func (m *mutationTelemetry) recordCreated(table, pk string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.created[table] = append(m.created[table], pk)
+}
+
+func (m *mutationTelemetry) recordDeleted(table, pk string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted[table] = append(m.deleted[table], pk)
+}
+
+// summary returns the numbers of rows created and deleted by tables, e.g. "user: 2 created, 1 deleted".
+func (m *mutationTelemetry) summary() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tables := map[string]struct{}{}
+	for table := range m.created {
+		tables[table] = struct{}{}
+	}
+	for table := range m.deleted {
+		tables[table] = struct{}{}
+	}
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, table := range names {
+		lines[i] = fmt.Sprintf("%s: %d created, %d deleted", table, len(m.created[table]), len(m.deleted[table]))
+	}
+	return strings.Join(lines, "; ")
+}
+
+// primary key columns of tables whose rows are tracked
+var mutationPrimaryKeys = map[string]string{
+	models.TableNames.User:              models.UserColumns.ID,
+	models.TableNames.Credential:        models.CredentialColumns.UserID,
+	models.TableNames.UserArchive:       models.UserArchiveColumns.ID,
+	models.TableNames.ArchiveCheckpoint: models.ArchiveCheckpointColumns.Job,
+	models.TableNames.IdempotencyKey:    models.IdempotencyKeyColumns.ID,
+	models.TableNames.Order:             models.OrderColumns.ID,
+}
+
+// leakedRows returns rows created by the test which still exist in db, e.g. "user(0123456789ABCDEFGHJKMNPQRS)".
+// NOTE: soft-deleted rows are leaked because they still exist
+func (m *mutationTelemetry) leakedRows(ctx context.Context, db *sql.DB) ([]string, error) {
+	m.mu.Lock()
+	created := map[string][]string{}
+	for table, pks := range m.created {
+		created[table] = append([]string(nil), pks...)
+	}
+	m.mu.Unlock()
+
+	tables := make([]string, 0, len(created))
+	for table := range created {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var leaked []string
+	for _, table := range tables {
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", quoteIdentifier(table), quoteIdentifier(mutationPrimaryKeys[table]))
+		for _, pk := range created[table] {
+			var n int
+			if err := db.QueryRowContext(ctx, query, pk).Scan(&n); err != nil {
+				return nil, fmt.Errorf("failed to check rows of %s: %w", table, err)
+			}
+			if n > 0 {
+				leaked = append(leaked, fmt.Sprintf("%s(%s)", table, pk))
+			}
+		}
+	}
+	return leaked, nil
+}
+
+// registerMutationHooks adds sqlboiler hooks which record writes to the telemetry in ctx.
+// NOTE: sqlboiler appends hooks to global slices without locks, so it must be called before tests start
+func registerMutationHooks() {
+	created := func(ctx context.Context, table, pk string) error {
+		if m, ok := mutationTelemetryFrom(ctx); ok {
+			m.recordCreated(table, pk)
+		}
+		return nil
+	}
+	deleted := func(ctx context.Context, table, pk string) error {
+		if m, ok := mutationTelemetryFrom(ctx); ok {
+			m.recordDeleted(table, pk)
+		}
+		return nil
+	}
+
+	models.AddUserHook(boil.AfterInsertHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.User) error {
+		return created(ctx, models.TableNames.User, o.ID)
+	})
+	models.AddUserHook(boil.AfterDeleteHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.User) error {
+		return deleted(ctx, models.TableNames.User, o.ID)
+	})
+	models.AddCredentialHook(boil.AfterInsertHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.Credential) error {
+		return created(ctx, models.TableNames.Credential, o.UserID)
+	})
+	models.AddCredentialHook(boil.AfterDeleteHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.Credential) error {
+		return deleted(ctx, models.TableNames.Credential, o.UserID)
+	})
+	models.AddUserArchiveHook(boil.AfterInsertHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.UserArchive) error {
+		return created(ctx, models.TableNames.UserArchive, o.ID)
+	})
+	models.AddUserArchiveHook(boil.AfterDeleteHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.UserArchive) error {
+		return deleted(ctx, models.TableNames.UserArchive, o.ID)
+	})
+	models.AddArchiveCheckpointHook(boil.AfterInsertHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.ArchiveCheckpoint) error {
+		return created(ctx, models.TableNames.ArchiveCheckpoint, o.Job)
+	})
+	models.AddArchiveCheckpointHook(boil.AfterDeleteHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.ArchiveCheckpoint) error {
+		return deleted(ctx, models.TableNames.ArchiveCheckpoint, o.Job)
+	})
+	models.AddIdempotencyKeyHook(boil.AfterInsertHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.IdempotencyKey) error {
+		return created(ctx, models.TableNames.IdempotencyKey, o.ID)
+	})
+	models.AddIdempotencyKeyHook(boil.AfterDeleteHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.IdempotencyKey) error {
+		return deleted(ctx, models.TableNames.IdempotencyKey, o.ID)
+	})
+	models.AddOrderHook(boil.AfterInsertHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.Order) error {
+		return created(ctx, models.TableNames.Order, o.ID)
+	})
+	models.AddOrderHook(boil.AfterDeleteHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.Order) error {
+		return deleted(ctx, models.TableNames.Order, o.ID)
+	})
+	models.AddUserTenantHook(boil.AfterInsertHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.UserTenant) error {
+		return created(ctx, models.TableNames.UserTenant, o.UserID)
+	})
+	models.AddUserTenantHook(boil.AfterDeleteHook, func(ctx context.Context, _ boil.ContextExecutor, o *models.UserTenant) error {
+		return deleted(ctx, models.TableNames.UserTenant, o.UserID)
+	})
+}
+
+// trackMutations returns ctx recording writes of the test, whose summary is logged when the test finishes.
+func trackMutations(ctx context.Context, t testing.TB) (context.Context, *mutationTelemetry) {
+	m := newMutationTelemetry()
+	t.Cleanup(func() {
+		if summary := m.summary(); summary != "" {
+			t.Logf("mutations: %s", summary)
+		}
+	})
+	return withMutationTelemetry(ctx, m), m
+}
+
+// assertNoLeaks fails the test if rows created by it still exist in db, e.g. in a database shared with other tests.
+// NOTE: defer it before the teardown deleting the rows so that it runs after the teardown
+func (m *mutationTelemetry) assertNoLeaks(t testing.TB, ctx context.Context, db *sql.DB) {
+	t.Helper()
+	leaked, err := m.leakedRows(ctx, db)
+	require.NoError(t, err)
+	require.Empty(t, leaked, "rows created by the test outlive it")
+}
+
+// test using go-mysql-server
+func TestMutationTelemetryWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	alice := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Alice", Age: lo.ToPtr(30)}
+	// NOTE: writes without the telemetry are not recorded
+	require.NoError(t, NewUserRepository(db).Register(context.Background(), alice))
+
+	// run
+	ctx, m := trackMutations(context.Background(), t)
+	r := NewUserRepository(db)
+	require.NoError(t, r.Register(ctx, mike))
+	require.NoError(t, r.Register(ctx, bob))
+	require.NoError(t, r.HardDelete(ctx, mike))
+	require.NoError(t, r.Delete(ctx, alice))
+
+	// assert
+	require.Equal(t, "user: 2 created, 2 deleted", m.summary())
+	leaked, err := m.leakedRows(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user(" + bob.ID + ")"}, leaked)
+
+	// soft-deleted rows still outlive the test
+	require.NoError(t, r.Delete(ctx, bob))
+	leaked, err = m.leakedRows(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user(" + bob.ID + ")"}, leaked)
+}
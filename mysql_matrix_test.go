@@ -0,0 +1,139 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// NOTE: set GOSQLTESTS_MYSQL_VERSIONS (comma separated, e.g. 5.7,8.0,8.4) to run the whole suite once per version of MySQL.
+// Versions are tags of the mysql image unless they name an image (e.g. mariadb:10.11), and override GOSQLTESTS_MYSQL_IMAGE.
+// Container tests of a version share one container, which is removed after the run of the version
+// unless GOSQLTESTS_REUSE_CONTAINERS=1.
+const mysqlVersionsEnv = "GOSQLTESTS_MYSQL_VERSIONS"
+
+// matrixImage is the image of the version which the suite is running on (empty unless the matrix runs).
+var matrixImage string
+
+// sharedContainers are containers shared by tests, by image. They are guarded by reusedContainerMu.
+var sharedContainers = map[string]testcontainers.Container{}
+
+// shareContainers reports whether container tests without options share one container of each image.
+func shareContainers() bool {
+	return reuseContainers() || matrixImage != "" || sharesDBBackendContainer()
+}
+
+// mysqlMatrixImages returns the images of GOSQLTESTS_MYSQL_VERSIONS (nil if it is not set).
+func mysqlMatrixImages() ([]string, error) {
+	env := os.Getenv(mysqlVersionsEnv)
+	if env == "" {
+		return nil, nil
+	}
+
+	images := []string{}
+	for _, v := range strings.Split(env, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("empty version in %s: %q", mysqlVersionsEnv, env)
+		}
+		if !strings.Contains(v, ":") {
+			v = "mysql:" + v
+		}
+		images = append(images, v)
+	}
+	return images, nil
+}
+
+// runMySQLMatrix runs the tests once on each image and returns the exit code, which fails if the tests fail on any image.
+func runMySQLMatrix(m *testing.M, images []string) int {
+	defer func() { matrixImage = "" }()
+
+	code := 0
+	failed := []string{}
+	for _, image := range images {
+		matrixImage = image
+		fmt.Printf("=== MYSQL %s\n", image)
+		if c := m.Run(); c != 0 {
+			code = c
+			failed = append(failed, image)
+		}
+
+		if err := removeSharedContainer(context.Background(), image); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if code == 0 {
+				code = 1
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("--- FAIL: MYSQL %s\n", strings.Join(failed, ", "))
+	}
+	return code
+}
+
+// removeSharedContainer terminates the container shared by tests of the image unless it is reused by later runs.
+func removeSharedContainer(ctx context.Context, image string) error {
+	reusedContainerMu.Lock()
+	defer reusedContainerMu.Unlock()
+
+	container, ok := sharedContainers[image]
+	if !ok || reuseContainers() {
+		return nil
+	}
+	delete(sharedContainers, image)
+	if err := container.Terminate(ctx); err != nil {
+		return fmt.Errorf("failed to terminate container of %s: %w", image, err)
+	}
+	return nil
+}
+
+func TestMySQLMatrixImages(t *testing.T) {
+	tests := []struct {
+		title       string
+		env         string
+		expected    []string
+		expectedErr string
+	}{
+		{"not set", "", nil, ""},
+		{"versions of mysql", "5.7,8.0,8.4", []string{"mysql:5.7", "mysql:8.0", "mysql:8.4"}, ""},
+		{"images", " 8.0 , mariadb:10.11", []string{"mysql:8.0", "mariadb:10.11"}, ""},
+		{"empty version", "5.7,,8.0", nil, `empty version in GOSQLTESTS_MYSQL_VERSIONS: "5.7,,8.0"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			t.Setenv(mysqlVersionsEnv, tt.env)
+
+			// run
+			actual, err := mysqlMatrixImages()
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestMySQLImageOfMatrix(t *testing.T) {
+	t.Setenv(mysqlImageEnv, "mariadb:10.11")
+	// NOTE: the test may run in the matrix itself
+	defer func(image string) { matrixImage = image }(matrixImage)
+
+	// run
+	matrixImage = "mysql:8.4"
+
+	// assert
+	require.Equal(t, "mysql:8.4", mysqlImage())
+	require.True(t, shareContainers())
+	require.Equal(t, "gosqltests-mysql-mysql-8.4", reusedContainerNameOf(mysqlImage()))
+}
@@ -0,0 +1,51 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maximum length of database names in MySQL
+const maxDatabaseNameLength = 64
+
+var invalidNamespaceChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// NamespacedDatabase returns the database name of the job identified by token, e.g. "practice_job_1234" for "job-1234".
+// Jobs sharing one MySQL server can run in parallel by connecting to their own databases (see ClientConfig.Database).
+// NOTE: tables are not prefixed because queries of models have fixed table names,
+// while the database is chosen by the connection, so no query has to be rewritten
+func NamespacedDatabase(base, token string) (string, error) {
+	suffix := strings.Trim(invalidNamespaceChars.ReplaceAllString(strings.ToLower(token), "_"), "_")
+	if suffix == "" {
+		return "", fmt.Errorf("namespace token must contain alphanumeric characters (token: %q)", token)
+	}
+
+	name := base + "_" + suffix
+	if len(name) > maxDatabaseNameLength {
+		return "", fmt.Errorf("database name must be at most %d characters (name: %s)", maxDatabaseNameLength, name)
+	}
+	return name, nil
+}
+
+// CreateDatabase creates the database if it does not exist.
+func CreateDatabase(ctx context.Context, db *sql.DB, name string) error {
+	if _, err := db.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS "+quoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+	return nil
+}
+
+// DropDatabase drops the database with all of its tables if it exists.
+func DropDatabase(ctx context.Context, db *sql.DB, name string) error {
+	if _, err := db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to drop database %s: %w", name, err)
+	}
+	return nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,124 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+func TestNamespacedDatabase(t *testing.T) {
+	tests := []struct {
+		title       string
+		token       string
+		expected    string
+		expectedErr string
+	}{
+		{
+			"alphanumeric token",
+			"1234",
+			"practice_1234",
+			"",
+		},
+		{
+			"invalid characters are replaced",
+			"Job-1234/retry.2",
+			"practice_job_1234_retry_2",
+			"",
+		},
+		{
+			"no alphanumeric characters",
+			"--",
+			"",
+			`namespace token must contain alphanumeric characters (token: "--")`,
+		},
+		{
+			"too long",
+			strings.Repeat("a", 56),
+			"",
+			"database name must be at most 64 characters (name: practice_" + strings.Repeat("a", 56) + ")",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual, err := NamespacedDatabase("practice", tt.token)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestCreateDatabaseWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta("CREATE DATABASE IF NOT EXISTS `practice_1234`")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DROP DATABASE IF EXISTS `practice_1234`")).
+		WillReturnError(errors.New("connection refused"))
+
+	// run
+	createErr := CreateDatabase(context.TODO(), db, "practice_1234")
+	dropErr := DropDatabase(context.TODO(), db, "practice_1234")
+
+	// assert
+	require.NoError(t, createErr)
+	require.EqualError(t, dropErr, "failed to drop database practice_1234: connection refused")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestNamespacedDatabaseWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+
+	// run
+	// NOTE: two jobs share the server
+	var repositories []UserRepository
+	for _, token := range []string{"job-1", "job-2"} {
+		database, err := NamespacedDatabase("practice", token)
+		require.NoError(t, err)
+		root, err := NewClientWithWait(ctx, &ClientConfig{Port: port})
+		require.NoError(t, err)
+		require.NoError(t, CreateDatabase(ctx, root, database))
+		root.Close()
+
+		db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, Database: database})
+		require.NoError(t, err)
+		defer db.Close()
+		require.NoError(t, Migrate(ctx, db))
+		repositories = append(repositories, NewUserRepository(db))
+	}
+	// the same user can be registered by both jobs
+	user := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	for _, r := range repositories {
+		require.NoError(t, r.Register(ctx, user))
+	}
+
+	// assert
+	for _, r := range repositories {
+		found, total, err := r.List(ctx, nil)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), total)
+		require.Equal(t, []*User{user}, found)
+	}
+}
@@ -0,0 +1,162 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrUnknownUser is returned when an order is placed by a user who does not exist.
+var ErrUnknownUser = errors.New("user of the order does not exist")
+
+// ErrUserHasOrders is returned when a user who has orders is hard-deleted.
+// NOTE: orders are kept for accounting, so the foreign key does not cascade
+var ErrUserHasOrders = errors.New("user has orders")
+
+// MySQL error numbers of foreign key violations
+const (
+	mysqlErrRowIsReferenced = 1451 // ER_ROW_IS_REFERENCED_2
+	mysqlErrNoReferencedRow = 1452 // ER_NO_REFERENCED_ROW_2
+)
+
+type Order struct {
+	ID     string
+	UserID string
+	Item   string
+	Amount int
+	// CreatedAt is truncated to seconds by the column.
+	CreatedAt time.Time
+}
+
+// UserWithOrders is a user with its orders in the order of creation.
+type UserWithOrders struct {
+	*User
+	Orders []*Order
+}
+
+type orderRepository struct {
+	db boil.ContextExecutor
+}
+
+func NewOrderRepository(db *sql.DB) *orderRepository {
+	return newOrderRepository(db)
+}
+
+func newOrderRepository(db boil.ContextExecutor) *orderRepository {
+	return &orderRepository{
+		db: db,
+	}
+}
+
+// Place inserts the order. It returns ErrUnknownUser if the user of the order does not exist.
+// NOTE: soft-deleted users can still place orders because their rows exist
+func (r *orderRepository) Place(ctx context.Context, order *Order) error {
+	if _, err := ParseUserID(order.UserID); err != nil {
+		return err
+	}
+
+	o := toOrderModel(order)
+	if err := o.Insert(ctx, r.db, boil.Infer()); err != nil {
+		return fmt.Errorf("failed to insert order (id: %s): %w", order.ID, wrapForeignKeyError(wrapStorageError(err), order.UserID))
+	}
+
+	return nil
+}
+
+// ListByUser returns the orders of the user in the order of creation.
+func (r *orderRepository) ListByUser(ctx context.Context, userID string) ([]*Order, error) {
+	orders, err := models.Orders(
+		models.OrderWhere.UserID.EQ(userID),
+		ordersByCreation(),
+	).All(ctx, r.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders (user_id: %s): %w", userID, err)
+	}
+
+	return fromOrderModels(orders), nil
+}
+
+// GetUserWithOrders returns the user with its orders, which are loaded eagerly by one more query.
+// Soft-deleted users are not found as Get.
+func (r *orderRepository) GetUserWithOrders(ctx context.Context, userID string) (*UserWithOrders, error) {
+	if _, err := ParseUserID(userID); err != nil {
+		return nil, err
+	}
+
+	user, err := models.Users(
+		userByID(userID),
+		qm.Load(models.UserRels.Orders, ordersByCreation()),
+	).One(ctx, r.db)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user was not found (id: %s): %w", userID, err)
+		}
+
+		return nil, fmt.Errorf("failed to get user with orders (id: %s): %w", userID, err)
+	}
+
+	return &UserWithOrders{
+		User:   fromUserModel(user),
+		Orders: fromOrderModels(user.R.GetOrders()),
+	}, nil
+}
+
+// ordersByCreation sorts orders by created_at, and by id among orders created in the same second.
+func ordersByCreation() qm.QueryMod {
+	return qm.OrderBy(fmt.Sprintf("%s, %s",
+		quotedColumn(models.TableNames.Order, models.OrderColumns.CreatedAt),
+		quotedColumn(models.TableNames.Order, models.OrderColumns.ID),
+	))
+}
+
+// wrapForeignKeyError converts the foreign key violation of an order of the user into ErrUnknownUser
+// and returns other errors as they are.
+func wrapForeignKeyError(err error, userID string) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrNoReferencedRow {
+		return err
+	}
+	return fmt.Errorf("%w (user_id: %s): %s", ErrUnknownUser, userID, err)
+}
+
+// wrapUserHasOrdersError converts the foreign key violation of deleting a user who has orders into ErrUserHasOrders
+// and returns other errors as they are.
+func wrapUserHasOrdersError(err error, userID string) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrRowIsReferenced {
+		return err
+	}
+	return fmt.Errorf("%w (id: %s): %s", ErrUserHasOrders, userID, err)
+}
+
+func toOrderModel(o *Order) *models.Order {
+	return &models.Order{
+		ID:        o.ID,
+		UserID:    o.UserID,
+		Item:      o.Item,
+		Amount:    o.Amount,
+		CreatedAt: o.CreatedAt.UTC().Truncate(time.Second),
+	}
+}
+
+func fromOrderModels(orders models.OrderSlice) []*Order {
+	res := make([]*Order, len(orders))
+	for i, o := range orders {
+		res[i] = &Order{
+			ID:        o.ID,
+			UserID:    o.UserID,
+			Item:      o.Item,
+			Amount:    o.Amount,
+			CreatedAt: o.CreatedAt,
+		}
+	}
+	return res
+}
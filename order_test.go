@@ -0,0 +1,230 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+var orderCreatedAt = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// assertOrders places orders of users and reads them with the users.
+func assertOrders(ctx context.Context, t *testing.T, db *sql.DB) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob"}
+	deleted := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)}
+	users := NewUserRepository(db)
+	for _, u := range []*User{mike, bob, deleted} {
+		require.NoError(t, users.Register(ctx, u))
+	}
+
+	// NOTE: orders are placed out of the order of creation
+	book := &Order{ID: "01GNNA1J000000000000000002", UserID: mike.ID, Item: "book", Amount: 1200, CreatedAt: orderCreatedAt.Add(time.Hour)}
+	pen := &Order{ID: "01GNNA1J000000000000000001", UserID: mike.ID, Item: "pen", Amount: 100, CreatedAt: orderCreatedAt}
+	cup := &Order{ID: "01GNNA1J000000000000000003", UserID: deleted.ID, Item: "cup", Amount: 500, CreatedAt: orderCreatedAt}
+	orders := NewOrderRepository(db)
+	for _, o := range []*Order{book, pen, cup} {
+		require.NoError(t, orders.Place(ctx, o))
+	}
+	require.NoError(t, users.Delete(ctx, deleted))
+
+	t.Run("list orders of a user", func(t *testing.T) {
+		actual, err := orders.ListByUser(ctx, mike.ID)
+
+		require.NoError(t, err)
+		require.Equal(t, []*Order{pen, book}, actual)
+	})
+
+	t.Run("get a user with orders", func(t *testing.T) {
+		actual, err := orders.GetUserWithOrders(ctx, mike.ID)
+
+		require.NoError(t, err)
+		require.Equal(t, &UserWithOrders{User: mike, Orders: []*Order{pen, book}}, actual)
+	})
+
+	t.Run("get a user without orders", func(t *testing.T) {
+		actual, err := orders.GetUserWithOrders(ctx, bob.ID)
+
+		require.NoError(t, err)
+		require.Equal(t, &UserWithOrders{User: bob, Orders: []*Order{}}, actual)
+	})
+
+	t.Run("soft-deleted user is not found", func(t *testing.T) {
+		_, err := orders.GetUserWithOrders(ctx, deleted.ID)
+
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("soft-deleted user still has orders", func(t *testing.T) {
+		actual, err := orders.ListByUser(ctx, deleted.ID)
+
+		require.NoError(t, err)
+		require.Equal(t, []*Order{cup}, actual)
+	})
+}
+
+// test using go-mysql-server
+func TestOrdersWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	// NOTE: go-mysql-server rejects every child row while foreign key checks are enabled (see newMigrationClient),
+	// so violations of the foreign key are tested by sqlmock and testcontainers instead
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	assertOrders(ctx, t, db)
+}
+
+// test using testcontainers
+func TestOrdersWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	assertOrders(ctx, t, db)
+}
+
+// test using testcontainers
+func TestOrderForeignKeyWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	users := NewUserRepository(db)
+	orders := NewOrderRepository(db)
+	require.NoError(t, users.Register(ctx, mike))
+	require.NoError(t, orders.Place(ctx, &Order{ID: "01GNNA1J000000000000000001", UserID: mike.ID, Item: "pen", Amount: 100, CreatedAt: orderCreatedAt}))
+
+	t.Run("order of an unknown user", func(t *testing.T) {
+		err := orders.Place(ctx, &Order{ID: "01GNNA1J000000000000000002", UserID: "1123456789ABCDEFGHJKMNPQRS", Item: "pen", Amount: 100, CreatedAt: orderCreatedAt})
+
+		require.ErrorIs(t, err, ErrUnknownUser)
+		require.ErrorContains(t, err, "(user_id: 1123456789ABCDEFGHJKMNPQRS)")
+	})
+
+	t.Run("hard-delete a user who has orders", func(t *testing.T) {
+		err := users.HardDelete(ctx, mike)
+
+		require.ErrorIs(t, err, ErrUserHasOrders)
+		_, err = users.Get(ctx, mike.ID)
+		require.NoError(t, err)
+	})
+
+	AssertReferentialIntegrity(t, db)
+}
+
+// test using go-sqlmock
+func TestOrderForeignKeyWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	pen := &Order{ID: "01GNNA1J000000000000000001", UserID: mike.ID, Item: "pen", Amount: 100, CreatedAt: orderCreatedAt}
+	insertOrder := regexp.QuoteMeta("INSERT INTO `order` (`id`,`user_id`,`item`,`amount`,`created_at`) VALUES (?,?,?,?,?)")
+	deleteUser := regexp.QuoteMeta("DELETE FROM `user` WHERE `id`=?")
+
+	tests := []struct {
+		title       string
+		mock        func(sqlmock.Sqlmock)
+		run         func(context.Context, *sql.DB) error
+		expectedErr error
+		expectedMsg string
+	}{
+		{
+			"place an order",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insertOrder).
+					WithArgs(pen.ID, pen.UserID, pen.Item, pen.Amount, pen.CreatedAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			func(ctx context.Context, db *sql.DB) error { return NewOrderRepository(db).Place(ctx, pen) },
+			nil,
+			"",
+		},
+		{
+			"order of an unknown user",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insertOrder).
+					WillReturnError(&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails"})
+			},
+			func(ctx context.Context, db *sql.DB) error { return NewOrderRepository(db).Place(ctx, pen) },
+			ErrUnknownUser,
+			"failed to insert order (id: 01GNNA1J000000000000000001): user of the order does not exist (user_id: 0123456789ABCDEFGHJKMNPQRS): " +
+				"models: unable to insert into order: Error 1452: Cannot add or update a child row: a foreign key constraint fails",
+		},
+		{
+			"hard-delete a user who has orders",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(deleteUser).
+					WithArgs(mike.ID).
+					WillReturnError(&mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: a foreign key constraint fails"})
+			},
+			func(ctx context.Context, db *sql.DB) error { return NewUserRepository(db).HardDelete(ctx, mike) },
+			ErrUserHasOrders,
+			"failed to hard delete user: user has orders (id: 0123456789ABCDEFGHJKMNPQRS): " +
+				"models: unable to delete from user: Error 1451: Cannot delete or update a parent row: a foreign key constraint fails",
+		},
+		{
+			"other errors are returned as they are",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insertOrder).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '01GNNA1J000000000000000001' for key 'order.PRIMARY'"})
+			},
+			func(ctx context.Context, db *sql.DB) error { return NewOrderRepository(db).Place(ctx, pen) },
+			nil,
+			"failed to insert order (id: 01GNNA1J000000000000000001): " +
+				"models: unable to insert into order: Error 1062: Duplicate entry '01GNNA1J000000000000000001' for key 'order.PRIMARY'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			err := tt.run(context.TODO(), db)
+
+			// assert
+			require.NoError(t, mock.ExpectationsWereMet())
+			if tt.expectedMsg == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedMsg)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestGetUserWithOrdersLoadsOrdersEagerlyWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// mock
+	db, mock := prepareMockDB(t)
+	ExpectGetUser(mock, mike)
+	// NOTE: orders of all users found are read by one query instead of a query per user
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `order` WHERE (`order`.`user_id` IN (?)) ORDER BY `order`.`created_at`, `order`.`id`;")).
+		WithArgs(mike.ID).
+		WillReturnRows(sqlmock.NewRows(orderColumnNames).AddRow("01GNNA1J000000000000000001", mike.ID, "pen", 100, orderCreatedAt))
+
+	// run
+	actual, err := NewOrderRepository(db).GetUserWithOrders(context.TODO(), mike.ID)
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, &UserWithOrders{
+		User:   mike,
+		Orders: []*Order{{ID: "01GNNA1J000000000000000001", UserID: mike.ID, Item: "pen", Amount: 100, CreatedAt: orderCreatedAt}},
+	}, actual)
+}
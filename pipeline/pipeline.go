@@ -0,0 +1,112 @@
+// Package pipeline defines tiers of the testing strategy, which CI jobs select by GOSQLTESTS_PROFILE.
+// Each tier adds slower but more realistic tests to the previous one:
+//
+//   - fast: sqlmock and go-mysql-server, for every push
+//   - full: + testcontainers for each MySQL version, for pull requests
+//   - nightly: + compatibility fuzzing between backends, for scheduled jobs
+//
+// Adopters can register their own profiles by Register.
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ProfileEnv is the environment variable to select a profile (fast by default).
+const ProfileEnv = "GOSQLTESTS_PROFILE"
+
+// names of the built-in profiles
+const (
+	Fast    = "fast"
+	Full    = "full"
+	Nightly = "nightly"
+)
+
+// Profile is a tier of tests run by a CI job.
+type Profile struct {
+	Name string
+	// Backends are names of DBTestBackends which the shared repository tests run on, e.g. "sqlmock".
+	Backends []string
+	// MySQLImages are images of the testcontainers backend. Tests run on each of them.
+	// Empty means the default image.
+	MySQLImages []string
+	// CompatibilityFuzzRuns is the number of random queries compared between backends. 0 disables fuzzing.
+	CompatibilityFuzzRuns int
+}
+
+var profiles = struct {
+	mu       sync.RWMutex
+	profiles map[string]*Profile
+}{
+	profiles: map[string]*Profile{
+		Fast: {
+			Name:     Fast,
+			Backends: []string{"sqlmock", "gomysqlserver"},
+		},
+		Full: {
+			Name:        Full,
+			Backends:    []string{"sqlmock", "gomysqlserver", "testcontainers"},
+			MySQLImages: []string{"mysql:5.7", "mysql:8"},
+		},
+		Nightly: {
+			Name:                  Nightly,
+			Backends:              []string{"sqlmock", "gomysqlserver", "testcontainers"},
+			MySQLImages:           []string{"mysql:5.7", "mysql:8", "mariadb:10.11"},
+			CompatibilityFuzzRuns: 1000,
+		},
+	},
+}
+
+// Register registers the profile. A profile of the same name is replaced.
+func Register(p *Profile) {
+	profiles.mu.Lock()
+	defer profiles.mu.Unlock()
+	profiles.profiles[p.Name] = copyProfile(p)
+}
+
+// Lookup returns a copy of the profile registered by the name.
+func Lookup(name string) (*Profile, error) {
+	profiles.mu.RLock()
+	defer profiles.mu.RUnlock()
+
+	p, ok := profiles.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (registered: %v)", name, names())
+	}
+	return copyProfile(p), nil
+}
+
+// FromEnv returns the profile selected by GOSQLTESTS_PROFILE.
+func FromEnv() (*Profile, error) {
+	name := os.Getenv(ProfileEnv)
+	if name == "" {
+		name = Fast
+	}
+
+	p, err := Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ProfileEnv, err)
+	}
+	return p, nil
+}
+
+// names returns the sorted names of registered profiles. The caller must hold the lock.
+func names() []string {
+	names := make([]string, 0, len(profiles.profiles))
+	for name := range profiles.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NOTE: profiles are copied so that callers cannot modify registered ones
+func copyProfile(p *Profile) *Profile {
+	c := *p
+	c.Backends = append([]string(nil), p.Backends...)
+	c.MySQLImages = append([]string(nil), p.MySQLImages...)
+	return &c
+}
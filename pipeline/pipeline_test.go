@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		title       string
+		env         string
+		expected    string
+		expectedErr string
+	}{
+		{"fast by default", "", Fast, ""},
+		{"full", "full", Full, ""},
+		{"nightly", "nightly", Nightly, ""},
+		{"unknown profile", "weekly", "", `invalid GOSQLTESTS_PROFILE: unknown profile "weekly" (registered: [fast full nightly])`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			t.Setenv(ProfileEnv, tt.env)
+
+			// run
+			actual, err := FromEnv()
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual.Name)
+		})
+	}
+}
+
+func TestProfilesAreTiered(t *testing.T) {
+	fast, err := Lookup(Fast)
+	require.NoError(t, err)
+	full, err := Lookup(Full)
+	require.NoError(t, err)
+	nightly, err := Lookup(Nightly)
+	require.NoError(t, err)
+
+	// each tier runs everything of the previous one
+	require.Subset(t, full.Backends, fast.Backends)
+	require.Subset(t, nightly.Backends, full.Backends)
+	require.Subset(t, nightly.MySQLImages, full.MySQLImages)
+	require.Zero(t, full.CompatibilityFuzzRuns)
+	require.Positive(t, nightly.CompatibilityFuzzRuns)
+}
+
+func TestRegister(t *testing.T) {
+	p := &Profile{Name: "custom", Backends: []string{"gomysqlserver"}}
+	defer func() {
+		profiles.mu.Lock()
+		delete(profiles.profiles, p.Name)
+		profiles.mu.Unlock()
+	}()
+
+	// run
+	Register(p)
+	p.Backends[0] = "sqlmock"
+
+	// assert
+	actual, err := Lookup("custom")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gomysqlserver"}, actual.Backends)
+
+	// returned profiles are copies
+	actual.Backends[0] = "sqlmock"
+	actual, err = Lookup("custom")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gomysqlserver"}, actual.Backends)
+}
@@ -0,0 +1,144 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-sqlmock
+func TestTooManyConnectionsWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS").
+		WillReturnError(&mysql.MySQLError{Number: 1040, Message: "Too many connections"})
+
+	// run
+	r := NewUserRepository(db)
+	_, err := r.Get(context.TODO(), "0123456789ABCDEFGHJKMNPQRS")
+
+	// assert
+	require.True(t, IsTooManyConnections(err))
+	require.True(t, isNotSent(err))
+}
+
+// test using go-mysql-server
+func TestPoolQueueingWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	table := prepareSimulator(t, port)
+	_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow("0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20), nil, nil, int64(1), seededAt, seededAt))
+
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, MaxOpenConns: 2})
+	require.NoError(t, err)
+	r := NewUserRepository(db)
+
+	// hold all connections of the pool
+	var conns []*sql.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		conns = append(conns, conn)
+	}
+
+	// run
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.Get(ctx, "0123456789ABCDEFGHJKMNPQRS")
+			errs <- err
+		}()
+	}
+
+	// assert
+	// callers queue instead of opening more connections
+	require.Eventually(t, func() bool { return db.Stats().WaitCount == 10 }, time.Second, time.Millisecond)
+	require.Equal(t, 2, db.Stats().OpenConnections)
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.LessOrEqual(t, db.Stats().OpenConnections, 2)
+	requireNoLeakedConnections(t, db)
+}
+
+// test using testcontainers
+func TestMaxConnectionsWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t, withMaxConnections(5))
+	require.NoError(t, NewUserRepository(db).Register(ctx, &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}))
+
+	t.Run("pool within max_connections queues callers", func(t *testing.T) {
+		db.SetMaxOpenConns(4)
+		r := NewUserRepository(db)
+
+		// run
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := r.Get(ctx, "0123456789ABCDEFGHJKMNPQRS")
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		// assert
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		require.LessOrEqual(t, db.Stats().OpenConnections, 4)
+	})
+
+	t.Run("pool beyond max_connections fails with classified error", func(t *testing.T) {
+		db.SetMaxOpenConns(0)
+
+		// run
+		var err error
+		var conns []*sql.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for i := 0; i < 20 && err == nil; i++ {
+			var conn *sql.Conn
+			conn, err = db.Conn(ctx)
+			if err == nil {
+				err = conn.PingContext(ctx)
+				conns = append(conns, conn)
+			}
+		}
+
+		// assert
+		require.True(t, IsTooManyConnections(err), fmt.Sprintf("unexpected error: %v", err))
+		// NOTE: the extra connection for root is included
+		require.LessOrEqual(t, len(conns), 6)
+	})
+}
@@ -0,0 +1,40 @@
+-- name: GetUser :one
+SELECT id, name, age, deleted_at, email, version, created_at, updated_at FROM user
+WHERE id = ? AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: GetUserByName :one
+SELECT id, name, age, deleted_at, email, version, created_at, updated_at FROM user
+WHERE name = ? AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: CreateUser :exec
+INSERT INTO user (id, name, age, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: SoftDeleteUser :exec
+UPDATE user SET deleted_at = ? WHERE id = ?;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM user
+WHERE deleted_at IS NULL
+  AND name LIKE sqlc.arg(name_pattern)
+  AND (sqlc.arg(min_age) = 0 OR age >= sqlc.arg(min_age))
+  AND (sqlc.arg(max_age) = 0 OR age <= sqlc.arg(max_age));
+
+-- name: ListUsers :many
+-- NOTE: sqlc cannot build queries dynamically, so conditions which are not specified are disabled by their arguments
+-- (0 for ages and an empty string for the cursor), and the order is chosen by CASE.
+SELECT id, name, age, deleted_at, email, version, created_at, updated_at FROM user
+WHERE deleted_at IS NULL
+  AND name LIKE sqlc.arg(name_pattern)
+  AND (sqlc.arg(min_age) = 0 OR age >= sqlc.arg(min_age))
+  AND (sqlc.arg(max_age) = 0 OR age <= sqlc.arg(max_age))
+  AND (sqlc.arg(after_id) = '' OR (sqlc.arg(id_desc) AND id < sqlc.arg(after_id)) OR (NOT sqlc.arg(id_desc) AND id > sqlc.arg(after_id)))
+ORDER BY
+  CASE WHEN sqlc.arg(sort) = 'name_asc' THEN name END ASC,
+  CASE WHEN sqlc.arg(sort) = 'name_desc' THEN name END DESC,
+  CASE WHEN sqlc.arg(sort) = 'age_asc' THEN age END ASC,
+  CASE WHEN sqlc.arg(sort) = 'age_desc' THEN age END DESC,
+  CASE WHEN sqlc.arg(id_desc) THEN id END DESC,
+  id ASC
+LIMIT ? OFFSET ?;
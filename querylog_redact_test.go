@@ -0,0 +1,217 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// value shown instead of arguments of sensitive columns
+const redactedValue = "[REDACTED]"
+
+var (
+	// column list of INSERT (or REPLACE), whose placeholders in VALUES are bound to the columns in order
+	insertColumnsPattern = regexp.MustCompile("(?is)^\\s*(?:INSERT|REPLACE)\\s+INTO\\s+\\S+\\s*\\(([^)]*)\\)\\s*VALUES")
+	// end of VALUES of INSERT
+	onDuplicateKeyPattern = regexp.MustCompile("(?i)ON\\s+DUPLICATE\\s+KEY\\s+UPDATE")
+	// column compared with (or set to) the placeholder at the end, such as "`user`.`id` = ?" or "`id` IN (?,?"
+	boundColumnPattern = regexp.MustCompile("(?i)`(\\w+)`\\s*(?:=|<>|!=|<=|>=|<|>|LIKE|IN\\s*\\((?:\\s*\\?\\s*,)*)\\s*$")
+)
+
+// statements returns the logged statements with their arguments, masking the arguments of sensitiveColumns.
+func (l *queryLog) statements() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return lo.Map(l.queries, func(q *loggedQuery, _ int) string {
+		return l.format(q)
+	})
+}
+
+// format returns the statement with its arguments such as `SELECT ... WHERE (`user`.`id` = ?) ["0123..."]`.
+// NOTE: arguments whose columns are unknown are masked as well if any column is sensitive, so that nothing leaks by mistake
+func (l *queryLog) format(q *loggedQuery) string {
+	if len(q.args) == 0 {
+		return q.query
+	}
+
+	columns := boundColumns(q.query)
+	args := lo.Map(q.args, func(arg driver.NamedValue, i int) string {
+		if len(l.sensitiveColumns) > 0 && (i >= len(columns) || columns[i] == "" || lo.Contains(l.sensitiveColumns, columns[i])) {
+			return redactedValue
+		}
+		return formatArg(arg.Value)
+	})
+	return fmt.Sprintf("%s [%s]", q.query, strings.Join(args, ", "))
+}
+
+func formatArg(v driver.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []byte:
+		return fmt.Sprintf("%q", v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// boundColumns returns the columns which the placeholders of the query are bound to in order ("" if unknown).
+// It understands statements built by sqlboiler: column lists of INSERT and comparisons and assignments of columns.
+func boundColumns(query string) []string {
+	var insertColumns []string
+	valuesStart, valuesEnd := -1, len(query)
+	if m := insertColumnsPattern.FindStringSubmatchIndex(query); m != nil {
+		insertColumns = lo.Map(strings.Split(query[m[2]:m[3]], ","), func(c string, _ int) string {
+			return strings.Trim(c, "` \n\t")
+		})
+		valuesStart = m[1]
+		if loc := onDuplicateKeyPattern.FindStringIndex(query); loc != nil {
+			valuesEnd = loc[0]
+		}
+	}
+
+	var columns []string
+	for i, c := range query {
+		if c != '?' {
+			continue
+		}
+		if insertColumns != nil && valuesStart <= i && i < valuesEnd {
+			columns = append(columns, insertColumns[len(columns)%len(insertColumns)])
+			continue
+		}
+		if m := boundColumnPattern.FindStringSubmatch(query[:i]); m != nil {
+			columns = append(columns, m[1])
+			continue
+		}
+		columns = append(columns, "")
+	}
+	return columns
+}
+
+func TestBoundColumns(t *testing.T) {
+	tests := []struct {
+		title    string
+		query    string
+		expected []string
+	}{
+		{
+			"insert",
+			"INSERT INTO `user` (`id`,`name`,`email`) VALUES (?,?,?)",
+			[]string{"id", "name", "email"},
+		},
+		{
+			"bulk insert",
+			"INSERT INTO `user` (`id`,`name`) VALUES (?,?),(?,?)",
+			[]string{"id", "name", "id", "name"},
+		},
+		{
+			"upsert",
+			"INSERT INTO `user` (`id`,`name`) VALUES (?,?) ON DUPLICATE KEY UPDATE `name` = ?",
+			[]string{"id", "name", "name"},
+		},
+		{
+			"select",
+			"SELECT `user`.* FROM `user` WHERE (`user`.`email` = ?) AND (`user`.`deleted_at` is null) LIMIT 1",
+			[]string{"email"},
+		},
+		{
+			"update",
+			"UPDATE `user` SET `name` = ?, `age`=? WHERE (`user`.`id` = ?)",
+			[]string{"name", "age", "id"},
+		},
+		{
+			"in",
+			"SELECT `user`.* FROM `user` WHERE (`user`.`id` IN (?,?, ?)) AND (`user`.`name` LIKE ?)",
+			[]string{"id", "id", "id", "name"},
+		},
+		{
+			"unknown column",
+			"SELECT GET_LOCK(?, ?)",
+			[]string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual := boundColumns(tt.query)
+
+			// assert
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestQueryLogRedactionWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+
+	// simulator
+	prepareSimulator(t, port)
+
+	tests := []struct {
+		title     string
+		sensitive []string
+		expected  []string
+	}{
+		{
+			"no sensitive columns",
+			nil,
+			[]string{
+				"INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?) " +
+					`["0123456789ABCDEFGHJKMNPQRS", "Mike", 20, NULL, "mike@example.com", 1, 2022-11-01T00:00:00Z, 2022-11-01T00:00:00Z]`,
+				"SELECT `user`.* FROM `user` WHERE (`user`.`email` = ?) AND (`user`.`deleted_at` is null) LIMIT 1; " +
+					`["mike@example.com"]`,
+			},
+		},
+		{
+			"email and name are sensitive",
+			[]string{models.UserColumns.Email, models.UserColumns.Name},
+			[]string{
+				"INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?) " +
+					`["0123456789ABCDEFGHJKMNPQRS", [REDACTED], 20, NULL, [REDACTED], 1, 2022-11-01T00:00:00Z, 2022-11-01T00:00:00Z]`,
+				"SELECT `user`.* FROM `user` WHERE (`user`.`email` = ?) AND (`user`.`deleted_at` is null) LIMIT 1; " +
+					`[[REDACTED]]`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			l := &queryLog{sensitiveColumns: tt.sensitive}
+			db, err := newQueryLoggedClient(port, l)
+			require.NoError(t, err)
+			defer closeTestClient(t, db)
+			r := NewUserRepository(db, WithClock(newFakeClock()))
+
+			// run
+			require.NoError(t, r.Register(ctx, &User{ID: mike.ID, Name: mike.Name, Age: mike.Age, Email: mike.Email}))
+			_, err = r.GetByEmail(ctx, mike.Email)
+			require.NoError(t, err)
+			require.NoError(t, r.HardDelete(ctx, mike))
+
+			// assert
+			actual := l.statements()
+			require.Equal(t, tt.expected, actual[:2])
+			// NOTE: ids remain visible
+			require.Contains(t, actual[2], `["0123456789ABCDEFGHJKMNPQRS"]`)
+		})
+	}
+}
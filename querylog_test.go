@@ -0,0 +1,161 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// queryLog records statements sent by a client of withQueryLog, e.g. to assert how many times a repository hits the database.
+type queryLog struct {
+	mu      sync.Mutex
+	queries []*loggedQuery
+	// sensitiveColumns are columns (e.g. email) whose bound values are masked by statements
+	sensitiveColumns []string
+}
+
+// loggedQuery is a statement with its arguments and how long the server took until it responded.
+// NOTE: the duration of a query does not include reading its rows
+type loggedQuery struct {
+	query    string
+	args     []driver.NamedValue
+	duration time.Duration
+}
+
+func (l *queryLog) record(query string, args []driver.NamedValue, duration time.Duration, err error) {
+	// NOTE: driver.ErrSkip means database/sql sends the statement again in another way (e.g. prepared statement)
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries = append(l.queries, &loggedQuery{query: query, args: args, duration: duration})
+}
+
+// count returns the number of statements starting with prefix, e.g. "SELECT".
+func (l *queryLog) count(prefix string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, q := range l.queries {
+		if strings.HasPrefix(q.query, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (l *queryLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queries = nil
+}
+
+// newQueryLoggedClient returns a client which records statements in l.
+// NOTE: foreign key checks are disabled as newMigrationClient does, so that it also works on prepareMigratedSimulator
+func newQueryLoggedClient(port int, l *queryLog) (*sql.DB, error) {
+	cfg := (&ClientConfig{Port: port}).mysqlConfig()
+	cfg.Params = map[string]string{"foreign_key_checks": "0"}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(withQueryLog(l)(connector)), nil
+}
+
+func withQueryLog(l *queryLog) connectorWrapper {
+	return func(c driver.Connector) driver.Connector {
+		return &queryLogConnector{Connector: c, log: l}
+	}
+}
+
+type queryLogConnector struct {
+	driver.Connector
+	log *queryLog
+}
+
+func (c *queryLogConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &queryLogConn{Conn: conn, log: c.log}, nil
+}
+
+// queryLogConn wraps a connection of the MySQL driver, which implements every optional interface used below.
+type queryLogConn struct {
+	driver.Conn
+	log *queryLog
+}
+
+func (c *queryLogConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	res, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	c.log.record(query, args, time.Since(start), err)
+	return res, err
+}
+
+func (c *queryLogConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	c.log.record(query, args, time.Since(start), err)
+	return rows, err
+}
+
+func (c *queryLogConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &queryLogStmt{Stmt: stmt, query: query, log: c.log}, nil
+}
+
+func (c *queryLogConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *queryLogConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *queryLogConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *queryLogConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+func (c *queryLogConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.Conn.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+type queryLogStmt struct {
+	driver.Stmt
+	query string
+	log   *queryLog
+}
+
+func (s *queryLogStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	res, err := s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+	s.log.record(s.query, args, time.Since(start), err)
+	return res, err
+}
+
+func (s *queryLogStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	s.log.record(s.query, args, time.Since(start), err)
+	return rows, err
+}
+
+func (s *queryLogStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.Stmt.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
@@ -0,0 +1,70 @@
+package gosqltests
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// NOTE: set GOSQLTESTS_SEED to reproduce random data of a failed test, which is logged when the test fails.
+const seedEnv = "GOSQLTESTS_SEED"
+
+// testSeed returns the seed of random data in the test, which is derived from the test name unless GOSQLTESTS_SEED is set.
+func testSeed(t testing.TB) int64 {
+	t.Helper()
+
+	var value int64
+	if env := os.Getenv(seedEnv); env != "" {
+		v, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid %s: %s", seedEnv, err)
+		}
+		value = v
+	} else {
+		h := fnv.New64a()
+		h.Write([]byte(t.Name()))
+		value = int64(h.Sum64())
+	}
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("random data was generated by %s=%d", seedEnv, value)
+		}
+	})
+	return value
+}
+
+// testRand returns a random source of the test. See testSeed for its seed.
+func testRand(t testing.TB) *rand.Rand {
+	t.Helper()
+	return rand.New(rand.NewSource(testSeed(t)))
+}
+
+func TestTestSeed(t *testing.T) {
+	t.Run("derived from the test name", func(t *testing.T) {
+		t.Setenv(seedEnv, "")
+
+		// run
+		seed := testSeed(t)
+
+		// assert
+		require.Equal(t, seed, testSeed(t))
+		t.Run("another test", func(t *testing.T) {
+			require.NotEqual(t, seed, testSeed(t))
+		})
+	})
+
+	t.Run("overridden by env", func(t *testing.T) {
+		t.Setenv(seedEnv, "-42")
+
+		// run
+		seed := testSeed(t)
+
+		// assert
+		require.Equal(t, int64(-42), seed)
+	})
+}
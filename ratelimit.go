@@ -0,0 +1,119 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// tokenBucket refills rate tokens per second up to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, clock Clock) *tokenBucket {
+	return &tokenBucket{
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before using it.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a token which was reserved but not used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens++
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	d := b.reserve()
+	if d == 0 {
+		return nil
+	}
+
+	select {
+	case <-b.clock.After(d):
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return fmt.Errorf("rate limit wait was cancelled: %w", ctx.Err())
+	}
+}
+
+// rateLimitedUserRepository limits mutations to protect shared databases. Reads are not limited.
+type rateLimitedUserRepository struct {
+	UserRepository
+	limiter *tokenBucket
+}
+
+// NewRateLimitedUserRepository allows rate mutations per second with bursts up to burst.
+func NewRateLimitedUserRepository(repo UserRepository, rate float64, burst int) *rateLimitedUserRepository {
+	return newRateLimitedUserRepository(repo, rate, burst, systemClock{})
+}
+
+func newRateLimitedUserRepository(repo UserRepository, rate float64, burst int, clock Clock) *rateLimitedUserRepository {
+	return &rateLimitedUserRepository{
+		UserRepository: repo,
+		limiter:        newTokenBucket(rate, burst, clock),
+	}
+}
+
+func (r *rateLimitedUserRepository) Register(ctx context.Context, user *User) error {
+	if err := r.limiter.wait(ctx); err != nil {
+		return err
+	}
+	return r.UserRepository.Register(ctx, user)
+}
+
+func (r *rateLimitedUserRepository) Delete(ctx context.Context, user *User) error {
+	if err := r.limiter.wait(ctx); err != nil {
+		return err
+	}
+	return r.UserRepository.Delete(ctx, user)
+}
@@ -0,0 +1,162 @@
+package gosqltests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances time by the waited duration instantly, so that tests never sleep.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+	// block makes After never fire
+	block bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+
+	ch := make(chan time.Time, 1)
+	if !c.block {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestRateLimitedRegister(t *testing.T) {
+	tests := []struct {
+		title         string
+		rate          float64
+		burst         int
+		calls         int
+		interval      time.Duration
+		expectedWaits []time.Duration
+	}{
+		{
+			"calls within burst are not limited",
+			1,
+			3,
+			3,
+			0,
+			nil,
+		},
+		{
+			"calls beyond burst wait for refill",
+			2,
+			2,
+			4,
+			0,
+			[]time.Duration{500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			"tokens are refilled while idle",
+			1,
+			1,
+			3,
+			time.Second,
+			nil,
+		},
+		{
+			"partially refilled tokens shorten the wait",
+			1,
+			1,
+			3,
+			250 * time.Millisecond,
+			[]time.Duration{750 * time.Millisecond, 750 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			repo := &mockUserRepository{}
+			clock := newFakeClock()
+
+			// run
+			r := newRateLimitedUserRepository(repo, tt.rate, tt.burst, clock)
+			for i := 0; i < tt.calls; i++ {
+				if i > 0 {
+					clock.Advance(tt.interval)
+				}
+				err := r.Register(context.TODO(), &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)})
+				require.NoError(t, err)
+			}
+
+			// assert
+			require.Len(t, repo.registered, tt.calls)
+			require.Equal(t, tt.expectedWaits, clock.waits)
+		})
+	}
+}
+
+func TestRateLimitedReadsAreNotLimited(t *testing.T) {
+	// mock
+	repo := &mockUserRepository{
+		users: map[string]*User{
+			"Mike": {ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		},
+	}
+	clock := newFakeClock()
+
+	// run
+	r := newRateLimitedUserRepository(repo, 1, 1, clock)
+	for i := 0; i < 10; i++ {
+		_, err := r.Get(context.TODO(), "0123456789ABCDEFGHJKMNPQRS")
+		require.NoError(t, err)
+	}
+
+	// assert
+	require.Empty(t, clock.waits)
+}
+
+func TestRateLimitedCancelled(t *testing.T) {
+	// mock
+	repo := &mockUserRepository{}
+	clock := newFakeClock()
+	clock.block = true
+
+	r := newRateLimitedUserRepository(repo, 1, 1, clock)
+	user := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, r.Delete(context.TODO(), user))
+
+	// run
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	err := r.Register(ctx, user)
+
+	// assert
+	require.EqualError(t, err, "rate limit wait was cancelled: context canceled")
+	require.Empty(t, repo.registered)
+	require.Equal(t, []time.Duration{time.Second}, clock.waits)
+
+	// NOTE: the cancelled reservation is given back, so the next call does not wait any more
+	clock.Advance(time.Second)
+	clock.block = false
+	require.NoError(t, r.Register(context.TODO(), user))
+	require.Equal(t, []time.Duration{time.Second}, clock.waits)
+}
@@ -0,0 +1,232 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	testcontainers "github.com/testcontainers/testcontainers-go"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// withReplication enables the binary log with GTIDs, which both the source and the replica require.
+// serverID must be unique among the servers of the replication.
+func withReplication(serverID int) containerOption {
+	return func(req *testcontainers.ContainerRequest) {
+		req.Cmd = append(req.Cmd,
+			fmt.Sprintf("--server-id=%d", serverID),
+			"--log-bin=mysql-bin",
+			"--gtid-mode=ON",
+			"--enforce-gtid-consistency=ON",
+		)
+	}
+}
+
+// prepareLaggingReplica starts the migrated primary and its replica, which applies each transaction
+// of the primary lag after it is committed, and returns their clients.
+func prepareLaggingReplica(ctx context.Context, t *testing.T, lag time.Duration) (*sql.DB, *sql.DB) {
+	// NOTE: MariaDB has neither the options of GTIDs nor CHANGE REPLICATION SOURCE TO
+	if !strings.HasPrefix(mysqlImage(), "mysql:") {
+		t.Skipf("%s does not support replication of the harness", mysqlImage())
+	}
+
+	network := prepareNetwork(ctx, t)
+	primaryPort := startContainer(ctx, t, withNetwork(network, "primary"), withReplication(1))
+	replicaPort := startContainer(ctx, t, withNetwork(network, "replica"), withReplication(2))
+
+	primary, err := NewStrictClient(primaryPort)
+	require.NoError(t, err)
+	t.Cleanup(func() { closeTestClient(t, primary) })
+	replica, err := NewStrictClient(replicaPort)
+	require.NoError(t, err)
+	t.Cleanup(func() { closeTestClient(t, replica) })
+
+	skipUnlessSupported(ctx, t, replica, featureReplicationSource)
+	// NOTE: the replica connects to the primary by its hostname in the network, not by the mapped port
+	_, err = replica.ExecContext(ctx, fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_HOST = 'primary', SOURCE_USER = 'root', "+
+		"SOURCE_AUTO_POSITION = 1, SOURCE_DELAY = %d, GET_SOURCE_PUBLIC_KEY = 1", int(lag.Seconds())))
+	require.NoError(t, err)
+	_, err = replica.ExecContext(ctx, "START REPLICA")
+	require.NoError(t, err)
+
+	require.NoError(t, Migrate(ctx, primary))
+	waitForReplica(ctx, t, primary, replica)
+	return primary, replica
+}
+
+// waitForReplica waits until the replica applies all transactions committed in the primary so far.
+func waitForReplica(ctx context.Context, t *testing.T, primary, replica *sql.DB) {
+	t.Helper()
+
+	var executed string
+	require.NoError(t, primary.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&executed))
+	// NOTE: it returns 1 if the timeout (in seconds) has expired
+	var timedOut int
+	require.NoError(t, replica.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, 60)", executed).Scan(&timedOut))
+	require.Zero(t, timedOut, "replica did not apply %s", executed)
+}
+
+// test using go-sqlmock
+func TestReplicaWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	tests := []struct {
+		title   string
+		primary func(sqlmock.Sqlmock)
+		replica func(sqlmock.Sqlmock)
+		run     func(context.Context, *sql.DB, *userRepository) error
+	}{
+		{
+			"get reads the replica",
+			func(mock sqlmock.Sqlmock) {},
+			func(mock sqlmock.Sqlmock) { ExpectGetUser(mock, mike) },
+			func(ctx context.Context, _ *sql.DB, r *userRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+		},
+		{
+			"get by name reads the replica",
+			func(mock sqlmock.Sqlmock) {},
+			func(mock sqlmock.Sqlmock) { ExpectGetUserByName(mock, mike) },
+			func(ctx context.Context, _ *sql.DB, r *userRepository) error {
+				_, err := r.GetByName(ctx, mike.Name)
+				return err
+			},
+		},
+		{
+			"list reads the replica",
+			func(mock sqlmock.Sqlmock) {},
+			func(mock sqlmock.Sqlmock) { ExpectListUsers(mock, nil, 1, mike) },
+			func(ctx context.Context, _ *sql.DB, r *userRepository) error {
+				_, _, err := r.List(ctx, nil)
+				return err
+			},
+		},
+		{
+			"get consistent reads the primary",
+			func(mock sqlmock.Sqlmock) { ExpectGetUser(mock, mike) },
+			func(mock sqlmock.Sqlmock) {},
+			func(ctx context.Context, _ *sql.DB, r *userRepository) error {
+				_, err := r.GetConsistent(ctx, mike.ID)
+				return err
+			},
+		},
+		{
+			"register writes the primary",
+			func(mock sqlmock.Sqlmock) { ExpectInsertUser(mock, mike) },
+			func(mock sqlmock.Sqlmock) {},
+			func(ctx context.Context, _ *sql.DB, r *userRepository) error {
+				return r.Register(ctx, mike)
+			},
+		},
+		{
+			"get in a transaction reads the primary",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				ExpectGetUser(mock, mike)
+				mock.ExpectCommit()
+			},
+			func(mock sqlmock.Sqlmock) {},
+			func(ctx context.Context, primary *sql.DB, r *userRepository) error {
+				return NewTxManager(primary, WithReplica(r.replica)).WithinTx(ctx, func(ctx context.Context) error {
+					repos, err := RepositoriesFromContext(ctx)
+					if err != nil {
+						return err
+					}
+					_, err = repos.Users.Get(ctx, mike.ID)
+					return err
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			primary, primaryMock := prepareMockDB(t)
+			replica, replicaMock := prepareMockDB(t)
+			tt.primary(primaryMock)
+			tt.replica(replicaMock)
+
+			// run
+			err := tt.run(context.TODO(), primary, NewUserRepository(primary, WithReplica(replica)))
+
+			// assert
+			require.NoError(t, err)
+			require.NoError(t, primaryMock.ExpectationsWereMet())
+			require.NoError(t, replicaMock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestStaleReplicaWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	primaryPort, err := testport.Reserve()
+	require.NoError(t, err)
+	replicaPort, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	// NOTE: the replica never applies writes of the primary, as if it lagged forever
+	prepareSimulator(t, primaryPort)
+	prepareSimulator(t, replicaPort)
+	primary, err := NewStrictClient(primaryPort)
+	require.NoError(t, err)
+	defer closeTestClient(t, primary)
+	replica, err := NewStrictClient(replicaPort)
+	require.NoError(t, err)
+	defer closeTestClient(t, replica)
+
+	r := NewUserRepository(primary, WithReplica(replica))
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, r.Register(ctx, mike))
+
+	t.Run("get reads the stale replica", func(t *testing.T) {
+		_, err := r.Get(ctx, mike.ID)
+
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("get consistent reads the primary", func(t *testing.T) {
+		actual, err := r.GetConsistent(ctx, mike.ID)
+
+		require.NoError(t, err)
+		require.Equal(t, mike, actual)
+	})
+}
+
+// test using testcontainers
+func TestReplicaLagWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	const lag = 3 * time.Second
+	primary, replica := prepareLaggingReplica(ctx, t, lag)
+
+	r := NewUserRepository(primary, WithReplica(replica))
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// run
+	require.NoError(t, r.Register(ctx, mike))
+
+	// assert
+	// NOTE: the replica has not applied the insert yet
+	_, err := r.Get(ctx, mike.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	actual, err := r.GetConsistent(ctx, mike.ID)
+	require.NoError(t, err)
+	require.Equal(t, mike, actual)
+
+	require.Eventually(t, func() bool {
+		found, err := r.Get(ctx, mike.ID)
+		return err == nil && found.ID == mike.ID
+	}, lag+10*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,156 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+)
+
+// EntityAdapter maps entities of type T, identified by ID, onto their sqlboiler models,
+// so that Repository implements the common operations of any table.
+type EntityAdapter[T any, ID comparable] interface {
+	// Name returns the name of the entity in errors, e.g. "user".
+	Name() string
+	// ID returns the id of the entity.
+	ID(entity *T) ID
+	// ValidateID returns an error if the id is malformed, so that no statement is sent.
+	ValidateID(id ID) error
+	// ByID returns the query mod selecting the entity of the id.
+	ByID(id ID) qm.QueryMod
+	// One returns the first entity selected by mods (e.g. by models.Users(mods...).One), or sql.ErrNoRows.
+	One(ctx context.Context, exec boil.ContextExecutor, mods ...qm.QueryMod) (*T, error)
+	// All returns the entities selected by mods.
+	All(ctx context.Context, exec boil.ContextExecutor, mods ...qm.QueryMod) ([]*T, error)
+	Insert(ctx context.Context, exec boil.ContextExecutor, entity *T) error
+	Delete(ctx context.Context, exec boil.ContextExecutor, entity *T) error
+}
+
+// Repository implements Get, List, Register and Delete of entities by their EntityAdapter,
+// so that a new entity only needs its adapter instead of a copy of userRepository.
+type Repository[T any, ID comparable] struct {
+	db boil.ContextExecutor
+	// reader runs reads which may be stale, e.g. on a replica (db by default)
+	reader       boil.ContextExecutor
+	adapter      EntityAdapter[T, ID]
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	// scope adds query mods to reads, e.g. to narrow down entities to a tenant
+	scope func(mods ...qm.QueryMod) []qm.QueryMod
+	// read runs the statements of a read operation, e.g. with retries
+	read func(ctx context.Context, operation string, f func() error) error
+	// write runs the statements of a write operation of the entity of id, e.g. with retries in a transaction
+	write func(ctx context.Context, operation string, id ID, f func(context.Context, boil.ContextExecutor) error) error
+}
+
+// NewRepository returns the repository of the entities of the adapter with the default timeouts.
+func NewRepository[T any, ID comparable](db *sql.DB, adapter EntityAdapter[T, ID]) *Repository[T, ID] {
+	return newRepository[T, ID](db, adapter)
+}
+
+func newRepository[T any, ID comparable](db boil.ContextExecutor, adapter EntityAdapter[T, ID]) *Repository[T, ID] {
+	return &Repository[T, ID]{
+		db:           db,
+		reader:       db,
+		adapter:      adapter,
+		readTimeout:  defaultReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+		scope: func(mods ...qm.QueryMod) []qm.QueryMod {
+			return mods
+		},
+		read: func(_ context.Context, _ string, f func() error) error {
+			return f()
+		},
+		write: func(ctx context.Context, _ string, _ ID, f func(context.Context, boil.ContextExecutor) error) error {
+			return f(ctx, db)
+		},
+	}
+}
+
+// Get returns the entity of the id. It returns sql.ErrNoRows if the entity is not found.
+func (r *Repository[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	return r.get(ctx, r.reader, id)
+}
+
+// GetConsistent returns the entity of the id as Get, but reads db instead of reader.
+func (r *Repository[T, ID]) GetConsistent(ctx context.Context, id ID) (*T, error) {
+	return r.get(ctx, r.db, id)
+}
+
+func (r *Repository[T, ID]) get(ctx context.Context, exec boil.ContextExecutor, id ID) (*T, error) {
+	if err := r.adapter.ValidateID(id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var entity *T
+	err := r.read(ctx, OperationGet, func() error {
+		var err error
+		entity, err = r.adapter.One(ctx, exec, r.scope(r.adapter.ByID(id))...)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s was not found (id: %v): %w", r.adapter.Name(), id, err)
+		}
+
+		return nil, fmt.Errorf("failed to get %s (id: %v): %w", r.adapter.Name(), id, err)
+	}
+
+	return entity, nil
+}
+
+// List returns the entities selected by mods (e.g. where clauses, order and limit).
+func (r *Repository[T, ID]) List(ctx context.Context, mods ...qm.QueryMod) ([]*T, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var entities []*T
+	err := r.read(ctx, OperationList, func() error {
+		var err error
+		entities, err = r.adapter.All(ctx, r.reader, r.scope(mods...)...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.adapter.Name(), err)
+	}
+
+	return entities, nil
+}
+
+// Register inserts the entity.
+func (r *Repository[T, ID]) Register(ctx context.Context, entity *T) error {
+	id := r.adapter.ID(entity)
+	if err := r.adapter.ValidateID(id); err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	return r.write(ctx, OperationRegister, id, func(ctx context.Context, exec boil.ContextExecutor) error {
+		if err := r.adapter.Insert(ctx, exec, entity); err != nil {
+			return fmt.Errorf("failed to insert %s: %w", r.adapter.Name(), err)
+		}
+		return nil
+	})
+}
+
+// Delete deletes the entity as the adapter does (e.g. soft deletion).
+func (r *Repository[T, ID]) Delete(ctx context.Context, entity *T) error {
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	return r.write(ctx, OperationDelete, r.adapter.ID(entity), func(ctx context.Context, exec boil.ContextExecutor) error {
+		if err := r.adapter.Delete(ctx, exec, entity); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", r.adapter.Name(), err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,120 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// test using every selected DBTestBackend
+func TestUserRepositoryOnBackends(t *testing.T) {
+	for _, b := range selectedBackends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			RunStandardSuite(t, b.newBackend)
+		})
+	}
+}
+
+// test using every selected DBTestBackend which stores rows
+func TestUserRepositoryImplementationsOnBackends(t *testing.T) {
+	implementations := []struct {
+		name          string
+		newRepository func(db *sql.DB) UserRepository
+	}{
+		{"sqlboiler", func(db *sql.DB) UserRepository { return NewUserRepository(db) }},
+		{"sqlc", func(db *sql.DB) UserRepository { return NewSQLCUserRepository(db) }},
+	}
+
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			for _, b := range selectedBackends(t) {
+				t.Run(b.name, func(t *testing.T) {
+					RunConformanceSuite(t, b.newBackend, impl.newRepository)
+				})
+			}
+		})
+	}
+}
+
+// orderAdapter is an EntityAdapter of orders, which checks that Repository works for entities other than users.
+type orderAdapter struct{}
+
+func (orderAdapter) Name() string              { return "order" }
+func (orderAdapter) ID(order *Order) string    { return order.ID }
+func (orderAdapter) ValidateID(_ string) error { return nil }
+func (orderAdapter) ByID(id string) qm.QueryMod {
+	return models.OrderWhere.ID.EQ(id)
+}
+
+func (orderAdapter) One(ctx context.Context, exec boil.ContextExecutor, mods ...qm.QueryMod) (*Order, error) {
+	order, err := models.Orders(mods...).One(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	return fromOrderModels(models.OrderSlice{order})[0], nil
+}
+
+func (orderAdapter) All(ctx context.Context, exec boil.ContextExecutor, mods ...qm.QueryMod) ([]*Order, error) {
+	orders, err := models.Orders(mods...).All(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	return fromOrderModels(orders), nil
+}
+
+func (orderAdapter) Insert(ctx context.Context, exec boil.ContextExecutor, order *Order) error {
+	return toOrderModel(order).Insert(ctx, exec, boil.Infer())
+}
+
+func (orderAdapter) Delete(ctx context.Context, exec boil.ContextExecutor, order *Order) error {
+	_, err := toOrderModel(order).Delete(ctx, exec)
+	return err
+}
+
+// test using go-mysql-server
+func TestRepositoryWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	// NOTE: go-mysql-server rejects every child row while foreign key checks are enabled (see newMigrationClient)
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	require.NoError(t, NewUserRepository(db).Register(ctx, &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"}))
+	createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	book := &Order{ID: "order1", UserID: "0123456789ABCDEFGHJKMNPQRS", Item: "book", Amount: 1, CreatedAt: createdAt}
+	pen := &Order{ID: "order2", UserID: "0123456789ABCDEFGHJKMNPQRS", Item: "pen", Amount: 3, CreatedAt: createdAt.Add(time.Hour)}
+
+	r := NewRepository[Order, string](db, orderAdapter{})
+
+	// run
+	require.NoError(t, r.Register(ctx, book))
+	require.NoError(t, r.Register(ctx, pen))
+
+	// assert
+	found, err := r.Get(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, book, found)
+
+	orders, err := r.List(ctx, models.OrderWhere.Amount.GT(1))
+	require.NoError(t, err)
+	require.Equal(t, []*Order{pen}, orders)
+
+	require.NoError(t, r.Delete(ctx, book))
+	_, err = r.Get(ctx, book.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.EqualError(t, err, "order was not found (id: order1): sql: no rows in result set")
+
+	orders, err = r.List(ctx, ordersByCreation())
+	require.NoError(t, err)
+	require.Equal(t, []*Order{pen}, orders)
+}
@@ -0,0 +1,44 @@
+package gosqltests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// test using testcontainers
+func TestRestartDatabaseWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+
+	db, restartDatabase := prepareRestartableContainer(ctx, t)
+	r := NewRetryUserRepository(NewUserRepository(db), 10, 100*time.Millisecond)
+	require.NoError(t, r.Register(ctx, mike))
+
+	// a transaction is in flight during the restart
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO `user` (`id`,`name`,`age`) VALUES (?,?,?)", bob.ID, bob.Name, bob.Age)
+	require.NoError(t, err)
+
+	// run
+	err = restartDatabase(ctx)
+	require.NoError(t, err)
+
+	// assert
+	// the in-flight transaction fails instead of hanging, and nothing is committed
+	require.Error(t, tx.Commit())
+
+	// the pool recovers
+	found, err := r.Get(ctx, mike.ID)
+	require.NoError(t, err)
+	require.Equal(t, mike, found)
+
+	_, err = r.Get(ctx, bob.ID)
+	require.Error(t, err)
+	require.NoError(t, r.Register(ctx, bob))
+}
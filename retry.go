@@ -0,0 +1,225 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// retrier calls a function up to maxAttempts times while it fails by retryable errors, doubling the wait from backoff.
+type retrier struct {
+	maxAttempts int
+	backoff     time.Duration
+	// maxBackoff caps the wait if positive
+	maxBackoff time.Duration
+	// jitter is the ratio of each wait which is randomized (0 waits exactly)
+	jitter float64
+	clock  Clock
+	// random returns a number in [0, 1), which is rand.Float64 if nil
+	random func() float64
+}
+
+// retryUserRepository retries operations failed by connection errors, e.g. while the database is restarting.
+type retryUserRepository struct {
+	UserRepository
+	retrier
+}
+
+// NewRetryUserRepository tries each operation up to maxAttempts times, doubling the wait from backoff.
+func NewRetryUserRepository(repo UserRepository, maxAttempts int, backoff time.Duration) *retryUserRepository {
+	return newRetryUserRepository(repo, maxAttempts, backoff, systemClock{})
+}
+
+func newRetryUserRepository(repo UserRepository, maxAttempts int, backoff time.Duration, clock Clock) *retryUserRepository {
+	return &retryUserRepository{
+		UserRepository: repo,
+		retrier: retrier{
+			maxAttempts: maxAttempts,
+			backoff:     backoff,
+			clock:       clock,
+		},
+	}
+}
+
+// isNotSent reports whether the error occurred before the statement reached the server.
+func isNotSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, driver.ErrBadConn) || IsTooManyConnections(err)
+}
+
+// isConnectionLost reports whether the connection was broken; the statement may or may not have been executed.
+func isConnectionLost(err error) bool {
+	return isNotSent(err) || errors.Is(err, mysql.ErrInvalidConn)
+}
+
+func (r *retrier) retry(ctx context.Context, retryable func(error) bool, f func() error) error {
+	wait := r.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = f()
+		if err == nil || !retryable(err) || attempt >= r.maxAttempts {
+			return err
+		}
+
+		select {
+		case <-r.clock.After(r.jittered(wait)):
+		case <-ctx.Done():
+			return fmt.Errorf("retry was cancelled: %w", err)
+		}
+		wait *= 2
+		if r.maxBackoff > 0 && wait > r.maxBackoff {
+			wait = r.maxBackoff
+		}
+	}
+}
+
+// jittered shortens the wait randomly by up to its jitter ratio,
+// so that clients which failed at the same time (e.g. by a deadlock) do not retry at the same time again.
+func (r *retrier) jittered(wait time.Duration) time.Duration {
+	if r.jitter <= 0 {
+		return wait
+	}
+	random := r.random
+	if random == nil {
+		random = rand.Float64
+	}
+	return wait - time.Duration(float64(wait)*r.jitter*random())
+}
+
+// MySQL error numbers of transactions rolled back by the server
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+)
+
+// IsTransient reports whether the operation may succeed if it is retried,
+// i.e. it failed by a deadlock, a lock wait timeout or a lost (reset) connection.
+func IsTransient(err error) bool {
+	return isRolledBack(err) || isConnectionLost(err) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// isRolledBack reports whether the server rolled back the statement, which is safe to retry even if it writes.
+// NOTE: a lock wait timeout rolls back only the statement, but the whole transaction is retried anyway
+func isRolledBack(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == mysqlErrDeadlock || mysqlErr.Number == mysqlErrLockWaitTimeout
+}
+
+// isRetryableWrite reports whether a write failed without being executed.
+func isRetryableWrite(err error) bool {
+	return isRolledBack(err) || isNotSent(err)
+}
+
+// RetryPolicy configures the retry of transient errors (see WithTransientRetry).
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt
+	MaxAttempts int
+	// InitialBackoff is doubled after each attempt up to MaxBackoff (unlimited if 0)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the ratio of each wait which is randomized in [0, 1] (e.g. 0.5 waits 50%-100% of the backoff)
+	Jitter float64
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be positive: %d", p.MaxAttempts)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("jitter must be in [0, 1]: %v", p.Jitter)
+	}
+	return nil
+}
+
+func (p RetryPolicy) retrier(clock Clock) *retrier {
+	return &retrier{
+		maxAttempts: p.MaxAttempts,
+		backoff:     p.InitialBackoff,
+		maxBackoff:  p.MaxBackoff,
+		jitter:      p.Jitter,
+		clock:       clock,
+	}
+}
+
+// operations of NewUserRepository which WithTransientRetry configures
+const (
+	OperationRegister   = "Register"
+	OperationUpsert     = "Upsert"
+	OperationList       = "List"
+	OperationListAfter  = "ListAfter"
+	OperationCount      = "Count"
+	OperationExists     = "Exists"
+	OperationGet        = "Get"
+	OperationGetByName  = "GetByName"
+	OperationGetByEmail = "GetByEmail"
+	OperationGetMany    = "GetMany"
+	OperationSearch     = "Search"
+	OperationDelete     = "Delete"
+	OperationHardDelete = "HardDelete"
+	OperationRestore    = "Restore"
+	OperationUpdate     = "Update"
+)
+
+var userOperations = []string{
+	OperationRegister, OperationUpsert, OperationList, OperationListAfter, OperationCount, OperationExists, OperationGet,
+	OperationGetMany, OperationSearch, OperationGetByName, OperationGetByEmail, OperationDelete, OperationHardDelete,
+	OperationRestore, OperationUpdate,
+}
+
+// Register is retried only if the user was not sent to the server, because it is not idempotent.
+func (r *retryUserRepository) Register(ctx context.Context, user *User) error {
+	return r.retry(ctx, isNotSent, func() error {
+		return r.UserRepository.Register(ctx, user)
+	})
+}
+
+func (r *retryUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	var users []*User
+	var total int64
+	err := r.retry(ctx, isConnectionLost, func() error {
+		var err error
+		users, total, err = r.UserRepository.List(ctx, query)
+		return err
+	})
+	return users, total, err
+}
+
+func (r *retryUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	var user *User
+	err := r.retry(ctx, isConnectionLost, func() error {
+		var err error
+		user, err = r.UserRepository.Get(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+func (r *retryUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	var user *User
+	err := r.retry(ctx, isConnectionLost, func() error {
+		var err error
+		user, err = r.UserRepository.GetByName(ctx, name)
+		return err
+	})
+	return user, err
+}
+
+func (r *retryUserRepository) Delete(ctx context.Context, user *User) error {
+	return r.retry(ctx, isConnectionLost, func() error {
+		return r.UserRepository.Delete(ctx, user)
+	})
+}
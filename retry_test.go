@@ -0,0 +1,618 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// flakyUserRepository fails the first failures calls with err.
+type flakyUserRepository struct {
+	mockUserRepository
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyUserRepository) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyUserRepository) Register(ctx context.Context, user *User) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.mockUserRepository.Register(ctx, user)
+}
+
+func (f *flakyUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.mockUserRepository.Get(ctx, id)
+}
+
+var errConnectionRefused = &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}
+
+// test using mocked repository
+func TestRetry(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	tests := []struct {
+		title         string
+		failures      int
+		err           error
+		run           func(context.Context, UserRepository) error
+		expectedErr   error
+		expectedCalls int
+		expectedWaits []time.Duration
+	}{
+		{
+			"get succeeds after connection is refused",
+			2,
+			errConnectionRefused,
+			func(ctx context.Context, r UserRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			nil,
+			3,
+			[]time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			"get succeeds after connection is lost",
+			1,
+			mysql.ErrInvalidConn,
+			func(ctx context.Context, r UserRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			nil,
+			2,
+			[]time.Duration{100 * time.Millisecond},
+		},
+		{
+			"get gives up after max attempts",
+			5,
+			driver.ErrBadConn,
+			func(ctx context.Context, r UserRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			driver.ErrBadConn,
+			3,
+			[]time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			"get is not retried if user is not found",
+			1,
+			sql.ErrNoRows,
+			func(ctx context.Context, r UserRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			sql.ErrNoRows,
+			1,
+			nil,
+		},
+		{
+			"register succeeds after connection is refused",
+			1,
+			errConnectionRefused,
+			func(ctx context.Context, r UserRepository) error {
+				return r.Register(ctx, mike)
+			},
+			nil,
+			2,
+			[]time.Duration{100 * time.Millisecond},
+		},
+		{
+			"register is not retried if connection is lost",
+			1,
+			mysql.ErrInvalidConn,
+			func(ctx context.Context, r UserRepository) error {
+				return r.Register(ctx, mike)
+			},
+			mysql.ErrInvalidConn,
+			1,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			repo := &flakyUserRepository{
+				mockUserRepository: mockUserRepository{users: map[string]*User{"Mike": mike}},
+				failures:           tt.failures,
+				err:                tt.err,
+			}
+			clock := newFakeClock()
+
+			// run
+			r := newRetryUserRepository(repo, 3, 100*time.Millisecond, clock)
+			err := tt.run(context.TODO(), r)
+
+			// assert
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.expectedErr)
+			}
+			require.Equal(t, tt.expectedCalls, repo.calls)
+			require.Equal(t, tt.expectedWaits, clock.waits)
+		})
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	// mock
+	repo := &flakyUserRepository{failures: 5, err: errConnectionRefused}
+	clock := newFakeClock()
+	clock.block = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// run
+	r := newRetryUserRepository(repo, 3, 100*time.Millisecond, clock)
+	_, err := r.Get(ctx, "0123456789ABCDEFGHJKMNPQRS")
+
+	// assert
+	require.EqualError(t, err, "retry was cancelled: dial tcp: connection refused")
+	require.Equal(t, 1, repo.calls)
+}
+
+// test using go-mysql-server
+func TestRetryWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	simDB, table := simulatorDB()
+	s := startSimulator(t, port, simDB)
+	_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow("0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20), nil, nil, int64(1), seededAt, seededAt))
+
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	r := NewRetryUserRepository(NewUserRepository(db), 5, 10*time.Millisecond)
+	_, err = r.Get(context.TODO(), "0123456789ABCDEFGHJKMNPQRS")
+	require.NoError(t, err)
+
+	// restart the server, which closes all pooled connections
+	require.NoError(t, s.Close())
+	table = prepareSimulator(t, port)
+	_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow("0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20), nil, nil, int64(1), seededAt, seededAt))
+
+	// run
+	found, err := r.Get(context.TODO(), "0123456789ABCDEFGHJKMNPQRS")
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), CreatedAt: seededAt, UpdatedAt: seededAt}, found)
+}
+
+// test using go-mysql-server with the fault-injection driver
+func TestReadRetryWithGoMySQLServer(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), CreatedAt: seededAt, UpdatedAt: seededAt}
+
+	tests := []struct {
+		title string
+		// failures is the number of statements failed by lost connections
+		failures int
+		run      func(context.Context, UserRepository) (interface{}, error)
+		expected interface{}
+		// expectedStatements is the number of statements sent including failed ones
+		expectedStatements int
+		expectedErr        error
+	}{
+		{
+			"get is retried",
+			2,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.Get(ctx, mike.ID)
+			},
+			mike,
+			3,
+			nil,
+		},
+		{
+			"get by name is retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.GetByName(ctx, mike.Name)
+			},
+			mike,
+			2,
+			nil,
+		},
+		{
+			"list is retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				users, _, err := r.List(ctx, nil)
+				return users, err
+			},
+			[]*User{mike},
+			// NOTE: count fails, then count and select succeed
+			3,
+			nil,
+		},
+		{
+			"count is retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.(*userRepository).Count(ctx, nil)
+			},
+			int64(1),
+			2,
+			nil,
+		},
+		{
+			"exists is retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.(*userRepository).Exists(ctx, mike.ID)
+			},
+			true,
+			2,
+			nil,
+		},
+		{
+			"read fails after max attempts",
+			3,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.Get(ctx, mike.ID)
+			},
+			nil,
+			3,
+			mysql.ErrInvalidConn,
+		},
+		{
+			"register is not retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return nil, r.Register(ctx, &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)})
+			},
+			nil,
+			1,
+			mysql.ErrInvalidConn,
+		},
+		{
+			"upsert is not retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return nil, r.(*userRepository).Upsert(ctx, &User{ID: mike.ID, Name: mike.Name, Age: lo.ToPtr(21)})
+			},
+			nil,
+			1,
+			mysql.ErrInvalidConn,
+		},
+		{
+			"delete is not retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return nil, r.Delete(ctx, mike)
+			},
+			nil,
+			1,
+			mysql.ErrInvalidConn,
+		},
+		{
+			"hard delete is not retried",
+			1,
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return nil, r.(*userRepository).HardDelete(ctx, mike)
+			},
+			nil,
+			1,
+			mysql.ErrInvalidConn,
+		},
+	}
+
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	table := prepareSimulator(t, port)
+	_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow(mike.ID, mike.Name, int32(*mike.Age), nil, nil, int64(1), seededAt, seededAt))
+
+	injector := &faultInjector{}
+	db := newFaultInjectedClient(t, port, injector)
+	r := NewUserRepository(db, WithReadRetry(3, time.Millisecond))
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			injector.reset()
+			injector.failNext(tt.failures, mysql.ErrInvalidConn)
+
+			// run
+			actual, err := tt.run(context.TODO(), r)
+
+			// assert
+			require.Len(t, injector.statements, tt.expectedStatements)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestReadIsNotRetriedByDefaultWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+
+	injector := &faultInjector{}
+	injector.failNext(1, mysql.ErrInvalidConn)
+	r := NewUserRepository(newFaultInjectedClient(t, port, injector))
+
+	// run
+	_, err = r.Get(context.TODO(), "0123456789ABCDEFGHJKMNPQRS")
+
+	// assert
+	require.ErrorIs(t, err, mysql.ErrInvalidConn)
+	require.Len(t, injector.statements, 1)
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		title    string
+		err      error
+		expected bool
+	}{
+		{"deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{"lock wait timeout", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, true},
+		{"wrapped deadlock", fmt.Errorf("failed to insert user: %w", &mysql.MySQLError{Number: 1213}), true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"invalid connection", mysql.ErrInvalidConn, true},
+		{"connection refused", errConnectionRefused, true},
+		{"duplicate entry", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{"no rows", sql.ErrNoRows, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, IsTransient(tt.err))
+		})
+	}
+}
+
+func TestRetrierBackoff(t *testing.T) {
+	tests := []struct {
+		title         string
+		policy        RetryPolicy
+		expectedWaits []time.Duration
+	}{
+		{
+			"doubled without jitter",
+			RetryPolicy{MaxAttempts: 4, InitialBackoff: 100 * time.Millisecond},
+			[]time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+		},
+		{
+			"capped by max backoff",
+			RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond},
+			[]time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			"shortened by jitter",
+			RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Jitter: 0.5},
+			[]time.Duration{75 * time.Millisecond, 150 * time.Millisecond, 225 * time.Millisecond, 225 * time.Millisecond},
+		},
+		{
+			"full jitter",
+			RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, Jitter: 1},
+			[]time.Duration{50 * time.Millisecond, 100 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			clock := newFakeClock()
+			r := tt.policy.retrier(clock)
+			r.random = func() float64 { return 0.5 }
+
+			// run
+			calls := 0
+			err := r.retry(context.TODO(), IsTransient, func() error {
+				calls++
+				return &mysql.MySQLError{Number: 1213}
+			})
+
+			// assert
+			require.Error(t, err)
+			require.Equal(t, tt.policy.MaxAttempts, calls)
+			require.Equal(t, tt.expectedWaits, clock.waits)
+		})
+	}
+}
+
+func TestWithTransientRetryOfInvalidConfig(t *testing.T) {
+	require.PanicsWithValue(t, "invalid retry policy: max attempts must be positive: 0", func() {
+		WithTransientRetry(RetryPolicy{})
+	})
+	require.PanicsWithValue(t, "invalid retry policy: jitter must be in [0, 1]: 1.5", func() {
+		WithTransientRetry(RetryPolicy{MaxAttempts: 3, Jitter: 1.5})
+	})
+	require.PanicsWithValue(t, "unknown operation: RegisterAll", func() {
+		WithTransientRetry(RetryPolicy{MaxAttempts: 3}, "RegisterAll")
+	})
+}
+
+// test using go-sqlmock
+func TestTransientRetryWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+	lockWaitTimeout := &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Jitter: 0.5}
+
+	selectUser := regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) LIMIT 1 FOR UPDATE;")
+	insertLog := regexp.QuoteMeta("INSERT INTO `audit_log` (`user_id`,`action`,`actor`,`created_at`,`before_json`,`after_json`) VALUES (?,?,?,?,?,?)")
+
+	tests := []struct {
+		title       string
+		opts        []UserRepositoryOption
+		mock        func(sqlmock.Sqlmock)
+		run         func(context.Context, *userRepository) error
+		expectedErr error
+	}{
+		{
+			"register succeeds on retry after a deadlock",
+			[]UserRepositoryOption{WithTransientRetry(policy)},
+			func(mock sqlmock.Sqlmock) {
+				ExpectInsertUser(mock, mike).WillReturnError(deadlock)
+				ExpectInsertUser(mock, mike)
+			},
+			func(ctx context.Context, r *userRepository) error { return r.Register(ctx, mike) },
+			nil,
+		},
+		{
+			"delete succeeds on retry after lock wait timeouts",
+			[]UserRepositoryOption{WithTransientRetry(policy, OperationDelete)},
+			func(mock sqlmock.Sqlmock) {
+				ExpectSoftDeleteUser(mock, mike).WillReturnError(lockWaitTimeout)
+				ExpectSoftDeleteUser(mock, mike).WillReturnError(lockWaitTimeout)
+				ExpectSoftDeleteUser(mock, mike)
+			},
+			func(ctx context.Context, r *userRepository) error { return r.Delete(ctx, mike) },
+			nil,
+		},
+		{
+			"get succeeds on retry after the connection is reset",
+			[]UserRepositoryOption{WithTransientRetry(policy, OperationGet)},
+			func(mock sqlmock.Sqlmock) {
+				ExpectGetUser(mock, mike).WillReturnError(mysql.ErrInvalidConn)
+				ExpectGetUser(mock, mike)
+			},
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			nil,
+		},
+		{
+			"register with audit log is retried as a whole transaction",
+			[]UserRepositoryOption{WithAuditLog(), WithTransientRetry(policy, OperationRegister)},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectUser).WithArgs(mike.ID).WillReturnRows(sqlmock.NewRows(userColumnNames))
+				ExpectInsertUser(mock, mike).WillReturnError(deadlock)
+				mock.ExpectRollback()
+
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectUser).WithArgs(mike.ID).WillReturnRows(sqlmock.NewRows(userColumnNames))
+				ExpectInsertUser(mock, mike)
+				mock.ExpectQuery(selectUser).WithArgs(mike.ID).WillReturnRows(UserRows(mike))
+				mock.ExpectExec(insertLog).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			func(ctx context.Context, r *userRepository) error { return r.Register(ctx, mike) },
+			nil,
+		},
+		{
+			"deadlocks exhaust attempts",
+			[]UserRepositoryOption{WithTransientRetry(policy)},
+			func(mock sqlmock.Sqlmock) {
+				ExpectInsertUser(mock, mike).WillReturnError(deadlock)
+				ExpectInsertUser(mock, mike).WillReturnError(deadlock)
+				ExpectInsertUser(mock, mike).WillReturnError(deadlock)
+			},
+			func(ctx context.Context, r *userRepository) error { return r.Register(ctx, mike) },
+			deadlock,
+		},
+		{
+			"register is not retried if the connection is reset because it may have been executed",
+			[]UserRepositoryOption{WithTransientRetry(policy)},
+			func(mock sqlmock.Sqlmock) {
+				ExpectInsertUser(mock, mike).WillReturnError(mysql.ErrInvalidConn)
+			},
+			func(ctx context.Context, r *userRepository) error { return r.Register(ctx, mike) },
+			mysql.ErrInvalidConn,
+		},
+		{
+			"operation without policy is not retried",
+			[]UserRepositoryOption{WithTransientRetry(policy, OperationGet)},
+			func(mock sqlmock.Sqlmock) {
+				ExpectInsertUser(mock, mike).WillReturnError(deadlock)
+			},
+			func(ctx context.Context, r *userRepository) error { return r.Register(ctx, mike) },
+			deadlock,
+		},
+		{
+			"non-transient error is not retried",
+			[]UserRepositoryOption{WithTransientRetry(policy)},
+			func(mock sqlmock.Sqlmock) {
+				ExpectGetUser(mock, mike).WillReturnError(sql.ErrConnDone)
+			},
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			err := tt.run(context.TODO(), NewUserRepository(db, tt.opts...))
+
+			// assert
+			require.NoError(t, mock.ExpectationsWereMet())
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestTransientRetryIsDisabledInTransactionWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectBegin()
+	ExpectInsertUser(mock, mike).WillReturnError(deadlock)
+	mock.ExpectRollback()
+
+	// run
+	err := NewTxManager(db, WithTransientRetry(RetryPolicy{MaxAttempts: 3})).WithinTx(context.TODO(), func(ctx context.Context) error {
+		repos, err := RepositoriesFromContext(ctx)
+		require.NoError(t, err)
+		return repos.Users.Register(ctx, mike)
+	})
+
+	// assert
+	require.ErrorIs(t, err, deadlock)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
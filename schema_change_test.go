@@ -0,0 +1,65 @@
+package gosqltests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// test using testcontainers
+// NOTE: the schema is changed with MySQL online DDL (ALGORITHM=INPLACE, LOCK=NONE),
+// which is what gh-ost and pt-online-schema-change rely on not to block writes.
+func TestOnlineSchemaChangeWithTestContainers(t *testing.T) {
+	tests := []struct {
+		title  string
+		ddl    string
+		revert string
+		// features of the server which ddl requires
+		requires []serverFeature
+	}{
+		{
+			"add a column",
+			"ALTER TABLE `user` ADD COLUMN `nickname` VARCHAR(255) NULL, ALGORITHM=INPLACE, LOCK=NONE",
+			"ALTER TABLE `user` DROP COLUMN `nickname`",
+			nil,
+		},
+		{
+			"add an index",
+			"ALTER TABLE `user` ADD INDEX `idx_age` (`age`), ALGORITHM=INPLACE, LOCK=NONE",
+			"ALTER TABLE `user` DROP INDEX `idx_age`",
+			nil,
+		},
+		{
+			"widen a column",
+			// NOTE: in-place extension is allowed only while the length prefix stays 1 byte (up to 255 bytes)
+			"ALTER TABLE `user` MODIFY COLUMN `name` VARCHAR(60) NOT NULL, ALGORITHM=INPLACE, LOCK=NONE",
+			"ALTER TABLE `user` MODIFY COLUMN `name` VARCHAR(40) NOT NULL",
+			[]serverFeature{featureInPlaceVarcharExtension},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ctx := context.Background()
+			db := prepareContainer(ctx, t, withFastMySQL())
+			for _, f := range tt.requires {
+				skipUnlessSupported(ctx, t, db, f)
+			}
+			r := NewUserRepository(db)
+
+			// run
+			stop := startStressWriter(ctx, r, 8, testRand(t))
+			_, err := db.ExecContext(ctx, tt.ddl)
+			result := stop()
+			// NOTE: the reused container must keep the original schema
+			defer db.ExecContext(ctx, tt.revert)
+
+			// assert
+			require.NoError(t, err)
+			require.Empty(t, result.failures)
+			require.NotEmpty(t, result.written)
+			requireAllWritten(ctx, t, r, result.written)
+		})
+	}
+}
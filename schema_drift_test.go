@@ -0,0 +1,487 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/memory"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// schemaColumn is a column compared by the schema drift detector.
+type schemaColumn struct {
+	Name string
+	// Type is the column type like "varchar(26)", without display widths of integers
+	Type     string
+	Nullable bool
+}
+
+// tableSchemas are columns of tables by table names, in the order of the definition.
+type tableSchemas map[string][]schemaColumn
+
+// modelTypes are the sqlboiler models of tables.
+// NOTE: schema_migrations has no model as it is blacklisted in sqlboiler.toml
+var modelTypes = map[string]reflect.Type{
+	models.TableNames.User:              reflect.TypeOf(models.User{}),
+	models.TableNames.Credential:        reflect.TypeOf(models.Credential{}),
+	models.TableNames.UserArchive:       reflect.TypeOf(models.UserArchive{}),
+	models.TableNames.ArchiveCheckpoint: reflect.TypeOf(models.ArchiveCheckpoint{}),
+	models.TableNames.IdempotencyKey:    reflect.TypeOf(models.IdempotencyKey{}),
+	models.TableNames.AuditLog:          reflect.TypeOf(models.AuditLog{}),
+	models.TableNames.Order:             reflect.TypeOf(models.Order{}),
+	models.TableNames.UserTenant:        reflect.TypeOf(models.UserTenant{}),
+}
+
+// introspectSchema reads columns of the current database from information_schema.
+func introspectSchema(ctx context.Context, db *sql.DB) (tableSchemas, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT `table_name`, `column_name`, `column_type`, `is_nullable` FROM `information_schema`.`columns` "+
+			"WHERE `table_schema` = DATABASE() ORDER BY `table_name`, `ordinal_position`",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	defer rows.Close()
+
+	tables := tableSchemas{}
+	for rows.Next() {
+		var table, nullable string
+		var c schemaColumn
+		if err := rows.Scan(&table, &c.Name, &c.Type, &nullable); err != nil {
+			return nil, fmt.Errorf("failed to read columns: %w", err)
+		}
+		c.Type = normalizeColumnType(c.Type)
+		c.Nullable = nullable == "YES"
+		tables[table] = append(tables[table], c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	return tables, nil
+}
+
+// memorySchema returns columns of the go-mysql-server memory tables.
+func memorySchema(t *testing.T, db *memory.Database) tableSchemas {
+	ctx := simsql.NewEmptyContext()
+	names, err := db.GetTableNames(ctx)
+	require.NoError(t, err)
+
+	tables := tableSchemas{}
+	for _, name := range names {
+		table, ok, err := db.GetTableInsensitive(ctx, name)
+		require.NoError(t, err)
+		require.True(t, ok, name)
+		for _, c := range table.Schema() {
+			tables[name] = append(tables[name], schemaColumn{Name: c.Name, Type: normalizeColumnType(c.Type.String()), Nullable: c.Nullable})
+		}
+	}
+	return tables
+}
+
+var integerDisplayWidth = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+// normalizeColumnType removes display widths of integers, which MySQL 5.7 shows but MySQL 8.0 does not.
+// NOTE: tinyint(1) is kept because it means bool
+func normalizeColumnType(typ string) string {
+	typ = strings.ToLower(typ)
+	if strings.HasPrefix(typ, "tinyint(1)") {
+		return typ
+	}
+	return integerDisplayWidth.ReplaceAllString(typ, "$1")
+}
+
+// schemaDrifts returns differences of tables from the reference, one line for each.
+// If partial is true, tables missing in tables are ignored (e.g. go-mysql-server memory tables only define some of them).
+func schemaDrifts(source string, reference, tables tableSchemas, partial bool) []string {
+	var drifts []string
+	for _, name := range sortedTableNames(reference) {
+		columns, ok := tables[name]
+		if !ok {
+			if !partial {
+				drifts = append(drifts, fmt.Sprintf("%s: table %s is missing", source, name))
+			}
+			continue
+		}
+		drifts = append(drifts, columnDrifts(source, name, reference[name], columns)...)
+	}
+	for _, name := range sortedTableNames(tables) {
+		if _, ok := reference[name]; !ok {
+			drifts = append(drifts, fmt.Sprintf("%s: table %s is unknown", source, name))
+		}
+	}
+	return drifts
+}
+
+func columnDrifts(source, table string, reference, columns []schemaColumn) []string {
+	var drifts []string
+	byName := map[string]schemaColumn{}
+	for _, c := range columns {
+		byName[c.Name] = c
+	}
+	for _, want := range reference {
+		got, ok := byName[want.Name]
+		if !ok {
+			drifts = append(drifts, fmt.Sprintf("%s: %s.%s is missing", source, table, want.Name))
+			continue
+		}
+		if got.Type != want.Type {
+			drifts = append(drifts, fmt.Sprintf("%s: %s.%s: type %s, want %s", source, table, want.Name, got.Type, want.Type))
+		}
+		if got.Nullable != want.Nullable {
+			drifts = append(drifts, fmt.Sprintf("%s: %s.%s: %s, want %s", source, table, want.Name, nullability(got.Nullable), nullability(want.Nullable)))
+		}
+	}
+
+	known := map[string]bool{}
+	for _, c := range reference {
+		known[c.Name] = true
+	}
+	for _, c := range columns {
+		if !known[c.Name] {
+			drifts = append(drifts, fmt.Sprintf("%s: %s.%s is unknown", source, table, c.Name))
+		}
+	}
+
+	// NOTE: the order matters for rows inserted into memory tables by position
+	if drifts == nil && !sameColumnOrder(reference, columns) {
+		drifts = append(drifts, fmt.Sprintf("%s: %s: columns in order (%s), want (%s)", source, table, joinColumnNames(columns), joinColumnNames(reference)))
+	}
+	return drifts
+}
+
+// modelDrifts returns differences of the sqlboiler models from the reference, one line for each.
+func modelDrifts(reference tableSchemas) []string {
+	const source = "sqlboiler models"
+
+	var drifts []string
+	for _, name := range sortedTableNames(reference) {
+		if name == "schema_migrations" {
+			continue
+		}
+		typ, ok := modelTypes[name]
+		if !ok {
+			drifts = append(drifts, fmt.Sprintf("%s: table %s has no model", source, name))
+			continue
+		}
+
+		fields := map[string]reflect.StructField{}
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if tag := f.Tag.Get("boil"); tag != "" && tag != "-" {
+				fields[tag] = f
+			}
+		}
+		for _, c := range reference[name] {
+			f, ok := fields[c.Name]
+			if !ok {
+				drifts = append(drifts, fmt.Sprintf("%s: %s.%s is missing", source, name, c.Name))
+				continue
+			}
+			want := goTypeOf(c)
+			if want == "" {
+				drifts = append(drifts, fmt.Sprintf("%s: %s.%s: type %s is not supported", source, name, c.Name, c.Type))
+				continue
+			}
+			if got := f.Type.String(); got != want {
+				drifts = append(drifts, fmt.Sprintf("%s: %s.%s: %s.%s is %s, want %s", source, name, c.Name, typ.Name(), f.Name, got, want))
+			}
+			delete(fields, c.Name)
+		}
+		for _, column := range sortedKeys(fields) {
+			drifts = append(drifts, fmt.Sprintf("%s: %s.%s is unknown", source, name, column))
+		}
+	}
+	return drifts
+}
+
+// goTypeOf returns the type of a model field sqlboiler generates for the column, or "" if it is not supported.
+func goTypeOf(c schemaColumn) string {
+	var typ, nullType string
+	switch base := strings.SplitN(c.Type, "(", 2)[0]; {
+	case c.Type == "tinyint(1)":
+		typ, nullType = "bool", "null.Bool"
+	case base == "varchar" || base == "char" || base == "text":
+		typ, nullType = "string", "null.String"
+	case base == "int":
+		typ, nullType = "int", "null.Int"
+	case base == "bigint":
+		typ, nullType = "int64", "null.Int64"
+	case base == "datetime" || base == "timestamp":
+		typ, nullType = "time.Time", "null.Time"
+	case base == "json":
+		typ, nullType = "types.JSON", "null.JSON"
+	default:
+		return ""
+	}
+	if c.Nullable {
+		return nullType
+	}
+	return typ
+}
+
+// assertNoSchemaDrift fails the test with a human-readable diff if drifts are found.
+func assertNoSchemaDrift(t require.TestingT, reference string, drifts ...[]string) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	var lines []string
+	for _, d := range drifts {
+		lines = append(lines, d...)
+	}
+	if len(lines) > 0 {
+		t.Errorf("schema drifted from %s:\n\t%s", reference, strings.Join(lines, "\n\t"))
+		t.FailNow()
+	}
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+func sameColumnOrder(a, b []schemaColumn) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+func joinColumnNames(columns []schemaColumn) string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func sortedTableNames(tables tableSchemas) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(fields map[string]reflect.StructField) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestSchemaDrifts(t *testing.T) {
+	reference := tableSchemas{
+		"user": {
+			{Name: "id", Type: "varchar(26)"},
+			{Name: "age", Type: "int", Nullable: true},
+		},
+		"credential": {
+			{Name: "user_id", Type: "varchar(26)"},
+		},
+	}
+
+	tests := []struct {
+		title    string
+		tables   tableSchemas
+		partial  bool
+		expected []string
+	}{
+		{
+			"same schema",
+			reference,
+			false,
+			nil,
+		},
+		{
+			"type and nullability",
+			tableSchemas{
+				"user":       {{Name: "id", Type: "text"}, {Name: "age", Type: "bigint"}},
+				"credential": {{Name: "user_id", Type: "varchar(26)"}},
+			},
+			false,
+			[]string{
+				"memory: user.id: type text, want varchar(26)",
+				"memory: user.age: type bigint, want int",
+				"memory: user.age: NOT NULL, want NULL",
+			},
+		},
+		{
+			"missing and unknown",
+			tableSchemas{
+				"user":  {{Name: "id", Type: "varchar(26)"}, {Name: "email", Type: "varchar(254)", Nullable: true}},
+				"audit": {{Name: "id", Type: "bigint"}},
+			},
+			false,
+			[]string{
+				"memory: table credential is missing",
+				"memory: user.age is missing",
+				"memory: user.email is unknown",
+				"memory: table audit is unknown",
+			},
+		},
+		{
+			"missing tables are ignored if partial",
+			tableSchemas{"user": reference["user"]},
+			true,
+			nil,
+		},
+		{
+			"column order",
+			tableSchemas{
+				"user":       {{Name: "age", Type: "int", Nullable: true}, {Name: "id", Type: "varchar(26)"}},
+				"credential": {{Name: "user_id", Type: "varchar(26)"}},
+			},
+			false,
+			[]string{"memory: user: columns in order (age, id), want (id, age)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			drifts := schemaDrifts("memory", reference, tt.tables, tt.partial)
+
+			// assert
+			require.Equal(t, tt.expected, drifts)
+		})
+	}
+}
+
+func TestModelDrifts(t *testing.T) {
+	tests := []struct {
+		title     string
+		reference tableSchemas
+		expected  []string
+	}{
+		{
+			"nullability",
+			tableSchemas{models.TableNames.Credential: {
+				{Name: "user_id", Type: "varchar(26)"},
+				{Name: "password_hash", Type: "varchar(60)", Nullable: true},
+			}},
+			[]string{"sqlboiler models: credential.password_hash: Credential.PasswordHash is string, want null.String"},
+		},
+		{
+			"missing and unknown columns",
+			tableSchemas{models.TableNames.Credential: {
+				{Name: "user_id", Type: "varchar(26)"},
+				{Name: "created_at", Type: "datetime"},
+			}},
+			[]string{
+				"sqlboiler models: credential.created_at is missing",
+				"sqlboiler models: credential.password_hash is unknown",
+			},
+		},
+		{
+			"table without model",
+			tableSchemas{"team": {{Name: "id", Type: "varchar(26)"}}, "schema_migrations": {{Name: "version", Type: "bigint"}}},
+			[]string{"sqlboiler models: table team has no model"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, modelDrifts(tt.reference))
+		})
+	}
+}
+
+func TestNormalizeColumnType(t *testing.T) {
+	tests := []struct {
+		typ      string
+		expected string
+	}{
+		{"int(11)", "int"},
+		{"bigint(20) unsigned", "bigint unsigned"},
+		{"tinyint(1)", "tinyint(1)"},
+		{"tinyint(4)", "tinyint"},
+		{"VARCHAR(26)", "varchar(26)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			require.Equal(t, tt.expected, normalizeColumnType(tt.typ))
+		})
+	}
+}
+
+func TestAssertNoSchemaDrift(t *testing.T) {
+	// run
+	rt := &recordingT{}
+	rt.run(func() {
+		assertNoSchemaDrift(rt, "MySQL", []string{"memory: user.id: type text, want varchar(26)"}, nil, []string{"sqlboiler models: table team has no model"})
+	})
+
+	// assert
+	require.True(t, rt.failed)
+	require.Equal(t, []string{
+		"schema drifted from MySQL:\n\tmemory: user.id: type text, want varchar(26)\n\tsqlboiler models: table team has no model",
+	}, rt.errors)
+}
+
+// test using go-mysql-server
+func TestSchemaDriftWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	// run
+	// NOTE: the schema migrated on go-mysql-server is the reference, as MySQL is not available here
+	reference, err := introspectSchema(ctx, db)
+	require.NoError(t, err)
+	memoryDB, _ := simulatorDB()
+
+	// assert
+	assertNoSchemaDrift(t, "migrations on go-mysql-server",
+		schemaDrifts("go-mysql-server memory", reference, memorySchema(t, memoryDB), true),
+		modelDrifts(reference),
+	)
+}
+
+// test using testcontainers
+func TestSchemaDriftWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	simulatorClient, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	// run
+	reference, err := introspectSchema(ctx, db)
+	require.NoError(t, err)
+	migrated, err := introspectSchema(ctx, simulatorClient)
+	require.NoError(t, err)
+	memoryDB, _ := simulatorDB()
+
+	// assert
+	assertNoSchemaDrift(t, "MySQL",
+		schemaDrifts("go-mysql-server", reference, migrated, false),
+		schemaDrifts("go-mysql-server memory", reference, memorySchema(t, memoryDB), true),
+		modelDrifts(reference),
+	)
+}
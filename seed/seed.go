@@ -0,0 +1,176 @@
+// Package seed generates realistic users deterministically, e.g. for load tests.
+// The same seed value always generates the same users.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User has the fields of gosqltests.User except timestamps, which are set by the database when it is inserted.
+// NOTE: this package does not import gosqltests so that tests of gosqltests can use it
+type User struct {
+	ID   string
+	Name string
+	// Age is always generated.
+	Age *int
+	// Email is not generated (users without email are inserted as NULL).
+	Email string
+}
+
+var (
+	firstNames = []string{
+		"Mike", "Bob", "Mary", "Alice", "John", "Emma", "James", "Olivia", "Robert", "Sophia",
+		"David", "Mia", "Daniel", "Chloe", "Kenji", "Yuki", "Hiroshi", "Sakura", "Carlos", "Lucia",
+	}
+	lastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Wilson", "Taylor",
+		"Tanaka", "Suzuki", "Sato", "Kim", "Lee", "Martin", "Lopez", "Clark", "Lewis", "Walker",
+	}
+)
+
+// age distribution of generated users
+const (
+	meanAge   = 35
+	stddevAge = 12
+	minAge    = 13
+	maxAge    = 90
+)
+
+// baseTime is the timestamp of the first ULID. Each user is 1 ms later than the previous one,
+// so that ids are ordered by generation.
+var baseTime = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type generator struct {
+	mu    sync.Mutex
+	rand  *rand.Rand
+	count int64
+	names map[string]struct{}
+}
+
+// NewGenerator returns a generator which is safe for concurrent use.
+// NOTE: users generated concurrently are the same set, but which goroutine gets which user is not deterministic
+func NewGenerator(seed int64) *generator {
+	return NewGeneratorFromRand(rand.New(rand.NewSource(seed)))
+}
+
+// NewGeneratorFromRand returns a generator using r, e.g. the one shared by a test.
+// r must not be used concurrently outside of the generator.
+func NewGeneratorFromRand(r *rand.Rand) *generator {
+	return &generator{
+		rand:  r,
+		names: map[string]struct{}{},
+	}
+}
+
+// User generates a user whose id and name are unique in the generator.
+func (g *generator) User() *User {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.ulid(baseTime.Add(time.Duration(g.count) * time.Millisecond))
+	g.count++
+
+	age := g.age()
+	return &User{
+		ID:   id,
+		Name: g.name(),
+		Age:  &age,
+	}
+}
+
+// Users generates n users.
+func (g *generator) Users(n int) []*User {
+	users := make([]*User, n)
+	for i := range users {
+		users[i] = g.User()
+	}
+	return users
+}
+
+// NOTE: names are unique because user.name has a unique key
+func (g *generator) name() string {
+	base := firstNames[g.rand.Intn(len(firstNames))] + " " + lastNames[g.rand.Intn(len(lastNames))]
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := g.names[name]; !ok {
+			break
+		}
+		name = fmt.Sprintf("%s %d", base, i)
+	}
+	g.names[name] = struct{}{}
+	return name
+}
+
+func (g *generator) age() int {
+	age := int(math.Round(g.rand.NormFloat64()*stddevAge + meanAge))
+	if age < minAge {
+		return minAge
+	}
+	if age > maxAge {
+		return maxAge
+	}
+	return age
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulid encodes 48 bit timestamp in ms and 80 bit randomness as 26 characters of Crockford's base32.
+func (g *generator) ulid(t time.Time) string {
+	var b [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (8 * (5 - i)))
+	}
+	g.rand.Read(b[6:])
+
+	// NOTE: 26 characters have 130 bits, so the first character has only 3 bits
+	var sb strings.Builder
+	for i := 0; i < 26; i++ {
+		shift := 125 - 5*i
+		var v byte
+		for j := 0; j < 5; j++ {
+			bit := shift + 4 - j
+			if bit > 127 {
+				continue
+			}
+			v = v<<1 | (b[15-bit/8]>>(bit%8))&1
+		}
+		sb.WriteByte(crockford[v])
+	}
+	return sb.String()
+}
+
+// insertChunkSize is the number of rows inserted by one statement.
+const insertChunkSize = 1000
+
+// Insert bulk-inserts users into db, which can be any MySQL compatible backend.
+// NOTE: users are inserted by raw SQL instead of the repository, so that seeding does not depend on the code under test
+func Insert(ctx context.Context, db *sql.DB, users []*User) error {
+	for start := 0; start < len(users); start += insertChunkSize {
+		end := start + insertChunkSize
+		if end > len(users) {
+			end = len(users)
+		}
+		chunk := users[start:end]
+
+		rows := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*4)
+		for _, u := range chunk {
+			rows = append(rows, "(?,?,?,?)")
+			args = append(args, u.ID, u.Name, u.Age, sql.NullString{String: u.Email, Valid: u.Email != ""})
+		}
+
+		query := "INSERT INTO `user` (`id`,`name`,`age`,`email`) VALUES " + strings.Join(rows, ",")
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert users [%d, %d): %w", start, end, err)
+		}
+	}
+	return nil
+}
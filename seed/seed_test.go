@@ -0,0 +1,62 @@
+package seed
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	require.Equal(t, NewGenerator(1).Users(100), NewGenerator(1).Users(100))
+	require.NotEqual(t, NewGenerator(1).Users(100), NewGenerator(2).Users(100))
+	require.Equal(t, NewGenerator(1).Users(100), NewGeneratorFromRand(rand.New(rand.NewSource(1))).Users(100))
+}
+
+func TestGeneratorUsers(t *testing.T) {
+	users := NewGenerator(1).Users(1000)
+
+	ids := map[string]struct{}{}
+	names := map[string]struct{}{}
+	for _, u := range users {
+		require.Regexp(t, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, u.ID)
+		require.LessOrEqual(t, len(u.Name), 40)
+		require.NotNil(t, u.Age)
+		require.GreaterOrEqual(t, *u.Age, minAge)
+		require.LessOrEqual(t, *u.Age, maxAge)
+		ids[u.ID] = struct{}{}
+		names[u.Name] = struct{}{}
+	}
+	require.Len(t, ids, len(users))
+	require.Len(t, names, len(users))
+
+	// ids are ordered by generation
+	require.True(t, sort.SliceIsSorted(users, func(i, j int) bool { return users[i].ID < users[j].ID }))
+	// NOTE: the timestamp part of 2022-01-01T00:00:00Z
+	require.Equal(t, "01FR9EZ700", users[0].ID[:10])
+}
+
+func TestInsertWithSQLMock(t *testing.T) {
+	users := NewGenerator(1).Users(insertChunkSize + 1)
+
+	// mock
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectExec(`^INSERT INTO .user. \(.id.,.name.,.age.,.email.\) VALUES (\(\?,\?,\?,\?\),){999}\(\?,\?,\?,\?\)$`).
+		WillReturnResult(sqlmock.NewResult(0, insertChunkSize))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`email`) VALUES (?,?,?,?)")).
+		WithArgs(users[insertChunkSize].ID, users[insertChunkSize].Name, users[insertChunkSize].Age, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// run
+	err = Insert(context.TODO(), db, users)
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
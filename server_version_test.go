@@ -0,0 +1,195 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// serverVersion is the flavor and the version of the MySQL-compatible server.
+type serverVersion struct {
+	mariaDB             bool
+	major, minor, patch int
+}
+
+func (v serverVersion) String() string {
+	flavor := "MySQL"
+	if v.mariaDB {
+		flavor = "MariaDB"
+	}
+	return fmt.Sprintf("%s %d.%d.%d", flavor, v.major, v.minor, v.patch)
+}
+
+// atLeast reports whether v is the same as or later than min of the same flavor.
+func (v serverVersion) atLeast(min serverVersion) bool {
+	if v.major != min.major {
+		return v.major > min.major
+	}
+	if v.minor != min.minor {
+		return v.minor > min.minor
+	}
+	return v.patch >= min.patch
+}
+
+var serverVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+// parseServerVersion parses the result of VERSION(), e.g. "8.0.32", "5.7.41-log" or "10.11.2-MariaDB-1:10.11.2+maria~ubu2204".
+func parseServerVersion(s string) (serverVersion, error) {
+	m := serverVersionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return serverVersion{}, fmt.Errorf("unknown server version: %q", s)
+	}
+	// NOTE: the pattern guarantees digits
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return serverVersion{
+		mariaDB: strings.Contains(s, "MariaDB"),
+		major:   major,
+		minor:   minor,
+		patch:   patch,
+	}, nil
+}
+
+func queryServerVersion(ctx context.Context, db *sql.DB) (serverVersion, error) {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return serverVersion{}, fmt.Errorf("failed to get server version: %w", err)
+	}
+	return parseServerVersion(version)
+}
+
+// serverFeature is SQL whose support differs among servers which the suite runs on.
+// Tests using it are skipped on the other servers, so that known differences are not reported as failures.
+type serverFeature struct {
+	name string
+	// minimum versions supporting the feature ("" if no version of the flavor supports it)
+	minMySQL   string
+	minMariaDB string
+}
+
+var (
+	// required by the unique keys of utf8mb4 VARCHAR(254) and VARCHAR(255) columns created by migrations
+	featureLargeIndexPrefix = serverFeature{name: "index keys up to 3072 bytes", minMySQL: "5.7.7", minMariaDB: "10.2.2"}
+	// used by the online schema change test
+	featureInPlaceVarcharExtension = serverFeature{name: "extending VARCHAR in place", minMySQL: "5.7.0", minMariaDB: "10.2.2"}
+	// used by the replica lag test (MariaDB has CHANGE MASTER TO instead)
+	featureReplicationSource = serverFeature{name: "CHANGE REPLICATION SOURCE TO", minMySQL: "8.0.23"}
+)
+
+// supports reports whether the server of the version supports f.
+func (v serverVersion) supports(f serverFeature) bool {
+	min := f.minMySQL
+	if v.mariaDB {
+		min = f.minMariaDB
+	}
+	if min == "" {
+		return false
+	}
+	minVersion, err := parseServerVersion(min)
+	if err != nil {
+		panic(err)
+	}
+	return v.atLeast(minVersion)
+}
+
+// unsupportedReason returns why the test is skipped if the server of db does not support f.
+func unsupportedReason(ctx context.Context, db *sql.DB, f serverFeature) (string, bool, error) {
+	v, err := queryServerVersion(ctx, db)
+	if err != nil {
+		return "", false, err
+	}
+	if v.supports(f) {
+		return "", false, nil
+	}
+	return fmt.Sprintf("skipped because %s does not support %s", v, f.name), true, nil
+}
+
+// skipUnlessSupported skips the test if the server of db does not support f.
+func skipUnlessSupported(ctx context.Context, t testing.TB, db *sql.DB, f serverFeature) {
+	t.Helper()
+
+	reason, unsupported, err := unsupportedReason(ctx, db, f)
+	require.NoError(t, err)
+	if unsupported {
+		t.Skip(reason)
+	}
+}
+
+// skipUnlessMigratable skips the test if the migrations cannot run on the server of db.
+func skipUnlessMigratable(ctx context.Context, t testing.TB, db *sql.DB) {
+	t.Helper()
+
+	reason, unsupported, err := unsupportedReason(ctx, db, featureLargeIndexPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unsupported {
+		t.Skip(reason)
+	}
+}
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		title       string
+		version     string
+		expected    serverVersion
+		expectedErr string
+	}{
+		{"mysql 8", "8.0.32", serverVersion{major: 8, minor: 0, patch: 32}, ""},
+		{"mysql 5.7 with suffix", "5.7.41-log", serverVersion{major: 5, minor: 7, patch: 41}, ""},
+		{"mariadb", "10.11.2-MariaDB-1:10.11.2+maria~ubu2204", serverVersion{mariaDB: true, major: 10, minor: 11, patch: 2}, ""},
+		{"unknown", "unknown", serverVersion{}, `unknown server version: "unknown"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual, err := parseServerVersion(tt.version)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestServerVersionSupports(t *testing.T) {
+	tests := []struct {
+		title    string
+		version  string
+		feature  serverFeature
+		expected bool
+	}{
+		{"mysql 8", "8.0.32", featureLargeIndexPrefix, true},
+		{"mysql 5.7", "5.7.41", featureLargeIndexPrefix, true},
+		{"older mysql 5.7", "5.7.6", featureLargeIndexPrefix, false},
+		{"mariadb", "10.11.2-MariaDB", featureLargeIndexPrefix, true},
+		{"older mariadb", "10.1.48-MariaDB", featureLargeIndexPrefix, false},
+		{"mariadb is not compared with mysql versions", "10.2.1-MariaDB", featureInPlaceVarcharExtension, false},
+		{"unsupported by any version of the flavor", "10.11.2-MariaDB", serverFeature{name: "mysql only", minMySQL: "8.0.0"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			v, err := parseServerVersion(tt.version)
+			require.NoError(t, err)
+
+			// run
+			actual := v.supports(tt.feature)
+
+			// assert
+			require.Equal(t, tt.expected, actual, "%s supports %s", v, tt.feature.name)
+		})
+	}
+}
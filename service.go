@@ -0,0 +1,86 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// age range accepted by UserService
+const (
+	minUserAge = 13
+	maxUserAge = 150
+)
+
+var (
+	ErrNameTaken     = errors.New("name is already taken")
+	ErrAgeRestricted = errors.New("age is not allowed")
+)
+
+//go:generate go run github.com/golang/mock/mockgen@v1.6.0 -source=service.go -destination=mocks/mock_service.go -package=mocks
+
+type UserRepository interface {
+	Register(ctx context.Context, user *User) error
+	List(ctx context.Context, query *ListQuery) ([]*User, int64, error)
+	Get(ctx context.Context, id string) (*User, error)
+	GetByName(ctx context.Context, name string) (*User, error)
+	Delete(ctx context.Context, user *User) error
+}
+
+var _ UserRepository = (*userRepository)(nil)
+
+// WelcomeEvent is published after a user is registered.
+type WelcomeEvent struct {
+	UserID string
+	Name   string
+}
+
+type EventPublisher interface {
+	Publish(ctx context.Context, event *WelcomeEvent) error
+}
+
+type userService struct {
+	repo      UserRepository
+	publisher EventPublisher
+}
+
+func NewUserService(repo UserRepository, publisher EventPublisher) *userService {
+	return &userService{
+		repo:      repo,
+		publisher: publisher,
+	}
+}
+
+func (s *userService) Register(ctx context.Context, user *User) error {
+	// NOTE: users without age are allowed, as the age is optional
+	if user.Age != nil && (*user.Age < minUserAge || *user.Age > maxUserAge) {
+		return fmt.Errorf("failed to register user (age: %d): %w", *user.Age, ErrAgeRestricted)
+	}
+
+	_, err := s.repo.GetByName(ctx, user.Name)
+	if err == nil {
+		return fmt.Errorf("failed to register user (name: %s): %w", user.Name, ErrNameTaken)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check user name: %w", err)
+	}
+
+	if err := s.repo.Register(ctx, user); err != nil {
+		return err
+	}
+
+	event := &WelcomeEvent{
+		UserID: user.ID,
+		Name:   user.Name,
+	}
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish welcome event: %w", err)
+	}
+
+	return nil
+}
+
+func (s *userService) Get(ctx context.Context, id string) (*User, error) {
+	return s.repo.Get(ctx, id)
+}
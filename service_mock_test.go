@@ -0,0 +1,66 @@
+package gosqltests_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests"
+	"github.com/syuparn/gosqltests/mocks"
+)
+
+// test using generated mocks (run `go generate ./...` after changing the interfaces)
+func TestServiceRegisterWithGeneratedMock(t *testing.T) {
+	mike := &gosqltests.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	tests := []struct {
+		title       string
+		mock        func(*mocks.MockUserRepository, *mocks.MockEventPublisher)
+		expectedErr error
+	}{
+		{
+			"register a user",
+			func(repo *mocks.MockUserRepository, publisher *mocks.MockEventPublisher) {
+				gomock.InOrder(
+					repo.EXPECT().GetByName(gomock.Any(), "Mike").Return(nil, sql.ErrNoRows),
+					repo.EXPECT().Register(gomock.Any(), mike).Return(nil),
+					publisher.EXPECT().Publish(gomock.Any(), &gosqltests.WelcomeEvent{UserID: mike.ID, Name: mike.Name}).Return(nil),
+				)
+			},
+			nil,
+		},
+		{
+			"name is taken",
+			func(repo *mocks.MockUserRepository, publisher *mocks.MockEventPublisher) {
+				repo.EXPECT().GetByName(gomock.Any(), "Mike").Return(&gosqltests.User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(25)}, nil)
+			},
+			gosqltests.ErrNameTaken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mocks.NewMockUserRepository(ctrl)
+			publisher := mocks.NewMockEventPublisher(ctrl)
+			tt.mock(repo, publisher)
+
+			// run
+			s := gosqltests.NewUserService(repo, publisher)
+			err := s.Register(context.TODO(), mike)
+
+			// assert
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/memory"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// NOTE: business rules do not depend on SQL at all, so a mocked repository is enough
+// to cover every branch quickly. Real backends are used only to check that the rules
+// still hold when wired to the actual repository.
+
+type mockUserRepository struct {
+	users      map[string]*User
+	registered []*User
+	err        error
+}
+
+func (m *mockUserRepository) Register(ctx context.Context, user *User) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.registered = append(m.registered, user)
+	return nil
+}
+
+func (m *mockUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	return nil, 0, m.err
+}
+
+func (m *mockUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if u, ok := m.users[name]; ok {
+		return u, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockUserRepository) Delete(ctx context.Context, user *User) error {
+	return m.err
+}
+
+type recordingPublisher struct {
+	events []*WelcomeEvent
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *WelcomeEvent) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// test using mocked repository
+func TestServiceRegisterWithMockRepository(t *testing.T) {
+	tests := []struct {
+		title          string
+		user           *User
+		expectedEvents []*WelcomeEvent
+	}{
+		{
+			"register a user",
+			&User{
+				ID:   "0123456789ABCDEFGHJKMNPQRS",
+				Name: "Mike",
+				Age:  lo.ToPtr(20),
+			},
+			[]*WelcomeEvent{
+				{UserID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"},
+			},
+		},
+		{
+			"youngest allowed age",
+			&User{
+				ID:   "0123456789ABCDEFGHJKMNPQRS",
+				Name: "Mike",
+				Age:  lo.ToPtr(13),
+			},
+			[]*WelcomeEvent{
+				{UserID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"},
+			},
+		},
+		{
+			"age is unknown",
+			&User{
+				ID:   "0123456789ABCDEFGHJKMNPQRS",
+				Name: "Mike",
+			},
+			[]*WelcomeEvent{
+				{UserID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			repo := &mockUserRepository{}
+			publisher := &recordingPublisher{}
+
+			// run
+			s := NewUserService(repo, publisher)
+			err := s.Register(context.TODO(), tt.user)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, []*User{tt.user}, repo.registered)
+			require.Equal(t, tt.expectedEvents, publisher.events)
+		})
+	}
+}
+
+func TestServiceRegisterErrorWithMockRepository(t *testing.T) {
+	tests := []struct {
+		title        string
+		user         *User
+		users        map[string]*User
+		repoErr      error
+		publisherErr error
+		expectedErr  string
+	}{
+		{
+			"name is taken",
+			NewUserBuilder().WithName("Mike").Build(),
+			map[string]*User{
+				"Mike": NewUserBuilder().WithName("Mike").Build(),
+			},
+			nil,
+			nil,
+			"failed to register user (name: Mike): name is already taken",
+		},
+		{
+			"too young",
+			NewUserBuilder().WithAge(12).Build(),
+			nil,
+			nil,
+			nil,
+			"failed to register user (age: 12): age is not allowed",
+		},
+		{
+			"too old",
+			NewUserBuilder().WithAge(151).Build(),
+			nil,
+			nil,
+			nil,
+			"failed to register user (age: 151): age is not allowed",
+		},
+		{
+			"repository error",
+			NewUserBuilder().Build(),
+			nil,
+			fmt.Errorf("crashed unexpectedly!!!"),
+			nil,
+			"failed to check user name: crashed unexpectedly!!!",
+		},
+		{
+			"publisher error",
+			NewUserBuilder().Build(),
+			nil,
+			nil,
+			fmt.Errorf("broker is down"),
+			"failed to publish welcome event: broker is down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			repo := &mockUserRepository{users: tt.users, err: tt.repoErr}
+			publisher := &recordingPublisher{err: tt.publisherErr}
+
+			// run
+			s := NewUserService(repo, publisher)
+			err := s.Register(context.TODO(), tt.user)
+
+			// assert
+			require.Error(t, err)
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestServiceRegisterWithGoMySQLServer(t *testing.T) {
+	tests := []struct {
+		title       string
+		user        *User
+		prepare     func(*simsql.Context, *memory.Table)
+		expectedErr error
+	}{
+		{
+			"register a user",
+			&User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+			func(ctx *simsql.Context, table *memory.Table) {
+				_ = table.Insert(ctx, simsql.NewRow(
+					"0123456789ABCDEFGHJKMNPQRS",
+					"Mike",
+					int32(20),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
+				))
+			},
+			nil,
+		},
+		{
+			"name is taken",
+			&User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(25)},
+			func(ctx *simsql.Context, table *memory.Table) {
+				_ = table.Insert(ctx, simsql.NewRow(
+					"0123456789ABCDEFGHJKMNPQRS",
+					"Mike",
+					int32(20),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
+				))
+			},
+			ErrNameTaken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			table := prepareSimulator(t, 23306)
+			tt.prepare(simsql.NewEmptyContext(), table)
+
+			// run
+			db, err := NewStrictClient(23306)
+			require.NoError(t, err)
+			publisher := &recordingPublisher{}
+			s := NewUserService(NewUserRepository(db), publisher)
+			err = s.Register(context.TODO(), tt.user)
+
+			// assert
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				require.Empty(t, publisher.events)
+				return
+			}
+			require.NoError(t, err)
+			AssertPersisted(t, context.TODO(), db, tt.user)
+			found, err := s.Get(context.TODO(), tt.user.ID)
+			require.NoError(t, err)
+			require.Equal(t, tt.user, found)
+			require.Len(t, publisher.events, 1)
+		})
+	}
+}
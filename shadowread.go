@@ -0,0 +1,115 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// default timeout of a shadow read, which is not bound to the caller's context
+const defaultShadowReadTimeout = 5 * time.Second
+
+// shadowReadUserRepository runs reads against both the primary and the shadow repository
+// and returns the primary result. The shadow is read asynchronously so that it does not add latency.
+// Writes only go to the primary.
+type shadowReadUserRepository struct {
+	UserRepository
+	shadow   UserRepository
+	recorder DivergenceRecorder
+	timeout  time.Duration
+	wg       sync.WaitGroup
+}
+
+func NewShadowReadUserRepository(primary, shadow UserRepository, recorder DivergenceRecorder) *shadowReadUserRepository {
+	return &shadowReadUserRepository{
+		UserRepository: primary,
+		shadow:         shadow,
+		recorder:       recorder,
+		timeout:        defaultShadowReadTimeout,
+	}
+}
+
+var _ UserRepository = (*shadowReadUserRepository)(nil)
+
+func (r *shadowReadUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	user, err := r.UserRepository.Get(ctx, id)
+	r.shadowRead(ctx, fmt.Sprintf("Get(%s)", id), copyUser(user), err, func(ctx context.Context) (interface{}, error) {
+		return r.shadow.Get(ctx, id)
+	})
+
+	return user, err
+}
+
+func (r *shadowReadUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	user, err := r.UserRepository.GetByName(ctx, name)
+	r.shadowRead(ctx, fmt.Sprintf("GetByName(%s)", name), copyUser(user), err, func(ctx context.Context) (interface{}, error) {
+		return r.shadow.GetByName(ctx, name)
+	})
+
+	return user, err
+}
+
+func (r *shadowReadUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	users, total, err := r.UserRepository.List(ctx, query)
+	r.shadowRead(ctx, "List", &listResult{Users: copyUsers(users), Total: total}, err, func(ctx context.Context) (interface{}, error) {
+		shadowUsers, shadowTotal, err := r.shadow.List(ctx, query)
+		return &listResult{Users: shadowUsers, Total: shadowTotal}, err
+	})
+
+	return users, total, err
+}
+
+// Wait blocks until all pending shadow reads are compared.
+func (r *shadowReadUserRepository) Wait() {
+	r.wg.Wait()
+}
+
+// copyUsers copies users as copyUser, keeping nil as nil so that it equals an empty result of the shadow.
+func copyUsers(users []*User) []*User {
+	if users == nil {
+		return nil
+	}
+	return lo.Map(users, func(u *User, _ int) *User { return copyUser(u) })
+}
+
+// shadowRead compares primary with the result of read in the background.
+// read gets the values of ctx (e.g. the tenant of WithTenant), but neither its deadline nor its cancellation.
+// NOTE: primary must be a copy of the result returned to the caller, who may modify it during the comparison
+func (r *shadowReadUserRepository) shadowRead(
+	ctx context.Context,
+	operation string,
+	primary interface{},
+	primaryErr error,
+	read func(context.Context) (interface{}, error),
+) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		// NOTE: the caller's context may be cancelled as soon as the primary result is returned
+		ctx, cancel := context.WithTimeout(withoutCancel(ctx), r.timeout)
+		defer cancel()
+
+		shadow, shadowErr := read(ctx)
+		compareResults(ctx, r.recorder, operation, primary, primaryErr, shadow, shadowErr)
+	}()
+}
+
+// detachedContext has the values of its parent, but is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// withoutCancel returns a context which is not cancelled when ctx is.
+// NOTE: it is context.WithoutCancel, which requires Go 1.21
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
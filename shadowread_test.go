@@ -0,0 +1,212 @@
+package gosqltests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dolthub/go-mysql-server/memory"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// blockingUserRepository blocks reads until release is closed.
+type blockingUserRepository struct {
+	mockUserRepository
+	release chan struct{}
+}
+
+func (b *blockingUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	<-b.release
+	return b.mockUserRepository.Get(ctx, id)
+}
+
+// test using mocked repository
+func TestShadowReadDoesNotBlockPrimary(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// mock
+	primary := &mockUserRepository{users: map[string]*User{"Mike": mike}}
+	shadow := &blockingUserRepository{
+		mockUserRepository: mockUserRepository{users: map[string]*User{}},
+		release:            make(chan struct{}),
+	}
+
+	// run
+	recorder := &recordingDivergenceRecorder{}
+	r := NewShadowReadUserRepository(primary, shadow, recorder)
+	user, err := r.Get(context.TODO(), mike.ID)
+
+	// assert
+	// NOTE: the primary result is returned while the shadow read is still blocked
+	require.NoError(t, err)
+	require.Equal(t, mike, user)
+	require.Empty(t, recorder.Divergences())
+
+	close(shadow.release)
+	r.Wait()
+	divergences := recorder.Divergences()
+	require.Len(t, divergences, 1)
+	require.Equal(t, "Get(0123456789ABCDEFGHJKMNPQRS)", divergences[0].Operation)
+	require.Equal(t, mike, divergences[0].Primary)
+	require.Nil(t, divergences[0].Secondary)
+}
+
+// test using mocked repository (run with -race)
+func TestShadowReadResultCanBeModified(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// mock
+	primary := &mockUserRepository{users: map[string]*User{"Mike": copyUser(mike)}}
+	shadow := &blockingUserRepository{
+		mockUserRepository: mockUserRepository{users: map[string]*User{"Mike": copyUser(mike)}},
+		release:            make(chan struct{}),
+	}
+
+	// run
+	recorder := &recordingDivergenceRecorder{}
+	r := NewShadowReadUserRepository(primary, shadow, recorder)
+	user, err := r.Get(context.TODO(), mike.ID)
+	require.NoError(t, err)
+
+	// NOTE: the caller modifies the result while it is compared with the shadow
+	close(shadow.release)
+	user.Name = "Michael"
+	*user.Age = 21
+	r.Wait()
+
+	// assert
+	require.Empty(t, recorder.Divergences())
+}
+
+// test using two go-mysql-server instances
+func TestShadowReadWithGoMySQLServer(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	tests := []struct {
+		title               string
+		prepareShadow       func(*simsql.Context, *memory.Table)
+		run                 func(context.Context, UserRepository) (interface{}, error)
+		expected            interface{}
+		expectedDivergences []*Divergence
+	}{
+		{
+			"same rows",
+			func(ctx *simsql.Context, table *memory.Table) {
+				_ = table.Insert(ctx, simsql.NewRow("0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20), nil, nil, int64(1), seededAt, seededAt))
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.Get(ctx, mike.ID)
+			},
+			mike,
+			[]*Divergence{},
+		},
+		{
+			"row is missing in shadow",
+			func(ctx *simsql.Context, table *memory.Table) {},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.Get(ctx, mike.ID)
+			},
+			mike,
+			[]*Divergence{
+				{Operation: "Get(0123456789ABCDEFGHJKMNPQRS)", Primary: mike},
+			},
+		},
+		{
+			"list results are different",
+			func(ctx *simsql.Context, table *memory.Table) {
+				_ = table.Insert(ctx, simsql.NewRow("0123456789ABCDEFGHJKMNPQRS", "Mike", int32(21), nil, nil, int64(1), seededAt, seededAt))
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				users, _, err := r.List(ctx, nil)
+				return users, err
+			},
+			[]*User{mike},
+			[]*Divergence{
+				{
+					Operation: "List",
+					Primary:   &listResult{Users: []*User{mike}, Total: 1},
+					Secondary: &listResult{Users: []*User{{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(21), CreatedAt: seededAt, UpdatedAt: seededAt}}, Total: 1},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			primaryPort, err := testport.Reserve()
+			require.NoError(t, err)
+			prepareSimulator(t, primaryPort)
+			shadowPort, err := testport.Reserve()
+			require.NoError(t, err)
+			shadowTable := prepareSimulator(t, shadowPort)
+			tt.prepareShadow(simsql.NewEmptyContext(), shadowTable)
+
+			primaryDB, err := NewStrictClient(primaryPort)
+			require.NoError(t, err)
+			shadowDB, err := NewStrictClient(shadowPort)
+			require.NoError(t, err)
+			// NOTE: the fake clock stores the same timestamps as the rows of the shadow
+			primary := NewUserRepository(primaryDB, WithClock(newFakeClock()))
+			require.NoError(t, primary.Register(context.TODO(), mike))
+
+			// run
+			recorder := &recordingDivergenceRecorder{}
+			r := NewShadowReadUserRepository(primary, NewUserRepository(shadowDB), recorder)
+			actual, err := tt.run(context.TODO(), r)
+			r.Wait()
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.expectedDivergences, recorder.Divergences())
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestShadowReadWithTenantWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// simulator
+	// NOTE: foreign key checks are disabled to insert user_tenant (see newMigrationClient)
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	acme := WithTenant(ctx, "acme")
+	require.NoError(t, NewTenantUserRepository(TenantColumn(db)).Register(acme, mike))
+
+	// run
+	recorder := &recordingDivergenceRecorder{}
+	r := NewShadowReadUserRepository(NewTenantUserRepository(TenantColumn(db)), NewTenantUserRepository(TenantColumn(db)), recorder)
+	callerCtx, cancel := context.WithCancel(acme)
+	user, err := r.Get(callerCtx, mike.ID)
+	// NOTE: the shadow read must neither lose the tenant nor be cancelled with the caller's context
+	cancel()
+	r.Wait()
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, mike.ID, user.ID)
+	require.Empty(t, recorder.Divergences())
+}
+
+func TestWithoutCancel(t *testing.T) {
+	parent, cancel := context.WithTimeout(WithTenant(context.Background(), "acme"), time.Hour)
+	ctx := withoutCancel(parent)
+	cancel()
+
+	tenant, err := TenantFromContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "acme", tenant)
+	require.NoError(t, ctx.Err())
+	require.Nil(t, ctx.Done())
+	_, ok := ctx.Deadline()
+	require.False(t, ok)
+}
@@ -0,0 +1,85 @@
+// Package simulator serves an in-memory database by go-mysql-server, which MySQL clients connect to as the user root without password.
+// Go tests and `gosqltests serve` share it so that non-Go clients see the same simulated database.
+package simulator
+
+import (
+	"fmt"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/information_schema"
+)
+
+// Database is the name of the database served, which docker-compose.yml also creates.
+const Database = "practice"
+
+// NewDatabase returns an empty database to serve, whose tables are created by migrations.
+func NewDatabase() *memory.Database {
+	return memory.NewDatabase(Database)
+}
+
+// New returns a server of db listening on address (e.g. "localhost:3306"), which starts by Start.
+func New(address string, db *memory.Database) (*server.Server, error) {
+	// NOTE: the mutable provider accepts CREATE DATABASE, e.g. for namespaced databases
+	return newServer(address, memory.NewMemoryDBProvider(
+		db,
+		information_schema.NewInformationSchemaDatabase(),
+	))
+}
+
+func newServer(address string, provider sql.MutableDatabaseProvider) (*server.Server, error) {
+	engine := sqle.NewDefault(provider)
+	engine.Analyzer.Catalog.MySQLDb.AddSuperUser("root", "localhost", "")
+	// NOTE: clients in other hosts (e.g. containers) connect to a server listening on 0.0.0.0
+	engine.Analyzer.Catalog.MySQLDb.AddSuperUser("root", "%", "")
+
+	config := server.Config{
+		Protocol: "tcp",
+		Address:  address,
+	}
+	return server.NewDefaultServer(config, engine)
+}
+
+// SharedServer is a server whose databases are created on demand, e.g. one database per test,
+// so that tests are isolated without starting a server (and listening on a port) each.
+type SharedServer struct {
+	*server.Server
+	provider sql.MutableDatabaseProvider
+}
+
+// NewShared returns a server without databases listening on address, which starts by Start.
+func NewShared(address string) (*SharedServer, error) {
+	provider := memory.NewMemoryDBProvider(information_schema.NewInformationSchemaDatabase())
+	s, err := newServer(address, provider)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedServer{Server: s, provider: provider}, nil
+}
+
+// CreateDatabase creates an empty database of the name, which is served until DropDatabase.
+// It is safe to call concurrently with different names.
+func (s *SharedServer) CreateDatabase(name string) (*memory.Database, error) {
+	ctx := sql.NewEmptyContext()
+	if s.provider.HasDatabase(ctx, name) {
+		return nil, fmt.Errorf("database %s already exists", name)
+	}
+	if err := s.provider.CreateDatabase(ctx, name); err != nil {
+		return nil, fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+	db, err := s.provider.Database(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+	return db.(*memory.Database), nil
+}
+
+// DropDatabase drops the database of the name and its tables.
+func (s *SharedServer) DropDatabase(name string) error {
+	if err := s.provider.DropDatabase(sql.NewEmptyContext(), name); err != nil {
+		return fmt.Errorf("failed to drop database %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// seedRows inserts rows into the table by INSERT statements, so that values are validated and converted
+// by the SQL layer as MySQL does. Each row has values in the order of tableColumnNames (as simsql.NewRow).
+// NOTE: prefer it to memory.Table.Insert, which bypasses the SQL layer; it panics on values not of the exact Go types
+// of the columns (e.g. int instead of int32) and its errors are easily ignored
+func seedRows(t testing.TB, db *sql.DB, table string, rows ...[]interface{}) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	columns, ok := tableColumnNames[table]
+	if !ok {
+		t.Fatalf("unknown table %s", table)
+	}
+	for i, values := range rows {
+		if len(values) != len(columns) {
+			t.Fatalf("row %d of %s must have %d values (got: %d)", i, table, len(columns), len(values))
+		}
+		row := map[string]interface{}{}
+		for j, c := range columns {
+			row[c] = values[j]
+		}
+		if err := insertFixtureRow(context.Background(), db, table, row); err != nil {
+			t.Fatalf("failed to seed row %d of %s: %s", i, table, err)
+		}
+	}
+}
+
+// test using go-mysql-server
+func TestSeedRowsWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	table := prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	t.Run("values are converted by the column types", func(t *testing.T) {
+		// run
+		// NOTE: the age is int (not int32 of the column) and the email is empty
+		seedRows(t, db, models.TableNames.User, []interface{}{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, seededAt, seededAt})
+
+		// assert
+		found, err := NewUserRepository(db).Get(context.Background(), "0123456789ABCDEFGHJKMNPQRS")
+		require.NoError(t, err)
+		require.Equal(t, "Mike", found.Name)
+		require.Equal(t, 20, *found.Age)
+	})
+
+	t.Run("invalid values are rejected by the SQL layer", func(t *testing.T) {
+		row := map[string]interface{}{
+			models.UserColumns.ID:   "1123456789ABCDEFGHJKMNPQRS",
+			models.UserColumns.Name: "Bob",
+			models.UserColumns.Age:  "twenty",
+		}
+
+		// run
+		err := insertFixtureRow(context.Background(), db, models.TableNames.User, row)
+
+		// assert
+		require.Error(t, err)
+		_, err = NewUserRepository(db).Get(context.Background(), "1123456789ABCDEFGHJKMNPQRS")
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("literals are converted as MySQL does", func(t *testing.T) {
+		deleted := []interface{}{"2123456789ABCDEFGHJKMNPQRS", "Mary", "30", "2023-01-01 00:00:00", nil, "1", "2022-12-01 00:00:00", "2023-01-01 00:00:00"}
+
+		// run
+		seedRows(t, db, models.TableNames.User, deleted)
+
+		// assert
+		_, err := NewUserRepository(db).Get(context.Background(), "2123456789ABCDEFGHJKMNPQRS")
+		require.ErrorIs(t, err, sql.ErrNoRows)
+		// NOTE: the memory table only accepts values of the exact Go types of the columns
+		require.Panics(t, func() {
+			_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow(deleted...))
+		})
+	})
+}
@@ -0,0 +1,139 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/simulator"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// sharedSimulator is one go-mysql-server serving a database of each test prepared by prepareSimulatorDatabase.
+// NOTE: it is started by TestMain if -db-backend=simulator (otherwise by the first test using it) and closed by TestMain
+var sharedSimulator struct {
+	once   sync.Once
+	err    error
+	server *simulator.SharedServer
+	port   int
+	// databases counts databases created, to name them uniquely
+	databases int64
+}
+
+func startSharedSimulator() (*simulator.SharedServer, int, error) {
+	sharedSimulator.once.Do(func() {
+		port, err := testport.Reserve()
+		if err != nil {
+			sharedSimulator.err = err
+			return
+		}
+		s, err := simulator.NewShared(fmt.Sprintf("localhost:%d", port))
+		if err != nil {
+			sharedSimulator.err = fmt.Errorf("failed to create shared simulator: %w", err)
+			return
+		}
+		go func() {
+			if err := s.Start(); err != nil {
+				panic(err)
+			}
+		}()
+		sharedSimulator.server, sharedSimulator.port = s, port
+	})
+	return sharedSimulator.server, sharedSimulator.port, sharedSimulator.err
+}
+
+// closeSharedSimulator closes the shared simulator if it has been started.
+func closeSharedSimulator() error {
+	if sharedSimulator.server == nil {
+		return nil
+	}
+	if err := sharedSimulator.server.Close(); err != nil {
+		return fmt.Errorf("failed to close shared simulator: %w", err)
+	}
+	return nil
+}
+
+// createSimulatorDatabase creates a database of the test with the tables of prepareSimulator on the shared simulator,
+// and returns the port of the simulator and the name of the database. The database is dropped when the test finishes.
+func createSimulatorDatabase(t testing.TB) (int, string, *memory.Table) {
+	t.Helper()
+
+	s, port, err := startSharedSimulator()
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("test_%d", atomic.AddInt64(&sharedSimulator.databases, 1))
+	database, err := s.CreateDatabase(name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, s.DropDatabase(name))
+	})
+	return port, name, addSimulatorTables(database)
+}
+
+// prepareSimulatorDatabase creates a database of the test by createSimulatorDatabase and returns a client of it.
+// Unlike prepareSimulator, no server is started for each test, so tests calling t.Parallel do not consume ports.
+func prepareSimulatorDatabase(t testing.TB) (*sql.DB, *memory.Table) {
+	t.Helper()
+
+	port, name, table := createSimulatorDatabase(t)
+	db, err := NewClientWithWait(context.Background(), &ClientConfig{Port: port, Database: name, StrictScan: true})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		closeTestClient(t, db)
+	})
+	return db, table
+}
+
+// test using go-mysql-server
+func TestPrepareSimulatorDatabaseWithGoMySQLServer(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", CreatedAt: seededAt, UpdatedAt: seededAt}
+
+	// NOTE: every test registers the same user, which conflicts unless each test has its own database
+	for i := 0; i < 10; i++ {
+		t.Run(fmt.Sprintf("test%d", i), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			// simulator
+			db, _ := prepareSimulatorDatabase(t)
+			r := NewUserRepository(db)
+
+			// run
+			err := r.Register(ctx, &User{ID: mike.ID, Name: mike.Name, CreatedAt: seededAt, UpdatedAt: seededAt})
+
+			// assert
+			require.NoError(t, err)
+			users, total, err := r.List(ctx, nil)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), total)
+			require.Equal(t, []*User{mike}, users)
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestPrepareSimulatorDatabaseDropsDatabaseWithGoMySQLServer(t *testing.T) {
+	var name string
+	t.Run("test", func(t *testing.T) {
+		db, _ := prepareSimulatorDatabase(t)
+		require.NoError(t, db.QueryRowContext(context.Background(), "SELECT DATABASE()").Scan(&name))
+	})
+
+	// assert
+	_, port, err := startSharedSimulator()
+	require.NoError(t, err)
+	// NOTE: the shared simulator has no practice database
+	db, err := newClient(port, "information_schema")
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	var count int
+	require.NoError(t, db.QueryRowContext(context.Background(),
+		"SELECT COUNT(*) FROM `information_schema`.`schemata` WHERE `schema_name` = ?", name).Scan(&count))
+	require.Equal(t, 0, count)
+}
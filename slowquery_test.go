@@ -0,0 +1,263 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// statements which MySQL can EXPLAIN
+var explainablePrefixes = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE"}
+
+var trackedQueries = struct {
+	mu sync.Mutex
+	// query logs and databases to EXPLAIN slow queries on by test names
+	logs     map[string]*queryLog
+	explains map[string]*sql.DB
+}{
+	logs:     map[string]*queryLog{},
+	explains: map[string]*sql.DB{},
+}
+
+// trackQueries returns a queryLog which AssertNoSlowQueries of the test checks. Pass it to newQueryLoggedClient.
+// Slow queries are explained on explain if it is not nil, which should be a client of MySQL (e.g. of prepareContainer)
+// because go-mysql-server does not plan queries as MySQL does.
+func trackQueries(t testing.TB, explain *sql.DB) *queryLog {
+	l := &queryLog{}
+	trackedQueries.mu.Lock()
+	defer trackedQueries.mu.Unlock()
+	trackedQueries.logs[t.Name()] = l
+	trackedQueries.explains[t.Name()] = explain
+
+	t.Cleanup(func() {
+		trackedQueries.mu.Lock()
+		defer trackedQueries.mu.Unlock()
+		delete(trackedQueries.logs, t.Name())
+		delete(trackedQueries.explains, t.Name())
+	})
+	return l
+}
+
+// AssertNoSlowQueries fails the test if any statement logged by trackQueries of the test took threshold or longer.
+// Call it at the end of a scenario, e.g. to catch full scans of queries which are fast only with a few rows.
+func AssertNoSlowQueries(t testing.TB, threshold time.Duration) {
+	t.Helper()
+
+	trackedQueries.mu.Lock()
+	l, ok := trackedQueries.logs[t.Name()]
+	explain := trackedQueries.explains[t.Name()]
+	trackedQueries.mu.Unlock()
+	if !ok {
+		t.Fatalf("queries of %s are not tracked: call trackQueries first", t.Name())
+	}
+
+	assertNoSlowQueries(t, l, threshold, explain)
+}
+
+func assertNoSlowQueries(t require.TestingT, l *queryLog, threshold time.Duration, explain *sql.DB) {
+	slow := l.slowQueries(threshold)
+	if len(slow) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d statements took %s or longer:", len(slow), threshold)
+	for _, q := range slow {
+		fmt.Fprintf(&b, "\n\t%s: %s", q.duration, l.format(q))
+		if explain == nil || !isExplainable(q.query) {
+			continue
+		}
+		plan, err := explainQuery(context.Background(), explain, q)
+		if err != nil {
+			fmt.Fprintf(&b, "\n\t\tfailed to explain: %s", err)
+			continue
+		}
+		for _, line := range plan {
+			fmt.Fprintf(&b, "\n\t\t%s", line)
+		}
+	}
+	require.Fail(t, b.String())
+}
+
+// slowQueries returns statements which took threshold or longer.
+func (l *queryLog) slowQueries(threshold time.Duration) []*loggedQuery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return lo.Filter(l.queries, func(q *loggedQuery, _ int) bool {
+		return q.duration >= threshold
+	})
+}
+
+func isExplainable(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	return lo.SomeBy(explainablePrefixes, func(prefix string) bool {
+		return strings.HasPrefix(query, prefix)
+	})
+}
+
+// explainQuery returns the header and the rows of EXPLAIN of the statement, whose columns are separated by " | ".
+func explainQuery(ctx context.Context, db *sql.DB, q *loggedQuery) ([]string, error) {
+	args := lo.Map(q.args, func(arg driver.NamedValue, _ int) interface{} { return arg.Value })
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+q.query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	lines := []string{strings.Join(columns, " | ")}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		if err := rows.Scan(lo.Map(values, func(_ sql.NullString, i int) interface{} { return &values[i] })...); err != nil {
+			return nil, err
+		}
+		lines = append(lines, strings.Join(lo.Map(values, func(v sql.NullString, _ int) string {
+			if !v.Valid {
+				return "NULL"
+			}
+			return v.String
+		}), " | "))
+	}
+	return lines, rows.Err()
+}
+
+// test using go-mysql-server
+func TestAssertNoSlowQueriesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	require.NoError(t, NewUserRepository(db).Register(ctx, mike))
+
+	// NOTE: go-mysql-server cannot prepare EXPLAIN with parameters, so they are interpolated by the client
+	cfg, err := mysql.ParseDSN(dsn(port, defaultDatabase))
+	require.NoError(t, err)
+	cfg.InterpolateParams = true
+	connector, err := mysql.NewConnector(cfg)
+	require.NoError(t, err)
+	explain := sql.OpenDB(connector)
+	defer closeTestClient(t, explain)
+
+	// NOTE: the latency of the proxy makes every statement slow
+	proxy := prepareChaosProxy(t, port)
+
+	tests := []struct {
+		title    string
+		latency  time.Duration
+		explain  *sql.DB
+		expected []string
+	}{
+		{
+			"no slow queries",
+			0,
+			nil,
+			nil,
+		},
+		{
+			"slow query",
+			100 * time.Millisecond,
+			nil,
+			[]string{"1 statements took 50ms or longer:", "SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?)"},
+		},
+		{
+			"slow query with EXPLAIN",
+			100 * time.Millisecond,
+			explain,
+			[]string{"1 statements took 50ms or longer:", "SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?)", "plan"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			l := &queryLog{}
+			client, err := newQueryLoggedClient(proxy.Port(), l)
+			require.NoError(t, err)
+			defer closeTestClient(t, client)
+			// NOTE: connect before the latency is set, so that only the query is delayed
+			require.NoError(t, client.PingContext(ctx))
+			proxy.SetLatency(tt.latency)
+			defer proxy.Reset()
+
+			_, err = NewUserRepository(client).Get(ctx, mike.ID)
+			require.NoError(t, err)
+
+			// run
+			rt := &recordingT{}
+			rt.run(func() { assertNoSlowQueries(rt, l, 50*time.Millisecond, tt.explain) })
+
+			// assert
+			require.Equal(t, tt.expected != nil, rt.failed, rt.errors)
+			for _, e := range tt.expected {
+				require.Contains(t, rt.errors[0], e)
+			}
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestAssertNoSlowQueriesOfTrackedQueriesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	client, err := newQueryLoggedClient(port, trackQueries(t, nil))
+	require.NoError(t, err)
+	defer closeTestClient(t, client)
+
+	_, _, err = NewUserRepository(client).List(ctx, nil)
+	require.NoError(t, err)
+
+	// assert
+	AssertNoSlowQueries(t, time.Second)
+}
+
+// test using testcontainers
+func TestAssertNoSlowQueriesWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	port := startContainer(ctx, t, withFastMySQL())
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, StrictScan: true})
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	require.NoError(t, Migrate(ctx, db))
+	require.NoError(t, NewUserRepository(db).RegisterAll(ctx, generateUsers(t, 1000)))
+
+	l := &queryLog{}
+	client, err := newQueryLoggedClient(port, l)
+	require.NoError(t, err)
+	defer closeTestClient(t, client)
+
+	// run
+	// NOTE: the threshold is 0 to report every statement, as no query of the repository is slow on 1000 rows
+	_, _, err = NewUserRepository(client).List(ctx, &ListQuery{NamePrefix: "M"})
+	require.NoError(t, err)
+	rt := &recordingT{}
+	rt.run(func() { assertNoSlowQueries(rt, l, 0, db) })
+
+	// assert
+	require.True(t, rt.failed)
+	// NOTE: EXPLAIN of MySQL shows the index used to filter names
+	require.Contains(t, rt.errors[0], "possible_keys")
+	require.Contains(t, rt.errors[0], "name")
+}
@@ -0,0 +1,525 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// NOTE: soak tests run until shortly before the timeout, so they are opt-in, e.g.
+// go test . -run Soak -soak -timeout 2h
+var soak = flag.Bool("soak", false, "run soak tests until shortly before -timeout")
+
+const (
+	// soakMargin is left before the deadline of the test to check the last invariants and tear down the container.
+	soakMargin = 2 * time.Minute
+	// soakDefaultDuration is used if tests have no deadline (-timeout 0).
+	soakDefaultDuration = time.Hour
+	// soakMaxHeapGrowth is how much the live heap may grow after the first round.
+	// NOTE: rows of each worker are bounded, so the heap should not grow during the soak
+	soakMaxHeapGrowth = 64 << 20
+)
+
+// soakDuration returns how long the soak runs before deadline of the test.
+func soakDuration(deadline time.Time, ok bool, now time.Time) time.Duration {
+	if !ok {
+		return soakDefaultDuration
+	}
+	remaining := deadline.Sub(now)
+	margin := soakMargin
+	if margin > remaining/10 {
+		margin = remaining / 10
+	}
+	return remaining - margin
+}
+
+type soakConfig struct {
+	workers int
+	// usersPerWorker bounds rows of each worker so that the data does not grow during the soak
+	usersPerWorker int
+	duration       time.Duration
+	// checkEvery is the interval between invariant checks, during which workers keep running operations
+	checkEvery time.Duration
+	ops        []*soakOp
+	// checkServer checks connections of the server as well (go-mysql-server does not have the status)
+	checkServer bool
+}
+
+// soakOp is an operation of the mix, which returns an error if the result does not match rows of the worker.
+// Operations which have no rows to act on do nothing.
+type soakOp struct {
+	name string
+	run  func(ctx context.Context, w *soakWorker) error
+}
+
+// soakOperations is the full operation mix. Each worker registers, reads, deletes and restores only its own users,
+// so that results are compared with the rows it knows.
+var soakOperations = []*soakOp{
+	{"register", func(ctx context.Context, w *soakWorker) error {
+		if w.full() {
+			return nil
+		}
+		u := w.newUser()
+		if err := w.users.Register(ctx, u.User); err != nil {
+			return err
+		}
+		w.owned = append(w.owned, u)
+		return nil
+	}},
+	{"register idempotent", func(ctx context.Context, w *soakWorker) error {
+		if w.full() {
+			return nil
+		}
+		u := w.newUser()
+		u.key = u.Name
+		if _, err := w.users.RegisterIdempotent(ctx, u.key, u.User); err != nil {
+			return err
+		}
+		w.owned = append(w.owned, u)
+		w.keys++
+
+		// replay of the key returns the registered user instead of registering another one
+		replayed, err := w.users.RegisterIdempotent(ctx, u.key, &User{ID: string(NewUserID()), Name: u.Name + "-replayed"})
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(replayed, u.User) {
+			return fmt.Errorf("replay of key %q returned %+v, expected %+v", u.key, replayed, u.User)
+		}
+		return nil
+	}},
+	{"get", func(ctx context.Context, w *soakWorker) error {
+		u, ok := w.pick(func(*soakUser) bool { return true })
+		if !ok {
+			return nil
+		}
+		found, err := w.users.Get(ctx, u.ID)
+		return u.expect(found, err)
+	}},
+	{"get by name", func(ctx context.Context, w *soakWorker) error {
+		u, ok := w.pick(func(*soakUser) bool { return true })
+		if !ok {
+			return nil
+		}
+		found, err := w.users.GetByName(ctx, u.Name)
+		return u.expect(found, err)
+	}},
+	{"list", func(ctx context.Context, w *soakWorker) error {
+		users, total, err := w.users.List(ctx, &ListQuery{NamePrefix: w.namePrefix(), Order: OrderByNameAsc})
+		if err != nil {
+			return err
+		}
+		active := 0
+		for _, u := range w.owned {
+			if !u.deleted {
+				active++
+			}
+		}
+		if total != int64(active) || len(users) != active {
+			return fmt.Errorf("listed %d users (total: %d), expected %d", len(users), total, active)
+		}
+		return nil
+	}},
+	{"delete", func(ctx context.Context, w *soakWorker) error {
+		u, ok := w.pick(func(u *soakUser) bool { return !u.deleted })
+		if !ok {
+			return nil
+		}
+		if err := w.users.Delete(ctx, u.User); err != nil {
+			return err
+		}
+		u.deleted = true
+		return nil
+	}},
+	{"restore", func(ctx context.Context, w *soakWorker) error {
+		u, ok := w.pick(func(u *soakUser) bool { return u.deleted })
+		if !ok {
+			return nil
+		}
+		if err := w.users.Restore(ctx, u.ID); err != nil {
+			return err
+		}
+		u.deleted = false
+		return nil
+	}},
+	{"hard delete", func(ctx context.Context, w *soakWorker) error {
+		u, ok := w.pick(func(*soakUser) bool { return true })
+		if !ok {
+			return nil
+		}
+		if err := w.users.HardDelete(ctx, u.User); err != nil {
+			return err
+		}
+		w.remove(u)
+		// NOTE: credentials are deleted by the foreign key, while idempotency keys remain without their users
+		if u.key != "" {
+			w.orphanedKeys++
+		}
+		return nil
+	}},
+	{"set password", func(ctx context.Context, w *soakWorker) error {
+		u, ok := w.pick(func(u *soakUser) bool { return !u.deleted })
+		if !ok {
+			return nil
+		}
+		password := fmt.Sprintf("p@ssw0rd-%d", w.rng.Int())
+		if err := w.credentials.SetPassword(ctx, u.ID, password); err != nil {
+			return err
+		}
+		u.hasPassword = true
+		return w.credentials.VerifyPassword(ctx, u.ID, password)
+	}},
+}
+
+type soakUser struct {
+	*User
+	deleted     bool
+	hasPassword bool
+	// key is the idempotency key which registered the user
+	key string
+}
+
+// expect checks the result of Get (or GetByName) of the user.
+func (u *soakUser) expect(found *User, err error) error {
+	if u.deleted {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("deleted user (id: %s) is found: %+v, %v", u.ID, found, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// NOTE: Restore bumps UpdatedAt, which the worker does not know
+	if found.UpdatedAt.Before(u.UpdatedAt) {
+		return fmt.Errorf("found user (id: %s) updated at %s, expected at or after %s", u.ID, found.UpdatedAt, u.UpdatedAt)
+	}
+	actual := *found
+	actual.UpdatedAt = u.UpdatedAt
+	if !reflect.DeepEqual(&actual, u.User) {
+		return fmt.Errorf("found %+v, expected %+v", found, u.User)
+	}
+	return nil
+}
+
+type soakWorker struct {
+	id          int
+	users       *userRepository
+	credentials *credentialRepository
+	rng         *rand.Rand
+	cfg         *soakConfig
+
+	seq   int
+	owned []*soakUser
+	// keys is the number of idempotency keys inserted by the worker
+	keys int
+	// orphanedKeys is the number of idempotency keys whose users were hard-deleted
+	orphanedKeys int
+	// done is the number of operations by names
+	done map[string]int
+}
+
+func newSoakWorker(id int, db *sql.DB, rng *rand.Rand, cfg *soakConfig) *soakWorker {
+	credentials := NewCredentialRepository(db)
+	// NOTE: the default cost takes most of the time of the soak
+	credentials.cost = bcrypt.MinCost
+	return &soakWorker{
+		id:          id,
+		users:       NewUserRepository(db),
+		credentials: credentials,
+		rng:         rng,
+		cfg:         cfg,
+		done:        map[string]int{},
+	}
+}
+
+func (w *soakWorker) namePrefix() string {
+	return fmt.Sprintf("soak-%d-", w.id)
+}
+
+func (w *soakWorker) newUser() *soakUser {
+	w.seq++
+	u := &User{
+		ID:   string(NewUserID()),
+		Name: fmt.Sprintf("%s%d", w.namePrefix(), w.seq),
+		Age:  lo.ToPtr(13 + w.rng.Intn(78)),
+	}
+	if w.rng.Intn(2) == 0 {
+		u.Email = u.Name + "@example.com"
+	}
+	return &soakUser{User: u}
+}
+
+func (w *soakWorker) full() bool {
+	return len(w.owned) >= w.cfg.usersPerWorker
+}
+
+// pick returns one of the users satisfying cond at random.
+func (w *soakWorker) pick(cond func(*soakUser) bool) (*soakUser, bool) {
+	var candidates []*soakUser
+	for _, u := range w.owned {
+		if cond(u) {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	return candidates[w.rng.Intn(len(candidates))], true
+}
+
+func (w *soakWorker) remove(u *soakUser) {
+	for i, o := range w.owned {
+		if o == u {
+			w.owned = append(w.owned[:i], w.owned[i+1:]...)
+			return
+		}
+	}
+}
+
+// runUntil runs random operations until deadline and returns the first error.
+func (w *soakWorker) runUntil(ctx context.Context, deadline time.Time) error {
+	for time.Now().Before(deadline) {
+		op := w.cfg.ops[w.rng.Intn(len(w.cfg.ops))]
+		if err := op.run(ctx, w); err != nil {
+			return fmt.Errorf("worker %d failed to %s: %w", w.id, op.name, err)
+		}
+		w.done[op.name]++
+	}
+	return nil
+}
+
+// soakCounts are row counts expected by the workers.
+type soakCounts struct {
+	active       int64
+	deleted      int64
+	credentials  int64
+	keys         int64
+	orphanedKeys int64
+}
+
+func expectedSoakCounts(workers []*soakWorker) soakCounts {
+	var c soakCounts
+	for _, w := range workers {
+		for _, u := range w.owned {
+			if u.deleted {
+				c.deleted++
+			} else {
+				c.active++
+			}
+			if u.hasPassword {
+				c.credentials++
+			}
+		}
+		c.keys += int64(w.keys)
+		c.orphanedKeys += int64(w.orphanedKeys)
+	}
+	return c
+}
+
+// checkSoakInvariants compares rows in db with expected, and checks referential integrity and connections.
+// It must be called while no operation is running.
+func checkSoakInvariants(ctx context.Context, db *sql.DB, expected soakCounts, checkServer bool) error {
+	var problems []string
+
+	counts := []struct {
+		name     string
+		query    string
+		expected int64
+	}{
+		{"active users", "SELECT COUNT(*) FROM `user` WHERE `deleted_at` IS NULL", expected.active},
+		{"deleted users", "SELECT COUNT(*) FROM `user` WHERE `deleted_at` IS NOT NULL", expected.deleted},
+		{"credentials", "SELECT COUNT(*) FROM `credential`", expected.credentials},
+		{"idempotency keys", "SELECT COUNT(*) FROM `idempotency_key`", expected.keys},
+		// referential integrity
+		{"credentials without users", "SELECT COUNT(*) FROM `credential` c LEFT JOIN `user` u ON c.`user_id` = u.`id` WHERE u.`id` IS NULL", 0},
+		{"idempotency keys without users", "SELECT COUNT(*) FROM `idempotency_key` k LEFT JOIN `user` u ON k.`user_id` = u.`id` WHERE u.`id` IS NULL", expected.orphanedKeys},
+	}
+	for _, c := range counts {
+		var n int64
+		if err := db.QueryRowContext(ctx, c.query).Scan(&n); err != nil {
+			return fmt.Errorf("failed to count %s: %w", c.name, err)
+		}
+		if n != c.expected {
+			problems = append(problems, fmt.Sprintf("%d %s, expected %d", n, c.name, c.expected))
+		}
+	}
+
+	stats := db.Stats()
+	if stats.InUse > 0 {
+		problems = append(problems, fmt.Sprintf("%d connections are still in use", stats.InUse))
+	}
+	if checkServer {
+		var name string
+		var threads int
+		if err := db.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'Threads_connected'").Scan(&name, &threads); err != nil {
+			return fmt.Errorf("failed to get connections of the server: %w", err)
+		}
+		// NOTE: nothing but the pool connects to the server during the soak
+		if open := db.Stats().OpenConnections; threads > open {
+			problems = append(problems, fmt.Sprintf("%d connections on the server, while the pool opens %d", threads, open))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invariants are broken: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// runSoak runs the operation mix with workers against db for cfg.duration,
+// and checks invariants, the pool and the heap every cfg.checkEvery.
+func runSoak(ctx context.Context, t *testing.T, db *sql.DB, cfg *soakConfig) {
+	rng := testRand(t)
+	workers := make([]*soakWorker, cfg.workers)
+	for i := range workers {
+		workers[i] = newSoakWorker(i, db, rand.New(rand.NewSource(rng.Int63())), cfg)
+	}
+
+	start := time.Now()
+	end := start.Add(cfg.duration)
+	var baseHeap uint64
+	for round := 1; time.Now().Before(end); round++ {
+		deadline := time.Now().Add(cfg.checkEvery)
+		if deadline.After(end) {
+			deadline = end
+		}
+
+		errs := make([]error, len(workers))
+		var wg sync.WaitGroup
+		for i, w := range workers {
+			wg.Add(1)
+			go func(i int, w *soakWorker) {
+				defer wg.Done()
+				errs[i] = w.runUntil(ctx, deadline)
+			}(i, w)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err, "round %d", round)
+		}
+
+		require.NoError(t, checkSoakInvariants(ctx, db, expectedSoakCounts(workers), cfg.checkServer), "round %d", round)
+		heap := heapInUse()
+		if round == 1 {
+			baseHeap = heap
+		}
+		require.LessOrEqual(t, heap, baseHeap+soakMaxHeapGrowth, "heap grew after round 1 (round %d)", round)
+		t.Logf("round %d (elapsed: %s): heap %d bytes, pool %+v", round, time.Since(start).Round(time.Second), heap, db.Stats())
+	}
+
+	done := map[string]int{}
+	for _, w := range workers {
+		for name, n := range w.done {
+			done[name] += n
+		}
+	}
+	t.Logf("operations: %v", done)
+}
+
+// test using testcontainers (opt-in by -soak)
+func TestSoakWithTestContainers(t *testing.T) {
+	if !*soak {
+		t.Skip("skipped because soak tests are opt-in (go test . -run Soak -soak -timeout 2h)")
+	}
+	ctx := context.Background()
+	deadline, ok := t.Deadline()
+	cfg := &soakConfig{
+		workers:        8,
+		usersPerWorker: 100,
+		duration:       soakDuration(deadline, ok, time.Now()),
+		checkEvery:     time.Minute,
+		ops:            soakOperations,
+		checkServer:    true,
+	}
+
+	db := prepareContainer(ctx, t)
+	db.SetMaxOpenConns(cfg.workers)
+	// NOTE: connections are reopened during the soak, which should not leak on either side
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	runSoak(ctx, t, db, cfg)
+}
+
+// test using go-mysql-server
+func TestSoakWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// NOTE: go-mysql-server does not delete credentials by the foreign key because the checks are disabled
+	var ops []*soakOp
+	for _, op := range soakOperations {
+		if op.name != "set password" {
+			ops = append(ops, op)
+		}
+	}
+
+	runSoak(ctx, t, db, &soakConfig{
+		workers:        4,
+		usersPerWorker: 20,
+		duration:       time.Second,
+		checkEvery:     250 * time.Millisecond,
+		ops:            ops,
+	})
+}
+
+// test using go-mysql-server
+func TestCheckSoakInvariantsWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, NewUserRepository(db).Register(ctx, mike))
+	require.NoError(t, checkSoakInvariants(ctx, db, soakCounts{active: 1}, false))
+
+	// run
+	// NOTE: foreign key checks are disabled in the client
+	_, err = db.ExecContext(ctx, "INSERT INTO `credential` (`user_id`, `password_hash`) VALUES (?, ?)", "1123456789ABCDEFGHJKMNPQRS", "hash")
+	require.NoError(t, err)
+	err = checkSoakInvariants(ctx, db, soakCounts{active: 1}, false)
+
+	// assert
+	require.EqualError(t, err, "invariants are broken: 1 credentials, expected 0, 1 credentials without users, expected 0")
+}
+
+func TestSoakDuration(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		title    string
+		deadline time.Time
+		ok       bool
+		expected time.Duration
+	}{
+		{"margin before the deadline", now.Add(2 * time.Hour), true, 2*time.Hour - soakMargin},
+		{"short timeout", now.Add(10 * time.Minute), true, 9 * time.Minute},
+		{"no deadline", time.Time{}, false, soakDefaultDuration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, soakDuration(tt.deadline, tt.ok, now))
+		})
+	}
+}
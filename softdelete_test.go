@@ -0,0 +1,134 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-sqlmock
+func TestHardDeleteWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `user` WHERE `id`=?")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// run
+	r := NewUserRepository(db)
+	err := r.HardDelete(context.TODO(), &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)})
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title        string
+		rowsAffected int64
+		expectedErr  error
+	}{
+		{
+			"restore a user",
+			1,
+			nil,
+		},
+		{
+			"deleted user is not found",
+			0,
+			sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			clock := newFakeClock()
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE `user` SET `deleted_at` = ?, `updated_at` = ? WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is not null)")).
+				WithArgs(nil, clock.Now(), "0123456789ABCDEFGHJKMNPQRS").
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+
+			// run
+			r := NewUserRepository(db, WithClock(clock))
+			err := r.Restore(context.TODO(), "0123456789ABCDEFGHJKMNPQRS")
+
+			// assert
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+type softDeleteUserRepository interface {
+	UserRepository
+	HardDelete(ctx context.Context, user *User) error
+	Restore(ctx context.Context, id string) error
+}
+
+func assertSoftDeleteLifecycle(t *testing.T, r softDeleteUserRepository) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, r.Register(ctx, mike))
+
+	// soft-deleted users are hidden
+	require.NoError(t, r.Delete(ctx, mike))
+	_, err := r.Get(ctx, mike.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = r.GetByName(ctx, mike.Name)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	users, total, err := r.List(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, users)
+	require.Zero(t, total)
+
+	// restored users are visible again
+	require.NoError(t, r.Restore(ctx, mike.ID))
+	found, err := r.Get(ctx, mike.ID)
+	require.NoError(t, err)
+	require.Equal(t, mike, found)
+	require.ErrorIs(t, r.Restore(ctx, mike.ID), sql.ErrNoRows)
+
+	// hard-deleted users cannot be restored
+	require.NoError(t, r.Delete(ctx, mike))
+	require.NoError(t, r.HardDelete(ctx, mike))
+	require.ErrorIs(t, r.Restore(ctx, mike.ID), sql.ErrNoRows)
+	require.NoError(t, r.Register(ctx, mike))
+}
+
+// test using go-mysql-server
+func TestSoftDeleteWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+
+	assertSoftDeleteLifecycle(t, NewUserRepository(db))
+}
+
+// test using testcontainers
+func TestSoftDeleteWithTestContainers(t *testing.T) {
+	db := prepareContainer(context.Background(), t)
+
+	assertSoftDeleteLifecycle(t, NewUserRepository(db))
+}
+
+// test using the in-memory fake
+func TestSoftDeleteWithInMemory(t *testing.T) {
+	assertSoftDeleteLifecycle(t, NewInMemoryUserRepository())
+}
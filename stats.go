@@ -0,0 +1,45 @@
+package gosqltests
+
+import (
+	"database/sql"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// ReportStats calls report with statistics of the connection pool every interval until stop is called.
+// stop waits for the last report to finish.
+func ReportStats(db *sql.DB, interval time.Duration, report func(sql.DBStats)) (stop func()) {
+	return reportStats(db, interval, report, systemClock{})
+}
+
+func reportStats(db *sql.DB, interval time.Duration, report func(sql.DBStats), clock Clock) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-clock.After(interval):
+				report(db.Stats())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
+
+// PublishStats publishes statistics of the connection pool as the expvar variable, which is read on every request.
+// It panics if the name is already published, as expvar.Publish does.
+func PublishStats(name string, db *sql.DB) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return db.Stats()
+	}))
+}
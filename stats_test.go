@@ -0,0 +1,113 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// requireNoLeakedConnections checks all connections are returned to the pool, e.g. after concurrent tests.
+func requireNoLeakedConnections(t testing.TB, db *sql.DB) {
+	t.Helper()
+	require.Eventually(t, func() bool { return db.Stats().InUse == 0 }, time.Second, time.Millisecond,
+		"connections are still in use: %+v", db.Stats())
+}
+
+// test using go-mysql-server
+func TestReportStatsWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var mu sync.Mutex
+	var reported []sql.DBStats
+	lastInUse := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reported) == 0 {
+			return -1
+		}
+		return reported[len(reported)-1].InUse
+	}
+
+	// run
+	stop := ReportStats(db, time.Millisecond, func(s sql.DBStats) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, s)
+	})
+	defer stop()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+
+	// assert
+	require.Eventually(t, func() bool { return lastInUse() == 1 }, time.Second, time.Millisecond)
+	conn.Close()
+	require.Eventually(t, func() bool { return lastInUse() == 0 }, time.Second, time.Millisecond)
+
+	// no report after stop
+	stop()
+	mu.Lock()
+	n := len(reported)
+	mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	require.Len(t, reported, n)
+	mu.Unlock()
+}
+
+func TestPublishStatsWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// run
+	PublishStats("gosqltests_test_db", db)
+	require.NoError(t, db.PingContext(ctx))
+
+	// assert
+	var stats sql.DBStats
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("gosqltests_test_db").String()), &stats))
+	require.Equal(t, 1, stats.OpenConnections)
+	require.Equal(t, 1, stats.Idle)
+}
+
+func TestConnMaxLifetimeWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+
+	// run
+	db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, ConnMaxLifetime: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer db.Close()
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, db.PingContext(ctx))
+
+	// assert
+	// NOTE: the expired connection is closed when it is taken from the pool
+	require.Equal(t, int64(1), db.Stats().MaxLifetimeClosed)
+}
@@ -0,0 +1,49 @@
+package gosqltests
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrStorageFull is returned when the database has no space left for the write.
+var ErrStorageFull = errors.New("storage is full")
+
+// MySQL error numbers caused by exhausted storage
+const (
+	mysqlErrDiskFull       = 1021 // ER_DISK_FULL
+	mysqlErrRecordFileFull = 1114 // ER_RECORD_FILE_FULL ("The table is full")
+)
+
+// StorageError is a write failure caused by the database storage, not by the data itself.
+type StorageError struct {
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrStorageFull, e.Err)
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StorageError) Is(target error) bool {
+	return target == ErrStorageFull
+}
+
+// wrapStorageError converts storage errors into StorageError and returns other errors as they are.
+func wrapStorageError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case mysqlErrDiskFull, mysqlErrRecordFileFull:
+		return &StorageError{Err: err}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,104 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/seed"
+)
+
+// test using go-sqlmock
+func TestStorageErrorWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title        string
+		err          error
+		expectedFull bool
+	}{
+		{
+			"table is full",
+			&mysql.MySQLError{Number: 1114, Message: "The table 'user' is full"},
+			true,
+		},
+		{
+			"disk is full",
+			&mysql.MySQLError{Number: 1021, Message: "Disk full (/var/lib/mysql/); waiting for someone to free some space..."},
+			true,
+		},
+		{
+			"duplicated entry",
+			&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'Mike' for key 'user.name'"},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")).
+				WithArgs(ULIDArg(), "Mike", 20, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+				WillReturnError(tt.err)
+
+			// run
+			r := NewUserRepository(db)
+			err := r.Register(context.TODO(), &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)})
+
+			// assert
+			require.Equal(t, tt.expectedFull, errors.Is(err, ErrStorageFull))
+			var mysqlErr *mysql.MySQLError
+			require.True(t, errors.As(err, &mysqlErr))
+			require.Equal(t, tt.err, mysqlErr)
+		})
+	}
+}
+
+func TestRegisterAllStorageErrorWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`email`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?),(?,?,?,?,?,?)")).
+		WithArgs(ULIDArg(), "Mike", 20, nil, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute), ULIDArg(), "Bob", 25, nil, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+		WillReturnError(&mysql.MySQLError{Number: 1114, Message: "The table 'user' is full"})
+	// NOTE: rows are not retried one by one
+	mock.ExpectRollback()
+
+	// run
+	r := NewUserRepository(db)
+	err := r.RegisterAll(context.TODO(), []*User{
+		{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+	})
+
+	// assert
+	require.ErrorIs(t, err, ErrStorageFull)
+	require.EqualError(t, err, "failed to insert users: storage is full: Error 1114: The table 'user' is full")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using testcontainers
+func TestStorageFullWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t, withSmallDisk())
+	r := NewUserRepository(db)
+
+	// run
+	gen := seed.NewGeneratorFromRand(testRand(t))
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = r.RegisterAll(ctx, toUsers(gen.Users(registerAllChunkSize)))
+	}
+
+	// assert
+	require.ErrorIs(t, err, ErrStorageFull)
+
+	// the repository still works for reads
+	_, _, err = r.List(ctx, &ListQuery{Limit: 1})
+	require.NoError(t, err)
+}
@@ -0,0 +1,93 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/seed"
+)
+
+type stressResult struct {
+	mu       sync.Mutex
+	written  []*User
+	failures []error
+}
+
+// startStressWriter keeps registering users generated by rng with workers goroutines until the returned stop is called.
+// stop waits for all in-flight writes and returns what was written.
+func startStressWriter(ctx context.Context, r UserRepository, workers int, rng *rand.Rand) (stop func() *stressResult) {
+	result := &stressResult{}
+	done := make(chan struct{})
+	gen := seed.NewGeneratorFromRand(rng)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				user := fromSeedUser(gen.User())
+				err := r.Register(ctx, user)
+
+				result.mu.Lock()
+				if err != nil {
+					result.failures = append(result.failures, fmt.Errorf("failed to write user (id: %s): %w", user.ID, err))
+				} else {
+					result.written = append(result.written, user)
+				}
+				result.mu.Unlock()
+			}
+		}()
+	}
+
+	return func() *stressResult {
+		close(done)
+		wg.Wait()
+		return result
+	}
+}
+
+// requireAllWritten checks every written user is stored and nothing else is.
+func requireAllWritten(ctx context.Context, t *testing.T, r UserRepository, written []*User) {
+	users, total, err := r.List(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(written)), total)
+	require.ElementsMatch(t, written, users)
+}
+
+// test using go-mysql-server
+func TestStressWriterWithGoMySQLServer(t *testing.T) {
+	// simulator
+	prepareSimulator(t, 23306)
+
+	db, err := NewStrictClient(23306)
+	require.NoError(t, err)
+	r := NewUserRepository(db)
+
+	// run
+	stop := startStressWriter(context.TODO(), r, 4, testRand(t))
+	for {
+		_, total, err := r.List(context.TODO(), nil)
+		require.NoError(t, err)
+		if total >= 100 {
+			break
+		}
+	}
+	result := stop()
+
+	// assert
+	require.Empty(t, result.failures)
+	requireAllWritten(context.TODO(), t, r, result.written)
+	requireNoLeakedConnections(t, db)
+}
@@ -0,0 +1,195 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrLossyScan = errors.New("value would be changed by scanning")
+
+// NewStrictClient returns a client which fails to read a row if a value would silently lose data in the repository.
+// See ClientConfig.StrictScan.
+func NewStrictClient(port int) (*sql.DB, error) {
+	return newClient(port, defaultDatabase, withStrictScan(strconv.IntSize))
+}
+
+// withStrictScan wraps a connector so that rows are checked against intSize bit int.
+func withStrictScan(intSize int) connectorWrapper {
+	return func(c driver.Connector) driver.Connector {
+		return &strictConnector{Connector: c, intSize: intSize}
+	}
+}
+
+// checkLossless reports whether a value of the database type is kept as it is in the Go type of the repository.
+// NOTE: database/sql cannot tell the destination to the driver, so integers are checked against int
+// and decimals against float64, which are used by models (e.g. null.Int truncates int64 to int on 32-bit platforms)
+func checkLossless(typeName string, v driver.Value, intSize int) error {
+	if v == nil {
+		return nil
+	}
+
+	var s string
+	switch v := v.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	default:
+		return nil
+	}
+
+	switch {
+	case strings.HasSuffix(typeName, "INT"):
+		if _, err := strconv.ParseInt(s, 10, intSize); err != nil {
+			return fmt.Errorf("%w: %s overflows %d bit int", ErrLossyScan, s, intSize)
+		}
+	case typeName == "DECIMAL":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s is not a number", ErrLossyScan, s)
+		}
+		scale := 0
+		if i := strings.IndexByte(s, '.'); i >= 0 {
+			scale = len(s) - i - 1
+		}
+		if actual := strconv.FormatFloat(f, 'f', scale, 64); actual != s {
+			return fmt.Errorf("%w: %s is rounded to %s in float64", ErrLossyScan, s, actual)
+		}
+	}
+	return nil
+}
+
+type strictConnector struct {
+	driver.Connector
+	intSize int
+}
+
+func (c *strictConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &strictConn{Conn: conn, intSize: c.intSize}, nil
+}
+
+// strictConn wraps a connection of the MySQL driver, which implements every optional interface used below.
+type strictConn struct {
+	driver.Conn
+	intSize int
+}
+
+func (c *strictConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *strictConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &strictRows{Rows: rows, intSize: c.intSize}, nil
+}
+
+func (c *strictConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &strictStmt{Stmt: stmt, intSize: c.intSize}, nil
+}
+
+func (c *strictConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *strictConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *strictConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *strictConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+func (c *strictConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.Conn.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+type strictStmt struct {
+	driver.Stmt
+	intSize int
+}
+
+func (s *strictStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+func (s *strictStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &strictRows{Rows: rows, intSize: s.intSize}, nil
+}
+
+func (s *strictStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.Stmt.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+// strictRows checks values of each row before database/sql converts them into destinations.
+// NOTE: column type interfaces are passed through because database/sql finds them by type assertions
+type strictRows struct {
+	driver.Rows
+	intSize int
+}
+
+func (r *strictRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+
+	columns := r.Columns()
+	for i, v := range dest {
+		if err := checkLossless(r.ColumnTypeDatabaseTypeName(i), v, r.intSize); err != nil {
+			return fmt.Errorf("failed to read column %s: %w", columns[i], err)
+		}
+	}
+	return nil
+}
+
+func (r *strictRows) HasNextResultSet() bool {
+	return r.Rows.(driver.RowsNextResultSet).HasNextResultSet()
+}
+
+func (r *strictRows) NextResultSet() error {
+	return r.Rows.(driver.RowsNextResultSet).NextResultSet()
+}
+
+func (r *strictRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.Rows.(driver.RowsColumnTypeDatabaseTypeName).ColumnTypeDatabaseTypeName(index)
+}
+
+func (r *strictRows) ColumnTypeScanType(index int) reflect.Type {
+	return r.Rows.(driver.RowsColumnTypeScanType).ColumnTypeScanType(index)
+}
+
+func (r *strictRows) ColumnTypeNullable(index int) (bool, bool) {
+	return r.Rows.(driver.RowsColumnTypeNullable).ColumnTypeNullable(index)
+}
+
+func (r *strictRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	return r.Rows.(driver.RowsColumnTypePrecisionScale).ColumnTypePrecisionScale(index)
+}
@@ -0,0 +1,152 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+func TestCheckLossless(t *testing.T) {
+	tests := []struct {
+		title       string
+		typeName    string
+		value       interface{}
+		intSize     int
+		expectedErr string
+	}{
+		{
+			"int in range",
+			"BIGINT",
+			[]byte("2147483647"),
+			32,
+			"",
+		},
+		{
+			"int overflows",
+			"BIGINT",
+			[]byte("2147483648"),
+			32,
+			"value would be changed by scanning: 2147483648 overflows 32 bit int",
+		},
+		{
+			"int of binary protocol overflows",
+			"BIGINT",
+			int64(-2147483649),
+			32,
+			"value would be changed by scanning: -2147483649 overflows 32 bit int",
+		},
+		{
+			"unsigned int overflows",
+			"UNSIGNED BIGINT",
+			uint64(1 << 63),
+			64,
+			"value would be changed by scanning: 9223372036854775808 overflows 64 bit int",
+		},
+		{
+			"decimal kept in float64",
+			"DECIMAL",
+			[]byte("19.99"),
+			64,
+			"",
+		},
+		{
+			"decimal rounded in float64",
+			"DECIMAL",
+			[]byte("12345678901234567890.12"),
+			64,
+			"value would be changed by scanning: 12345678901234567890.12 is rounded to 12345678901234567168.00 in float64",
+		},
+		{
+			"null",
+			"BIGINT",
+			nil,
+			32,
+			"",
+		},
+		{
+			"other types are not checked",
+			"VARCHAR",
+			[]byte("12345678901234567890"),
+			32,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			err := checkLossless(tt.typeName, tt.value, tt.intSize)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				require.True(t, errors.Is(err, ErrLossyScan))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestStrictScanWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "CREATE TABLE amount (id INT PRIMARY KEY, big BIGINT, price DECIMAL(30,2))")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO amount VALUES (1, 2147483647, 19.99), (2, 2147483648, 19.99), (3, 1, 12345678901234567890.12)")
+	require.NoError(t, err)
+
+	// NOTE: int is emulated as 32 bit
+	strictDB, err := newClient(port, defaultDatabase, withStrictScan(32))
+	require.NoError(t, err)
+
+	tests := []struct {
+		title       string
+		id          int
+		expectedErr string
+	}{
+		{
+			"lossless",
+			1,
+			"",
+		},
+		{
+			"bigint overflows int",
+			2,
+			"failed to read column big: value would be changed by scanning: 2147483648 overflows 32 bit int",
+		},
+		{
+			"decimal is rounded in float64",
+			3,
+			"failed to read column price: value would be changed by scanning: 12345678901234567890.12 is rounded to 12345678901234567168.00 in float64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			var big int64
+			var price float64
+			err := strictDB.QueryRowContext(ctx, "SELECT big, price FROM amount WHERE id = ?", tt.id).Scan(&big, &price)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				require.True(t, errors.Is(err, ErrLossyScan))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
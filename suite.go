@@ -0,0 +1,309 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// DBTestBackend is one of the ways to provide a database to repository tests.
+// Test cases written against it run unchanged on every backend.
+type DBTestBackend interface {
+	Name() string
+	Setup(ctx context.Context, t *testing.T) *sql.DB
+	Seed(ctx context.Context, t *testing.T, users ...*User)
+	Teardown(ctx context.Context, t *testing.T)
+}
+
+// MockBackend is a backend which answers only expected queries instead of storing seeded rows.
+// The standard suite sets expectations through Mock.
+type MockBackend interface {
+	DBTestBackend
+	Mock() sqlmock.Sqlmock
+}
+
+func isMockBackend(backend DBTestBackend) bool {
+	_, ok := backend.(MockBackend)
+	return ok
+}
+
+// RunStandardSuite runs the repository test corpus of this package against backends created by newBackend.
+// Other repositories can use it as a conformance suite of their own test backends.
+// Each case runs on a new backend.
+func RunStandardSuite(t *testing.T, newBackend func() DBTestBackend) {
+	runSuiteOnBackends(t, newBackend, func(db *sql.DB) UserRepository { return NewUserRepository(db) })
+}
+
+// RunConformanceSuite runs the same corpus against another implementation of UserRepository created by newRepository,
+// so that it is verified to behave the same as NewUserRepository (e.g. NewSQLCUserRepository).
+// Mock backends are skipped because their expectations are the queries of NewUserRepository.
+func RunConformanceSuite(t *testing.T, newBackend func() DBTestBackend, newRepository func(db *sql.DB) UserRepository) {
+	if isMockBackend(newBackend()) {
+		t.Skip("skipped because expectations of mock backends are queries of NewUserRepository")
+	}
+	runSuiteOnBackends(t, newBackend, newRepository)
+}
+
+func runSuiteOnBackends(t *testing.T, newBackend func() DBTestBackend, newRepository func(db *sql.DB) UserRepository) {
+	for _, tt := range standardSuite() {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newBackend()
+			db := backend.Setup(ctx, t)
+			defer backend.Teardown(ctx, t)
+			backend.Seed(ctx, t, tt.seed...)
+			if m, ok := backend.(MockBackend); ok {
+				tt.mock(m.Mock())
+			}
+
+			// run
+			r := newRepository(db)
+			actual, err := tt.run(ctx, r)
+
+			// assert
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			if user, ok := actual.(*User); ok && !isMockBackend(backend) {
+				AssertPersisted(t, ctx, db, user)
+			}
+		})
+	}
+}
+
+// RunRepositorySuite runs the same corpus against repositories which do not use a database, e.g. fakes.
+// newRepository must return a repository containing only users.
+func RunRepositorySuite(t *testing.T, newRepository func(t *testing.T, users ...*User) UserRepository) {
+	for _, tt := range standardSuite() {
+		tt := tt
+		t.Run(tt.title, func(t *testing.T) {
+			ctx := context.Background()
+			r := newRepository(t, tt.seed...)
+
+			// run
+			actual, err := tt.run(ctx, r)
+
+			// assert
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// AssertPersisted reads the user by raw SQL instead of the repository,
+// so that tests fail even if the repository reports success without writing the row.
+func AssertPersisted(t require.TestingT, ctx context.Context, db *sql.DB, user *User) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+
+	var (
+		id        string
+		name      string
+		age       *int
+		email     sql.NullString
+		createdAt time.Time
+		updatedAt time.Time
+	)
+	err := db.QueryRowContext(ctx, "SELECT `id`, `name`, `age`, `email`, `created_at`, `updated_at` FROM `user` WHERE `id` = ?", user.ID).
+		Scan(&id, &name, &age, &email, &createdAt, &updatedAt)
+	require.NoError(t, err, fmt.Sprintf("user (id: %s) is not persisted", user.ID))
+	require.Equal(t, user, &User{ID: id, Name: name, Age: age, Email: email.String, CreatedAt: createdAt.UTC(), UpdatedAt: updatedAt.UTC()})
+}
+
+type suiteCase struct {
+	title string
+	seed  []*User
+	// mock sets expectations for backends which cannot store rows
+	mock        func(sqlmock.Sqlmock)
+	run         func(context.Context, UserRepository) (interface{}, error)
+	expected    interface{}
+	expectedErr error
+}
+
+func standardSuite() []*suiteCase {
+	// NOTE: timestamps are set so that every backend stores the same ones instead of the time of registration
+	at := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), CreatedAt: at, UpdatedAt: at}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25), CreatedAt: at, UpdatedAt: at}
+	anonymous := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Anonymous", CreatedAt: at, UpdatedAt: at}
+
+	return []*suiteCase{
+		{
+			"get a user",
+			[]*User{mike, bob},
+			func(mock sqlmock.Sqlmock) {
+				ExpectGetUser(mock, mike)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.Get(ctx, mike.ID)
+			},
+			mike,
+			nil,
+		},
+		{
+			"user is not found",
+			[]*User{bob},
+			func(mock sqlmock.Sqlmock) {
+				ExpectGetUserNotFound(mock, mike.ID)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.Get(ctx, mike.ID)
+			},
+			nil,
+			sql.ErrNoRows,
+		},
+		{
+			"get a user by malformed id",
+			[]*User{mike},
+			// NOTE: no query is sent
+			func(mock sqlmock.Sqlmock) {},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.Get(ctx, "0123456789abcdefghjkmnpqrs")
+			},
+			nil,
+			ErrInvalidUserID,
+		},
+		{
+			"register a user with malformed id",
+			nil,
+			func(mock sqlmock.Sqlmock) {},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return nil, r.Register(ctx, &User{ID: "mike", Name: "Mike", Age: lo.ToPtr(20)})
+			},
+			nil,
+			ErrInvalidUserID,
+		},
+		{
+			"get a user by name",
+			[]*User{mike, bob},
+			func(mock sqlmock.Sqlmock) {
+				ExpectGetUserByName(mock, bob)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				return r.GetByName(ctx, bob.Name)
+			},
+			bob,
+			nil,
+		},
+		{
+			"list users",
+			[]*User{bob, mike},
+			func(mock sqlmock.Sqlmock) {
+				ExpectListUsers(mock, nil, 2, mike, bob)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				users, _, err := r.List(ctx, nil)
+				return users, err
+			},
+			[]*User{mike, bob},
+			nil,
+		},
+		{
+			"register a user",
+			nil,
+			func(mock sqlmock.Sqlmock) {
+				ExpectInsertUser(mock, mike)
+				ExpectGetUser(mock, mike)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				if err := r.Register(ctx, mike); err != nil {
+					return nil, err
+				}
+				return r.Get(ctx, mike.ID)
+			},
+			mike,
+			nil,
+		},
+		{
+			"delete a user",
+			[]*User{mike},
+			func(mock sqlmock.Sqlmock) {
+				ExpectSoftDeleteUser(mock, mike)
+				ExpectGetUserNotFound(mock, mike.ID)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				if err := r.Delete(ctx, mike); err != nil {
+					return nil, err
+				}
+				return r.Get(ctx, mike.ID)
+			},
+			nil,
+			sql.ErrNoRows,
+		},
+		{
+			"deleted user is not listed",
+			[]*User{mike, bob},
+			func(mock sqlmock.Sqlmock) {
+				ExpectSoftDeleteUser(mock, mike)
+				ExpectListUsers(mock, nil, 1, bob)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				if err := r.Delete(ctx, mike); err != nil {
+					return nil, err
+				}
+				users, _, err := r.List(ctx, nil)
+				return users, err
+			},
+			[]*User{bob},
+			nil,
+		},
+		{
+			"register a user without age",
+			nil,
+			func(mock sqlmock.Sqlmock) {
+				ExpectInsertUser(mock, anonymous)
+				ExpectGetUser(mock, anonymous)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				if err := r.Register(ctx, anonymous); err != nil {
+					return nil, err
+				}
+				return r.Get(ctx, anonymous.ID)
+			},
+			anonymous,
+			nil,
+		},
+		{
+			// NOTE: MySQL sorts NULL before any value
+			"users without age come first in order of age",
+			[]*User{bob, anonymous, mike},
+			func(mock sqlmock.Sqlmock) {
+				ExpectListUsers(mock, &ListQuery{Order: OrderByAgeAsc}, 3, anonymous, mike, bob)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				users, _, err := r.List(ctx, &ListQuery{Order: OrderByAgeAsc})
+				return users, err
+			},
+			[]*User{anonymous, mike, bob},
+			nil,
+		},
+		{
+			"users without age are not in a range of ages",
+			[]*User{anonymous, mike},
+			func(mock sqlmock.Sqlmock) {
+				ExpectListUsers(mock, &ListQuery{MinAge: 13}, 1, mike)
+			},
+			func(ctx context.Context, r UserRepository) (interface{}, error) {
+				users, _, err := r.List(ctx, &ListQuery{MinAge: 13})
+				return users, err
+			},
+			[]*User{mike},
+			nil,
+		},
+	}
+}
@@ -0,0 +1,196 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoTenant is returned by repositories of NewTenantUserRepository if the context has no tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+type tenantKey struct{}
+
+// WithTenant returns ctx of the tenant, whose users are read and written by repositories of NewTenantUserRepository.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or ErrNoTenant.
+func TenantFromContext(ctx context.Context) (string, error) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	if !ok || tenant == "" {
+		return "", ErrNoTenant
+	}
+	return tenant, nil
+}
+
+// TenantStrategy isolates users of tenants from each other. Select TenantDatabases or TenantColumn.
+type TenantStrategy interface {
+	// repository returns the repository of users of the tenant.
+	repository(ctx context.Context, tenant string) (*userRepository, error)
+}
+
+// TenantDatabaseOpener returns the client of the database of the tenant (e.g. tenantDatabasePool.Open).
+type TenantDatabaseOpener func(ctx context.Context, tenant string) (*sql.DB, error)
+
+type databaseTenantStrategy struct {
+	open TenantDatabaseOpener
+	opts []UserRepositoryOption
+}
+
+// TenantDatabases stores users of each tenant in the database of the tenant, which must be migrated beforehand.
+// Tenants are isolated by the server, and names and emails are unique only in each tenant.
+func TenantDatabases(open TenantDatabaseOpener, opts ...UserRepositoryOption) TenantStrategy {
+	return &databaseTenantStrategy{open: open, opts: opts}
+}
+
+func (s *databaseTenantStrategy) repository(ctx context.Context, tenant string) (*userRepository, error) {
+	db, err := s.open(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database of tenant %s: %w", tenant, err)
+	}
+	return NewUserRepository(db, s.opts...), nil
+}
+
+type columnTenantStrategy struct {
+	db   *sql.DB
+	opts []UserRepositoryOption
+}
+
+// TenantColumn stores users of all tenants in the same tables, and records the tenant of each user in user_tenant.tenant_id.
+// Users are registered with their tenants in a transaction, and only users of the tenant are read and deleted.
+// NOTE: names and emails are still unique among all tenants, because they share the unique keys of user.
+// Use TenantDatabases if a tenant must not be able to tell names of other tenants by ErrNameTaken.
+func TenantColumn(db *sql.DB, opts ...UserRepositoryOption) TenantStrategy {
+	return &columnTenantStrategy{db: db, opts: opts}
+}
+
+func (s *columnTenantStrategy) repository(ctx context.Context, tenant string) (*userRepository, error) {
+	r := NewUserRepository(s.db, s.opts...)
+	r.tenant = tenant
+	return r, nil
+}
+
+// tenantUserRepository reads and writes users of the tenant of each context by the strategy.
+// NOTE: it only implements UserRepository, so that no method can bypass the tenant
+type tenantUserRepository struct {
+	strategy TenantStrategy
+}
+
+func NewTenantUserRepository(strategy TenantStrategy) *tenantUserRepository {
+	return &tenantUserRepository{
+		strategy: strategy,
+	}
+}
+
+var _ UserRepository = (*tenantUserRepository)(nil)
+
+func (r *tenantUserRepository) Register(ctx context.Context, user *User) error {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return err
+	}
+	return repo.Register(ctx, user)
+}
+
+func (r *tenantUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return repo.List(ctx, query)
+}
+
+func (r *tenantUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repo.Get(ctx, id)
+}
+
+func (r *tenantUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetByName(ctx, name)
+}
+
+func (r *tenantUserRepository) Delete(ctx context.Context, user *User) error {
+	repo, err := r.repository(ctx)
+	if err != nil {
+		return err
+	}
+	return repo.Delete(ctx, user)
+}
+
+func (r *tenantUserRepository) repository(ctx context.Context) (*userRepository, error) {
+	tenant, err := TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.strategy.repository(ctx, tenant)
+}
+
+// tenantDatabasePool opens a client of the database of each tenant once,
+// which is named by NamespacedDatabase with the database of the config and the tenant (e.g. "practice_acme").
+type tenantDatabasePool struct {
+	mu  sync.Mutex
+	cfg ClientConfig
+	dbs map[string]*sql.DB
+}
+
+// NewTenantDatabasePool returns the pool whose Open is a TenantDatabaseOpener. Close it to close all clients.
+func NewTenantDatabasePool(cfg *ClientConfig) *tenantDatabasePool {
+	return &tenantDatabasePool{
+		cfg: *cfg,
+		dbs: map[string]*sql.DB{},
+	}
+}
+
+// Open returns the client of the tenant, opening it at the first call.
+func (p *tenantDatabasePool) Open(ctx context.Context, tenant string) (*sql.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if db, ok := p.dbs[tenant]; ok {
+		return db, nil
+	}
+
+	base := valueOr(p.cfg.Database, defaultDatabase)
+	database, err := NamespacedDatabase(base, tenant)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: otherwise tenants which differ only in case or symbols (e.g. "Acme" and "acme") would share the database
+	if database != base+"_"+tenant {
+		return nil, fmt.Errorf("tenant must consist of lower case alphanumeric characters and underscores (tenant: %q)", tenant)
+	}
+	cfg := p.cfg
+	cfg.Database = database
+	db, err := NewClientFromConfig(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.dbs[tenant] = db
+	return db, nil
+}
+
+// Close closes clients of all tenants.
+func (p *tenantDatabasePool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var closeErr error
+	for tenant, db := range p.dbs {
+		if err := db.Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("failed to close database of tenant %s: %w", tenant, err)
+		}
+		delete(p.dbs, tenant)
+	}
+	return closeErr
+}
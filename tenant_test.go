@@ -0,0 +1,221 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// assertTenantIsolation registers users of two tenants and checks that neither tenant can read or delete the other's.
+func assertTenantIsolation(ctx context.Context, t *testing.T, r UserRepository) {
+	acme := WithTenant(ctx, "acme")
+	globex := WithTenant(ctx, "globex")
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob"}
+	require.NoError(t, r.Register(acme, mike))
+	require.NoError(t, r.Register(globex, bob))
+
+	t.Run("list users of the tenant", func(t *testing.T) {
+		for _, tt := range []struct {
+			ctx      context.Context
+			expected []*User
+		}{
+			{acme, []*User{mike}},
+			{globex, []*User{bob}},
+		} {
+			users, total, err := r.List(tt.ctx, nil)
+
+			require.NoError(t, err)
+			require.Equal(t, int64(1), total)
+			require.Equal(t, tt.expected, users)
+		}
+	})
+
+	t.Run("users of other tenants are not found", func(t *testing.T) {
+		_, err := r.Get(acme, bob.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		_, err = r.GetByName(acme, bob.Name)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+
+		_, err = r.Get(globex, mike.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("users of other tenants are not deleted", func(t *testing.T) {
+		// NOTE: the error depends on the strategy, as the user does not exist in the database of the tenant
+		_ = r.Delete(acme, bob)
+
+		found, err := r.Get(globex, bob.ID)
+		require.NoError(t, err)
+		require.Equal(t, bob, found)
+	})
+
+	t.Run("users of the tenant are deleted", func(t *testing.T) {
+		require.NoError(t, r.Delete(acme, mike))
+
+		_, err := r.Get(acme, mike.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("context without tenant", func(t *testing.T) {
+		_, _, err := r.List(ctx, nil)
+		require.ErrorIs(t, err, ErrNoTenant)
+
+		err = r.Register(ctx, &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary"})
+		require.ErrorIs(t, err, ErrNoTenant)
+	})
+}
+
+// prepareTenantDatabases creates and migrates the databases of the tenants in the server, and returns the pool of them.
+// The databases are dropped when the test finishes, so that reused containers start from empty databases.
+func prepareTenantDatabases(ctx context.Context, t *testing.T, port int, tenants ...string) *tenantDatabasePool {
+	root, err := NewClientWithWait(ctx, &ClientConfig{Port: port})
+	require.NoError(t, err)
+
+	pool := NewTenantDatabasePool(&ClientConfig{Port: port})
+	var databases []string
+	t.Cleanup(func() {
+		require.NoError(t, pool.Close())
+		for _, database := range databases {
+			require.NoError(t, DropDatabase(ctx, root, database))
+		}
+		require.NoError(t, root.Close())
+	})
+
+	for _, tenant := range tenants {
+		database, err := NamespacedDatabase("practice", tenant)
+		require.NoError(t, err)
+		require.NoError(t, CreateDatabase(ctx, root, database))
+		databases = append(databases, database)
+
+		db, err := pool.Open(ctx, tenant)
+		require.NoError(t, err)
+		require.NoError(t, Migrate(ctx, db))
+	}
+	return pool
+}
+
+// test using go-mysql-server
+func TestTenantColumnWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	// NOTE: foreign key checks are disabled to insert user_tenant (see newMigrationClient)
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	assertTenantIsolation(ctx, t, NewTenantUserRepository(TenantColumn(db)))
+}
+
+// test using go-mysql-server
+func TestTenantColumnScopesReadsWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	// NOTE: foreign key checks are disabled to insert user_tenant (see newMigrationClient)
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	strategy := TenantColumn(db)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Email: "mike@example.com"}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Email: "bob@example.com"}
+	require.NoError(t, NewTenantUserRepository(strategy).Register(WithTenant(ctx, "acme"), mike))
+	require.NoError(t, NewTenantUserRepository(strategy).Register(WithTenant(ctx, "globex"), bob))
+	r, err := strategy.repository(ctx, "acme")
+	require.NoError(t, err)
+
+	t.Run("ListAfter", func(t *testing.T) {
+		users, next, err := r.ListAfter(ctx, "", 10)
+
+		require.NoError(t, err)
+		require.Equal(t, "", next)
+		require.Equal(t, []*User{mike}, users)
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		total, err := r.Count(ctx, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), total)
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		exists, err := r.Exists(ctx, bob.ID)
+
+		require.NoError(t, err)
+		require.False(t, exists)
+	})
+
+	t.Run("GetByEmail", func(t *testing.T) {
+		_, err := r.GetByEmail(ctx, bob.Email)
+
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+// test using go-mysql-server
+func TestTenantDatabasesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareEmptySimulator(t, port)
+	pool := prepareTenantDatabases(ctx, t, port, "acme", "globex")
+
+	assertTenantIsolation(ctx, t, NewTenantUserRepository(TenantDatabases(pool.Open)))
+}
+
+// test using testcontainers
+func TestTenantColumnWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	assertTenantIsolation(ctx, t, NewTenantUserRepository(TenantColumn(db)))
+	AssertReferentialIntegrity(t, db)
+}
+
+// test using testcontainers
+func TestTenantDatabasesWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	port := startContainer(ctx, t)
+	pool := prepareTenantDatabases(ctx, t, port, "acme", "globex")
+
+	assertTenantIsolation(ctx, t, NewTenantUserRepository(TenantDatabases(pool.Open)))
+}
+
+func TestTenantDatabasePoolOfInvalidTenant(t *testing.T) {
+	pool := NewTenantDatabasePool(&ClientConfig{Port: 3306})
+	defer pool.Close()
+
+	// run
+	_, err := pool.Open(context.Background(), "Acme")
+
+	// assert
+	// NOTE: "Acme" would share the database practice_acme with "acme"
+	require.EqualError(t, err, `tenant must consist of lower case alphanumeric characters and underscores (tenant: "Acme")`)
+}
+
+func TestTenantFromContext(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := TenantFromContext(ctx)
+	require.ErrorIs(t, err, ErrNoTenant)
+
+	_, err = TenantFromContext(WithTenant(ctx, ""))
+	require.ErrorIs(t, err, ErrNoTenant)
+
+	tenant, err := TenantFromContext(WithTenant(ctx, "acme"))
+	require.NoError(t, err)
+	require.Equal(t, "acme", tenant)
+}
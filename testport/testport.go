@@ -0,0 +1,54 @@
+// Package testport allocates TCP ports for servers started in tests.
+package testport
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+var (
+	mu       sync.Mutex
+	reserved = map[int]struct{}{}
+)
+
+// maximum number of attempts to find a port which is not reserved
+const maxAttempts = 100
+
+// Reserve returns a free port on localhost. The port is not returned again in this process until it is released,
+// so that parallel tests never get the same port even before they start listening on it.
+func Reserve() (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i := 0; i < maxAttempts; i++ {
+		port, err := free()
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := reserved[port]; ok {
+			continue
+		}
+		reserved[port] = struct{}{}
+		return port, nil
+	}
+	return 0, fmt.Errorf("failed to find a free port in %d attempts", maxAttempts)
+}
+
+// Release makes the port available for Reserve again.
+func Release(port int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(reserved, port)
+}
+
+func free() (int, error) {
+	// NOTE: free port are chosen if port 0 is specified
+	l, err := net.Listen("tcp4", "localhost:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to listen: %w", err)
+	}
+	// close connection to use later
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,47 @@
+package testport
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveConcurrent(t *testing.T) {
+	const n = 50
+
+	// run
+	var wg sync.WaitGroup
+	ports := make(chan int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			port, err := Reserve()
+			require.NoError(t, err)
+			ports <- port
+		}()
+	}
+	wg.Wait()
+	close(ports)
+
+	// assert
+	seen := map[int]struct{}{}
+	for port := range ports {
+		require.NotContains(t, seen, port)
+		seen[port] = struct{}{}
+		Release(port)
+	}
+	require.Len(t, seen, n)
+}
+
+func TestRelease(t *testing.T) {
+	port, err := Reserve()
+	require.NoError(t, err)
+
+	// run
+	Release(port)
+
+	// assert
+	require.NotContains(t, reserved, port)
+}
@@ -0,0 +1,80 @@
+package testsupport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/syuparn/gosqltests/simulator"
+)
+
+// dumpFile is the file in containers which dumps are written to and loaded from.
+// NOTE: output of exec is read only after the command finishes, so a large dump is not piped through it
+const dumpFile = "/tmp/gosqltests-dump.sql"
+
+// DumpDatabase writes the dump of the database of the MySQL container (practice) by mysqldump in the container to w,
+// e.g. to seed a database once and load the dump into containers of other tests by LoadDatabase.
+func DumpDatabase(ctx context.Context, container testcontainers.Container, w io.Writer) error {
+	if err := execShell(ctx, container, fmt.Sprintf("mysqldump --single-transaction -uroot %s > %s", simulator.Database, dumpFile)); err != nil {
+		return fmt.Errorf("failed to dump database: %w", err)
+	}
+
+	f, err := container.CopyFileFromContainer(ctx, dumpFile)
+	if err != nil {
+		return fmt.Errorf("failed to copy dump from container: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to copy dump from container: %w", err)
+	}
+
+	return removeDumpFile(ctx, container)
+}
+
+// LoadDatabase executes the dump of r (e.g. written by DumpDatabase) by mysql in the MySQL container on its database (practice).
+// Tables in the dump are dropped and re-created, while other tables are left as they are.
+func LoadDatabase(ctx context.Context, container testcontainers.Container, r io.Reader) error {
+	dump, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	if err := container.CopyToContainer(ctx, dump, dumpFile, 0o644); err != nil {
+		return fmt.Errorf("failed to copy dump to container: %w", err)
+	}
+
+	if err := execShell(ctx, container, fmt.Sprintf("mysql -uroot %s < %s", simulator.Database, dumpFile)); err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	return removeDumpFile(ctx, container)
+}
+
+func removeDumpFile(ctx context.Context, container testcontainers.Container) error {
+	if err := execShell(ctx, container, "rm -f "+dumpFile); err != nil {
+		return fmt.Errorf("failed to remove dump in container: %w", err)
+	}
+	return nil
+}
+
+// execShell runs the command by sh in the container. It returns stderr of the command as an error if it fails.
+func execShell(ctx context.Context, container testcontainers.Container, command string) error {
+	code, output, err := container.Exec(ctx, []string{"sh", "-c", command})
+	if err != nil {
+		return fmt.Errorf("failed to exec %q: %w", command, err)
+	}
+	if code == 0 {
+		return nil
+	}
+
+	// NOTE: stdout and stderr of exec without TTY are multiplexed into output
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(io.Discard, &stderr, output); err != nil {
+		return fmt.Errorf("%q exited with %d", command, code)
+	}
+	return fmt.Errorf("%q exited with %d: %s", command, code, strings.TrimSpace(stderr.String()))
+}
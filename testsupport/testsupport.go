@@ -0,0 +1,86 @@
+// Package testsupport starts the databases which tests run against, i.e. go-sqlmock, go-mysql-server and containers,
+// and releases them by t.Cleanup, so that tests need no teardown and nothing is leaked even if they fail by t.Fatal.
+package testsupport
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/docker/go-connections/nat"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/syuparn/gosqltests/simulator"
+)
+
+// NewMockDB returns a database mocked by go-sqlmock, which is closed when the test finishes.
+func NewMockDB(t testing.TB) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db, mock
+}
+
+// StartSimulator serves db by go-mysql-server on the port of localhost until the test finishes.
+// NOTE: the returned server can be closed earlier to simulate a restart, because closing it twice is harmless
+func StartSimulator(t testing.TB, port int, db *memory.Database) *server.Server {
+	t.Helper()
+
+	s, err := simulator.New(fmt.Sprintf("localhost:%d", port), db)
+	if err != nil {
+		t.Fatalf("failed to create simulator: %s", err)
+	}
+	go func() {
+		if err := s.Start(); err != nil {
+			panic(err)
+		}
+	}()
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Errorf("failed to close simulator: %s", err)
+		}
+	})
+
+	return s
+}
+
+// StartContainer starts a container of req, which is terminated when the test finishes.
+// The provider of req (e.g. testcontainers.ProviderPodman) is used, and the container is started even if req.Started is false.
+func StartContainer(ctx context.Context, t testing.TB, req testcontainers.GenericContainerRequest) testcontainers.Container {
+	t.Helper()
+
+	req.Started = true
+	container, err := testcontainers.GenericContainer(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to start container: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Errorf("failed to terminate container: %s", err)
+		}
+	})
+
+	return container
+}
+
+// MappedPort returns the port of the host mapped to the port of the container (e.g. "3306").
+func MappedPort(ctx context.Context, t testing.TB, container testcontainers.Container, port nat.Port) int {
+	t.Helper()
+
+	mapped, err := container.MappedPort(ctx, port)
+	if err != nil {
+		t.Fatalf("failed to get mapped port: %s", err)
+	}
+	return mapped.Int()
+}
@@ -0,0 +1,45 @@
+package testsupport
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/simulator"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+func TestNewMockDB(t *testing.T) {
+	var db *sql.DB
+	t.Run("mock is available in the test", func(t *testing.T) {
+		db, _ = NewMockDB(t)
+
+		require.NoError(t, db.Ping())
+	})
+
+	// assert
+	require.EqualError(t, db.Ping(), "sql: database is closed")
+}
+
+// test using go-mysql-server
+func TestStartSimulator(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+	db, err := sql.Open("mysql", fmt.Sprintf("root:@(localhost:%d)/%s", port, simulator.Database))
+	require.NoError(t, err)
+	defer db.Close()
+
+	t.Run("simulator is served in the test", func(t *testing.T) {
+		StartSimulator(t, port, simulator.NewDatabase())
+
+		require.Eventually(t, func() bool { return db.Ping() == nil }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	// assert
+	db.SetMaxIdleConns(0)
+	require.Error(t, db.Ping())
+}
@@ -0,0 +1,201 @@
+package gosqltests
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type timestampedUserRepository interface {
+	UserRepository
+	Upsert(ctx context.Context, user *User, updateColumns ...string) error
+	Restore(ctx context.Context, id string) error
+}
+
+// assertUserTimestamps checks created_at and updated_at written by r, whose clock is clock and ids are sequentialIDs.
+func assertUserTimestamps(ctx context.Context, t *testing.T, r timestampedUserRepository, clock *fakeClock) {
+	mike := &User{Name: "Mike", Age: lo.ToPtr(20)}
+	registeredAt := clock.Now()
+
+	t.Run("register sets both timestamps", func(t *testing.T) {
+		// run
+		err := r.Register(ctx, mike)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, sequentialID(1), mike.ID)
+		require.Equal(t, registeredAt, mike.CreatedAt)
+		require.Equal(t, registeredAt, mike.UpdatedAt)
+		found, err := r.Get(ctx, mike.ID)
+		require.NoError(t, err)
+		require.Equal(t, mike, found)
+	})
+
+	t.Run("upsert of the user bumps updated_at", func(t *testing.T) {
+		clock.Advance(time.Hour)
+
+		// run
+		err := r.Upsert(ctx, &User{ID: mike.ID, Name: "Michael", Age: lo.ToPtr(21)})
+
+		// assert
+		require.NoError(t, err)
+		found, err := r.Get(ctx, mike.ID)
+		require.NoError(t, err)
+		require.Equal(t, registeredAt, found.CreatedAt)
+		require.Equal(t, clock.Now(), found.UpdatedAt)
+	})
+
+	t.Run("restore bumps updated_at", func(t *testing.T) {
+		require.NoError(t, r.Delete(ctx, mike))
+		clock.Advance(time.Hour)
+
+		// run
+		err := r.Restore(ctx, mike.ID)
+
+		// assert
+		require.NoError(t, err)
+		found, err := r.Get(ctx, mike.ID)
+		require.NoError(t, err)
+		require.Equal(t, registeredAt, found.CreatedAt)
+		require.Equal(t, clock.Now(), found.UpdatedAt)
+	})
+
+	t.Run("timestamps of the user are kept", func(t *testing.T) {
+		// NOTE: e.g. users imported from another database
+		bob := &User{Name: "Bob", CreatedAt: seededAt.Add(-time.Hour), UpdatedAt: seededAt}
+
+		// run
+		err := r.Register(ctx, bob)
+
+		// assert
+		require.NoError(t, err)
+		found, err := r.Get(ctx, bob.ID)
+		require.NoError(t, err)
+		require.Equal(t, seededAt.Add(-time.Hour), found.CreatedAt)
+		require.Equal(t, seededAt, found.UpdatedAt)
+	})
+}
+
+// test using every selected DBTestBackend which stores rows and the in-memory fake
+func TestUserTimestampsOnBackends(t *testing.T) {
+	for _, b := range selectedBackends(t) {
+		backend := b.newBackend()
+		if isMockBackend(backend) {
+			continue
+		}
+
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := backend.Setup(ctx, t)
+			defer backend.Teardown(ctx, t)
+			clock := newFakeClock()
+			r := NewUserRepository(db, WithClock(clock), WithIDGenerator(newSequentialIDs()))
+
+			assertUserTimestamps(ctx, t, r, clock)
+
+			t.Run("update bumps updated_at", func(t *testing.T) {
+				user, err := r.GetVersioned(ctx, sequentialID(1))
+				require.NoError(t, err)
+				createdAt := user.CreatedAt
+				clock.Advance(time.Hour)
+
+				// run
+				err = r.Update(ctx, user)
+
+				// assert
+				require.NoError(t, err)
+				require.Equal(t, clock.Now(), user.UpdatedAt)
+				found, err := r.Get(ctx, user.ID)
+				require.NoError(t, err)
+				require.Equal(t, createdAt, found.CreatedAt)
+				require.Equal(t, clock.Now(), found.UpdatedAt)
+			})
+		})
+	}
+
+	// the fake must behave the same as databases
+	t.Run("inmemory", func(t *testing.T) {
+		clock := newFakeClock()
+		r := NewInMemoryUserRepository()
+		r.clock, r.ids = clock, newSequentialIDs()
+
+		assertUserTimestamps(context.Background(), t, r, clock)
+	})
+}
+
+// test using go-sqlmock
+func TestUserTimestampsWithSQLMock(t *testing.T) {
+	insert := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")
+	upsert := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?) " +
+		"ON DUPLICATE KEY UPDATE `name` = VALUES(`name`),`age` = VALUES(`age`),`updated_at` = VALUES(`updated_at`),`version` = `version` + 1")
+	now := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		title string
+		mock  func(sqlmock.Sqlmock)
+		run   func(context.Context, *userRepository) error
+	}{
+		{
+			"register writes the time of the clock",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).
+					WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, now, now).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			func(ctx context.Context, r *userRepository) error {
+				return r.Register(ctx, &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)})
+			},
+		},
+		{
+			"register keeps timestamps of the user",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).
+					WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, now.Add(-time.Hour), now.Add(-time.Minute)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			func(ctx context.Context, r *userRepository) error {
+				return r.Register(ctx, &User{
+					ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20),
+					CreatedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Minute),
+				})
+			},
+		},
+		{
+			"upsert overwrites updated_at only",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(upsert).
+					WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, now.Add(-time.Hour), now).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			func(ctx context.Context, r *userRepository) error {
+				return r.Upsert(ctx, &User{
+					ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20),
+					CreatedAt: now.Add(-time.Hour), UpdatedAt: now.Add(-time.Hour),
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			// NOTE: the time of the clock is truncated to seconds of DATETIME columns
+			clock := newFakeClock()
+			clock.Advance(500 * time.Millisecond)
+			err := tt.run(context.TODO(), NewUserRepository(db, WithClock(clock)))
+
+			// assert
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package gosqltests
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+	testcontainers "github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// withRequireSecureTransport makes the server refuse connections without TLS, as managed MySQL does.
+// NOTE: MySQL generates a self-signed certificate at startup, which only skip-verify accepts
+func withRequireSecureTransport() containerOption {
+	return func(req *testcontainers.ContainerRequest) {
+		req.Cmd = append(req.Cmd, "--require-secure-transport=ON")
+		req.WaitingFor = wait.ForSQL("3306/tcp", "mysql", func(host string, port nat.Port) string {
+			return fmt.Sprintf("root:@(%s:%d)/practice?tls=skip-verify", host, port.Int())
+		})
+	}
+}
+
+// sessionStatus returns the status variable of the session, e.g. Ssl_version.
+func sessionStatus(ctx context.Context, t *testing.T, db *sql.DB, name string) string {
+	var variable, value string
+	require.NoError(t, db.QueryRowContext(ctx, "SHOW SESSION STATUS LIKE ?", name).Scan(&variable, &value))
+	return value
+}
+
+// test using testcontainers
+func TestTLSWithTestContainers(t *testing.T) {
+	// NOTE: MariaDB does not generate certificates
+	if !strings.HasPrefix(mysqlImage(), "mysql:") {
+		t.Skipf("%s has no certificates of TLS", mysqlImage())
+	}
+
+	ctx := context.Background()
+	port := startContainer(ctx, t, withFastMySQL(), withRequireSecureTransport())
+	require.NoError(t, RegisterTLSConfig("gosqltests-container", &tls.Config{
+		// NOTE: the self-signed certificate cannot be verified
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+	}))
+
+	t.Run("connection without TLS is refused", func(t *testing.T) {
+		db, err := NewClientFromConfig(&ClientConfig{Port: port})
+		require.NoError(t, err)
+		defer closeTestClient(t, db)
+
+		// run
+		err = db.PingContext(ctx)
+
+		// assert
+		require.ErrorContains(t, err, "Connections using insecure transport are prohibited")
+	})
+
+	t.Run("connection with the registered TLS config", func(t *testing.T) {
+		db, err := NewClientWithWait(ctx, &ClientConfig{
+			Port:                 port,
+			TLS:                  "gosqltests-container",
+			InterpolateParams:    true,
+			StrictScan:           true,
+			ConnectionAttributes: map[string]string{"service": "gosqltests"},
+		})
+		require.NoError(t, err)
+		defer closeTestClient(t, db)
+		require.NoError(t, Migrate(ctx, db))
+		// NOTE: the session status is of the only connection
+		db.SetMaxOpenConns(1)
+
+		// run
+		r := NewUserRepository(db)
+		mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"}
+		require.NoError(t, r.Register(ctx, mike))
+		found, err := r.Get(ctx, mike.ID)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, mike, found)
+		require.NotEmpty(t, sessionStatus(ctx, t, db, "Ssl_version"))
+		// NOTE: arguments are interpolated instead of preparing statements
+		require.Equal(t, "0", sessionStatus(ctx, t, db, "Com_stmt_prepare"))
+		var service string
+		require.NoError(t, db.QueryRowContext(ctx,
+			"SELECT ATTR_VALUE FROM performance_schema.session_connect_attrs WHERE PROCESSLIST_ID = CONNECTION_ID() AND ATTR_NAME = 'service'",
+		).Scan(&service))
+		require.Equal(t, "gosqltests", service)
+	})
+}
@@ -0,0 +1,162 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/syuparn/gosqltests"
+
+// NewTracedClient returns a client which emits a span for each query, statement execution and transaction end.
+func NewTracedClient(port int, tp trace.TracerProvider) (*sql.DB, error) {
+	return newClient(port, defaultDatabase, withTracing(tp))
+}
+
+func withTracing(tp trace.TracerProvider) connectorWrapper {
+	return func(c driver.Connector) driver.Connector {
+		return &tracedConnector{Connector: c, tracer: tp.Tracer(tracerName)}
+	}
+}
+
+type tracedConnector struct {
+	driver.Connector
+	tracer trace.Tracer
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, tracer: c.tracer}, nil
+}
+
+// record emits a span of the finished operation.
+// NOTE: spans are started afterwards because the driver may return driver.ErrSkip,
+// which means database/sql retries the statement in another way (e.g. prepared statement)
+func record(ctx context.Context, tracer trace.Tracer, name, query string, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMySQL),
+	}
+	if query != "" {
+		opts = append(opts, trace.WithAttributes(semconv.DBStatementKey.String(query)))
+	}
+	_, span := tracer.Start(ctx, name, opts...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedConn wraps a connection of the MySQL driver, which implements every optional interface used below.
+type tracedConn struct {
+	driver.Conn
+	tracer trace.Tracer
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	res, err := c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+	record(ctx, c.tracer, "sql.exec", query, start, err)
+	return res, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	record(ctx, c.tracer, "sql.query", query, start, err)
+	return rows, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, query: query, tracer: c.tracer}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	tx, err := c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	record(ctx, c.tracer, "sql.begin", "", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{Tx: tx, ctx: ctx, tracer: c.tracer}, nil
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *tracedConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return c.Conn.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	query  string
+	tracer trace.Tracer
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	res, err := s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+	record(ctx, s.tracer, "sql.exec", s.query, start, err)
+	return res, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	record(ctx, s.tracer, "sql.query", s.query, start, err)
+	return rows, err
+}
+
+func (s *tracedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.Stmt.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+type tracedTx struct {
+	driver.Tx
+	// NOTE: driver.Tx does not receive a context, so the one of BeginTx is used as the parent of spans
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+func (t *tracedTx) Commit() error {
+	start := time.Now()
+	err := t.Tx.Commit()
+	record(t.ctx, t.tracer, "sql.commit", "", start, err)
+	return err
+}
+
+func (t *tracedTx) Rollback() error {
+	start := time.Now()
+	err := t.Tx.Rollback()
+	record(t.ctx, t.tracer, "sql.rollback", "", start, err)
+	return err
+}
@@ -0,0 +1,112 @@
+package gosqltests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+type expectedSpan struct {
+	name      string
+	statement string
+	status    codes.Code
+}
+
+func toExpectedSpans(spans []sdktrace.ReadOnlySpan) []*expectedSpan {
+	return lo.Map(spans, func(s sdktrace.ReadOnlySpan, _ int) *expectedSpan {
+		statement := ""
+		for _, attr := range s.Attributes() {
+			if attr.Key == attribute.Key("db.statement") {
+				statement = attr.Value.AsString()
+			}
+		}
+		return &expectedSpan{name: s.Name(), statement: statement, status: s.Status().Code}
+	})
+}
+
+// test using go-mysql-server
+func TestTracingWithGoMySQLServer(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	tests := []struct {
+		title       string
+		run         func(context.Context, UserRepository) error
+		expected    []*expectedSpan
+		expectedErr bool
+	}{
+		{
+			"get a user",
+			func(ctx context.Context, r UserRepository) error {
+				_, err := r.Get(ctx, mike.ID)
+				return err
+			},
+			[]*expectedSpan{
+				{"sql.query", "SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1;", codes.Unset},
+			},
+			false,
+		},
+		{
+			"register a user",
+			func(ctx context.Context, r UserRepository) error {
+				return r.Register(ctx, &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)})
+			},
+			[]*expectedSpan{
+				{"sql.exec", "INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)", codes.Unset},
+			},
+			false,
+		},
+		{
+			"register a duplicated user",
+			func(ctx context.Context, r UserRepository) error {
+				return r.Register(ctx, mike)
+			},
+			[]*expectedSpan{
+				{"sql.exec", "INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)", codes.Error},
+			},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			ctx := context.Background()
+			port, err := testport.Reserve()
+			require.NoError(t, err)
+
+			// simulator
+			prepareSimulator(t, port)
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			db, err := NewClientWithWait(ctx, &ClientConfig{Port: port, TracerProvider: tp})
+			require.NoError(t, err)
+			defer db.Close()
+
+			r := NewUserRepository(db)
+			require.NoError(t, r.Register(ctx, mike))
+			// NOTE: spans of seeding are skipped
+			seeded := len(recorder.Ended())
+
+			// run
+			err = tt.run(ctx, r)
+
+			// assert
+			if tt.expectedErr {
+				var mysqlErr *mysql.MySQLError
+				require.ErrorAs(t, err, &mysqlErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expected, toExpectedSpans(recorder.Ended()[seeded:]))
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/volatiletech/sqlboiler/v4/boil"
+)
+
+// ErrNoTransaction is returned by RepositoriesFromContext outside WithinTx.
+var ErrNoTransaction = errors.New("no transaction in context")
+
+// Repositories are repositories bound to the transaction of WithinTx.
+type Repositories struct {
+	Users       *userRepository
+	Credentials *credentialRepository
+	Audit       *auditRepository
+	Orders      *orderRepository
+}
+
+type txManager struct {
+	db *sql.DB
+	// userOpts configure Users of Repositories
+	userOpts []UserRepositoryOption
+}
+
+// NewTxManager returns a manager of transactions on db.
+// opts configure Users of the repositories which RepositoriesFromContext returns.
+func NewTxManager(db *sql.DB, opts ...UserRepositoryOption) *txManager {
+	return &txManager{
+		db:       db,
+		userOpts: opts,
+	}
+}
+
+// txScope is the transaction of WithinTx stored in its context.
+type txScope struct {
+	db           *sql.DB
+	tx           *sql.Tx
+	repositories *Repositories
+	// savepoints is the number of savepoints created in the transaction, which names the next one
+	savepoints int
+}
+
+type txScopeKey struct{}
+
+// WithinTx runs f in a transaction, which is committed if f returns nil and rolled back otherwise (or if f panics).
+// f gets the repositories bound to the transaction by RepositoriesFromContext(ctx),
+// so that it does not need to pass the transaction to them. WithinTx in f joins the outer transaction.
+// NOTE: a repository method failed in f is not undone until the transaction is rolled back,
+// so f must return the error instead of going on
+func (m *txManager) WithinTx(ctx context.Context, f func(ctx context.Context) error) error {
+	if scope, ok := ctx.Value(txScopeKey{}).(*txScope); ok && scope.db == m.db {
+		return f(ctx)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scope := &txScope{
+		db: m.db,
+		tx: tx,
+		repositories: &Repositories{
+			Users:       newUserRepository(tx, m.userOpts...),
+			Credentials: newCredentialRepository(tx),
+			Audit:       newAuditRepository(tx),
+			Orders:      newOrderRepository(tx),
+		},
+	}
+	if err := f(context.WithValue(ctx, txScopeKey{}, scope)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// WithinSavepoint runs f in a savepoint of the transaction of WithinTx running with ctx.
+// If f returns an error, only the writes of f are rolled back (ROLLBACK TO SAVEPOINT) and the error is returned,
+// so that the outer transaction can go on and still be committed. Savepoints can be nested.
+// It runs f by WithinTx if ctx has no transaction.
+// NOTE: a panic in f rolls back the whole transaction by WithinTx instead
+func (m *txManager) WithinSavepoint(ctx context.Context, f func(ctx context.Context) error) error {
+	scope, ok := ctx.Value(txScopeKey{}).(*txScope)
+	if !ok || scope.db != m.db {
+		return m.WithinTx(ctx, f)
+	}
+
+	scope.savepoints++
+	name := fmt.Sprintf("sp_%d", scope.savepoints)
+	if _, err := scope.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint %s: %w", name, err)
+	}
+
+	if err := f(ctx); err != nil {
+		if _, rollbackErr := scope.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			// NOTE: the error of f is not wrapped, so that callers do not go on as if only f failed
+			return fmt.Errorf("failed to roll back to savepoint %s: %w (error: %s)", name, rollbackErr, err)
+		}
+		return err
+	}
+
+	if _, err := scope.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// RepositoriesFromContext returns the repositories bound to the transaction of WithinTx running with ctx.
+func RepositoriesFromContext(ctx context.Context) (*Repositories, error) {
+	scope, ok := ctx.Value(txScopeKey{}).(*txScope)
+	if !ok {
+		return nil, ErrNoTransaction
+	}
+	return scope.repositories, nil
+}
+
+// transaction is begun by a repository method writing several rows atomically.
+type transaction interface {
+	boil.ContextExecutor
+	Commit() error
+	Rollback() error
+}
+
+// joinedTx is the transaction of WithinTx used by a repository method, which WithinTx commits or rolls back instead.
+type joinedTx struct {
+	*sql.Tx
+}
+
+func (joinedTx) Commit() error {
+	return nil
+}
+
+func (joinedTx) Rollback() error {
+	return nil
+}
+
+// beginTx begins a transaction on exec, or joins exec if it is the transaction of WithinTx.
+func beginTx(ctx context.Context, exec boil.ContextExecutor) (transaction, error) {
+	switch e := exec.(type) {
+	case *sql.Tx:
+		return joinedTx{e}, nil
+	case *sql.DB:
+		tx, err := e.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	default:
+		return nil, fmt.Errorf("%T cannot begin a transaction", exec)
+	}
+}
@@ -0,0 +1,517 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// test using go-sqlmock
+func TestWithinTxWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	insertUser := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")
+	insertUsers := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`email`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?)")
+	errCanceled := errors.New("canceled")
+
+	tests := []struct {
+		title       string
+		mock        func(mock sqlmock.Sqlmock)
+		callbackErr error
+		expectedErr string
+	}{
+		{
+			"repositories join the transaction, which is committed",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// NOTE: RegisterAll does not begin another transaction
+				mock.ExpectExec(insertUsers).
+					WithArgs(bob.ID, bob.Name, bob.Age, nil, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			nil,
+			"",
+		},
+		{
+			"rolled back if the callback fails",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(insertUsers).
+					WithArgs(bob.ID, bob.Name, bob.Age, nil, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectRollback()
+			},
+			errCanceled,
+			"canceled",
+		},
+		{
+			"rolled back if a repository fails",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertUser).
+					WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillReturnError(errors.New("connection refused"))
+				mock.ExpectRollback()
+			},
+			nil,
+			"failed to insert user: models: unable to insert into user: connection refused",
+		},
+		{
+			"transaction cannot begin",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+			},
+			nil,
+			"failed to begin transaction: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			err := NewTxManager(db).WithinTx(context.TODO(), func(ctx context.Context) error {
+				repos, err := RepositoriesFromContext(ctx)
+				require.NoError(t, err)
+				if err := repos.Users.Register(ctx, mike); err != nil {
+					return err
+				}
+				if err := repos.Users.RegisterAll(ctx, []*User{bob}); err != nil {
+					return err
+				}
+				return tt.callbackErr
+			})
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestWithinTxPanicWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	// run
+	require.PanicsWithValue(t, "unexpected", func() {
+		_ = NewTxManager(db).WithinTx(context.TODO(), func(ctx context.Context) error {
+			panic("unexpected")
+		})
+	})
+
+	// assert
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-sqlmock
+func TestNestedWithinTxWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	m := NewTxManager(db)
+
+	// run
+	err := m.WithinTx(context.TODO(), func(ctx context.Context) error {
+		outer, err := RepositoriesFromContext(ctx)
+		require.NoError(t, err)
+
+		return m.WithinTx(ctx, func(ctx context.Context) error {
+			inner, err := RepositoriesFromContext(ctx)
+			require.NoError(t, err)
+			require.Same(t, outer, inner)
+			return nil
+		})
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestWithinTxWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+
+	// run
+	err = NewTxManager(db, WithAuditLog()).WithinTx(WithActor(ctx, "admin"), func(ctx context.Context) error {
+		repos, err := RepositoriesFromContext(ctx)
+		require.NoError(t, err)
+		if err := repos.Users.Register(ctx, mike); err != nil {
+			return err
+		}
+		return repos.Credentials.SetPassword(ctx, mike.ID, "password")
+	})
+
+	// assert
+	require.NoError(t, err)
+	found, err := NewUserRepository(db).Get(ctx, mike.ID)
+	require.NoError(t, err)
+	require.Equal(t, mike, found)
+	require.NoError(t, NewCredentialRepository(db).VerifyPassword(ctx, mike.ID, "password"))
+	// options of the manager are applied to the repositories
+	history, err := NewAuditRepository(db).History(ctx, &AuditQuery{UserID: mike.ID})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, "admin", history[0].Actor)
+}
+
+func TestRepositoriesFromContextOutsideTx(t *testing.T) {
+	_, err := RepositoriesFromContext(context.TODO())
+	require.ErrorIs(t, err, ErrNoTransaction)
+}
+
+// test using testcontainers
+// NOTE: go-mysql-server does not isolate transactions
+func TestWithinTxWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+	m := NewTxManager(db)
+	outside := NewUserRepository(db)
+	errCanceled := errors.New("canceled")
+
+	tests := []struct {
+		title       string
+		users       []*User
+		callbackErr error
+		committed   bool
+	}{
+		{
+			"committed",
+			[]*User{{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}, {ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}},
+			nil,
+			true,
+		},
+		{
+			"rolled back",
+			[]*User{{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)}, {ID: "3123456789ABCDEFGHJKMNPQRS", Name: "Tom", Age: lo.ToPtr(35)}},
+			errCanceled,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			err := m.WithinTx(ctx, func(ctx context.Context) error {
+				repos, err := RepositoriesFromContext(ctx)
+				require.NoError(t, err)
+				require.NoError(t, repos.Users.Register(ctx, tt.users[0]))
+				require.NoError(t, repos.Users.RegisterAll(ctx, tt.users[1:]))
+				require.NoError(t, repos.Credentials.SetPassword(ctx, tt.users[0].ID, "password"))
+
+				// uncommitted rows are visible only in the transaction
+				for _, u := range tt.users {
+					found, err := repos.Users.Get(ctx, u.ID)
+					require.NoError(t, err)
+					require.Equal(t, u, found)
+
+					_, err = outside.Get(ctx, u.ID)
+					require.ErrorIs(t, err, sql.ErrNoRows)
+				}
+				require.NoError(t, repos.Credentials.VerifyPassword(ctx, tt.users[0].ID, "password"))
+				require.ErrorIs(t, NewCredentialRepository(db).VerifyPassword(ctx, tt.users[0].ID, "password"), ErrInvalidCredentials)
+
+				return tt.callbackErr
+			})
+
+			// assert
+			require.ErrorIs(t, err, tt.callbackErr)
+			for _, u := range tt.users {
+				_, err := outside.Get(ctx, u.ID)
+				if tt.committed {
+					require.NoError(t, err)
+				} else {
+					require.ErrorIs(t, err, sql.ErrNoRows)
+				}
+			}
+			if tt.committed {
+				require.NoError(t, NewCredentialRepository(db).VerifyPassword(ctx, tt.users[0].ID, "password"))
+			}
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestWithinSavepointWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	insertUser := regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")
+	errInner := errors.New("inner failed")
+	errOuter := errors.New("outer failed")
+
+	tests := []struct {
+		title       string
+		mock        func(mock sqlmock.Sqlmock)
+		innerErr    error
+		outerErr    error
+		expectedErr string
+	}{
+		{
+			"nested commit releases the savepoint",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertUser).WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(insertUser).WithArgs(bob.ID, bob.Name, bob.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			nil,
+			nil,
+			"",
+		},
+		{
+			"nested rollback only rolls back to the savepoint",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertUser).WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(insertUser).WithArgs(bob.ID, bob.Name, bob.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			errInner,
+			nil,
+			"",
+		},
+		{
+			"outer rollback rolls back the released savepoint",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertUser).WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(insertUser).WithArgs(bob.ID, bob.Name, bob.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			nil,
+			errOuter,
+			"outer failed",
+		},
+		{
+			"savepoint cannot be rolled back to",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insertUser).WithArgs(mike.ID, mike.Name, mike.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(insertUser).WithArgs(bob.ID, bob.Name, bob.Age, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnError(errors.New("connection refused"))
+				mock.ExpectRollback()
+			},
+			errInner,
+			nil,
+			"failed to roll back to savepoint sp_1: connection refused (error: inner failed)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+			m := NewTxManager(db)
+
+			// run
+			err := m.WithinTx(context.TODO(), func(ctx context.Context) error {
+				repos, err := RepositoriesFromContext(ctx)
+				require.NoError(t, err)
+				if err := repos.Users.Register(ctx, mike); err != nil {
+					return err
+				}
+
+				err = m.WithinSavepoint(ctx, func(ctx context.Context) error {
+					if err := repos.Users.Register(ctx, bob); err != nil {
+						return err
+					}
+					return tt.innerErr
+				})
+				// NOTE: the outer transaction goes on if only the savepoint is rolled back
+				if err != nil && !errors.Is(err, errInner) {
+					return err
+				}
+				return tt.outerErr
+			})
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// savepointScenario registers outer in a transaction and inner in a savepoint of it, which fail by the errors.
+type savepointScenario struct {
+	title          string
+	innerErr       error
+	outerErr       error
+	outerCommitted bool
+	innerCommitted bool
+}
+
+var errSavepointScenario = errors.New("failed")
+
+var savepointScenarios = []savepointScenario{
+	{"nested commit", nil, nil, true, true},
+	{"nested rollback", errSavepointScenario, nil, true, false},
+	{"outer rollback", nil, errSavepointScenario, false, false},
+}
+
+func (s *savepointScenario) run(ctx context.Context, t *testing.T, m *txManager, outer, inner *User) error {
+	return m.WithinTx(ctx, func(ctx context.Context) error {
+		repos, err := RepositoriesFromContext(ctx)
+		require.NoError(t, err)
+		require.NoError(t, repos.Users.Register(ctx, outer))
+
+		err = m.WithinSavepoint(ctx, func(ctx context.Context) error {
+			require.NoError(t, repos.Users.Register(ctx, inner))
+			return s.innerErr
+		})
+		require.ErrorIs(t, err, s.innerErr)
+
+		return s.outerErr
+	})
+}
+
+// test using go-mysql-server
+// NOTE: go-mysql-server accepts savepoints but does not roll back to them, so only nested commits are asserted
+func TestWithinSavepointWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	m := NewTxManager(db)
+
+	for _, s := range savepointScenarios {
+		t.Run(s.title, func(t *testing.T) {
+			outer := NewUserBuilder().Build()
+			inner := NewUserBuilder().Build()
+
+			// run
+			err := s.run(ctx, t, m, outer, inner)
+
+			// assert
+			require.ErrorIs(t, err, s.outerErr)
+			if s.innerCommitted {
+				for _, u := range []*User{outer, inner} {
+					found, err := NewUserRepository(db).Get(ctx, u.ID)
+					require.NoError(t, err)
+					require.Equal(t, u, found)
+				}
+			}
+		})
+	}
+}
+
+// test using testcontainers
+// NOTE: go-mysql-server does not roll back to savepoints
+func TestWithinSavepointWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+	m := NewTxManager(db)
+
+	for _, s := range savepointScenarios {
+		t.Run(s.title, func(t *testing.T) {
+			outer := NewUserBuilder().Build()
+			inner := NewUserBuilder().Build()
+
+			// run
+			err := s.run(ctx, t, m, outer, inner)
+
+			// assert
+			require.ErrorIs(t, err, s.outerErr)
+			for _, tt := range []struct {
+				user      *User
+				committed bool
+			}{
+				{outer, s.outerCommitted},
+				{inner, s.innerCommitted},
+			} {
+				_, err := NewUserRepository(db).Get(ctx, tt.user.ID)
+				if tt.committed {
+					require.NoError(t, err)
+				} else {
+					require.ErrorIs(t, err, sql.ErrNoRows)
+				}
+			}
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestNestedWithinSavepointWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp_3").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_3").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	m := NewTxManager(db)
+	errInner := errors.New("inner failed")
+
+	// run
+	// NOTE: the outermost one begins the transaction as WithinTx
+	err := m.WithinSavepoint(context.TODO(), func(ctx context.Context) error {
+		err := m.WithinSavepoint(ctx, func(ctx context.Context) error {
+			err := m.WithinSavepoint(ctx, func(ctx context.Context) error {
+				return errInner
+			})
+			if errors.Is(err, errInner) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// NOTE: savepoints are named uniquely in the transaction
+		return m.WithinSavepoint(ctx, func(ctx context.Context) error {
+			return nil
+		})
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,210 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-sqlmock
+func TestUpsertWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title         string
+		updateColumns []string
+		expectedQuery string
+	}{
+		{
+			"name and age are updated by default",
+			nil,
+			"INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`),`age` = VALUES(`age`),`updated_at` = VALUES(`updated_at`),`version` = `version` + 1",
+		},
+		{
+			"only age is updated",
+			[]string{"age"},
+			"INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?) ON DUPLICATE KEY UPDATE `age` = VALUES(`age`),`updated_at` = VALUES(`updated_at`),`version` = `version` + 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			mock.ExpectExec(regexp.QuoteMeta(tt.expectedQuery)).
+				WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			// run
+			r := NewUserRepository(db)
+			err := r.Upsert(context.TODO(), &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}, tt.updateColumns...)
+
+			// assert
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpsertInvalidColumnWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+
+	// run
+	r := NewUserRepository(db)
+	err := r.Upsert(context.TODO(), &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}, "id")
+
+	// assert
+	require.EqualError(t, err, "column cannot be updated by upsert: id")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type upsertUserRepository interface {
+	UserRepository
+	Upsert(ctx context.Context, user *User, updateColumns ...string) error
+}
+
+type upsertCase struct {
+	title         string
+	seed          []*User
+	user          *User
+	updateColumns []string
+	expected      []*User
+	// expectedErr is the number of the MySQL error
+	expectedErr uint16
+}
+
+func upsertCases() []*upsertCase {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+
+	return []*upsertCase{
+		{
+			"new user is registered",
+			[]*User{bob},
+			mike,
+			nil,
+			[]*User{mike, bob},
+			0,
+		},
+		{
+			"user of the same id is updated",
+			[]*User{mike, bob},
+			&User{ID: mike.ID, Name: "Michael", Age: lo.ToPtr(21)},
+			nil,
+			[]*User{{ID: mike.ID, Name: "Michael", Age: lo.ToPtr(21)}, bob},
+			0,
+		},
+		{
+			"user of the same name is updated and keeps its id",
+			[]*User{mike},
+			&User{ID: bob.ID, Name: "Mike", Age: lo.ToPtr(30)},
+			nil,
+			[]*User{{ID: mike.ID, Name: "Mike", Age: lo.ToPtr(30)}},
+			0,
+		},
+		{
+			"user of the same email is updated and keeps its id",
+			[]*User{{ID: mike.ID, Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}},
+			&User{ID: bob.ID, Name: "Michael", Age: lo.ToPtr(21), Email: "mike@example.com"},
+			nil,
+			[]*User{{ID: mike.ID, Name: "Michael", Age: lo.ToPtr(21), Email: "mike@example.com"}},
+			0,
+		},
+		{
+			"only specified columns are updated",
+			[]*User{mike},
+			&User{ID: mike.ID, Name: "Michael", Age: lo.ToPtr(21)},
+			[]string{"age"},
+			[]*User{{ID: mike.ID, Name: "Mike", Age: lo.ToPtr(21)}},
+			0,
+		},
+		{
+			"updated name conflicts with another user",
+			[]*User{mike, bob},
+			&User{ID: mike.ID, Name: "Bob", Age: lo.ToPtr(20)},
+			nil,
+			[]*User{mike, bob},
+			1062,
+		},
+	}
+}
+
+// assertUpsert checks the error and the users after Upsert.
+// If atomic is false, users are not checked after an error because the failed statement may be applied partially.
+func assertUpsert(t *testing.T, r upsertUserRepository, tt *upsertCase, atomic bool) {
+	ctx := context.Background()
+	for _, u := range tt.seed {
+		require.NoError(t, r.Register(ctx, u))
+	}
+
+	// run
+	err := r.Upsert(ctx, tt.user, tt.updateColumns...)
+
+	// assert
+	if tt.expectedErr != 0 {
+		var mysqlErr *mysql.MySQLError
+		require.True(t, errors.As(err, &mysqlErr), "unexpected error: %v", err)
+		require.Equal(t, tt.expectedErr, mysqlErr.Number)
+		if !atomic {
+			return
+		}
+	} else {
+		require.NoError(t, err)
+	}
+	users, _, err := r.List(ctx, nil)
+	require.NoError(t, err)
+	// NOTE: timestamps of upserted users are checked by assertUserTimestamps
+	require.Equal(t, withoutTimestamps(tt.expected...), withoutTimestamps(users...))
+}
+
+// test using go-mysql-server
+func TestUpsertWithGoMySQLServer(t *testing.T) {
+	for _, tt := range upsertCases() {
+		t.Run(tt.title, func(t *testing.T) {
+			ctx := context.Background()
+			port, err := testport.Reserve()
+			require.NoError(t, err)
+
+			// simulator
+			// NOTE: tables are created by migrations to have the unique key of name
+			prepareEmptySimulator(t, port)
+			db, err := newMigrationClient(port)
+			require.NoError(t, err)
+			require.NoError(t, Migrate(ctx, db))
+
+			// NOTE: go-mysql-server memory database does not roll back a failed statement
+			assertUpsert(t, NewUserRepository(db), tt, false)
+		})
+	}
+}
+
+// test using testcontainers
+func TestUpsertWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	for _, tt := range upsertCases() {
+		t.Run(tt.title, func(t *testing.T) {
+			require.NoError(t, truncateTables(ctx, db, "practice"))
+
+			assertUpsert(t, NewUserRepository(db), tt, true)
+		})
+	}
+}
+
+// test using the in-memory fake
+func TestUpsertWithInMemory(t *testing.T) {
+	for _, tt := range upsertCases() {
+		t.Run(tt.title, func(t *testing.T) {
+			assertUpsert(t, NewInMemoryUserRepository(), tt, true)
+		})
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/samber/lo"
 	"github.com/volatiletech/null/v8"
 	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
 
 	"github.com/syuparn/gosqltests/models"
 )
@@ -16,76 +20,540 @@ import (
 type User struct {
 	ID   string
 	Name string
-	Age  int
+	// Age is nil if it is unknown (NULL).
+	Age *int
+	// Email is unique among users. Empty string means the user has no email.
+	Email string
+	// CreatedAt and UpdatedAt are set by the repository when the user is registered and updated
+	// (zero until then). They are in UTC and truncated to seconds as DATETIME columns store them.
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
+// String formats the user like %+v of the struct, showing the age instead of its address (without timestamps).
+func (u *User) String() string {
+	age := "<nil>"
+	if u.Age != nil {
+		age = strconv.Itoa(*u.Age)
+	}
+	return fmt.Sprintf("&{ID:%s Name:%s Age:%s Email:%s}", u.ID, u.Name, age, u.Email)
+}
+
+// setInsertTimestamps sets CreatedAt and UpdatedAt which are not set yet to now, as sqlboiler does on insert.
+func setInsertTimestamps(user *User, now time.Time) {
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+}
+
+// default timeouts of each repository operation
+// (the caller's deadline is used instead if it is shorter)
+const (
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+)
+
 type userRepository struct {
-	db *sql.DB
+	// db is *sql.DB, or *sql.Tx in Repositories of WithinTx
+	db           boil.ContextExecutor
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	listStrategy string
+	// readRetry retries statements of read-only methods if set
+	readRetry *retrier
+	// transientRetry retries operations failed by transient errors, keyed by the operation names
+	transientRetry map[string]*retrier
+	// audit records writes in audit_log if set
+	audit *auditor
+	// tenant scopes Register, List, Get, GetByName and Delete to users of the tenant by user_tenant if set (see TenantColumn)
+	tenant string
+	// replica serves reads of WithReplica if set
+	replica *sql.DB
+	// clock sets created_at and updated_at of users
+	clock Clock
+	// ids generates ids of users registered without them
+	ids IDGenerator
+	// entities implements Get, Register and Delete with the options above
+	entities *Repository[User, string]
 }
 
-func NewUserRepository(db *sql.DB) *userRepository {
-	return &userRepository{
-		db: db,
+type UserRepositoryOption func(*userRepository)
+
+// WithReadTimeout sets the timeout of Get, GetByName and List. Non-positive value disables it.
+func WithReadTimeout(d time.Duration) UserRepositoryOption {
+	return func(r *userRepository) {
+		r.readTimeout = d
 	}
 }
 
+// WithWriteTimeout sets the timeout of Register, RegisterAll, Delete, HardDelete and Restore. Non-positive value disables it.
+func WithWriteTimeout(d time.Duration) UserRepositoryOption {
+	return func(r *userRepository) {
+		r.writeTimeout = d
+	}
+}
+
+// WithListStrategy selects the implementation of List registered by the name, e.g. by a feature flag.
+// List fails if no strategy is registered by the name.
+func WithListStrategy(name string) UserRepositoryOption {
+	return func(r *userRepository) {
+		r.listStrategy = name
+	}
+}
+
+// WithReadRetry retries statements of Get, GetByName, GetByEmail, List, Count and Exists up to maxAttempts times
+// if the connection is lost, doubling the wait from backoff. The read timeout covers all attempts.
+// NOTE: write methods are never retried by this option because a statement sent before the connection is lost
+// may have been executed (see NewRetryUserRepository to retry writes which were not sent)
+func WithReadRetry(maxAttempts int, backoff time.Duration) UserRepositoryOption {
+	return func(r *userRepository) {
+		r.readRetry = &retrier{
+			maxAttempts: maxAttempts,
+			backoff:     backoff,
+			clock:       systemClock{},
+		}
+	}
+}
+
+// WithTransientRetry retries the operations (all operations if none is given) failed by transient errors
+// (see IsTransient) by the policy. The timeout of each operation covers all attempts.
+// Writes are retried only if the server rolled them back (deadlocks and lock wait timeouts) or they were not sent,
+// and a write with WithAuditLog is retried with its audit log as a whole.
+// It panics if the policy is invalid or an operation is unknown, as it is a mistake of the configuration.
+// NOTE: operations of Repositories of WithinTx are not retried, because a deadlock rolls back the whole transaction
+// (retry WithinTx itself instead)
+func WithTransientRetry(policy RetryPolicy, operations ...string) UserRepositoryOption {
+	if err := policy.validate(); err != nil {
+		panic(fmt.Sprintf("invalid retry policy: %s", err))
+	}
+	if len(operations) == 0 {
+		operations = userOperations
+	}
+	for _, op := range operations {
+		if !lo.Contains(userOperations, op) {
+			panic(fmt.Sprintf("unknown operation: %s", op))
+		}
+	}
+
+	return func(r *userRepository) {
+		if r.transientRetry == nil {
+			r.transientRetry = map[string]*retrier{}
+		}
+		for _, op := range operations {
+			r.transientRetry[op] = policy.retrier(systemClock{})
+		}
+	}
+}
+
+// WithReplica reads users by Get, GetByName, GetByEmail, GetMany, List, ListAfter, ListStream, Count, Exists and Search
+// from the replica. Writes, GetVersioned and QueryRaw still use the primary db.
+// Reads of the replica may not see recent writes yet (replica lag); use GetConsistent to read the primary instead.
+// NOTE: Repositories of WithinTx read the transaction of the primary, which sees its own writes
+func WithReplica(replica *sql.DB) UserRepositoryOption {
+	return func(r *userRepository) {
+		r.replica = replica
+	}
+}
+
+// WithClock sets the clock of created_at and updated_at of users, e.g. to fix them in tests.
+func WithClock(clock Clock) UserRepositoryOption {
+	return func(r *userRepository) {
+		r.clock = clock
+	}
+}
+
+// WithIDGenerator sets the generator of ids of users registered without them (ULIDs by NewUserID by default),
+// e.g. to predict the ids in tests.
+func WithIDGenerator(ids IDGenerator) UserRepositoryOption {
+	return func(r *userRepository) {
+		r.ids = ids
+	}
+}
+
+func NewUserRepository(db *sql.DB, opts ...UserRepositoryOption) *userRepository {
+	return newUserRepository(db, opts...)
+}
+
+func newUserRepository(db boil.ContextExecutor, opts ...UserRepositoryOption) *userRepository {
+	r := &userRepository{
+		db:           db,
+		readTimeout:  defaultReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+		clock:        systemClock{},
+		ids:          ulidGenerator{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.entities = newRepository[User, string](db, &userAdapter{r: r})
+	r.entities.reader = r.reader()
+	r.entities.readTimeout = r.readTimeout
+	r.entities.writeTimeout = r.writeTimeout
+	r.entities.scope = r.scoped
+	r.entities.read = r.retryRead
+	r.entities.write = func(ctx context.Context, operation string, id string, f func(context.Context, boil.ContextExecutor) error) error {
+		return r.retryWrite(ctx, operation, func() error {
+			return r.audited(ctx, id, nil, f)
+		})
+	}
+	return r
+}
+
+// retryRead calls f, which must be read-only, with the retry of WithTransientRetry for the operation or WithReadRetry.
+func (r *userRepository) retryRead(ctx context.Context, operation string, f func() error) error {
+	if rt := r.transientRetrier(operation); rt != nil {
+		return rt.retry(ctx, IsTransient, f)
+	}
+	if r.readRetry == nil {
+		return f()
+	}
+	return r.readRetry.retry(ctx, isConnectionLost, f)
+}
+
+// retryWrite calls f with the retry of WithTransientRetry for the operation.
+func (r *userRepository) retryWrite(ctx context.Context, operation string, f func() error) error {
+	rt := r.transientRetrier(operation)
+	if rt == nil {
+		return f()
+	}
+	return rt.retry(ctx, isRetryableWrite, f)
+}
+
+// reader returns the executor of reads which may be stale, i.e. the replica of WithReplica outside transactions.
+func (r *userRepository) reader() boil.ContextExecutor {
+	if _, ok := r.db.(*sql.DB); !ok || r.replica == nil {
+		return r.db
+	}
+	return r.replica
+}
+
+// transientRetrier returns nil if the operation is not retried.
+func (r *userRepository) transientRetrier(operation string) *retrier {
+	if _, ok := r.db.(*sql.DB); !ok {
+		return nil
+	}
+	return r.transientRetry[operation]
+}
+
+// now returns the time of created_at and updated_at written by the repository.
+func (r *userRepository) now() time.Time {
+	return timestampOf(r.clock)
+}
+
+// timestampOf returns the current time of the clock as created_at and updated_at.
+// NOTE: DATETIME columns store seconds, so that the time is truncated to be the same as the one read back
+func timestampOf(clock Clock) time.Time {
+	return clock.Now().UTC().Truncate(time.Second)
+}
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// Register inserts the user. It returns ErrEmailTaken if another user has the same email.
+// The user gets an id by the IDGenerator of the repository if its ID is empty.
 func (r *userRepository) Register(ctx context.Context, user *User) error {
-	c := &models.User{
-		ID:   user.ID,
-		Name: user.Name,
-		Age:  null.IntFrom(user.Age),
+	r.assignID(user)
+	return r.entities.Register(ctx, user)
+}
+
+// assignID sets a generated id to the user without id.
+func (r *userRepository) assignID(user *User) {
+	if user.ID == "" {
+		user.ID = r.ids.NewUserID().String()
 	}
+}
+
+// columns which Upsert can update on conflict
+var upsertColumns = []string{models.UserColumns.Name, models.UserColumns.Age}
 
-	if err := c.Insert(ctx, r.db, boil.Infer()); err != nil {
-		return fmt.Errorf("failed to insert user: %w", err)
+// Upsert registers the user, or updates the user of the same id, name or email if it exists.
+// Only updateColumns (name and age by default) and updated_at are updated on conflict.
+// NOTE: a user of the same name or email keeps its id and created_at, and a soft-deleted user stays deleted
+func (r *userRepository) Upsert(ctx context.Context, user *User, updateColumns ...string) error {
+	if _, err := ParseUserID(user.ID); err != nil {
+		return err
+	}
+	if len(updateColumns) == 0 {
+		updateColumns = upsertColumns
+	}
+	for _, c := range updateColumns {
+		if !lo.Contains(upsertColumns, c) {
+			return fmt.Errorf("column cannot be updated by upsert: %s", c)
+		}
 	}
 
-	return nil
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	now := r.now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	// NOTE: updateColumns must not be appended to, as it may be upsertColumns
+	updated := append(append([]string{}, updateColumns...), models.UserColumns.UpdatedAt)
+
+	conflict := func(ctx context.Context, exec boil.ContextExecutor) (*models.User, error) {
+		c := toUserModel(user)
+		mods := []qm.QueryMod{
+			models.UserWhere.ID.EQ(c.ID),
+			qm.Or2(models.UserWhere.Name.EQ(c.Name)),
+		}
+		// NOTE: users without email do not conflict by email (it would match every user whose email IS NULL)
+		if user.Email != "" {
+			mods = append(mods, qm.Or2(models.UserWhere.Email.EQ(c.Email)))
+		}
+		return findUserForUpdate(ctx, exec, qm.Expr(mods...))
+	}
+	return r.retryWrite(ctx, OperationUpsert, func() error {
+		return r.audited(ctx, user.ID, conflict, func(ctx context.Context, exec boil.ContextExecutor) error {
+			if err := upsertUser(ctx, exec, toUserModel(user), updated); err != nil {
+				return fmt.Errorf("failed to upsert user: %w", wrapEmailTakenError(wrapStorageError(err), user))
+			}
+
+			return nil
+		})
+	})
 }
 
-func (r *userRepository) List(ctx context.Context) ([]*User, error) {
-	users, err := models.Users().All(ctx, r.db)
+// upsertUser inserts the user, or updates columns of the conflicting user and increments its version.
+// NOTE: sqlboiler can neither increment the version on conflict nor keep updated_at of the clock of the repository
+func upsertUser(ctx context.Context, exec boil.ContextExecutor, u *models.User, columns []string) error {
+	updates := lo.Map(columns, func(c string, _ int) string {
+		return fmt.Sprintf("`%s` = VALUES(`%s`)", c, c)
+	})
+	updates = append(updates, fmt.Sprintf("`%s` = `%s` + 1", models.UserColumns.Version, models.UserColumns.Version))
+
+	query := fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+		models.TableNames.User, strings.Join(userColumnNames, "`,`"),
+		strings.TrimSuffix(strings.Repeat("?,", len(userColumnNames)), ","), strings.Join(updates, ","))
+	_, err := exec.ExecContext(ctx, query, u.ID, u.Name, u.Age, u.DeletedAt, u.Email, u.Version, u.CreatedAt, u.UpdatedAt)
+	return err
+}
+
+func (r *userRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	filters, err := query.filters()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
+		return nil, 0, fmt.Errorf("invalid list query: %w", err)
 	}
+	filters = r.scoped(filters...)
 
-	return lo.Map(users, func(c *models.User, _ int) *User {
-		return &User{
-			ID:   c.ID,
-			Name: c.Name,
-			Age:  c.Age.Int,
-		}
-	}), nil
+	strategy, err := lookupListStrategy(r.listStrategy)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var users []*User
+	var total int64
+	err = r.retryRead(ctx, OperationList, func() error {
+		var err error
+		users, total, err = strategy(ctx, r.reader(), filters, query.pagination())
+		return err
+	})
+	return users, total, err
+}
+
+// Count returns the number of users matching the filters of query. Pagination of query is ignored.
+func (r *userRepository) Count(ctx context.Context, query *ListQuery) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	filters, err := query.filters()
+	if err != nil {
+		return 0, fmt.Errorf("invalid list query: %w", err)
+	}
+
+	var total int64
+	err = r.retryRead(ctx, OperationCount, func() error {
+		var err error
+		total, err = models.Users(r.scoped(filters...)...).Count(ctx, r.reader())
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return total, nil
+}
+
+// Exists reports whether the user exists without fetching the row.
+func (r *userRepository) Exists(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var exists bool
+	err := r.retryRead(ctx, OperationExists, func() error {
+		var err error
+		exists, err = models.Users(r.scoped(userByID(id))...).Exists(ctx, r.reader())
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check user (id: %s): %w", id, err)
+	}
+
+	return exists, nil
+}
+
+// scoped adds the query mod narrowing down users to the tenant of the repository, if any.
+func (r *userRepository) scoped(mods ...qm.QueryMod) []qm.QueryMod {
+	if r.tenant == "" {
+		return mods
+	}
+	return append(mods, qm.Where(
+		fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE %s = ?)",
+			quotedColumn(models.TableNames.User, models.UserColumns.ID),
+			quotedColumn(models.TableNames.UserTenant, models.UserTenantColumns.UserID),
+			quoteIdentifier(models.TableNames.UserTenant),
+			quotedColumn(models.TableNames.UserTenant, models.UserTenantColumns.TenantID),
+		),
+		r.tenant,
+	))
+}
+
+// checkTenant returns sql.ErrNoRows if the user does not belong to the tenant of the repository,
+// so that a tenant cannot even tell whether users of other tenants exist.
+func (r *userRepository) checkTenant(ctx context.Context, exec boil.ContextExecutor, id string) error {
+	if r.tenant == "" {
+		return nil
+	}
+
+	exists, err := models.UserTenants(
+		models.UserTenantWhere.UserID.EQ(id),
+		models.UserTenantWhere.TenantID.EQ(r.tenant),
+	).Exists(ctx, exec)
+	if err != nil {
+		return fmt.Errorf("failed to check tenant of user (id: %s): %w", id, err)
+	}
+	if !exists {
+		return fmt.Errorf("user was not found (id: %s): %w", id, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// query mods of reads, which are shared with the expectations of sqlmock (see mockexpect.go)
+
+func userByID(id string) qm.QueryMod {
+	return models.UserWhere.ID.EQ(id)
+}
+
+func userByName(name string) qm.QueryMod {
+	return models.UserWhere.Name.EQ(name)
 }
 
 func (r *userRepository) Get(ctx context.Context, id string) (*User, error) {
-	user, err := models.Users(
-		models.UserWhere.ID.EQ(string(id)),
-	).One(ctx, r.db)
+	return r.entities.Get(ctx, id)
+}
+
+// GetConsistent returns the user of the id as Get, but always reads the primary,
+// so that it sees writes which the replica of WithReplica has not applied yet (e.g. right after Register).
+func (r *userRepository) GetConsistent(ctx context.Context, id string) (*User, error) {
+	return r.entities.GetConsistent(ctx, id)
+}
+
+func (r *userRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var user *models.User
+	err := r.retryRead(ctx, OperationGetByName, func() error {
+		var err error
+		user, err = models.Users(r.scoped(userByName(name))...).One(ctx, r.reader())
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user was not found (id: %s): %w", id, err)
+			return nil, fmt.Errorf("user was not found (name: %s): %w", name, err)
 		}
 
-		return nil, fmt.Errorf("failed to get user (id: %s): %w", id, err)
+		return nil, fmt.Errorf("failed to get user (name: %s): %w", name, err)
 	}
 
-	return &User{
-		ID:   user.ID,
-		Name: user.Name,
-		Age:  user.Age.Int,
-	}, nil
+	return fromUserModel(user), nil
 }
 
-func (r *userRepository) Delete(ctx context.Context, user *User) error {
-	c := &models.User{
-		ID:   string(user.ID),
-		Name: string(user.Name),
-	}
+// GetByEmail returns the user of the email. Users without email are never found.
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var user *models.User
+	err := r.retryRead(ctx, OperationGetByEmail, func() error {
+		var err error
+		user, err = models.Users(r.scoped(
+			models.UserWhere.Email.EQ(null.StringFrom(email)),
+		)...).One(ctx, r.reader())
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user was not found (email: %s): %w", email, err)
+		}
 
-	if _, err := c.Delete(ctx, r.db); err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return nil, fmt.Errorf("failed to get user (email: %s): %w", email, err)
 	}
 
-	return nil
+	return fromUserModel(user), nil
+}
+
+// Delete soft-deletes the user, which is excluded from Get, GetByName and List until Restore is called.
+// NOTE: the name of a soft-deleted user cannot be used by other users because the row still exists
+func (r *userRepository) Delete(ctx context.Context, user *User) error {
+	return r.entities.Delete(ctx, user)
+}
+
+// HardDelete removes the row of the user regardless of whether it is soft-deleted.
+// It returns ErrUserHasOrders if the user has orders.
+func (r *userRepository) HardDelete(ctx context.Context, user *User) error {
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	return r.retryWrite(ctx, OperationHardDelete, func() error {
+		return r.audited(ctx, user.ID, nil, func(ctx context.Context, exec boil.ContextExecutor) error {
+			c := toUserModel(user)
+
+			if _, err := c.Delete(ctx, exec, true); err != nil {
+				return fmt.Errorf("failed to hard delete user: %w", wrapUserHasOrdersError(err, user.ID))
+			}
+
+			return nil
+		})
+	})
+}
+
+// Restore undoes the soft deletion of the user.
+func (r *userRepository) Restore(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	return r.retryWrite(ctx, OperationRestore, func() error {
+		return r.audited(ctx, id, nil, func(ctx context.Context, exec boil.ContextExecutor) error {
+			n, err := models.Users(
+				qm.WithDeleted(),
+				models.UserWhere.ID.EQ(id),
+				models.UserWhere.DeletedAt.IsNotNull(),
+			).UpdateAll(ctx, exec, models.M{
+				models.UserColumns.DeletedAt: nil,
+				models.UserColumns.UpdatedAt: r.now(),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to restore user (id: %s): %w", id, err)
+			}
+			if n == 0 {
+				return fmt.Errorf("deleted user was not found (id: %s): %w", id, sql.ErrNoRows)
+			}
+
+			return nil
+		})
+	})
 }
@@ -0,0 +1,76 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/volatiletech/sqlboiler/v4/boil"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// userAdapter is the EntityAdapter of users, on which userRepository implements Get, Register and Delete.
+// It reads the tenant of the repository when it is called, because TenantColumn sets it after construction.
+type userAdapter struct {
+	r *userRepository
+}
+
+func (a *userAdapter) Name() string {
+	return "user"
+}
+
+func (a *userAdapter) ID(user *User) string {
+	return user.ID
+}
+
+func (a *userAdapter) ValidateID(id string) error {
+	_, err := ParseUserID(id)
+	return err
+}
+
+func (a *userAdapter) ByID(id string) qm.QueryMod {
+	return userByID(id)
+}
+
+func (a *userAdapter) One(ctx context.Context, exec boil.ContextExecutor, mods ...qm.QueryMod) (*User, error) {
+	user, err := models.Users(mods...).One(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	return fromUserModel(user), nil
+}
+
+func (a *userAdapter) All(ctx context.Context, exec boil.ContextExecutor, mods ...qm.QueryMod) ([]*User, error) {
+	users, err := models.Users(mods...).All(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(users, func(u *models.User, _ int) *User { return fromUserModel(u) }), nil
+}
+
+// Insert inserts the user, and its tenant in the same transaction if the repository has one.
+// It returns ErrEmailTaken if another user has the same email.
+func (a *userAdapter) Insert(ctx context.Context, exec boil.ContextExecutor, user *User) error {
+	setInsertTimestamps(user, a.r.now())
+	if err := toUserModel(user).Insert(ctx, exec, boil.Infer()); err != nil {
+		return wrapEmailTakenError(wrapStorageError(err), user)
+	}
+	if a.r.tenant != "" {
+		t := &models.UserTenant{UserID: user.ID, TenantID: a.r.tenant}
+		if err := t.Insert(ctx, exec, boil.Infer()); err != nil {
+			return fmt.Errorf("failed to insert tenant of user: %w", err)
+		}
+	}
+	return nil
+}
+
+// Delete soft-deletes the user if it belongs to the tenant of the repository.
+func (a *userAdapter) Delete(ctx context.Context, exec boil.ContextExecutor, user *User) error {
+	if err := a.r.checkTenant(ctx, exec, user.ID); err != nil {
+		return err
+	}
+	_, err := toUserModel(user).Delete(ctx, exec, false)
+	return err
+}
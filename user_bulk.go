@@ -0,0 +1,132 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/v4/boil"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// number of rows inserted by one INSERT statement
+// (MySQL allows up to 65535 placeholders in a statement)
+const registerAllChunkSize = 1000
+
+// RowError is an error of a row in RegisterAll.
+type RowError struct {
+	Index int
+	ID    string
+	Err   error
+}
+
+// RegisterAllError reports all rows which could not be inserted.
+type RegisterAllError struct {
+	Total    int
+	Failures []*RowError
+}
+
+func (e *RegisterAllError) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		msgs = append(msgs, fmt.Sprintf("[%d] (id: %s) %s", f.Index, f.ID, f.Err))
+	}
+	return fmt.Sprintf("failed to register %d of %d users: %s", len(e.Failures), e.Total, strings.Join(msgs, ", "))
+}
+
+// RegisterAll inserts all users in a single transaction. If any of them fails, nothing is inserted.
+func (r *userRepository) RegisterAll(ctx context.Context, users []*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	now := r.now()
+	var invalid []*RowError
+	for i, user := range users {
+		r.assignID(user)
+		setInsertTimestamps(user, now)
+		if _, err := ParseUserID(user.ID); err != nil {
+			invalid = append(invalid, &RowError{Index: i, ID: user.ID, Err: err})
+		}
+	}
+	if len(invalid) > 0 {
+		return &RegisterAllError{Total: len(users), Failures: invalid}
+	}
+
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failures []*RowError
+	for start := 0; start < len(users); start += registerAllChunkSize {
+		end := start + registerAllChunkSize
+		if end > len(users) {
+			end = len(users)
+		}
+
+		err := insertUsers(ctx, tx, users[start:end])
+		if err == nil {
+			continue
+		}
+		// NOTE: every row would fail for the same reason
+		if errors.Is(err, ErrStorageFull) {
+			return err
+		}
+
+		// NOTE: MySQL does not tell which row violated constraints, so insert rows one by one to find them
+		for i, user := range users[start:end] {
+			c := toUserModel(user)
+			if err := c.Insert(ctx, tx, boil.Infer()); err != nil {
+				failures = append(failures, &RowError{Index: start + i, ID: user.ID, Err: wrapEmailTakenError(err, user)})
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return &RegisterAllError{Total: len(users), Failures: failures}
+	}
+
+	// NOTE: the inserted rows are recorded as given instead of being read back one by one
+	if r.audit != nil {
+		for _, user := range users {
+			if err := r.audit.insert(ctx, tx, user.ID, nil, toUserModel(user)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func insertUsers(ctx context.Context, exec boil.ContextExecutor, users []*User) error {
+	columns := []string{
+		models.UserColumns.ID, models.UserColumns.Name, models.UserColumns.Age, models.UserColumns.Email,
+		models.UserColumns.CreatedAt, models.UserColumns.UpdatedAt,
+	}
+
+	rows := make([]string, 0, len(users))
+	args := make([]interface{}, 0, len(users)*len(columns))
+	for _, user := range users {
+		m := toUserModel(user)
+		rows = append(rows, "(?,?,?,?,?,?)")
+		args = append(args, m.ID, m.Name, m.Age, m.Email, m.CreatedAt, m.UpdatedAt)
+	}
+
+	query := fmt.Sprintf("INSERT INTO `user` (`%s`) VALUES %s", strings.Join(columns, "`,`"), strings.Join(rows, ","))
+	if _, err := exec.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert users: %w", wrapStorageError(err))
+	}
+
+	return nil
+}
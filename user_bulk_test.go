@@ -0,0 +1,225 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dolthub/go-mysql-server/memory"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/seed"
+)
+
+// test using go-sqlmock
+func TestRegisterAllWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	clock := newFakeClock()
+	now := clock.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`email`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?),(?,?,?,?,?,?)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, now, now, "1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, now, now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	// run
+	r := NewUserRepository(db, WithClock(clock))
+	err := r.RegisterAll(context.TODO(), []*User{
+		{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegisterAllErrorWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`email`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?),(?,?,?,?,?,?)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute), "1123456789ABCDEFGHJKMNPQRS", "Mike", 25, nil, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+		WillReturnError(fmt.Errorf("Error 1062: Duplicate entry 'Mike' for key 'user.name'"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")).
+		WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")).
+		WithArgs("1123456789ABCDEFGHJKMNPQRS", "Mike", 25, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+		WillReturnError(fmt.Errorf("Error 1062: Duplicate entry 'Mike' for key 'user.name'"))
+	mock.ExpectRollback()
+
+	// run
+	r := NewUserRepository(db)
+	err := r.RegisterAll(context.TODO(), []*User{
+		{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(25)},
+	})
+
+	// assert
+	require.EqualError(t, err, "failed to register 1 of 2 users: [1] (id: 1123456789ABCDEFGHJKMNPQRS) models: unable to insert into user: Error 1062: Duplicate entry 'Mike' for key 'user.name'")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegisterAllInvalidIDWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+
+	// run
+	r := NewUserRepository(db)
+	err := r.RegisterAll(context.TODO(), []*User{
+		{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		{ID: "bob", Name: "Bob", Age: lo.ToPtr(25)},
+	})
+
+	// assert
+	// NOTE: no transaction is started
+	require.EqualError(t, err, "failed to register 1 of 2 users: [1] (id: bob) invalid user id (id: bob): ulid: bad data size when unmarshaling")
+	var registerAllErr *RegisterAllError
+	require.True(t, errors.As(err, &registerAllErr))
+	require.True(t, errors.Is(registerAllErr.Failures[0].Err, ErrInvalidUserID))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestRegisterAllWithGoMySQLServer(t *testing.T) {
+	// NOTE: more than registerAllChunkSize to insert in multiple statements
+	users := generateUsers(t, 2500)
+
+	// simulator
+	prepareSimulator(t, 23306)
+
+	// run
+	db, err := NewStrictClient(23306)
+	require.NoError(t, err)
+	r := NewUserRepository(db)
+	err = r.RegisterAll(context.TODO(), users)
+
+	// assert
+	require.NoError(t, err)
+	// NOTE: check rows at the boundaries of chunks
+	for _, i := range []int{0, registerAllChunkSize - 1, registerAllChunkSize, len(users) - 1} {
+		AssertPersisted(t, context.TODO(), db, users[i])
+	}
+	found, total, err := r.List(context.TODO(), nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(users)), total)
+	require.Equal(t, users, found)
+}
+
+func TestRegisterAllErrorWithGoMySQLServer(t *testing.T) {
+	tests := []struct {
+		title            string
+		users            []*User
+		prepare          func(*simsql.Context, *memory.Table)
+		expectedFailures []int
+	}{
+		{
+			"id already exists",
+			[]*User{
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+				{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)},
+			},
+			func(ctx *simsql.Context, table *memory.Table) {
+				_ = table.Insert(ctx, simsql.NewRow(
+					"0123456789ABCDEFGHJKMNPQRS",
+					"Mike",
+					int32(20),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
+				))
+			},
+			[]int{1},
+		},
+		{
+			"duplicated ids in arguments",
+			[]*User{
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+				{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)},
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bill", Age: lo.ToPtr(35)},
+			},
+			func(ctx *simsql.Context, table *memory.Table) {},
+			[]int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			table := prepareSimulator(t, 23306)
+			tt.prepare(simsql.NewEmptyContext(), table)
+
+			// run
+			db, err := NewStrictClient(23306)
+			require.NoError(t, err)
+			r := NewUserRepository(db)
+			err = r.RegisterAll(context.TODO(), tt.users)
+
+			// assert
+			// NOTE: go-mysql-server memory database does not roll back transactions,
+			// so atomicity is checked only with sqlmock and testcontainers
+			var registerAllErr *RegisterAllError
+			require.True(t, errors.As(err, &registerAllErr))
+			require.Equal(t, tt.expectedFailures, lo.Map(registerAllErr.Failures, func(f *RowError, _ int) int { return f.Index }))
+		})
+	}
+}
+
+// test using testcontainers
+func TestRegisterAllErrorWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	// run
+	r := NewUserRepository(db)
+	err := r.RegisterAll(ctx, []*User{
+		{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+		{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(25)},
+	})
+
+	// assert
+	var registerAllErr *RegisterAllError
+	require.True(t, errors.As(err, &registerAllErr))
+	require.Equal(t, 1, registerAllErr.Failures[0].Index)
+
+	// all rows are rolled back
+	_, total, err := r.List(ctx, nil)
+	require.NoError(t, err)
+	require.Zero(t, total)
+}
+
+func TestSeedInsertWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	users := generateUsers(t, 1500)
+
+	// simulator
+	prepareSimulator(t, 23306)
+
+	// run
+	db, err := NewStrictClient(23306)
+	require.NoError(t, err)
+	err = seed.Insert(ctx, db, lo.Map(users, func(u *User, _ int) *seed.User {
+		return &seed.User{ID: u.ID, Name: u.Name, Age: u.Age, Email: u.Email}
+	}))
+
+	// assert
+	require.NoError(t, err)
+	// NOTE: seed.Insert leaves timestamps to the defaults of the columns
+	found, total, err := NewUserRepository(db).List(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(users)), total)
+	require.ElementsMatch(t, users, withoutTimestamps(found...))
+	for _, u := range found {
+		require.False(t, u.CreatedAt.IsZero())
+	}
+}
@@ -0,0 +1,90 @@
+package gosqltests
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrInvalidCursor is returned by ListAfter if the cursor was not returned by ListAfter.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// max number of users in a page of ListAfter
+const maxCursorLimit = 1000
+
+// ListAfter returns up to limit users after the cursor in the order of ids, and the cursor of the next page.
+// The first page is returned by the empty cursor, and the next cursor is empty after the last page.
+// Unlike List with Offset, pages are read by the primary key (WHERE id > ? ORDER BY id LIMIT ?)
+// so that deep pages are as fast as the first one, and users are never repeated.
+// Users registered during paging are not skipped only if their ids are larger than any id read before,
+// which holds for monotonic ULIDs (NewUserID) but not for ids given by callers or other IDGenerators.
+func (r *userRepository) ListAfter(ctx context.Context, cursor string, limit int) ([]*User, string, error) {
+	if limit <= 0 || limit > maxCursorLimit {
+		return nil, "", fmt.Errorf("limit must be between 1 and %d (limit: %d)", maxCursorLimit, limit)
+	}
+	after, err := decodeUserCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	mods := []qm.QueryMod{}
+	if after != "" {
+		mods = append(mods, models.UserWhere.ID.GT(after))
+	}
+	// NOTE: one more user is read to know whether the next page exists,
+	// so that the last page never returns a cursor of an empty page
+	mods = append(mods,
+		qm.OrderBy(quotedColumn(models.TableNames.User, models.UserColumns.ID)+" ASC"),
+		qm.Limit(limit+1),
+	)
+
+	var users models.UserSlice
+	err = r.retryRead(ctx, OperationListAfter, func() error {
+		var err error
+		users, err = models.Users(r.scoped(mods...)...).All(ctx, r.reader())
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list users after cursor: %w", err)
+	}
+
+	next := ""
+	if len(users) > limit {
+		users = users[:limit]
+		next = encodeUserCursor(users[limit-1].ID)
+	}
+	return lo.Map(users, func(u *models.User, _ int) *User {
+		return fromUserModel(u)
+	}), next, nil
+}
+
+// encodeUserCursor hides the id in the cursor, so that clients do not depend on the key of pagination.
+func encodeUserCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// decodeUserCursor returns the id of the cursor, or the empty id of the first page.
+func decodeUserCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+	}
+	id, err := ParseUserID(string(b))
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+	}
+	return id.String(), nil
+}
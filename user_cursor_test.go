@@ -0,0 +1,175 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// test using go-mysql-server
+func TestListAfterWalksAllPagesWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := newMigrationClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	r := NewUserRepository(db)
+	users := make([]*User, 300)
+	for i := range users {
+		users[i] = NewUserBuilder().WithID(fmt.Sprintf("01GNNA1J0000000000000%05d", i)).Build()
+	}
+	require.NoError(t, r.RegisterAll(ctx, users))
+	// NOTE: soft-deleted users are skipped as List does
+	require.NoError(t, r.Delete(ctx, users[150]))
+	expected := append(append([]*User{}, users[:150]...), users[151:]...)
+
+	tests := []struct {
+		title         string
+		limit         int
+		expectedPages int
+	}{
+		{"last page is partial", 7, 43},
+		{"last page is full", 13, 23},
+		{"all users in a page", maxCursorLimit, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			var actual []*User
+			pages := 0
+			cursor := ""
+			for {
+				page, next, err := r.ListAfter(ctx, cursor, tt.limit)
+				require.NoError(t, err)
+				require.NotEmpty(t, page, "page %d must not be empty", pages)
+				require.LessOrEqual(t, len(page), tt.limit)
+				actual = append(actual, page...)
+				pages++
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+
+			// assert
+			require.Equal(t, expected, actual)
+			require.Equal(t, tt.expectedPages, pages)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestListAfterWithSQLMock(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` > ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 3;")
+	first := regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 3;")
+
+	tests := []struct {
+		title          string
+		cursor         string
+		limit          int
+		mock           func(mock sqlmock.Sqlmock)
+		expected       []*User
+		expectedCursor string
+		expectedErr    string
+	}{
+		{
+			"first page",
+			"",
+			2,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(first).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).
+						AddRow("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, time.Time{}, time.Time{}).
+						AddRow("1123456789ABCDEFGHJKMNPQRS", "Bob", nil, nil, nil, 1, time.Time{}, time.Time{}).
+						AddRow("2123456789ABCDEFGHJKMNPQRS", "Mary", 30, nil, nil, 1, time.Time{}, time.Time{}))
+			},
+			[]*User{
+				{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob"},
+			},
+			encodeUserCursor("1123456789ABCDEFGHJKMNPQRS"),
+			"",
+		},
+		{
+			"last page",
+			encodeUserCursor("1123456789ABCDEFGHJKMNPQRS"),
+			2,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("1123456789ABCDEFGHJKMNPQRS").
+					WillReturnRows(sqlmock.NewRows(userColumnNames).
+						AddRow("2123456789ABCDEFGHJKMNPQRS", "Mary", 30, nil, nil, 1, time.Time{}, time.Time{}))
+			},
+			[]*User{{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)}},
+			"",
+			"",
+		},
+		{
+			"cursor is not base64",
+			"1123456789ABCDEFGHJKMNPQRS==",
+			2,
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+			"",
+			`invalid cursor: "1123456789ABCDEFGHJKMNPQRS=="`,
+		},
+		{
+			"cursor is not an id",
+			encodeUserCursor("Mike"),
+			2,
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+			"",
+			`invalid cursor: "TWlrZQ"`,
+		},
+		{
+			"limit is zero",
+			"",
+			0,
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+			"",
+			"limit must be between 1 and 1000 (limit: 0)",
+		},
+		{
+			"limit is too large",
+			"",
+			maxCursorLimit + 1,
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+			"",
+			"limit must be between 1 and 1000 (limit: 1001)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			actual, cursor, err := NewUserRepository(db).ListAfter(context.TODO(), tt.cursor, tt.limit)
+
+			// assert
+			require.NoError(t, mock.ExpectationsWereMet())
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.expectedCursor, cursor)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// number of ids queried by one statement of GetMany
+// NOTE: huge IN lists make statements large and may make MySQL scan the table instead of the primary key
+const getManyChunkSize = 500
+
+// GetMany returns the users of ids keyed by their ids, querying by `WHERE id IN (...)` in chunks of getManyChunkSize.
+// Users which are not found (including soft-deleted ones) are absent from the map instead of failing as Get does.
+// Duplicated ids are queried once.
+func (r *userRepository) GetMany(ctx context.Context, ids []string) (map[string]*User, error) {
+	for _, id := range ids {
+		if _, err := ParseUserID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	users := make(map[string]*User, len(ids))
+	for _, chunk := range lo.Chunk(lo.Uniq(ids), getManyChunkSize) {
+		var found models.UserSlice
+		err := r.retryRead(ctx, OperationGetMany, func() error {
+			var err error
+			found, err = models.Users(r.scoped(models.UserWhere.ID.IN(chunk))...).All(ctx, r.reader())
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get users (ids: %d): %w", len(ids), err)
+		}
+
+		for _, u := range found {
+			users[u.ID] = fromUserModel(u)
+		}
+	}
+
+	return users, nil
+}
@@ -0,0 +1,148 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// test using go-sqlmock
+func TestGetManyWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	unknown := "2123456789ABCDEFGHJKMNPQRS"
+
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` IN (?,?,?)) AND (`user`.`deleted_at` is null);")).
+		WithArgs(mike.ID, bob.ID, unknown).
+		WillReturnRows(UserRows(mike, bob))
+
+	// run
+	actual, err := NewUserRepository(db).GetMany(context.TODO(), []string{mike.ID, bob.ID, unknown, mike.ID})
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, map[string]*User{mike.ID: mike, bob.ID: bob}, actual)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-sqlmock
+func TestGetManyChunksWithSQLMock(t *testing.T) {
+	users := generateUsers(t, getManyChunkSize+1)
+	ids := lo.Map(users, func(u *User, _ int) string { return u.ID })
+	query := func(n int) string {
+		return regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` IN (" +
+			strings.TrimSuffix(strings.Repeat("?,", n), ",") + ")) AND (`user`.`deleted_at` is null);")
+	}
+	args := func(ids []string) []driver.Value {
+		return lo.Map(ids, func(id string, _ int) driver.Value { return id })
+	}
+
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectQuery(query(getManyChunkSize)).
+		WithArgs(args(ids[:getManyChunkSize])...).
+		WillReturnRows(UserRows(users[:getManyChunkSize]...))
+	mock.ExpectQuery(query(1)).
+		WithArgs(args(ids[getManyChunkSize:])...).
+		WillReturnRows(UserRows(users[getManyChunkSize:]...))
+
+	// run
+	actual, err := NewUserRepository(db).GetMany(context.TODO(), ids)
+
+	// assert
+	require.NoError(t, err)
+	require.Len(t, actual, len(users))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-sqlmock
+func TestGetManyInvalidIDWithSQLMock(t *testing.T) {
+	// mock
+	db, mock := prepareMockDB(t)
+
+	// run
+	_, err := NewUserRepository(db).GetMany(context.TODO(), []string{"0123456789ABCDEFGHJKMNPQRS", "bob"})
+
+	// assert
+	// NOTE: no query is sent
+	require.ErrorIs(t, err, ErrInvalidUserID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestGetManyWithGoMySQLServer(t *testing.T) {
+	// NOTE: more than twice getManyChunkSize to query in multiple statements
+	users := generateUsers(t, 2*getManyChunkSize+1)
+	ids := lo.Map(users, func(u *User, _ int) string { return u.ID })
+	deleted := users[getManyChunkSize]
+	unknown := "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"
+
+	// simulator
+	prepareSimulator(t, 23306)
+	db, err := NewStrictClient(23306)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	r := NewUserRepository(db)
+	require.NoError(t, r.RegisterAll(context.TODO(), users))
+	require.NoError(t, r.Delete(context.TODO(), deleted))
+
+	byID := func(users []*User) map[string]*User {
+		return lo.SliceToMap(users, func(u *User) (string, *User) { return u.ID, u })
+	}
+
+	tests := []struct {
+		title    string
+		ids      []string
+		expected map[string]*User
+	}{
+		{
+			"no ids",
+			nil,
+			map[string]*User{},
+		},
+		{
+			"one less than a chunk",
+			ids[:getManyChunkSize-1],
+			byID(users[:getManyChunkSize-1]),
+		},
+		{
+			"exactly a chunk",
+			ids[:getManyChunkSize],
+			byID(users[:getManyChunkSize]),
+		},
+		{
+			"one more than a chunk",
+			// NOTE: the last id is of the soft-deleted user
+			ids[:getManyChunkSize+1],
+			byID(users[:getManyChunkSize]),
+		},
+		{
+			"multiple chunks",
+			ids,
+			byID(lo.Without(users, deleted)),
+		},
+		{
+			"unknown and duplicated ids",
+			[]string{ids[0], unknown, ids[0], ids[len(ids)-1]},
+			byID([]*User{users[0], users[len(users)-1]}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual, err := r.GetMany(context.TODO(), tt.ids)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// GetForUpdate returns the user locking its row by SELECT ... FOR UPDATE in tx, for read-modify-write flows.
+// Other transactions writing or locking the row wait until tx is committed or rolled back,
+// so that the user is not changed between the read and the write of tx. Soft-deleted users are not found as Get.
+// NOTE: the lock wait is limited by innodb_lock_wait_timeout (1205) as well as the read timeout
+func (r *userRepository) GetForUpdate(ctx context.Context, tx *sql.Tx, id string) (*User, error) {
+	if _, err := ParseUserID(id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	user, err := models.Users(r.scoped(userByID(id), qm.For("UPDATE"))...).One(ctx, tx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user was not found (id: %s): %w", id, err)
+		}
+
+		return nil, fmt.Errorf("failed to get user for update (id: %s): %w", id, err)
+	}
+
+	return fromUserModel(user), nil
+}
@@ -0,0 +1,126 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// test using go-sqlmock
+func TestGetForUpdateWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	query := regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1 FOR UPDATE;")
+
+	tests := []struct {
+		title       string
+		rows        []*User
+		expected    *User
+		expectedErr error
+	}{
+		{
+			"user is locked",
+			[]*User{mike},
+			mike,
+			nil,
+		},
+		{
+			"user is not found",
+			nil,
+			nil,
+			sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(query).WithArgs(mike.ID).WillReturnRows(UserRows(tt.rows...))
+			mock.ExpectRollback()
+
+			// run
+			tx, err := db.BeginTx(context.TODO(), nil)
+			require.NoError(t, err)
+			actual, err := NewUserRepository(db).GetForUpdate(context.TODO(), tx, mike.ID)
+			require.NoError(t, tx.Rollback())
+
+			// assert
+			require.NoError(t, mock.ExpectationsWereMet())
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// incrementAge increments the age of the user in tx by read-modify-write.
+func incrementAge(ctx context.Context, r *userRepository, tx *sql.Tx, id string) error {
+	user, err := r.GetForUpdate(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE `user` SET `age` = ? WHERE `id` = ?", *user.Age+1, id)
+	return err
+}
+
+// test using testcontainers
+func TestGetForUpdateWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	r := NewUserRepository(db)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, r.Register(ctx, mike))
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	require.NoError(t, incrementAge(ctx, r, tx, mike.ID))
+
+	// run
+	conflicts := reportLockConflicts(t, db)
+	written := make(chan error, 1)
+	go func() {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			written <- err
+			return
+		}
+		defer tx.Rollback()
+		if err := conflicts.do(ctx, func(ctx context.Context) error {
+			return incrementAge(ctx, r, tx, mike.ID)
+		}); err != nil {
+			written <- err
+			return
+		}
+		written <- tx.Commit()
+	}()
+
+	// assert
+	select {
+	case err := <-written:
+		t.Fatalf("concurrent writer did not wait for the lock: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	require.NoError(t, tx.Commit())
+
+	select {
+	case err := <-written:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("concurrent writer is still blocked after commit")
+	}
+	found, err := r.Get(ctx, mike.ID)
+	require.NoError(t, err)
+	// NOTE: the concurrent writer read the age committed by tx, so neither increment is lost
+	require.Equal(t, lo.ToPtr(22), found.Age)
+}
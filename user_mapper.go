@@ -0,0 +1,63 @@
+package gosqltests
+
+import (
+	"database/sql"
+
+	"github.com/samber/lo"
+	"github.com/volatiletech/null/v8"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/sqlcdb"
+)
+
+// toUserModel and fromUserModel are the only places to convert users (toSQLCCreateUserParams and fromSQLCUser for sqlc),
+// so that every column is mapped in both directions.
+
+func toUserModel(user *User) *models.User {
+	return &models.User{
+		ID:   user.ID,
+		Name: user.Name,
+		Age:  null.IntFromPtr(user.Age),
+		// NOTE: users without email are stored as NULL, which does not conflict with the unique key
+		Email:     null.NewString(user.Email, user.Email != ""),
+		Version:   initialUserVersion,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func fromUserModel(m *models.User) *User {
+	return &User{
+		ID:        m.ID,
+		Name:      m.Name,
+		Age:       m.Age.Ptr(),
+		Email:     m.Email.String,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+func toSQLCCreateUserParams(user *User) sqlcdb.CreateUserParams {
+	return sqlcdb.CreateUserParams{
+		ID:        user.ID,
+		Name:      user.Name,
+		Age:       sql.NullInt32{Int32: int32(lo.FromPtr(user.Age)), Valid: user.Age != nil},
+		Email:     sql.NullString{String: user.Email, Valid: user.Email != ""},
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func fromSQLCUser(u *sqlcdb.User) *User {
+	user := &User{
+		ID:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email.String,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+	if u.Age.Valid {
+		user.Age = lo.ToPtr(int(u.Age.Int32))
+	}
+	return user
+}
@@ -0,0 +1,83 @@
+package gosqltests
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/volatiletech/null/v8"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/sqlcdb"
+)
+
+func TestUserMapper(t *testing.T) {
+	tests := []struct {
+		title string
+		user  *User
+		model *models.User
+	}{
+		{
+			"all fields",
+			&User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"},
+			&models.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: null.IntFrom(20), Email: null.StringFrom("mike@example.com"), Version: 1},
+		},
+		{
+			"no email",
+			&User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+			&models.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: null.IntFrom(20), Version: 1},
+		},
+		{
+			"zero age",
+			&User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(0)},
+			&models.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: null.IntFrom(0), Version: 1},
+		},
+		{
+			"no age",
+			&User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"},
+			&models.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Version: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.model, toUserModel(tt.user))
+			require.Equal(t, tt.user, fromUserModel(tt.model))
+		})
+	}
+}
+
+func TestUserMapperNullAge(t *testing.T) {
+	// NOTE: NULL age is distinguished from 0
+	m := &models.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"}
+	require.Nil(t, fromUserModel(m).Age)
+
+	u := fromSQLCUser(&sqlcdb.User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"})
+	require.Nil(t, u.Age)
+	require.Equal(t, sql.NullInt32{}, toSQLCCreateUserParams(u).Age)
+}
+
+// NOTE: this fails when a column is added to models.User (or a field to User) but not to the mapper
+func TestUserMapperCoversAllFields(t *testing.T) {
+	user := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com", CreatedAt: seededAt, UpdatedAt: seededAt}
+	requireNoZeroFields(t, user, nil)
+
+	m := toUserModel(user)
+	// relationships are not columns, and deleted_at is only set by Delete
+	requireNoZeroFields(t, m, []string{"R", "L", "DeletedAt"})
+
+	require.Equal(t, user, fromUserModel(m))
+}
+
+func requireNoZeroFields(t *testing.T, v interface{}, ignored []string) {
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name := rv.Type().Field(i).Name
+		if lo.Contains(ignored, name) {
+			continue
+		}
+		require.False(t, rv.Field(i).IsZero(), "field %s of %s is not mapped", name, rv.Type())
+	}
+}
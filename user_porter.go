@@ -0,0 +1,276 @@
+package gosqltests
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// UserFormat is a file format of ImportUsers and ExportUsers.
+type UserFormat string
+
+// formats of users
+// CSV has the header of userCSVHeader, and JSON is an array of objects like {"id": "...", "name": "Mike", "age": 20, "email": "..."}.
+// Unknown ages and emails are empty in CSV and omitted in JSON.
+const (
+	FormatCSV  UserFormat = "csv"
+	FormatJSON UserFormat = "json"
+)
+
+// ParseUserFormat validates the name of a format, e.g. given by a flag.
+func ParseUserFormat(name string) (UserFormat, error) {
+	switch f := UserFormat(name); f {
+	case FormatCSV, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format: %s (must be %s or %s)", name, FormatCSV, FormatJSON)
+	}
+}
+
+// number of users inserted by one RegisterAll of ImportUsers
+const importBatchSize = 1000
+
+var userCSVHeader = []string{"id", "name", "age", "email"}
+
+// userRecord is a user in JSON files.
+type userRecord struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Age   *int   `json:"age,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// ImportUsers registers users read from r in the format, and returns the number of users imported.
+// Users are inserted in batches of importBatchSize, each of which is a transaction of RegisterAll,
+// so that files larger than memory can be imported. Batches before a failed one stay imported.
+func (r *userRepository) ImportUsers(ctx context.Context, src io.Reader, format UserFormat) (int, error) {
+	dec, err := newUserDecoder(src, format)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	batch := make([]*User, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := r.RegisterAll(ctx, batch); err != nil {
+			return fmt.Errorf("failed to import users (imported: %d): %w", imported, err)
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		user, err := dec.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, fmt.Errorf("failed to read users (imported: %d): %w", imported, err)
+		}
+
+		batch = append(batch, user)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return imported, err
+	}
+
+	return imported, nil
+}
+
+// ExportUsers writes all users except soft-deleted ones to w in the format in the order of ids,
+// and returns the number of users exported. Rows are read by ListStream, so that tables larger than memory can be exported.
+func (r *userRepository) ExportUsers(ctx context.Context, w io.Writer, format UserFormat) (int, error) {
+	enc, err := newUserEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	err = r.ListStream(ctx, nil, func(u *User) error {
+		if err := enc.write(u); err != nil {
+			return fmt.Errorf("failed to write user (id: %s): %w", u.ID, err)
+		}
+		exported++
+		return nil
+	})
+	if err != nil {
+		return exported, fmt.Errorf("failed to export users (exported: %d): %w", exported, err)
+	}
+	if err := enc.close(); err != nil {
+		return exported, fmt.Errorf("failed to export users (exported: %d): %w", exported, err)
+	}
+
+	return exported, nil
+}
+
+type userDecoder interface {
+	// next returns io.EOF after the last user.
+	next() (*User, error)
+}
+
+func newUserDecoder(r io.Reader, format UserFormat) (userDecoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVUserDecoder(r)
+	case FormatJSON:
+		return newJSONUserDecoder(r)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+type csvUserDecoder struct {
+	r *csv.Reader
+}
+
+func newCSVUserDecoder(r io.Reader) (*csvUserDecoder, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(userCSVHeader)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	for i, c := range userCSVHeader {
+		if header[i] != c {
+			return nil, fmt.Errorf("invalid csv header: %v (must be %v)", header, userCSVHeader)
+		}
+	}
+	return &csvUserDecoder{r: cr}, nil
+}
+
+func (d *csvUserDecoder) next() (*User, error) {
+	record, err := d.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{ID: record[0], Name: record[1], Email: record[3]}
+	if record[2] != "" {
+		age, err := strconv.Atoi(record[2])
+		if err != nil {
+			line, _ := d.r.FieldPos(2)
+			return nil, fmt.Errorf("invalid age in line %d: %q", line, record[2])
+		}
+		user.Age = &age
+	}
+	return user, nil
+}
+
+type jsonUserDecoder struct {
+	d *json.Decoder
+	// index is the index of the next user in the array
+	index int
+}
+
+func newJSONUserDecoder(r io.Reader) (*jsonUserDecoder, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if t, err := d.Token(); err != nil || t != json.Delim('[') {
+		return nil, fmt.Errorf("json must be an array of users")
+	}
+	return &jsonUserDecoder{d: d}, nil
+}
+
+func (d *jsonUserDecoder) next() (*User, error) {
+	if !d.d.More() {
+		if _, err := d.d.Token(); err != nil {
+			return nil, fmt.Errorf("json array is not closed: %w", err)
+		}
+		return nil, io.EOF
+	}
+
+	var record userRecord
+	if err := d.d.Decode(&record); err != nil {
+		return nil, fmt.Errorf("invalid user at index %d: %w", d.index, err)
+	}
+	d.index++
+	return &User{ID: record.ID, Name: record.Name, Age: record.Age, Email: record.Email}, nil
+}
+
+type userEncoder interface {
+	write(*User) error
+	// close writes the rest of the file.
+	close() error
+}
+
+func newUserEncoder(w io.Writer, format UserFormat) (userEncoder, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(userCSVHeader); err != nil {
+			return nil, fmt.Errorf("failed to write csv header: %w", err)
+		}
+		return &csvUserEncoder{w: cw}, nil
+	case FormatJSON:
+		return &jsonUserEncoder{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+type csvUserEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvUserEncoder) write(u *User) error {
+	age := ""
+	if u.Age != nil {
+		age = strconv.Itoa(*u.Age)
+	}
+	return e.w.Write([]string{u.ID, u.Name, age, u.Email})
+}
+
+func (e *csvUserEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonUserEncoder writes an array of users element by element instead of marshaling all users at once.
+type jsonUserEncoder struct {
+	w       io.Writer
+	written int
+}
+
+func (e *jsonUserEncoder) write(u *User) error {
+	b, err := json.Marshal(&userRecord{ID: u.ID, Name: u.Name, Age: u.Age, Email: u.Email})
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if e.written == 0 {
+		sep = "[\n"
+	}
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(b); err != nil {
+		return err
+	}
+	e.written++
+	return nil
+}
+
+func (e *jsonUserEncoder) close() error {
+	end := "\n]\n"
+	if e.written == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
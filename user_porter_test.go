@@ -0,0 +1,250 @@
+package gosqltests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// files of the same users in each format, as ExportUsers writes them
+var portedUserFiles = map[UserFormat]string{
+	FormatCSV: "id,name,age,email\n" +
+		"0123456789ABCDEFGHJKMNPQRS,Mike,20,mike@example.com\n" +
+		"1123456789ABCDEFGHJKMNPQRS,\"Bob, Jr.\",,\n" +
+		"2123456789ABCDEFGHJKMNPQRS,Mary,30,\n",
+	FormatJSON: "[\n" +
+		`{"id":"0123456789ABCDEFGHJKMNPQRS","name":"Mike","age":20,"email":"mike@example.com"},` + "\n" +
+		`{"id":"1123456789ABCDEFGHJKMNPQRS","name":"Bob, Jr."},` + "\n" +
+		`{"id":"2123456789ABCDEFGHJKMNPQRS","name":"Mary","age":30}` + "\n" +
+		"]\n",
+}
+
+var portedUsers = []*User{
+	{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"},
+	{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob, Jr."},
+	{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)},
+}
+
+// assertUserRoundTrip imports files of each format into an empty database and exports them back.
+func assertUserRoundTrip(ctx context.Context, t *testing.T, newDB func(t *testing.T) *sql.DB) {
+	for _, format := range []UserFormat{FormatCSV, FormatJSON} {
+		format := format
+
+		t.Run(fmt.Sprintf("%s file", format), func(t *testing.T) {
+			r := NewUserRepository(newDB(t))
+
+			// run
+			n, err := r.ImportUsers(ctx, strings.NewReader(portedUserFiles[format]), format)
+			require.NoError(t, err)
+			require.Equal(t, len(portedUsers), n)
+			var exported bytes.Buffer
+			n, err = r.ExportUsers(ctx, &exported, format)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, len(portedUsers), n)
+			require.Equal(t, portedUserFiles[format], exported.String())
+			users, _, err := r.List(ctx, nil)
+			require.NoError(t, err)
+			// NOTE: files have no timestamps, so imported users are timestamped when they are registered
+			require.Equal(t, portedUsers, withoutTimestamps(users...))
+		})
+
+		t.Run(fmt.Sprintf("%s file of several batches", format), func(t *testing.T) {
+			r := NewUserRepository(newDB(t))
+			users := make([]*User, importBatchSize*2+1)
+			for i := range users {
+				users[i] = NewUserBuilder().WithName(fmt.Sprintf("user%05d", i)).Build()
+			}
+			sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+			var file bytes.Buffer
+			enc, err := newUserEncoder(&file, format)
+			require.NoError(t, err)
+			for _, u := range users {
+				require.NoError(t, enc.write(u))
+			}
+			require.NoError(t, enc.close())
+
+			// run
+			n, err := r.ImportUsers(ctx, bytes.NewReader(file.Bytes()), format)
+			require.NoError(t, err)
+			require.Equal(t, len(users), n)
+			var exported bytes.Buffer
+			n, err = r.ExportUsers(ctx, &exported, format)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, len(users), n)
+			require.Equal(t, file.String(), exported.String())
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestUserRoundTripWithGoMySQLServer(t *testing.T) {
+	assertUserRoundTrip(context.Background(), t, func(t *testing.T) *sql.DB {
+		port, err := testport.Reserve()
+		require.NoError(t, err)
+		prepareSimulator(t, port)
+		db, err := NewClient(port)
+		require.NoError(t, err)
+		t.Cleanup(func() { closeTestClient(t, db) })
+		return db
+	})
+}
+
+// test using testcontainers
+func TestUserRoundTripWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	assertUserRoundTrip(ctx, t, func(t *testing.T) *sql.DB {
+		db := prepareContainer(ctx, t)
+		return db
+	})
+}
+
+// importWithFailedBatch imports two batches, the second of which fails, and returns the repository.
+func importWithFailedBatch(ctx context.Context, t *testing.T, db *sql.DB) *userRepository {
+	// NOTE: the last user of the second batch has the name of the first user
+	var file bytes.Buffer
+	enc, err := newUserEncoder(&file, FormatCSV)
+	require.NoError(t, err)
+	for i := 0; i < importBatchSize*2; i++ {
+		require.NoError(t, enc.write(NewUserBuilder().WithName(fmt.Sprintf("user%05d", i%(importBatchSize*2-1))).Build()))
+	}
+	require.NoError(t, enc.close())
+
+	// run
+	r := NewUserRepository(db)
+	n, err := r.ImportUsers(ctx, &file, FormatCSV)
+
+	// assert
+	require.ErrorContains(t, err, "failed to import users (imported: 1000): failed to register 1 of 1000 users: [999]")
+	require.Equal(t, importBatchSize, n)
+	return r
+}
+
+// test using go-mysql-server
+func TestImportUsersKeepsBatchesBeforeFailureWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+
+	// simulator
+	// NOTE: tables of prepareSimulator have no unique keys
+	port := prepareMigratedSimulator(ctx, t)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	// NOTE: rows of the failed batch are not checked, as go-mysql-server does not roll back transactions
+	importWithFailedBatch(ctx, t, db)
+}
+
+// test using testcontainers
+func TestImportUsersKeepsBatchesBeforeFailureWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	r := importWithFailedBatch(ctx, t, db)
+
+	// the failed batch is rolled back
+	total, err := r.Count(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(importBatchSize), total)
+}
+
+func TestImportUsersOfInvalidFile(t *testing.T) {
+	tests := []struct {
+		title       string
+		format      UserFormat
+		file        string
+		expectedErr string
+	}{
+		{
+			"unknown format",
+			"xml",
+			"<users></users>",
+			"unknown format: xml",
+		},
+		{
+			"csv without header",
+			FormatCSV,
+			"",
+			"failed to read csv header: EOF",
+		},
+		{
+			"csv of wrong header",
+			FormatCSV,
+			"id,name,email,age\n",
+			"invalid csv header: [id name email age] (must be [id name age email])",
+		},
+		{
+			"csv of invalid age",
+			FormatCSV,
+			"id,name,age,email\n0123456789ABCDEFGHJKMNPQRS,Mike,twenty,\n",
+			`failed to read users (imported: 0): invalid age in line 2: "twenty"`,
+		},
+		{
+			"csv of missing fields",
+			FormatCSV,
+			"id,name,age,email\n0123456789ABCDEFGHJKMNPQRS,Mike\n",
+			"failed to read users (imported: 0): record on line 2: wrong number of fields",
+		},
+		{
+			"json object",
+			FormatJSON,
+			`{"id":"0123456789ABCDEFGHJKMNPQRS","name":"Mike"}`,
+			"json must be an array of users",
+		},
+		{
+			"json of unknown field",
+			FormatJSON,
+			`[{"id":"0123456789ABCDEFGHJKMNPQRS","name":"Mike","nickname":"M"}]`,
+			`failed to read users (imported: 0): invalid user at index 0: json: unknown field "nickname"`,
+		},
+		{
+			"json array not closed",
+			FormatJSON,
+			`[{"id":"0123456789ABCDEFGHJKMNPQRS","name":"Mike"}`,
+			"failed to read users (imported: 0): invalid user at index 1: unexpected end of JSON input",
+		},
+		{
+			"invalid id",
+			FormatJSON,
+			`[{"id":"mike","name":"Mike"}]`,
+			"failed to import users (imported: 0): failed to register 1 of 1 users: [0] (id: mike) invalid user id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			// NOTE: no queries are expected, as the file is rejected before it is inserted
+			db, mock := prepareMockDB(t)
+
+			// run
+			n, err := NewUserRepository(db).ImportUsers(context.TODO(), strings.NewReader(tt.file), tt.format)
+
+			// assert
+			require.ErrorContains(t, err, tt.expectedErr)
+			require.Zero(t, n)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestParseUserFormat(t *testing.T) {
+	format, err := ParseUserFormat("json")
+	require.NoError(t, err)
+	require.Equal(t, FormatJSON, format)
+
+	_, err = ParseUserFormat("xml")
+	require.EqualError(t, err, "unknown format: xml (must be csv or json)")
+}
@@ -0,0 +1,129 @@
+package gosqltests
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/volatiletech/null/v8"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+type ListOrder int
+
+const (
+	OrderByIDAsc ListOrder = iota
+	OrderByIDDesc
+	OrderByNameAsc
+	OrderByNameDesc
+	OrderByAgeAsc
+	OrderByAgeDesc
+)
+
+// ListQuery narrows down and paginates users returned by List.
+// Zero values mean "not specified", so an empty query (or nil) lists all users ordered by id.
+type ListQuery struct {
+	Limit  int
+	Offset int
+	// After is a cursor which skips users up to the id (inclusive). It can only be used with OrderByID*.
+	After      string
+	NamePrefix string
+	MinAge     int
+	MaxAge     int
+	Order      ListOrder
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// validate checks the combination of conditions, which is shared by every implementation of List.
+func (q *ListQuery) validate() error {
+	if q == nil {
+		return nil
+	}
+
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("limit and offset must not be negative (limit: %d, offset: %d)", q.Limit, q.Offset)
+	}
+	if q.MaxAge != 0 && q.MinAge > q.MaxAge {
+		return fmt.Errorf("min age must not exceed max age (min: %d, max: %d)", q.MinAge, q.MaxAge)
+	}
+	if q.After != "" && q.Order != OrderByIDAsc && q.Order != OrderByIDDesc {
+		return errors.New("cursor can only be used with order by id")
+	}
+	return nil
+}
+
+// filters returns query mods which affect the total count.
+func (q *ListQuery) filters() ([]qm.QueryMod, error) {
+	if q == nil {
+		return nil, nil
+	}
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+
+	mods := []qm.QueryMod{}
+	if q.NamePrefix != "" {
+		mods = append(mods, qm.Where(quotedColumn(models.TableNames.User, models.UserColumns.Name)+" LIKE ?", likeEscaper.Replace(q.NamePrefix)+"%"))
+	}
+	if q.MinAge != 0 {
+		mods = append(mods, models.UserWhere.Age.GTE(null.IntFrom(q.MinAge)))
+	}
+	if q.MaxAge != 0 {
+		mods = append(mods, models.UserWhere.Age.LTE(null.IntFrom(q.MaxAge)))
+	}
+
+	return mods, nil
+}
+
+// pagination returns query mods which only affect the page. filters must be called beforehand to validate q.
+func (q *ListQuery) pagination() []qm.QueryMod {
+	if q == nil {
+		return []qm.QueryMod{qm.OrderBy(quotedColumn(models.TableNames.User, models.UserColumns.ID) + " ASC")}
+	}
+
+	mods := []qm.QueryMod{}
+	if q.After != "" {
+		if q.Order == OrderByIDDesc {
+			mods = append(mods, models.UserWhere.ID.LT(q.After))
+		} else {
+			mods = append(mods, models.UserWhere.ID.GT(q.After))
+		}
+	}
+
+	id := quotedColumn(models.TableNames.User, models.UserColumns.ID)
+	name := quotedColumn(models.TableNames.User, models.UserColumns.Name)
+	age := quotedColumn(models.TableNames.User, models.UserColumns.Age)
+
+	// NOTE: id is always used as a tie-breaker so that pages are stable
+	switch q.Order {
+	case OrderByIDDesc:
+		mods = append(mods, qm.OrderBy(id+" DESC"))
+	case OrderByNameAsc:
+		mods = append(mods, qm.OrderBy(name+" ASC, "+id+" ASC"))
+	case OrderByNameDesc:
+		mods = append(mods, qm.OrderBy(name+" DESC, "+id+" DESC"))
+	case OrderByAgeAsc:
+		mods = append(mods, qm.OrderBy(age+" ASC, "+id+" ASC"))
+	case OrderByAgeDesc:
+		mods = append(mods, qm.OrderBy(age+" DESC, "+id+" DESC"))
+	default:
+		mods = append(mods, qm.OrderBy(id+" ASC"))
+	}
+
+	if q.Limit != 0 {
+		mods = append(mods, qm.Limit(q.Limit))
+	} else if q.Offset != 0 {
+		// NOTE: MySQL does not accept OFFSET without LIMIT,
+		// and go-mysql-server returns no rows if LIMIT + OFFSET overflows int64
+		mods = append(mods, qm.Limit(math.MaxInt64-q.Offset))
+	}
+	if q.Offset != 0 {
+		mods = append(mods, qm.Offset(q.Offset))
+	}
+
+	return mods
+}
@@ -0,0 +1,97 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrUnknownColumn is returned by QueryRaw if the query selects a column which is not of the user table.
+var ErrUnknownColumn = errors.New("unknown column of user")
+
+// QueryRaw runs the query, which sqlboiler cannot express (e.g. UNION or window functions), and returns the users of the rows.
+// Columns are mapped by their names in any order (use aliases for expressions), and columns which are not selected are left zero.
+// NOTE: the query is sent as it is, so neither soft-deleted users nor users of other tenants are excluded,
+// and it is not retried because it may not be read-only
+func (r *userRepository) QueryRaw(ctx context.Context, query string, args ...interface{}) ([]*User, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	for _, c := range columns {
+		if _, ok := userFieldOf(&models.User{}, c); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, c)
+		}
+	}
+
+	var users []*User
+	for rows.Next() {
+		var u models.User
+		dest := make([]interface{}, len(columns))
+		for i, c := range columns {
+			dest[i], _ = userFieldOf(&u, c)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, fromUserModel(&u))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+
+	return users, nil
+}
+
+// ExecRaw runs the statement, which sqlboiler cannot express, and returns the number of affected rows.
+// NOTE: the statement is neither audited nor retried, and it bypasses tenants as QueryRaw does
+func (r *userRepository) ExecRaw(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute statement: %w", wrapStorageError(err))
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return n, nil
+}
+
+// userFieldOf returns the pointer to the field of the column in u.
+func userFieldOf(u *models.User, column string) (interface{}, bool) {
+	switch column {
+	case models.UserColumns.ID:
+		return &u.ID, true
+	case models.UserColumns.Name:
+		return &u.Name, true
+	case models.UserColumns.Age:
+		return &u.Age, true
+	case models.UserColumns.DeletedAt:
+		return &u.DeletedAt, true
+	case models.UserColumns.Email:
+		return &u.Email, true
+	case models.UserColumns.Version:
+		return &u.Version, true
+	case models.UserColumns.CreatedAt:
+		return &u.CreatedAt, true
+	case models.UserColumns.UpdatedAt:
+		return &u.UpdatedAt, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,191 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+func TestUserFieldOfAllColumns(t *testing.T) {
+	// NOTE: a column added to the table must be added to userFieldOf as well
+	for _, c := range userColumnNames {
+		_, ok := userFieldOf(&models.User{}, c)
+		require.True(t, ok, c)
+	}
+}
+
+// test using go-sqlmock
+func TestQueryRawWithSQLMock(t *testing.T) {
+	query := "SELECT name, id FROM user WHERE age > ?"
+
+	tests := []struct {
+		title       string
+		mock        func(sqlmock.Sqlmock)
+		expected    []*User
+		expectedErr error
+		expectedMsg string
+	}{
+		{
+			"columns are mapped by their names",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "id"}).
+						AddRow("Mike", "0123456789ABCDEFGHJKMNPQRS").
+						AddRow("Bob", "1123456789ABCDEFGHJKMNPQRS"))
+			},
+			[]*User{
+				{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"},
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob"},
+			},
+			nil,
+			"",
+		},
+		{
+			"no rows",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "id"}))
+			},
+			nil,
+			nil,
+			"",
+		},
+		{
+			"unknown column",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "cnt"}).AddRow("Mike", 1))
+			},
+			nil,
+			ErrUnknownColumn,
+			"unknown column of user: cnt",
+		},
+		{
+			"query fails",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(20).
+					WillReturnError(errors.New("connection refused"))
+			},
+			nil,
+			nil,
+			"failed to query users: connection refused",
+		},
+		{
+			"scan fails",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "age"}).AddRow("Mike", "twenty"))
+			},
+			nil,
+			nil,
+			`failed to scan user: sql: Scan error on column index 1, name "age": converting driver.Value type string ("twenty") to a int: invalid syntax`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			actual, err := NewUserRepository(db).QueryRaw(context.TODO(), query, 20)
+
+			// assert
+			require.NoError(t, mock.ExpectationsWereMet())
+			if tt.expectedMsg != "" {
+				require.EqualError(t, err, tt.expectedMsg)
+				if tt.expectedErr != nil {
+					require.ErrorIs(t, err, tt.expectedErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestExecRawWithSQLMock(t *testing.T) {
+	query := "UPDATE user SET age = age + 1 WHERE age IS NOT NULL"
+
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// run
+	n, err := NewUserRepository(db).ExecRaw(context.TODO(), query)
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestQueryRawWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	r := NewUserRepository(db)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+	bob := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+	alice := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Alice"}
+	for _, u := range []*User{mike, bob, alice} {
+		require.NoError(t, r.Register(ctx, u))
+	}
+
+	t.Run("query sqlboiler cannot express", func(t *testing.T) {
+		// run
+		// NOTE: the oldest user and users without age, in one query
+		actual, err := r.QueryRaw(ctx,
+			"(SELECT * FROM `user` WHERE `age` = (SELECT MAX(`age`) FROM `user`)) "+
+				"UNION ALL (SELECT * FROM `user` WHERE `age` IS NULL) ORDER BY `name`")
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []*User{alice, bob}, actual)
+	})
+
+	t.Run("aliases of expressions", func(t *testing.T) {
+		// run
+		actual, err := r.QueryRaw(ctx, "SELECT `id`, UPPER(`name`) AS `name` FROM `user` WHERE `id` = ?", mike.ID)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, []*User{{ID: mike.ID, Name: "MIKE"}}, actual)
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		// run
+		n, err := r.ExecRaw(ctx, "UPDATE `user` SET `age` = `age` + 1 WHERE `age` IS NOT NULL")
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, int64(2), n)
+		found, err := r.Get(ctx, mike.ID)
+		require.NoError(t, err)
+		require.Equal(t, lo.ToPtr(21), found.Age)
+	})
+}
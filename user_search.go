@@ -0,0 +1,45 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// SearchQuery narrows down users returned by Search. Zero values mean "not specified".
+type SearchQuery struct {
+	// NamePrefix matches literally, i.e. % and _ in it are not wildcards
+	NamePrefix string
+	MinAge     int
+	MaxAge     int
+	Limit      int
+}
+
+// Search returns users matching all conditions of query ordered by id. Users without age never match age conditions.
+// Unlike List, it does not count all matching users, so that only one statement is sent.
+// NOTE: conditions are built by the same query mods as List, which bind values as arguments
+func (r *userRepository) Search(ctx context.Context, query SearchQuery) ([]*User, error) {
+	q := &ListQuery{NamePrefix: query.NamePrefix, MinAge: query.MinAge, MaxAge: query.MaxAge, Limit: query.Limit}
+	filters, err := q.filters()
+	if err != nil {
+		return nil, fmt.Errorf("invalid search query: %w", err)
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var found models.UserSlice
+	err = r.retryRead(ctx, OperationSearch, func() error {
+		var err error
+		found, err = models.Users(append(r.scoped(filters...), q.pagination()...)...).All(ctx, r.reader())
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return lo.Map(found, func(u *models.User, _ int) *User { return fromUserModel(u) }), nil
+}
@@ -0,0 +1,224 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-sqlmock
+func TestSearchWithSQLMock(t *testing.T) {
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "M_ke", Age: lo.ToPtr(20)}
+
+	// NOTE: every combination of the conditions
+	tests := []struct {
+		title        string
+		query        SearchQuery
+		expectedSQL  string
+		expectedArgs []driver.Value
+	}{
+		{
+			"no conditions",
+			SearchQuery{},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			nil,
+		},
+		{
+			"limit",
+			SearchQuery{Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			nil,
+		},
+		{
+			"max age",
+			SearchQuery{MaxAge: 30},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			[]driver.Value{30},
+		},
+		{
+			"max age, limit",
+			SearchQuery{MaxAge: 30, Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			[]driver.Value{30},
+		},
+		{
+			"min age",
+			SearchQuery{MinAge: 20},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`age` >= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			[]driver.Value{20},
+		},
+		{
+			"min age, limit",
+			SearchQuery{MinAge: 20, Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`age` >= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			[]driver.Value{20},
+		},
+		{
+			"min age, max age",
+			SearchQuery{MinAge: 20, MaxAge: 30},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`age` >= ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			[]driver.Value{20, 30},
+		},
+		{
+			"min age, max age, limit",
+			SearchQuery{MinAge: 20, MaxAge: 30, Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`age` >= ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			[]driver.Value{20, 30},
+		},
+		{
+			"name prefix",
+			SearchQuery{NamePrefix: "M_"},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			[]driver.Value{`M\_%`},
+		},
+		{
+			"name prefix, limit",
+			SearchQuery{NamePrefix: "M_", Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			[]driver.Value{`M\_%`},
+		},
+		{
+			"name prefix, max age",
+			SearchQuery{NamePrefix: "M_", MaxAge: 30},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			[]driver.Value{`M\_%`, 30},
+		},
+		{
+			"name prefix, max age, limit",
+			SearchQuery{NamePrefix: "M_", MaxAge: 30, Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			[]driver.Value{`M\_%`, 30},
+		},
+		{
+			"name prefix, min age",
+			SearchQuery{NamePrefix: "M_", MinAge: 20},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` >= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			[]driver.Value{`M\_%`, 20},
+		},
+		{
+			"name prefix, min age, limit",
+			SearchQuery{NamePrefix: "M_", MinAge: 20, Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` >= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			[]driver.Value{`M\_%`, 20},
+		},
+		{
+			"name prefix, min age, max age",
+			SearchQuery{NamePrefix: "M_", MinAge: 20, MaxAge: 30},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` >= ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			[]driver.Value{`M\_%`, 20, 30},
+		},
+		{
+			"name prefix, min age, max age, limit",
+			SearchQuery{NamePrefix: "M_", MinAge: 20, MaxAge: 30, Limit: 10},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` >= ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 10;",
+			[]driver.Value{`M\_%`, 20, 30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta(tt.expectedSQL)).
+				WithArgs(tt.expectedArgs...).
+				WillReturnRows(UserRows(mike))
+
+			// run
+			actual, err := NewUserRepository(db).Search(context.TODO(), tt.query)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, []*User{mike}, actual)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestSearchInvalidQueryWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title       string
+		query       SearchQuery
+		expectedMsg string
+	}{
+		{
+			"negative limit",
+			SearchQuery{Limit: -1},
+			"invalid search query: limit and offset must not be negative (limit: -1, offset: 0)",
+		},
+		{
+			"min age exceeds max age",
+			SearchQuery{MinAge: 30, MaxAge: 20},
+			"invalid search query: min age must not exceed max age (min: 30, max: 20)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+
+			// run
+			_, err := NewUserRepository(db).Search(context.TODO(), tt.query)
+
+			// assert
+			// NOTE: no query is sent
+			require.EqualError(t, err, tt.expectedMsg)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestSearchWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	mary := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(35)}
+	underscore := &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "M_x", Age: lo.ToPtr(25)}
+	noAge := &User{ID: "3123456789ABCDEFGHJKMNPQRS", Name: "Max"}
+	deleted := &User{ID: "4123456789ABCDEFGHJKMNPQRS", Name: "Meg", Age: lo.ToPtr(22)}
+	bob := &User{ID: "5123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)}
+
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+	r := NewUserRepository(db)
+	require.NoError(t, r.RegisterAll(ctx, []*User{mike, mary, underscore, noAge, deleted, bob}))
+	require.NoError(t, r.Delete(ctx, deleted))
+
+	tests := []struct {
+		title    string
+		query    SearchQuery
+		expected []*User
+	}{
+		{"all users", SearchQuery{}, []*User{mike, mary, underscore, noAge, bob}},
+		{"name prefix", SearchQuery{NamePrefix: "M"}, []*User{mike, mary, underscore, noAge}},
+		{"wildcard in name prefix matches literally", SearchQuery{NamePrefix: "M_"}, []*User{underscore}},
+		{"age range excludes users without age", SearchQuery{MinAge: 20, MaxAge: 30}, []*User{mike, underscore, bob}},
+		{"name prefix and age range", SearchQuery{NamePrefix: "M", MinAge: 21}, []*User{mary, underscore}},
+		{"limit", SearchQuery{NamePrefix: "M", Limit: 2}, []*User{mike, mary}},
+		{"nothing matches", SearchQuery{NamePrefix: "Z"}, []*User{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual, err := r.Search(ctx, tt.query)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/syuparn/gosqltests/sqlcdb"
+)
+
+//go:generate go run github.com/sqlc-dev/sqlc/cmd/sqlc@v1.20.0 generate
+
+type sqlcUserRepository struct {
+	queries      *sqlcdb.Queries
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+var _ UserRepository = (*sqlcUserRepository)(nil)
+
+// NewSQLCUserRepository returns a UserRepository using queries generated by sqlc from queries/user.sql instead of sqlboiler.
+// It behaves the same as NewUserRepository (see RunConformanceSuite) with the default timeouts,
+// but it only has the methods of UserRepository.
+func NewSQLCUserRepository(db *sql.DB) *sqlcUserRepository {
+	return &sqlcUserRepository{
+		queries:      sqlcdb.New(db),
+		readTimeout:  defaultReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+	}
+}
+
+// Register inserts the user. It returns ErrEmailTaken if another user has the same email.
+func (r *sqlcUserRepository) Register(ctx context.Context, user *User) error {
+	if _, err := ParseUserID(user.ID); err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	setInsertTimestamps(user, timestampOf(systemClock{}))
+	if err := r.queries.CreateUser(ctx, toSQLCCreateUserParams(user)); err != nil {
+		return fmt.Errorf("failed to insert user: %w", wrapEmailTakenError(wrapStorageError(err), user))
+	}
+
+	return nil
+}
+
+// sorts of ListUsers by orders (ids are sorted by IDDesc of ListUsersParams)
+var sqlcListSorts = map[ListOrder]string{
+	OrderByNameAsc:  "name_asc",
+	OrderByNameDesc: "name_desc",
+	OrderByAgeAsc:   "age_asc",
+	OrderByAgeDesc:  "age_desc",
+}
+
+func (r *sqlcUserRepository) List(ctx context.Context, query *ListQuery) ([]*User, int64, error) {
+	if err := query.validate(); err != nil {
+		return nil, 0, fmt.Errorf("invalid list query: %w", err)
+	}
+	if query == nil {
+		query = &ListQuery{}
+	}
+	// NOTE: sqlc types LIMIT and OFFSET as int32
+	if query.Limit > math.MaxInt32 || query.Offset > math.MaxInt32 {
+		return nil, 0, fmt.Errorf("invalid list query: limit and offset must not exceed %d (limit: %d, offset: %d)", math.MaxInt32, query.Limit, query.Offset)
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	namePattern := likeEscaper.Replace(query.NamePrefix) + "%"
+	total, err := r.queries.CountUsers(ctx, sqlcdb.CountUsersParams{
+		NamePattern: namePattern,
+		MinAge:      query.MinAge,
+		MaxAge:      query.MaxAge,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	limit := int32(query.Limit)
+	if limit == 0 {
+		limit = math.MaxInt32
+	}
+	users, err := r.queries.ListUsers(ctx, sqlcdb.ListUsersParams{
+		NamePattern: namePattern,
+		MinAge:      query.MinAge,
+		MaxAge:      query.MaxAge,
+		AfterID:     query.After,
+		IDDesc:      query.Order == OrderByIDDesc || query.Order == OrderByNameDesc || query.Order == OrderByAgeDesc,
+		Sort:        sqlcListSorts[query.Order],
+		Limit:       limit,
+		Offset:      int32(query.Offset),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return lo.Map(users, func(u sqlcdb.User, _ int) *User {
+		return fromSQLCUser(&u)
+	}), total, nil
+}
+
+func (r *sqlcUserRepository) Get(ctx context.Context, id string) (*User, error) {
+	if _, err := ParseUserID(id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	user, err := r.queries.GetUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user was not found (id: %s): %w", id, err)
+		}
+
+		return nil, fmt.Errorf("failed to get user (id: %s): %w", id, err)
+	}
+
+	return fromSQLCUser(&user), nil
+}
+
+func (r *sqlcUserRepository) GetByName(ctx context.Context, name string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	user, err := r.queries.GetUserByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user was not found (name: %s): %w", name, err)
+		}
+
+		return nil, fmt.Errorf("failed to get user (name: %s): %w", name, err)
+	}
+
+	return fromSQLCUser(&user), nil
+}
+
+// Delete soft-deletes the user, which is excluded from Get, GetByName and List.
+func (r *sqlcUserRepository) Delete(ctx context.Context, user *User) error {
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	err := r.queries.SoftDeleteUser(ctx, sqlcdb.SoftDeleteUserParams{
+		// NOTE: sqlboiler also stores the time of the client, in UTC by default (boil.GetLocation)
+		DeletedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ID:        user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return nil
+}
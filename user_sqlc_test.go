@@ -0,0 +1,172 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// columns which queries of sqlc select (see queries/user.sql)
+var sqlcUserColumnNames = []string{
+	models.UserColumns.ID, models.UserColumns.Name, models.UserColumns.Age, models.UserColumns.DeletedAt, models.UserColumns.Email,
+	models.UserColumns.Version, models.UserColumns.CreatedAt, models.UserColumns.UpdatedAt,
+}
+
+// test using go-sqlmock
+func TestSQLCListWithSQLMock(t *testing.T) {
+	count := regexp.QuoteMeta("SELECT COUNT(*) FROM user")
+	list := regexp.QuoteMeta("SELECT id, name, age, deleted_at, email, version, created_at, updated_at FROM user")
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), CreatedAt: seededAt, UpdatedAt: seededAt}
+
+	tests := []struct {
+		title       string
+		query       *ListQuery
+		mock        func(mock sqlmock.Sqlmock)
+		expected    []*User
+		expectedErr string
+	}{
+		{
+			"all users",
+			nil,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(count).
+					WithArgs("%", 0, 0, 0, 0).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectQuery(list).
+					WithArgs("%", 0, 0, 0, 0, "", false, "", false, "", "", "", "", "", false, int32(math.MaxInt32), int32(0)).
+					WillReturnRows(sqlmock.NewRows(sqlcUserColumnNames).AddRow(mike.ID, mike.Name, *mike.Age, nil, nil, 1, mike.CreatedAt, mike.UpdatedAt))
+			},
+			[]*User{mike},
+			"",
+		},
+		{
+			"conditions are passed as arguments",
+			&ListQuery{NamePrefix: "M_", MinAge: 20, MaxAge: 30, Order: OrderByAgeDesc, Limit: 10, Offset: 5},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(count).
+					WithArgs(`M\_%`, 20, 20, 30, 30).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(6))
+				mock.ExpectQuery(list).
+					WithArgs(`M\_%`, 20, 20, 30, 30, "", true, "", true, "", "age_desc", "age_desc", "age_desc", "age_desc", true, int32(10), int32(5)).
+					WillReturnRows(sqlmock.NewRows(sqlcUserColumnNames).AddRow(mike.ID, mike.Name, *mike.Age, nil, nil, 1, mike.CreatedAt, mike.UpdatedAt))
+			},
+			[]*User{mike},
+			"",
+		},
+		{
+			"invalid query",
+			&ListQuery{MinAge: 30, MaxAge: 20},
+			// NOTE: no query is sent
+			func(mock sqlmock.Sqlmock) {},
+			nil,
+			"invalid list query: min age must not exceed max age (min: 30, max: 20)",
+		},
+		{
+			"count error",
+			nil,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(count).
+					WithArgs("%", 0, 0, 0, 0).
+					WillReturnError(errors.New("connection refused"))
+			},
+			nil,
+			"failed to count users: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			r := NewSQLCUserRepository(db)
+			actual, _, err := r.List(context.TODO(), tt.query)
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, actual)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-sqlmock
+func TestSQLCRegisterWithSQLMock(t *testing.T) {
+	user := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"}
+
+	// mock
+	db, mock := prepareMockDB(t)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO user (id, name, age, email, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)")).
+		WithArgs(user.ID, user.Name, user.Age, user.Email, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+		WillReturnError(&mysql.MySQLError{Number: mysqlErrDupEntry, Message: "Duplicate entry 'mike@example.com' for key 'user.email'"})
+
+	// run
+	err := NewSQLCUserRepository(db).Register(context.TODO(), user)
+
+	// assert
+	require.ErrorIs(t, err, ErrEmailTaken)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// test using go-mysql-server
+func TestSQLCListWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	users := generateUsers(t, 30)
+	seedUsers(ctx, t, db, users)
+	sqlboiler := NewUserRepository(db)
+	require.NoError(t, sqlboiler.Delete(ctx, users[0]))
+	sqlc := NewSQLCUserRepository(db)
+
+	// both implementations return the same page and total
+	for _, q := range []*ListQuery{
+		nil,
+		{Order: OrderByIDDesc, Limit: 5},
+		{Order: OrderByNameAsc, Limit: 10, Offset: 3},
+		{Order: OrderByNameDesc},
+		{Order: OrderByAgeAsc, MinAge: 30},
+		{Order: OrderByAgeDesc, MaxAge: 40, Offset: 2},
+		{MinAge: 25, MaxAge: 50, NamePrefix: users[5].Name[:1]},
+		{After: users[10].ID, Limit: 5},
+		{After: users[10].ID, Order: OrderByIDDesc},
+		{NamePrefix: "%"},
+		{Offset: 100},
+	} {
+		t.Run(fmt.Sprintf("%+v", q), func(t *testing.T) {
+			expected, expectedTotal, err := sqlboiler.List(ctx, q)
+			require.NoError(t, err)
+
+			// run
+			actual, total, err := sqlc.List(ctx, q)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+			require.Equal(t, expectedTotal, total)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package gosqltests
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/volatiletech/sqlboiler/v4/queries/qm"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ListStream calls fn with each user matching query in the order of query, as List returns them.
+// Rows are read one by one instead of loaded at once, so it can scan large tables in constant memory.
+// It stops and returns the error if fn returns an error.
+// NOTE: the read timeout and WithReadRetry are not applied, because the duration depends on fn and
+// a retry would call fn with the same users again. Cancel ctx to stop it.
+func (r *userRepository) ListStream(ctx context.Context, query *ListQuery, fn func(*User) error) error {
+	filters, err := query.filters()
+	if err != nil {
+		return fmt.Errorf("invalid list query: %w", err)
+	}
+
+	columns := lo.Map(userColumnNames, func(c string, _ int) string { return quotedColumn(models.TableNames.User, c) })
+	mods := append([]qm.QueryMod{qm.Select(columns...)}, filters...)
+	mods = append(mods, query.pagination()...)
+
+	rows, err := models.Users(mods...).QueryContext(ctx, r.reader())
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Age, &u.DeletedAt, &u.Email, &u.Version, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		if err := fn(fromUserModel(&u)); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package gosqltests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dolthub/go-mysql-server/memory"
+	simsql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// test using go-sqlmock
+func TestListStreamWithSQLMock(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT `user`.`id`, `user`.`name`, `user`.`age`, `user`.`deleted_at`, `user`.`email`, `user`.`version`, `user`.`created_at`, `user`.`updated_at` FROM `user` WHERE (`user`.`age` >= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;")
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		title       string
+		mock        func(mock sqlmock.Sqlmock)
+		fn          func(users *[]*User) func(*User) error
+		expected    []*User
+		expectedErr string
+	}{
+		{
+			"stream all rows",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).
+						AddRow("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, time.Time{}, time.Time{}).
+						AddRow("1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, "bob@example.com", 1, time.Time{}, time.Time{}))
+			},
+			func(users *[]*User) func(*User) error {
+				return func(u *User) error {
+					*users = append(*users, u)
+					return nil
+				}
+			},
+			[]*User{
+				{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25), Email: "bob@example.com"},
+			},
+			"",
+		},
+		{
+			"stop by error of fn",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).
+						AddRow("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, time.Time{}, time.Time{}).
+						AddRow("1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, time.Time{}, time.Time{}))
+			},
+			func(users *[]*User) func(*User) error {
+				return func(u *User) error {
+					*users = append(*users, u)
+					return errStop
+				}
+			},
+			[]*User{{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}},
+			"stop",
+		},
+		{
+			"error while reading rows",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(20).
+					WillReturnRows(sqlmock.NewRows(userColumnNames).
+						AddRow("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, time.Time{}, time.Time{}).
+						AddRow("1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, time.Time{}, time.Time{}).
+						RowError(1, errors.New("connection reset")))
+			},
+			func(users *[]*User) func(*User) error {
+				return func(u *User) error {
+					*users = append(*users, u)
+					return nil
+				}
+			},
+			[]*User{{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}},
+			"failed to list users: connection reset",
+		},
+		{
+			"query error",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(20).
+					WillReturnError(errors.New("connection refused"))
+			},
+			func(users *[]*User) func(*User) error {
+				return func(u *User) error {
+					*users = append(*users, u)
+					return nil
+				}
+			},
+			nil,
+			"failed to list users: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+
+			// run
+			var actual []*User
+			r := NewUserRepository(db)
+			err := r.ListStream(context.TODO(), &ListQuery{MinAge: 20}, tt.fn(&actual))
+
+			// assert
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expected, actual)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// test using go-mysql-server
+func TestListStreamWithGoMySQLServer(t *testing.T) {
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewClient(port)
+	require.NoError(t, err)
+	users := generateUsers(t, 50)
+	seedUsers(ctx, t, db, users)
+	r := NewUserRepository(db)
+	require.NoError(t, r.Delete(ctx, users[0]))
+
+	// streams return the same users as List
+	for _, q := range []*ListQuery{
+		nil,
+		{Order: OrderByAgeDesc, Limit: 10, Offset: 5},
+		{After: users[10].ID, MinAge: 30},
+	} {
+		t.Run(fmt.Sprintf("%+v", q), func(t *testing.T) {
+			expected, _, err := r.List(ctx, q)
+			require.NoError(t, err)
+
+			// run
+			var actual []*User
+			err = r.ListStream(ctx, q, func(u *User) error {
+				actual = append(actual, u)
+				return nil
+			})
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+		})
+	}
+}
+
+// heapInUse returns the live heap after garbage collection.
+func heapInUse() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// test using go-mysql-server
+func TestListStreamConstantMemoryWithGoMySQLServer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipped because it seeds 100k users")
+	}
+
+	const n = 100_000
+	ctx := context.Background()
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	// NOTE: the user table is replaced with a keyless one,
+	// because go-mysql-server scans all rows of a keyed table to insert each row
+	db, keyed := simulatorDB()
+	schema := keyed.Schema().Copy()
+	for _, c := range schema {
+		c.PrimaryKey = false
+	}
+	table := memory.NewTable(models.TableNames.User, simsql.NewPrimaryKeySchema(schema), db.GetForeignKeyCollection())
+	db.AddTable(models.TableNames.User, table)
+	simCtx := simsql.NewEmptyContext()
+	inserter := table.Inserter(simCtx)
+	for i := 0; i < n; i++ {
+		require.NoError(t, inserter.Insert(simCtx, simsql.NewRow(fmt.Sprintf("%026d", i), fmt.Sprintf("user%d", i), int32(20+i%50), nil, nil, int64(1), seededAt, seededAt)))
+	}
+	require.NoError(t, inserter.Close(simCtx))
+	startSimulator(t, port, db)
+	client, err := NewClient(port)
+	require.NoError(t, err)
+	defer client.Close()
+	r := NewUserRepository(client)
+
+	// run
+	// NOTE: the simulator runs in this process and buffers the result at first, which dominates the peak of the heap.
+	// The heap at the last row shows what the client retains, e.g. all users if they were loaded at once.
+	base := heapInUse()
+	var count int
+	var last uint64
+	err = r.ListStream(ctx, nil, func(u *User) error {
+		count++
+		if count == n {
+			last = heapInUse()
+		}
+		return nil
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, n, count)
+	const maxGrowth = 4 << 20
+	require.Less(t, int64(last)-int64(base), int64(maxGrowth), "heap grew from %d to %d bytes while streaming", base, last)
+}
+
+// test using in-memory fake
+func TestListStreamInMemory(t *testing.T) {
+	ctx := context.Background()
+	users := generateUsers(t, 10)
+	r := NewInMemoryUserRepository(users...)
+	expected, _, err := r.List(ctx, &ListQuery{Order: OrderByNameAsc})
+	require.NoError(t, err)
+
+	// run
+	var actual []*User
+	err = r.ListStream(ctx, &ListQuery{Order: OrderByNameAsc}, func(u *User) error {
+		actual = append(actual, u)
+		return nil
+	})
+
+	// assert
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
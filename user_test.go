@@ -4,44 +4,86 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"flag"
 	"fmt"
-	"net"
-	"path/filepath"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/docker/go-connections/nat"
-	sqle "github.com/dolthub/go-mysql-server"
 	"github.com/dolthub/go-mysql-server/memory"
 	"github.com/dolthub/go-mysql-server/server"
 	simsql "github.com/dolthub/go-mysql-server/sql"
-	"github.com/dolthub/go-mysql-server/sql/information_schema"
+	"github.com/dolthub/go-mysql-server/sql/expression"
+	"github.com/dolthub/go-mysql-server/sql/expression/function"
+	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
 	testcontainers "github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/syuparn/gosqltests/models"
+	"github.com/syuparn/gosqltests/simulator"
+	"github.com/syuparn/gosqltests/testport"
+	"github.com/syuparn/gosqltests/testsupport"
 )
 
+// NOTE: set GOSQLTESTS_MYSQL_PORT to use MySQL on the port instead of docker-compose.yml.
+// Otherwise the stack of docker-compose.yml is started unless it is running, and the port is looked up by `docker compose port`.
+const mysqlPortEnv = "GOSQLTESTS_MYSQL_PORT"
+
+// dockerComposePort returns the host port of MySQL started by docker-compose.yml.
+func dockerComposePort(ctx context.Context, t *testing.T) int {
+	skipIfOverBudget(t)
+
+	if env := os.Getenv(mysqlPortEnv); env != "" {
+		port, err := strconv.Atoi(env)
+		if err != nil {
+			t.Fatalf("invalid %s: %s", mysqlPortEnv, err)
+		}
+		return port
+	}
+
+	if err := compose.up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	port, err := compose.port(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
 // test using docker container
 func TestListWithDocker(t *testing.T) {
 	ctx := context.Background()
 	user := &User{
 		ID:   "0123456789ABCDEFGHJKMNPQRS",
 		Name: "Mike",
-		Age:  20,
+		Age:  lo.ToPtr(20),
 	}
 
-	db, err := NewClient(3306)
-	require.NoError(t, err)
+	db, _, release := openSharedDatabase(ctx, t)
+	defer db.Close()
+	defer release()
+	ctx, mutations := trackMutations(ctx, t)
+	defer mutations.assertNoLeaks(t, ctx, db)
 
 	// run
 	r := NewUserRepository(db)
-	err = r.Register(ctx, user)
+	err := r.Register(ctx, user)
 	require.NoError(t, err)
 
 	// teardown
-	defer r.Delete(ctx, user)
+	// NOTE: Delete only marks the row, which would conflict with the next run
+	defer r.HardDelete(ctx, user)
 
+	AssertPersisted(t, ctx, db, user)
 	found, err := r.Get(ctx, user.ID)
 	require.NoError(t, err)
 
@@ -54,17 +96,17 @@ func TestGetWithTestContainers(t *testing.T) {
 	user := &User{
 		ID:   "0123456789ABCDEFGHJKMNPQRS",
 		Name: "Mike",
-		Age:  20,
+		Age:  lo.ToPtr(20),
 	}
 
-	db, teardown := prepareContainer(ctx, t)
-	defer teardown()
+	db := prepareContainer(ctx, t)
 
 	// run
 	r := NewUserRepository(db)
 	err := r.Register(ctx, user)
 	require.NoError(t, err)
 
+	AssertPersisted(t, ctx, db, user)
 	found, err := r.Get(ctx, user.ID)
 	require.NoError(t, err)
 
@@ -81,7 +123,7 @@ func TestGetWithTestContainersConcurrent(t *testing.T) {
 			&User{
 				ID:   "0123456789ABCDEFGHJKMNPQRS",
 				Name: "Mike",
-				Age:  20,
+				Age:  lo.ToPtr(20),
 			},
 		},
 		{
@@ -89,86 +131,387 @@ func TestGetWithTestContainersConcurrent(t *testing.T) {
 			&User{
 				ID:   "1123456789ABCDEFGHJKMNPQRS",
 				Name: "Bob",
-				Age:  25,
+				Age:  lo.ToPtr(25),
 			},
 		},
 	}
 
 	for _, tt := range tests {
+		tt := tt
 		t.Run(tt.title, func(t *testing.T) {
 			t.Parallel()
 			ctx := context.Background()
-			db, teardown := prepareContainer(ctx, t)
-			defer teardown()
+			db := prepareContainer(ctx, t)
 
 			// run
 			r := NewUserRepository(db)
 			err := r.Register(ctx, tt.user)
 			require.NoError(t, err)
 
+			AssertPersisted(t, ctx, db, tt.user)
 			found, err := r.Get(ctx, tt.user.ID)
 			require.NoError(t, err)
 
 			require.Equal(t, tt.user, found)
+			requireNoLeakedConnections(t, db)
 		})
 	}
 }
 
-func prepareContainer(ctx context.Context, t *testing.T) (*sql.DB, func()) {
-	req := testcontainers.ContainerRequest{
-		Image: "mysql:8",
+// NOTE: set GOSQLTESTS_REUSE_CONTAINERS=1 to keep the MySQL container between test runs.
+// The reused container is shared by all tests, so they are serialized and tables are truncated at the beginning.
+const (
+	reuseContainersEnv  = "GOSQLTESTS_REUSE_CONTAINERS"
+	reusedContainerName = "gosqltests-mysql"
+)
+
+// reusedContainerMu serializes tests sharing the reused container.
+var reusedContainerMu sync.Mutex
+
+func reuseContainers() bool {
+	return os.Getenv(reuseContainersEnv) == "1"
+}
+
+// NOTE: set GOSQLTESTS_MYSQL_IMAGE (or -mysql-image) to run container tests on another server, e.g. mysql:5.7 or mariadb:10.11.
+// Tests using SQL which the server does not support are skipped (see serverFeature).
+const (
+	mysqlImageEnv     = "GOSQLTESTS_MYSQL_IMAGE"
+	defaultMySQLImage = "mysql:8"
+)
+
+var mysqlImageFlag = flag.String("mysql-image", "", "image of MySQL-compatible server started by testcontainers (overrides "+mysqlImageEnv+")")
+
+// mysqlImage returns the image of containers, in the order of the version of the MySQL matrix, the flag,
+// the environment variable and the default.
+func mysqlImage() string {
+	if matrixImage != "" {
+		return matrixImage
+	}
+	if *mysqlImageFlag != "" {
+		return *mysqlImageFlag
+	}
+	if env := os.Getenv(mysqlImageEnv); env != "" {
+		return env
+	}
+	return defaultMySQLImage
+}
+
+var containerNameReplacer = strings.NewReplacer(":", "-", "/", "-", "@", "-")
+
+// reusedContainerNameOf returns the name of the reused container of the image.
+// NOTE: containers of different images must not be reused for each other
+func reusedContainerNameOf(image string) string {
+	if image == defaultMySQLImage {
+		return reusedContainerName
+	}
+	return reusedContainerName + "-" + containerNameReplacer.Replace(image)
+}
+
+// NOTE: MariaDB images accept the MYSQL_* variables as aliases
+func mysqlContainerRequest() testcontainers.ContainerRequest {
+	return testcontainers.ContainerRequest{
+		Image: mysqlImage(),
 		Env: map[string]string{
 			"MYSQL_ALLOW_EMPTY_PASSWORD": "yes",
 			"MYSQL_DATABASE":             "practice",
 		},
 		ExposedPorts: []string{"3306/tcp"},
-		Mounts: testcontainers.ContainerMounts{
-			testcontainers.BindMount(absPath("initdb.d"), "/docker-entrypoint-initdb.d"),
-		},
 		WaitingFor: wait.ForSQL("3306/tcp", "mysql", func(host string, port nat.Port) string {
 			return fmt.Sprintf("root:@(%s:%d)/practice", host, port.Int())
 		}),
-		AutoRemove: true,
 	}
-	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: req,
-		Started:          true,
-	})
+}
+
+type containerOption func(*testcontainers.ContainerRequest)
+
+// withSmallDisk keeps the datadir in tmpfs and caps the tablespace where tables are stored,
+// so that writes fail with "table is full" after a few MB.
+func withSmallDisk() containerOption {
+	return func(req *testcontainers.ContainerRequest) {
+		setTmpfs(req, "/var/lib/mysql", "rw,size=512m")
+		req.Cmd = append(req.Cmd,
+			// NOTE: tables are created in the system tablespace, which cannot grow beyond max
+			"--innodb-file-per-table=OFF",
+			"--innodb-data-file-path=ibdata1:12M:autoextend:max:16M",
+		)
+	}
+}
+
+// withFastMySQL keeps the datadir in tmpfs and disables durability, which is useless for disposable containers.
+// It makes both startup and writes much faster.
+func withFastMySQL() containerOption {
+	return func(req *testcontainers.ContainerRequest) {
+		setTmpfs(req, "/var/lib/mysql", "rw")
+		req.Cmd = append(req.Cmd,
+			"--innodb-flush-log-at-trx-commit=0",
+			"--innodb-doublewrite=OFF",
+			"--sync-binlog=0",
+			"--skip-log-bin",
+		)
+	}
+}
+
+// setTmpfs mounts tmpfs unless another option has already mounted one on the path.
+func setTmpfs(req *testcontainers.ContainerRequest, path, options string) {
+	if req.Tmpfs == nil {
+		req.Tmpfs = map[string]string{}
+	}
+	if _, ok := req.Tmpfs[path]; !ok {
+		req.Tmpfs[path] = options
+	}
+}
+
+// withImage runs the image instead of the one chosen by mysqlImage, e.g. to test another version of MySQL.
+func withImage(image string) containerOption {
+	return func(req *testcontainers.ContainerRequest) {
+		req.Image = image
+	}
+}
+
+// withMaxConnections limits connections the server accepts.
+// NOTE: MySQL accepts one more connection for a user with CONNECTION_ADMIN, such as root.
+func withMaxConnections(n int) containerOption {
+	return func(req *testcontainers.ContainerRequest) {
+		req.Cmd = append(req.Cmd, fmt.Sprintf("--max-connections=%d", n))
+	}
+}
+
+func TestMySQLRequestOptions(t *testing.T) {
+	// run
+	req := mysqlContainerRequest()
+	for _, opt := range []containerOption{withSmallDisk(), withFastMySQL(), withMaxConnections(5)} {
+		opt(&req)
+	}
+
+	// assert
+	require.Equal(t, map[string]string{"/var/lib/mysql": "rw,size=512m"}, req.Tmpfs)
+	require.Equal(t, []string{
+		"--innodb-file-per-table=OFF",
+		"--innodb-data-file-path=ibdata1:12M:autoextend:max:16M",
+		"--innodb-flush-log-at-trx-commit=0",
+		"--innodb-doublewrite=OFF",
+		"--sync-binlog=0",
+		"--skip-log-bin",
+		"--max-connections=5",
+	}, req.Cmd)
+}
+
+func TestReusedContainerNameOf(t *testing.T) {
+	tests := []struct {
+		title    string
+		image    string
+		expected string
+	}{
+		{"default image", "mysql:8", "gosqltests-mysql"},
+		{"another version", "mysql:5.7", "gosqltests-mysql-mysql-5.7"},
+		{"mariadb", "mariadb:10.11", "gosqltests-mysql-mariadb-10.11"},
+		{"image of registry", "ghcr.io/org/mysql:8", "gosqltests-mysql-ghcr.io-org-mysql-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// run
+			actual := reusedContainerNameOf(tt.image)
+
+			// assert
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func prepareContainer(ctx context.Context, t testing.TB, opts ...containerOption) *sql.DB {
+	port := startContainer(ctx, t, opts...)
+
+	db, err := NewStrictClient(port)
 	if err != nil {
-		t.Fatalf("failed to start container: %s", err)
+		t.Fatalf("failed to create client: %s", err)
+	}
+	t.Cleanup(func() {
+		closeTestClient(t, db)
+	})
+
+	skipUnlessMigratable(ctx, t, db)
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("failed to migrate: %s", err)
 	}
 
-	teardown := func() {
-		if err := container.Terminate(ctx); err != nil {
-			t.Fatalf("failed to terminate container: %s", err)
+	if shareContainers() && len(opts) == 0 {
+		if err := truncateTables(ctx, db, "practice"); err != nil {
+			t.Fatalf("failed to truncate tables: %s", err)
 		}
 	}
 
-	port, err := container.MappedPort(ctx, "3306")
+	return db
+}
+
+// startContainer starts (or reuses) a MySQL container and returns its mapped port.
+func startContainer(ctx context.Context, t testing.TB, opts ...containerOption) int {
+	_, port := startMySQLContainer(ctx, t, opts...)
+	return port
+}
+
+// startMySQLContainer is startContainer which also returns the container, e.g. to exec commands in it.
+func startMySQLContainer(ctx context.Context, t testing.TB, opts ...containerOption) (testcontainers.Container, int) {
+	skipIfOverBudget(t)
+
+	// NOTE: customized containers cannot be shared
+	reuse := shareContainers() && len(opts) == 0
+
+	req := mysqlContainerRequest()
+	for _, opt := range opts {
+		opt(&req)
+	}
+
+	var container testcontainers.Container
+	if reuse {
+		container = startSharedContainer(ctx, t, req)
+	} else {
+		req.AutoRemove = true
+		container = testsupport.StartContainer(ctx, t, harnessRuntime.request(req))
+	}
+	// NOTE: tests of the MySQL matrix have the same names in every version
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("failed on %s", req.Image)
+		}
+	})
+
+	return container, harnessRuntime.localPort(ctx, t, container, "3306")
+}
+
+// startSharedContainer starts (or reuses) the container shared by tests of the image, which the test holds until it finishes.
+func startSharedContainer(ctx context.Context, t testing.TB, req testcontainers.ContainerRequest) testcontainers.Container {
+	req.Name = reusedContainerNameOf(req.Image)
+	// NOTE: Ryuk would remove the container after the test process exits
+	req.SkipReaper = reuseContainers()
+	reusedContainerMu.Lock()
+	t.Cleanup(reusedContainerMu.Unlock)
+
+	gr := harnessRuntime.request(req)
+	gr.Reuse = true
+	container, err := testcontainers.GenericContainer(ctx, gr)
 	if err != nil {
-		t.Fatalf("failed to get mapped port: %s", err)
+		t.Fatalf("failed to start container: %s", err)
 	}
+	sharedContainers[req.Image] = container
+	return container
+}
 
-	db, err := NewClient(port.Int())
+// prepareRestartableContainer starts a dedicated container which can be restarted by RestartDatabase.
+// NOTE: the host port is fixed because docker may map another port after restart,
+// and the container is not auto-removed because it would be removed when stopped.
+func prepareRestartableContainer(ctx context.Context, t *testing.T) (db *sql.DB, restartDatabase func(context.Context) error) {
+	skipIfOverBudget(t)
+	harnessRuntime.skipUnless(t, capabilityFixedHostPorts)
+
+	hostPort, err := testport.Reserve()
+	if err != nil {
+		t.Fatalf("failed to get free port: %s", err)
+	}
+
+	req := mysqlContainerRequest()
+	req.ExposedPorts = []string{fmt.Sprintf("%d:3306/tcp", hostPort)}
+	container := testsupport.StartContainer(ctx, t, harnessRuntime.request(req))
+
+	db, err = NewStrictClient(hostPort)
 	if err != nil {
 		t.Fatalf("failed to create client: %s", err)
 	}
 
-	return db, teardown
+	skipUnlessMigratable(ctx, t, db)
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("failed to migrate: %s", err)
+	}
+
+	// restartDatabase stops the server and waits until it accepts connections again.
+	restartDatabase = func(ctx context.Context) error {
+		if err := container.Stop(ctx, nil); err != nil {
+			return fmt.Errorf("failed to stop container: %w", err)
+		}
+		if err := container.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start container: %w", err)
+		}
+		// NOTE: the port of the container is ready before MySQL accepts queries
+		return NewHealthChecker(db, nil).WaitHealthy(ctx, defaultWaitTimeout)
+	}
+
+	return db, restartDatabase
 }
 
-func absPath(path string) string {
-	abs, err := filepath.Abs(path)
+// truncateTables empties all tables in the schema left by the previous run, except for the migration history.
+func truncateTables(ctx context.Context, db *sql.DB, schema string) error {
+	// NOTE: foreign key checks are disabled per session, so all statements must run on the same connection
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer conn.Close()
 
-	return abs
+	rows, err := conn.QueryContext(ctx,
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_type = 'BASE TABLE' AND table_name <> 'schema_migrations'",
+		schema,
+	)
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 1")
+
+	for _, table := range tables {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE `%s`.`%s`", schema, table)); err != nil {
+			return fmt.Errorf("failed to truncate %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+func TestTruncateTablesWithGoMySQLServer(t *testing.T) {
+	// simulator
+	table := prepareSimulator(t, 23306)
+	_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow(
+		"0123456789ABCDEFGHJKMNPQRS",
+		"Mike",
+		int32(20),
+		nil,
+		nil,
+		int64(1),
+		seededAt,
+		seededAt,
+	))
+
+	// run
+	db, err := NewStrictClient(23306)
+	require.NoError(t, err)
+	err = truncateTables(context.TODO(), db, "practice")
+	require.NoError(t, err)
+
+	// assert
+	users, total, err := NewUserRepository(db).List(context.TODO(), nil)
+	require.NoError(t, err)
+	require.Empty(t, users)
+	require.Zero(t, total)
 }
 
 // test using go-sqlmock
 func TestGetWithSQLMock(t *testing.T) {
-	columns := []string{"id", "name", "age"}
+	columns := userColumnNames
 
 	tests := []struct {
 		title    string
@@ -180,12 +523,28 @@ func TestGetWithSQLMock(t *testing.T) {
 		{
 			"get a user",
 			"0123456789ABCDEFGHJKMNPQRS",
-			"SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) LIMIT 1",
-			[]driver.Value{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1",
+			[]driver.Value{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, seededAt, seededAt.Add(time.Hour)},
 			&User{
-				ID:   "0123456789ABCDEFGHJKMNPQRS",
-				Name: "Mike",
-				Age:  20,
+				ID:        "0123456789ABCDEFGHJKMNPQRS",
+				Name:      "Mike",
+				Age:       lo.ToPtr(20),
+				CreatedAt: seededAt,
+				UpdatedAt: seededAt.Add(time.Hour),
+			},
+		},
+		{
+			"get a user with email",
+			"0123456789ABCDEFGHJKMNPQRS",
+			"SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1",
+			[]driver.Value{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, "mike@example.com", 1, seededAt, seededAt},
+			&User{
+				ID:        "0123456789ABCDEFGHJKMNPQRS",
+				Name:      "Mike",
+				Age:       lo.ToPtr(20),
+				Email:     "mike@example.com",
+				CreatedAt: seededAt,
+				UpdatedAt: seededAt,
 			},
 		},
 	}
@@ -193,10 +552,10 @@ func TestGetWithSQLMock(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.title, func(t *testing.T) {
 			// mock
-			db, mock, teardown := prepareMockDB(t)
-			defer teardown()
+			db, mock := setupMockDBBackend(context.TODO(), t)
 			rows := sqlmock.NewRows(columns).AddRow(tt.mockRow...)
 			mock.ExpectQuery(regexp.QuoteMeta(tt.query)).
+				WithArgs(tt.id).
 				WillReturnRows(rows)
 
 			// run
@@ -221,14 +580,14 @@ func TestGetErrorWithSQLMock(t *testing.T) {
 		{
 			"not found",
 			"0123456789ABCDEFGHJKMNPQRS",
-			"SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) LIMIT 1",
+			"SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1",
 			sql.ErrNoRows,
 			"user was not found (id: 0123456789ABCDEFGHJKMNPQRS): sql: no rows in result set",
 		},
 		{
 			"unexpected error",
 			"0123456789ABCDEFGHJKMNPQRS",
-			"SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) LIMIT 1",
+			"SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1",
 			fmt.Errorf("crashed unexpectedly!!!"),
 			"failed to get user (id: 0123456789ABCDEFGHJKMNPQRS): models: failed to execute a one query for user: bind failed to execute query: crashed unexpectedly!!!",
 		},
@@ -237,9 +596,9 @@ func TestGetErrorWithSQLMock(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.title, func(t *testing.T) {
 			// mock
-			db, mock, teardown := prepareMockDB(t)
-			defer teardown()
+			db, mock := setupMockDBBackend(context.TODO(), t)
 			mock.ExpectQuery(regexp.QuoteMeta(tt.query)).
+				WithArgs(tt.id).
 				WillReturnError(tt.mockErr)
 
 			// run
@@ -253,60 +612,353 @@ func TestGetErrorWithSQLMock(t *testing.T) {
 	}
 }
 
-func prepareMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock, func()) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+func TestListWithSQLMock(t *testing.T) {
+	columns := userColumnNames
+
+	tests := []struct {
+		title         string
+		query         *ListQuery
+		countQuery    string
+		countArgs     []driver.Value
+		listQuery     string
+		listArgs      []driver.Value
+		mockRows      [][]driver.Value
+		expected      []*User
+		expectedTotal int64
+	}{
+		{
+			"list all users",
+			nil,
+			"SELECT COUNT(*) FROM `user` WHERE (`user`.`deleted_at` is null);",
+			nil,
+			"SELECT `user`.* FROM `user` WHERE (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC;",
+			nil,
+			[][]driver.Value{
+				{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, time.Time{}, time.Time{}},
+				{"1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, time.Time{}, time.Time{}},
+			},
+			[]*User{
+				{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)},
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+			},
+			2,
+		},
+		{
+			"filter and paginate users",
+			&ListQuery{
+				Limit:      1,
+				Offset:     1,
+				NamePrefix: "M_",
+				MinAge:     20,
+				MaxAge:     30,
+				Order:      OrderByNameDesc,
+			},
+			"SELECT COUNT(*) FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` >= ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null);",
+			[]driver.Value{`M\_%`, 20, 30},
+			"SELECT `user`.* FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` >= ?) AND (`user`.`age` <= ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`name` DESC, `user`.`id` DESC LIMIT 1 OFFSET 1;",
+			[]driver.Value{`M\_%`, 20, 30},
+			[][]driver.Value{
+				{"0123456789ABCDEFGHJKMNPQRS", "M_ke", 20, nil, nil, 1, time.Time{}, time.Time{}},
+			},
+			[]*User{
+				{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "M_ke", Age: lo.ToPtr(20)},
+			},
+			2,
+		},
+		{
+			"skip users without limit",
+			&ListQuery{
+				Offset: 1,
+			},
+			"SELECT COUNT(*) FROM `user` WHERE (`user`.`deleted_at` is null);",
+			nil,
+			"SELECT `user`.* FROM `user` WHERE (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 9223372036854775806 OFFSET 1;",
+			nil,
+			[][]driver.Value{
+				{"1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, time.Time{}, time.Time{}},
+			},
+			[]*User{
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+			},
+			2,
+		},
+		{
+			"paginate users by cursor",
+			&ListQuery{
+				Limit: 1,
+				After: "0123456789ABCDEFGHJKMNPQRS",
+			},
+			"SELECT COUNT(*) FROM `user` WHERE (`user`.`deleted_at` is null);",
+			nil,
+			"SELECT `user`.* FROM `user` WHERE (`user`.`id` > ?) AND (`user`.`deleted_at` is null) ORDER BY `user`.`id` ASC LIMIT 1;",
+			[]driver.Value{"0123456789ABCDEFGHJKMNPQRS"},
+			[][]driver.Value{
+				{"1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, time.Time{}, time.Time{}},
+			},
+			[]*User{
+				{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Bob", Age: lo.ToPtr(25)},
+			},
+			2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := setupMockDBBackend(context.TODO(), t)
+			mock.ExpectQuery(regexp.QuoteMeta(tt.countQuery)).
+				WithArgs(tt.countArgs...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.expectedTotal))
+			rows := sqlmock.NewRows(columns)
+			for _, row := range tt.mockRows {
+				rows.AddRow(row...)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta(tt.listQuery)).
+				WithArgs(tt.listArgs...).
+				WillReturnRows(rows)
+
+			// run
+			r := NewUserRepository(db)
+			actual, total, err := r.List(context.TODO(), tt.query)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			require.Equal(t, tt.expectedTotal, total)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListErrorWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title       string
+		query       *ListQuery
+		expectedErr string
+	}{
+		{
+			"negative limit",
+			&ListQuery{Limit: -1},
+			"invalid list query: limit and offset must not be negative (limit: -1, offset: 0)",
+		},
+		{
+			"min age exceeds max age",
+			&ListQuery{MinAge: 30, MaxAge: 20},
+			"invalid list query: min age must not exceed max age (min: 30, max: 20)",
+		},
+		{
+			"cursor with order by name",
+			&ListQuery{After: "0123456789ABCDEFGHJKMNPQRS", Order: OrderByNameAsc},
+			"invalid list query: cursor can only be used with order by id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := setupMockDBBackend(context.TODO(), t)
+
+			// run
+			r := NewUserRepository(db)
+			_, _, err := r.List(context.TODO(), tt.query)
+
+			// assert
+			require.EqualError(t, err, tt.expectedErr)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTimeoutWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title   string
+		opts    []UserRepositoryOption
+		timeout time.Duration
+		prepare func(sqlmock.Sqlmock)
+		run     func(context.Context, *userRepository) error
+	}{
+		{
+			"read timeout",
+			[]UserRepositoryOption{WithReadTimeout(10 * time.Millisecond)},
+			0,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1")).
+					WithArgs("0123456789ABCDEFGHJKMNPQRS").
+					WillDelayFor(time.Second).
+					WillReturnRows(sqlmock.NewRows(userColumnNames))
+			},
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.Get(ctx, "0123456789ABCDEFGHJKMNPQRS")
+				return err
+			},
+		},
+		{
+			"write timeout",
+			[]UserRepositoryOption{WithWriteTimeout(10 * time.Millisecond)},
+			0,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `user` (`id`,`name`,`age`,`deleted_at`,`email`,`version`,`created_at`,`updated_at`) VALUES (?,?,?,?,?,?,?,?)")).
+					WithArgs("0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, TimeArg(time.Now(), time.Minute), TimeArg(time.Now(), time.Minute)).
+					WillDelayFor(time.Second).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			func(ctx context.Context, r *userRepository) error {
+				return r.Register(ctx, &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)})
+			},
+		},
+		{
+			"caller's deadline is shorter",
+			nil,
+			10 * time.Millisecond,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT `user`.* FROM `user` WHERE (`user`.`name` = ?) AND (`user`.`deleted_at` is null) LIMIT 1")).
+					WithArgs("Mike").
+					WillDelayFor(time.Second).
+					WillReturnRows(sqlmock.NewRows(userColumnNames))
+			},
+			func(ctx context.Context, r *userRepository) error {
+				_, err := r.GetByName(ctx, "Mike")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.prepare(mock)
+
+			// run
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			r := NewUserRepository(db, tt.opts...)
+			start := time.Now()
+			err := tt.run(ctx, r)
+
+			// assert
+			require.ErrorIs(t, err, sqlmock.ErrCancelled)
+			require.Less(t, time.Since(start), time.Second)
+		})
 	}
+}
 
-	teardown := func() {
-		db.Close()
+func TestCountWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title    string
+		query    *ListQuery
+		sql      string
+		args     []driver.Value
+		expected int64
+	}{
+		{
+			"count all users",
+			nil,
+			"SELECT COUNT(*) FROM `user` WHERE (`user`.`deleted_at` is null);",
+			nil,
+			2,
+		},
+		{
+			"count with filters ignoring pagination",
+			&ListQuery{Limit: 1, Offset: 1, NamePrefix: "M", MinAge: 20},
+			"SELECT COUNT(*) FROM `user` WHERE (`user`.`name` LIKE ?) AND (`user`.`age` >= ?) AND (`user`.`deleted_at` is null);",
+			[]driver.Value{"M%", 20},
+			1,
+		},
 	}
 
-	return db, mock, teardown
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := setupMockDBBackend(context.TODO(), t)
+			mock.ExpectQuery(regexp.QuoteMeta(tt.sql)).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.expected))
+
+			// run
+			r := NewUserRepository(db)
+			actual, err := r.Count(context.TODO(), tt.query)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
 }
 
-// test using go-mysql-server
-func TestGetWithGoMySQLServer(t *testing.T) {
+func TestExistsWithSQLMock(t *testing.T) {
+	tests := []struct {
+		title    string
+		count    int64
+		expected bool
+	}{
+		{"user exists", 1, true},
+		{"user does not exist", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := setupMockDBBackend(context.TODO(), t)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1;")).
+				WithArgs("0123456789ABCDEFGHJKMNPQRS").
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.count))
+
+			// run
+			r := NewUserRepository(db)
+			actual, err := r.Exists(context.TODO(), "0123456789ABCDEFGHJKMNPQRS")
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func prepareMockDB(t testing.TB) (*sql.DB, sqlmock.Sqlmock) {
+	return testsupport.NewMockDB(t)
+}
+
+// test using the backend chosen by -db-backend
+func TestGetOnDBBackend(t *testing.T) {
 	tests := []struct {
 		title    string
 		id       string
-		prepare  func(*simsql.Context, *memory.Table)
+		prepare  func(*testing.T, *sql.DB)
 		expected *User
 	}{
 		{
 			"get a user",
 			"0123456789ABCDEFGHJKMNPQRS",
-			func(ctx *simsql.Context, table *memory.Table) {
-				_ = table.Insert(ctx, simsql.NewRow(
-					"0123456789ABCDEFGHJKMNPQRS",
-					"Mike",
-					int64(20),
-				))
-				_ = table.Insert(ctx, simsql.NewRow(
-					"1123456789ABCDEFGHJKMNPQRS",
-					"Bob",
-					int64(25),
-				))
+			func(t *testing.T, db *sql.DB) {
+				seedRows(t, db, models.TableNames.User,
+					[]interface{}{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, seededAt, seededAt},
+					[]interface{}{"1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, seededAt, seededAt},
+				)
 			},
 			&User{
-				ID:   "0123456789ABCDEFGHJKMNPQRS",
-				Name: "Mike",
-				Age:  20,
+				ID:        "0123456789ABCDEFGHJKMNPQRS",
+				Name:      "Mike",
+				Age:       lo.ToPtr(20),
+				CreatedAt: seededAt,
+				UpdatedAt: seededAt,
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.title, func(t *testing.T) {
-			// simulator
-			table, teardown := prepareSimulator(t, 23306)
-			defer teardown()
-			tt.prepare(simsql.NewEmptyContext(), table)
+			// backend
+			db := setupStoringDBBackend(context.TODO(), t)
+			tt.prepare(t, db)
 
 			// run
-			db, err := NewClient(23306)
-			require.NoError(t, err)
 			r := NewUserRepository(db)
 			actual, err := r.Get(context.TODO(), tt.id)
 
@@ -317,6 +969,126 @@ func TestGetWithGoMySQLServer(t *testing.T) {
 	}
 }
 
+func TestListOnDBBackend(t *testing.T) {
+	prepare := func(t *testing.T, db *sql.DB) {
+		seedRows(t, db, models.TableNames.User,
+			[]interface{}{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, seededAt, seededAt},
+			[]interface{}{"1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, seededAt, seededAt},
+			[]interface{}{"2123456789ABCDEFGHJKMNPQRS", "Mary", 30, nil, nil, 1, seededAt, seededAt},
+			[]interface{}{"3123456789ABCDEFGHJKMNPQRS", "M_x", 35, nil, nil, 1, seededAt, seededAt},
+		)
+	}
+
+	tests := []struct {
+		title         string
+		query         *ListQuery
+		expectedIDs   []string
+		expectedTotal int64
+	}{
+		{
+			"list all users",
+			nil,
+			[]string{
+				"0123456789ABCDEFGHJKMNPQRS",
+				"1123456789ABCDEFGHJKMNPQRS",
+				"2123456789ABCDEFGHJKMNPQRS",
+				"3123456789ABCDEFGHJKMNPQRS",
+			},
+			4,
+		},
+		{
+			"filter by name prefix",
+			&ListQuery{NamePrefix: "M", Order: OrderByNameAsc},
+			[]string{
+				"3123456789ABCDEFGHJKMNPQRS",
+				"2123456789ABCDEFGHJKMNPQRS",
+				"0123456789ABCDEFGHJKMNPQRS",
+			},
+			3,
+		},
+		{
+			"name prefix wildcards are escaped",
+			&ListQuery{NamePrefix: "M_"},
+			[]string{
+				"3123456789ABCDEFGHJKMNPQRS",
+			},
+			1,
+		},
+		{
+			"filter by age range",
+			&ListQuery{MinAge: 21, MaxAge: 30, Order: OrderByAgeDesc},
+			[]string{
+				"2123456789ABCDEFGHJKMNPQRS",
+				"1123456789ABCDEFGHJKMNPQRS",
+			},
+			2,
+		},
+		{
+			"paginate by limit and offset",
+			&ListQuery{Limit: 2, Offset: 1},
+			[]string{
+				"1123456789ABCDEFGHJKMNPQRS",
+				"2123456789ABCDEFGHJKMNPQRS",
+			},
+			4,
+		},
+		{
+			"paginate by cursor",
+			&ListQuery{Limit: 2, After: "2123456789ABCDEFGHJKMNPQRS", Order: OrderByIDDesc},
+			[]string{
+				"1123456789ABCDEFGHJKMNPQRS",
+				"0123456789ABCDEFGHJKMNPQRS",
+			},
+			4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// backend
+			db := setupStoringDBBackend(context.TODO(), t)
+			prepare(t, db)
+
+			// run
+			r := NewUserRepository(db)
+			actual, total, err := r.List(context.TODO(), tt.query)
+
+			// assert
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedIDs, lo.Map(actual, func(u *User, _ int) string { return u.ID }))
+			require.Equal(t, tt.expectedTotal, total)
+		})
+	}
+}
+
+func TestCountAndExistsOnDBBackend(t *testing.T) {
+	// backend
+	db := setupStoringDBBackend(context.TODO(), t)
+	seedRows(t, db, models.TableNames.User,
+		[]interface{}{"0123456789ABCDEFGHJKMNPQRS", "Mike", 20, nil, nil, 1, seededAt, seededAt},
+		[]interface{}{"1123456789ABCDEFGHJKMNPQRS", "Bob", 25, nil, nil, 1, seededAt, seededAt},
+		[]interface{}{"2123456789ABCDEFGHJKMNPQRS", "Mary", 30, nil, nil, 1, seededAt, seededAt},
+	)
+	r := NewUserRepository(db)
+
+	// run & assert
+	total, err := r.Count(context.TODO(), nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), total)
+
+	total, err = r.Count(context.TODO(), &ListQuery{NamePrefix: "M", Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), total)
+
+	exists, err := r.Exists(context.TODO(), "1123456789ABCDEFGHJKMNPQRS")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = r.Exists(context.TODO(), "9123456789ABCDEFGHJKMNPQRS")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
 func TestGetWithGoMySQLServerConcurrent(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -332,18 +1104,30 @@ func TestGetWithGoMySQLServerConcurrent(t *testing.T) {
 				_ = table.Insert(ctx, simsql.NewRow(
 					"0123456789ABCDEFGHJKMNPQRS",
 					"Mike",
-					int64(20),
+					int32(20),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
 				))
 				_ = table.Insert(ctx, simsql.NewRow(
 					"1123456789ABCDEFGHJKMNPQRS",
 					"Bob",
-					int64(25),
+					int32(25),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
 				))
 			},
 			&User{
-				ID:   "0123456789ABCDEFGHJKMNPQRS",
-				Name: "Mike",
-				Age:  20,
+				ID:        "0123456789ABCDEFGHJKMNPQRS",
+				Name:      "Mike",
+				Age:       lo.ToPtr(20),
+				CreatedAt: seededAt,
+				UpdatedAt: seededAt,
 			},
 		},
 		{
@@ -353,35 +1137,47 @@ func TestGetWithGoMySQLServerConcurrent(t *testing.T) {
 				_ = table.Insert(ctx, simsql.NewRow(
 					"0123456789ABCDEFGHJKMNPQRS",
 					"Mike",
-					int64(20),
+					int32(20),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
 				))
 				_ = table.Insert(ctx, simsql.NewRow(
 					"1123456789ABCDEFGHJKMNPQRS",
 					"Bob",
-					int64(25),
+					int32(25),
+					nil,
+					nil,
+					int64(1),
+					seededAt,
+					seededAt,
 				))
 			},
 			&User{
-				ID:   "1123456789ABCDEFGHJKMNPQRS",
-				Name: "Bob",
-				Age:  25,
+				ID:        "1123456789ABCDEFGHJKMNPQRS",
+				Name:      "Bob",
+				Age:       lo.ToPtr(25),
+				CreatedAt: seededAt,
+				UpdatedAt: seededAt,
 			},
 		},
 	}
 
 	for _, tt := range tests {
+		tt := tt
 		t.Run(tt.title, func(t *testing.T) {
 			t.Parallel()
 
 			// simulator
-			port, err := freePort()
+			port, err := testport.Reserve()
 			require.NoError(t, err)
-			table, teardown := prepareSimulator(t, port)
-			defer teardown()
+			table := prepareSimulator(t, port)
 			tt.prepare(simsql.NewEmptyContext(), table)
 
 			// run
-			db, err := NewClient(port)
+			db, err := NewStrictClient(port)
 			require.NoError(t, err)
 			r := NewUserRepository(db)
 			actual, err := r.Get(context.TODO(), tt.id)
@@ -393,61 +1189,180 @@ func TestGetWithGoMySQLServerConcurrent(t *testing.T) {
 	}
 }
 
-func freePort() (int, error) {
-	// NOTE: free port are chosen if port 0 is specified
-	l, err := net.Listen("tcp4", "localhost:0")
-	if err != nil {
-		return 0, err
+func TestTimeoutWithGoMySQLServer(t *testing.T) {
+	tests := []struct {
+		title       string
+		opts        []UserRepositoryOption
+		cancelled   bool
+		expectedErr error
+	}{
+		{
+			"context is cancelled",
+			nil,
+			true,
+			context.Canceled,
+		},
+		{
+			"read timeout is exceeded",
+			[]UserRepositoryOption{WithReadTimeout(time.Nanosecond)},
+			false,
+			context.DeadlineExceeded,
+		},
+		{
+			"timeout is disabled",
+			[]UserRepositoryOption{WithReadTimeout(0)},
+			false,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// simulator
+			table := prepareSimulator(t, 23306)
+			_ = table.Insert(simsql.NewEmptyContext(), simsql.NewRow("0123456789ABCDEFGHJKMNPQRS", "Mike", int32(20), nil, nil, int64(1), seededAt, seededAt))
+
+			// run
+			db, err := NewStrictClient(23306)
+			require.NoError(t, err)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			r := NewUserRepository(db, tt.opts...)
+			_, err = r.Get(ctx, "0123456789ABCDEFGHJKMNPQRS")
+
+			// assert
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
 	}
-	// close connection to use later
-	l.Close()
-	addr := l.Addr().(*net.TCPAddr)
-	return addr.Port, nil
 }
 
-func prepareSimulator(t *testing.T, port int) (*memory.Table, func()) {
+func prepareSimulator(t testing.TB, port int) *memory.Table {
 	db, table := simulatorDB()
+	startSimulator(t, port, db)
+	return table
+}
 
-	engine := sqle.NewDefault(
-		simsql.NewDatabaseProvider(
-			db,
-			information_schema.NewInformationSchemaDatabase(),
-		))
-	engine.Analyzer.Catalog.MySQLDb.AddSuperUser("root", "localhost", "")
+// prepareEmptySimulator starts a simulator without tables, which are created by migrations.
+func prepareEmptySimulator(t testing.TB, port int) {
+	startSimulator(t, port, simulator.NewDatabase())
+}
 
-	config := server.Config{
-		Protocol: "tcp",
-		Address:  fmt.Sprintf("localhost:%d", port),
-	}
-	s, err := server.NewDefaultServer(config, engine)
+func startSimulator(t testing.TB, port int, db *memory.Database) *server.Server {
+	return testsupport.StartSimulator(t, port, db)
+}
+
+// userVersionDefault is DEFAULT 1 of the version column, so that rows inserted without versions are valid as in MySQL.
+func userVersionDefault() *simsql.ColumnDefaultValue {
+	d, err := simsql.NewColumnDefaultValue(expression.NewLiteral(int64(initialUserVersion), simsql.Int64), simsql.Int64, true, false, false)
 	if err != nil {
-		t.Fatal(err)
+		panic(err)
 	}
-	go func() {
-		if err = s.Start(); err != nil {
-			panic(err)
-		}
-	}()
+	return d
+}
 
-	teardown := func() {
-		if err := s.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}
+// seededAt is created_at and updated_at of rows inserted into simulators directly.
+// NOTE: DATETIME columns of go-mysql-server reject the zero time
+var seededAt = time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
 
-	return table, teardown
+// currentTimestampDefault is DEFAULT CURRENT_TIMESTAMP of created_at and updated_at, which fills rows inserted without them.
+func currentTimestampDefault() *simsql.ColumnDefaultValue {
+	now, err := function.NewNow()
+	if err != nil {
+		panic(err)
+	}
+	d, err := simsql.NewColumnDefaultValue(now, simsql.Datetime, false, false, false)
+	if err != nil {
+		panic(err)
+	}
+	return d
 }
 
 func simulatorDB() (*memory.Database, *memory.Table) {
-	db := memory.NewDatabase("practice")
+	db := simulator.NewDatabase()
+	return db, addSimulatorTables(db)
+}
 
-	tableName := "user"
+// addSimulatorTables creates the tables of simulatorDB in db, and returns the user table.
+func addSimulatorTables(db *memory.Database) *memory.Table {
+	tableName := models.TableNames.User
 	table := memory.NewTable(tableName, simsql.NewPrimaryKeySchema(simsql.Schema{
-		{Name: "id", Type: simsql.Text, Nullable: false, Source: tableName, PrimaryKey: true},
-		{Name: "name", Type: simsql.Text, Nullable: false, Source: tableName},
-		{Name: "age", Type: simsql.Int64, Nullable: false, Source: tableName},
+		{Name: models.UserColumns.ID, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 26), Nullable: false, Source: tableName, PrimaryKey: true},
+		{Name: models.UserColumns.Name, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 40), Nullable: false, Source: tableName},
+		{Name: models.UserColumns.Age, Type: simsql.Int32, Nullable: true, Source: tableName},
+		{Name: models.UserColumns.DeletedAt, Type: simsql.Datetime, Nullable: true, Source: tableName},
+		{Name: models.UserColumns.Email, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 254), Nullable: true, Source: tableName},
+		{Name: models.UserColumns.Version, Type: simsql.Int64, Nullable: false, Source: tableName, Default: userVersionDefault()},
+		{Name: models.UserColumns.CreatedAt, Type: simsql.Datetime, Nullable: false, Source: tableName, Default: currentTimestampDefault()},
+		{Name: models.UserColumns.UpdatedAt, Type: simsql.Datetime, Nullable: false, Source: tableName, Default: currentTimestampDefault()},
 	}), db.GetForeignKeyCollection())
 	db.AddTable(tableName, table)
 
-	return db, table
+	credentialTableName := models.TableNames.Credential
+	credentialTable := memory.NewTable(credentialTableName, simsql.NewPrimaryKeySchema(simsql.Schema{
+		{Name: models.CredentialColumns.UserID, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 26), Nullable: false, Source: credentialTableName, PrimaryKey: true},
+		{Name: models.CredentialColumns.PasswordHash, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 60), Nullable: false, Source: credentialTableName},
+	}), db.GetForeignKeyCollection())
+	db.AddTable(credentialTableName, credentialTable)
+
+	orderTableName := models.TableNames.Order
+	orderTable := memory.NewTable(orderTableName, simsql.NewPrimaryKeySchema(simsql.Schema{
+		{Name: models.OrderColumns.ID, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 26), Nullable: false, Source: orderTableName, PrimaryKey: true},
+		{Name: models.OrderColumns.UserID, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 26), Nullable: false, Source: orderTableName},
+		{Name: models.OrderColumns.Item, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 255), Nullable: false, Source: orderTableName},
+		{Name: models.OrderColumns.Amount, Type: simsql.Int32, Nullable: false, Source: orderTableName},
+		{Name: models.OrderColumns.CreatedAt, Type: simsql.Datetime, Nullable: false, Source: orderTableName},
+	}), db.GetForeignKeyCollection())
+	db.AddTable(orderTableName, orderTable)
+
+	userTenantTableName := models.TableNames.UserTenant
+	userTenantTable := memory.NewTable(userTenantTableName, simsql.NewPrimaryKeySchema(simsql.Schema{
+		{Name: models.UserTenantColumns.UserID, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 26), Nullable: false, Source: userTenantTableName, PrimaryKey: true},
+		{Name: models.UserTenantColumns.TenantID, Type: simsql.MustCreateStringWithDefaults(sqltypes.VarChar, 64), Nullable: false, Source: userTenantTableName},
+	}), db.GetForeignKeyCollection())
+	db.AddTable(userTenantTableName, userTenantTable)
+
+	return table
+}
+
+// withoutTimestamps returns copies of users without CreatedAt and UpdatedAt,
+// e.g. to compare them with rows which do not store timestamps.
+func withoutTimestamps(users ...*User) []*User {
+	return lo.Map(users, func(u *User, _ int) *User {
+		c := *u
+		c.CreatedAt = time.Time{}
+		c.UpdatedAt = time.Time{}
+		return &c
+	})
+}
+
+func TestUserString(t *testing.T) {
+	tests := []struct {
+		title    string
+		user     *User
+		expected string
+	}{
+		{
+			"all fields",
+			&User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20), Email: "mike@example.com"},
+			"&{ID:0123456789ABCDEFGHJKMNPQRS Name:Mike Age:20 Email:mike@example.com}",
+		},
+		{
+			"no age",
+			&User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike"},
+			"&{ID:0123456789ABCDEFGHJKMNPQRS Name:Mike Age:<nil> Email:}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.expected, fmt.Sprintf("%+v", tt.user))
+		})
+	}
 }
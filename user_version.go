@@ -0,0 +1,102 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/volatiletech/sqlboiler/v4/boil"
+
+	"github.com/syuparn/gosqltests/models"
+)
+
+// ErrStaleObject is returned by Update if the user has been updated since its version was read.
+var ErrStaleObject = errors.New("user was updated by another operation")
+
+// version of users when they are registered
+const initialUserVersion = 1
+
+// VersionedUser is a user with the version of its row, which each Update increments.
+type VersionedUser struct {
+	*User
+	Version int64
+}
+
+// GetVersioned returns the user with its version, which Update requires. Soft-deleted users are not found as Get.
+func (r *userRepository) GetVersioned(ctx context.Context, id string) (*VersionedUser, error) {
+	if _, err := ParseUserID(id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.readTimeout)
+	defer cancel()
+
+	var user *models.User
+	err := r.retryRead(ctx, OperationGet, func() error {
+		var err error
+		user, err = models.Users(r.scoped(userByID(id))...).One(ctx, r.db)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user was not found (id: %s): %w", id, err)
+		}
+
+		return nil, fmt.Errorf("failed to get user (id: %s): %w", id, err)
+	}
+
+	return &VersionedUser{User: fromUserModel(user), Version: user.Version}, nil
+}
+
+// Update overwrites the name, the age and the email of the user if its version has not changed since it was read,
+// and increments the version and sets UpdatedAt of user. It returns ErrStaleObject if another Update has changed the version,
+// so that concurrent updates never overwrite each other silently. Read the user again and retry then.
+// NOTE: Upsert increments the version of the user it updates without checking it
+func (r *userRepository) Update(ctx context.Context, user *VersionedUser) error {
+	if _, err := ParseUserID(user.ID); err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, r.writeTimeout)
+	defer cancel()
+
+	now := r.now()
+	err := r.retryWrite(ctx, OperationUpdate, func() error {
+		return r.audited(ctx, user.ID, nil, func(ctx context.Context, exec boil.ContextExecutor) error {
+			c := toUserModel(user.User)
+			n, err := models.Users(r.scoped(
+				userByID(user.ID),
+				models.UserWhere.Version.EQ(user.Version),
+			)...).UpdateAll(ctx, exec, models.M{
+				models.UserColumns.Name:      c.Name,
+				models.UserColumns.Age:       c.Age,
+				models.UserColumns.Email:     c.Email,
+				models.UserColumns.Version:   user.Version + 1,
+				models.UserColumns.UpdatedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update user (id: %s): %w", user.ID, wrapEmailTakenError(wrapStorageError(err), user.User))
+			}
+			if n > 0 {
+				return nil
+			}
+
+			exists, err := models.Users(r.scoped(userByID(user.ID))...).Exists(ctx, exec)
+			if err != nil {
+				return fmt.Errorf("failed to check user (id: %s): %w", user.ID, err)
+			}
+			if !exists {
+				return fmt.Errorf("user was not found (id: %s): %w", user.ID, sql.ErrNoRows)
+			}
+			return fmt.Errorf("%w (id: %s, version: %d)", ErrStaleObject, user.ID, user.Version)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	user.Version++
+	user.UpdatedAt = now
+	return nil
+}
@@ -0,0 +1,252 @@
+package gosqltests
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/syuparn/gosqltests/testport"
+)
+
+// assertOptimisticLocking updates users by their versions on the migrated database of db.
+func assertOptimisticLocking(ctx context.Context, t *testing.T, db *sql.DB) {
+	clock := newFakeClock()
+	r := NewUserRepository(db, WithClock(clock))
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	deleted := &User{ID: "1123456789ABCDEFGHJKMNPQRS", Name: "Mary", Age: lo.ToPtr(30)}
+	require.NoError(t, r.Register(ctx, mike))
+	require.NoError(t, r.Register(ctx, deleted))
+	require.NoError(t, r.Delete(ctx, deleted))
+
+	t.Run("registered user has the initial version", func(t *testing.T) {
+		actual, err := r.GetVersioned(ctx, mike.ID)
+
+		require.NoError(t, err)
+		require.Equal(t, &VersionedUser{User: mike, Version: 1}, actual)
+	})
+
+	t.Run("update increments the version", func(t *testing.T) {
+		user, err := r.GetVersioned(ctx, mike.ID)
+		require.NoError(t, err)
+		user.Name = "Michael"
+		user.Age = nil
+		user.Email = "michael@example.com"
+		clock.Advance(time.Hour)
+
+		// run
+		err = r.Update(ctx, user)
+
+		// assert
+		require.NoError(t, err)
+		require.Equal(t, int64(2), user.Version)
+		actual, err := r.GetVersioned(ctx, mike.ID)
+		require.NoError(t, err)
+		expected := &User{ID: mike.ID, Name: "Michael", Email: "michael@example.com", CreatedAt: mike.CreatedAt, UpdatedAt: clock.Now()}
+		require.Equal(t, &VersionedUser{User: expected, Version: 2}, actual)
+	})
+
+	t.Run("update of a stale version", func(t *testing.T) {
+		stale := &VersionedUser{User: &User{ID: mike.ID, Name: "Mike", Age: lo.ToPtr(20)}, Version: 1}
+
+		// run
+		err := r.Update(ctx, stale)
+
+		// assert
+		require.ErrorIs(t, err, ErrStaleObject)
+		require.EqualError(t, err, "user was updated by another operation (id: 0123456789ABCDEFGHJKMNPQRS, version: 1)")
+		require.Equal(t, int64(1), stale.Version)
+		actual, err := r.Get(ctx, mike.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Michael", actual.Name)
+	})
+
+	t.Run("upsert increments the version", func(t *testing.T) {
+		before, err := r.GetVersioned(ctx, mike.ID)
+		require.NoError(t, err)
+
+		// run
+		err = r.Upsert(ctx, &User{ID: mike.ID, Name: "Michael", Age: lo.ToPtr(21)})
+
+		// assert
+		require.NoError(t, err)
+		actual, err := r.GetVersioned(ctx, mike.ID)
+		require.NoError(t, err)
+		require.Equal(t, before.Version+1, actual.Version)
+		require.ErrorIs(t, r.Update(ctx, before), ErrStaleObject)
+	})
+
+	t.Run("update of a soft-deleted user", func(t *testing.T) {
+		err := r.Update(ctx, &VersionedUser{User: deleted, Version: 1})
+
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("update of an unknown user", func(t *testing.T) {
+		err := r.Update(ctx, &VersionedUser{User: &User{ID: "2123456789ABCDEFGHJKMNPQRS", Name: "Bob"}, Version: 1})
+
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+// test using go-mysql-server
+func TestOptimisticLockingWithGoMySQLServer(t *testing.T) {
+	port, err := testport.Reserve()
+	require.NoError(t, err)
+
+	// simulator
+	prepareSimulator(t, port)
+	db, err := NewStrictClient(port)
+	require.NoError(t, err)
+	defer closeTestClient(t, db)
+
+	assertOptimisticLocking(context.Background(), t, db)
+}
+
+// test using testcontainers
+func TestOptimisticLockingWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	assertOptimisticLocking(ctx, t, db)
+}
+
+// test using testcontainers
+func TestConcurrentUpdateWithTestContainers(t *testing.T) {
+	ctx := context.Background()
+	db := prepareContainer(ctx, t)
+
+	r := NewUserRepository(db)
+	mike := &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}
+	require.NoError(t, r.Register(ctx, mike))
+
+	// both updates read the same version before either of them writes
+	const n = 2
+	users := make([]*VersionedUser, n)
+	for i := range users {
+		u, err := r.GetVersioned(ctx, mike.ID)
+		require.NoError(t, err)
+		u.Age = lo.ToPtr(21 + i)
+		users[i] = u
+	}
+
+	// run
+	conflicts := reportLockConflicts(t, db)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, n)
+	for i := range users {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = conflicts.do(ctx, func(ctx context.Context) error {
+				return r.Update(ctx, users[i])
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// assert
+	succeeded := lo.Filter(lo.Range(n), func(i int, _ int) bool { return errs[i] == nil })
+	require.Len(t, succeeded, 1, "errors: %v", errs)
+	winner := users[succeeded[0]]
+	for i, err := range errs {
+		if i != succeeded[0] {
+			require.ErrorIs(t, err, ErrStaleObject)
+		}
+	}
+
+	actual, err := r.GetVersioned(ctx, mike.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), actual.Version)
+	require.Equal(t, winner.Age, actual.Age)
+}
+
+// test using go-sqlmock
+func TestUpdateWithSQLMock(t *testing.T) {
+	mike := &VersionedUser{User: &User{ID: "0123456789ABCDEFGHJKMNPQRS", Name: "Mike", Age: lo.ToPtr(20)}, Version: 3}
+	update := regexp.QuoteMeta("UPDATE `user` SET `age` = ?, `email` = ?, `name` = ?, `updated_at` = ?, `version` = ? " +
+		"WHERE (`user`.`id` = ?) AND (`user`.`version` = ?) AND (`user`.`deleted_at` is null)")
+	clock := newFakeClock()
+	exists := regexp.QuoteMeta("SELECT COUNT(*) FROM `user` WHERE (`user`.`id` = ?) AND (`user`.`deleted_at` is null) LIMIT 1;")
+
+	tests := []struct {
+		title           string
+		mock            func(sqlmock.Sqlmock)
+		expectedVersion int64
+		expectedErr     error
+		expectedMsg     string
+	}{
+		{
+			"update the user of the version",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).
+					WithArgs(20, nil, "Mike", clock.Now(), 4, mike.ID, 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			4,
+			nil,
+			"",
+		},
+		{
+			"version has been changed",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).
+					WithArgs(20, nil, "Mike", clock.Now(), 4, mike.ID, 3).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(exists).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			},
+			3,
+			ErrStaleObject,
+			"user was updated by another operation (id: 0123456789ABCDEFGHJKMNPQRS, version: 3)",
+		},
+		{
+			"user does not exist",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).
+					WithArgs(20, nil, "Mike", clock.Now(), 4, mike.ID, 3).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(exists).
+					WithArgs(mike.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			},
+			3,
+			sql.ErrNoRows,
+			"user was not found (id: 0123456789ABCDEFGHJKMNPQRS): sql: no rows in result set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			// mock
+			db, mock := prepareMockDB(t)
+			tt.mock(mock)
+			user := &VersionedUser{User: mike.User, Version: mike.Version}
+
+			// run
+			err := NewUserRepository(db, WithClock(clock)).Update(context.TODO(), user)
+
+			// assert
+			require.NoError(t, mock.ExpectationsWereMet())
+			require.Equal(t, tt.expectedVersion, user.Version)
+			if tt.expectedMsg == "" {
+				require.NoError(t, err)
+				require.Equal(t, clock.Now(), user.UpdatedAt)
+				return
+			}
+			require.EqualError(t, err, tt.expectedMsg)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}